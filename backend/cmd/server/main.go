@@ -2,18 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"github.com/shafikshaon/url_shortener/config"
 	"github.com/shafikshaon/url_shortener/internal/analytics"
 	"github.com/shafikshaon/url_shortener/internal/api"
 	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/billing"
+	"github.com/shafikshaon/url_shortener/internal/cache"
 	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
 	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/mail"
+	"github.com/shafikshaon/url_shortener/internal/metadata"
 	"github.com/shafikshaon/url_shortener/internal/middleware"
+	"github.com/shafikshaon/url_shortener/internal/ratelimit"
 	"github.com/shafikshaon/url_shortener/internal/service"
+	"github.com/shafikshaon/url_shortener/internal/urlcheck"
+	"github.com/shafikshaon/url_shortener/internal/webhook"
 )
 
 func main() {
@@ -28,7 +44,6 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
-	defer gormDB.Close()
 
 	logger.Infof(ctx, "✓ Database connected successfully")
 
@@ -42,16 +57,119 @@ func main() {
 	userRepo := database.NewUserRepository(gormDB.DB)
 	linkRepo := database.NewLinkRepository(gormDB.DB)
 	analyticsRepo := database.NewAnalyticsRepository(gormDB.DB)
+	webhookRepo := database.NewWebhookRepository(gormDB.DB)
+	domainRepo := database.NewDomainRepository(gormDB.DB)
+	orgRepo := database.NewOrganizationRepository(gormDB.DB)
+	apiKeyRepo := database.NewAPIKeyRepository(gormDB.DB)
+	twoFactorRepo := database.NewTwoFactorRepository(gormDB.DB)
+	sessionRepo := database.NewSessionRepository(gormDB.DB)
+	emailTokenRepo := database.NewEmailTokenRepository(gormDB.DB)
+	billingRepo := database.NewBillingRepository(gormDB.DB)
+
+	// Initialize the redirect cache and rate limit counters, falling back to
+	// in-process stores when Redis is disabled or unreachable
+	var cacheStore cache.Store
+	var rateLimitStore ratelimit.Store
+	var redisClient *redis.Client
+	if cfg.Redis.Enabled {
+		redisClient, err = cache.NewRedisClient(cfg)
+		if err != nil {
+			logger.Warnf(ctx, "Redis unavailable, using in-process cache and rate limits: %v", err)
+		} else {
+			cacheStore = cache.NewRedisStore(redisClient, "urlshortener:")
+			rateLimitStore = ratelimit.NewRedisStore(redisClient, "urlshortener:")
+			logger.Infof(ctx, "✓ Redis connected successfully")
+		}
+	}
+	if cacheStore == nil {
+		cacheStore = cache.NewLRUStore(cfg.Cache.LocalSize)
+		rateLimitStore = ratelimit.NewMemoryStore()
+	}
+	linkCache := cache.NewLinkCache(
+		cacheStore,
+		time.Duration(cfg.Cache.LinkTTLSeconds)*time.Second,
+		time.Duration(cfg.Cache.NegativeTTLSeconds)*time.Second,
+	)
+
+	// Initialize event bus and webhook delivery
+	eventBus := events.NewBus()
+	webhookDispatcher := webhook.NewDispatcher(webhookRepo, cfg)
+	eventBus.Subscribe(webhookDispatcher.HandleEvent)
+	webhookDispatcher.Start()
+
+	// Initialize click ingestion and monthly click quotas
+	clickQuota := analytics.NewClickQuota(analyticsRepo, orgRepo, eventBus, cfg)
+	clickPipeline := analytics.NewPipeline(analyticsRepo, clickQuota, eventBus, cfg)
+	clickPipeline.Start()
+
+	// Initialize the mailer
+	mailer, err := mail.NewMailer(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+	logger.Infof(ctx, "✓ Mailer initialized (%s)", cfg.Email.Driver)
+
+	// Initialize the Stripe client; billing stays off without a secret key
+	var billingClient billing.Client
+	if cfg.Stripe.SecretKey != "" {
+		billingClient = billing.NewStripeClient(cfg.Stripe.SecretKey)
+		logger.Infof(ctx, "✓ Stripe billing enabled")
+	}
+
+	// Load the blocklists link destinations are checked against
+	var blocklists urlcheck.Blocklists
+	if len(cfg.URLSafety.BlocklistFiles) > 0 {
+		fileBlocklist, err := urlcheck.NewFileBlocklist(cfg.URLSafety.BlocklistFiles)
+		if err != nil {
+			log.Fatalf("Failed to load URL blocklists: %v", err)
+		}
+		blocklists = append(blocklists, fileBlocklist)
+		logger.Infof(ctx, "✓ URL blocklists loaded (%d domains)", fileBlocklist.Len())
+	}
 
 	// Initialize services
 	jwtService := auth.NewJWTService(cfg)
-	linkService := service.NewLinkService(linkRepo, userRepo)
-	tracker := analytics.NewTracker(analyticsRepo)
+	sessionService := service.NewSessionService(sessionRepo, userRepo, jwtService, cfg)
+	authorizer := authz.NewAuthorizer(orgRepo)
+	linkService := service.NewLinkService(linkRepo, orgRepo, domainRepo, authorizer, linkCache, eventBus, rateLimitStore, blocklists, cfg)
+	orgService := service.NewOrganizationService(orgRepo, userRepo, authorizer, linkCache)
+	domainService := service.NewDomainService(domainRepo, authorizer, nil, linkCache, cfg)
+	tracker := analytics.NewTracker(analyticsRepo, clickPipeline, clickQuota)
+	webhookService := service.NewWebhookService(webhookRepo, authorizer)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo)
+	rateLimiter := ratelimit.NewLimiter(rateLimitStore, cfg.RateLimit.PerMinute)
+	twoFactorService := service.NewTwoFactorService(twoFactorRepo, userRepo, rateLimitStore, cfg)
+	billingService := service.NewBillingService(billingRepo, userRepo, orgRepo, authorizer, billingClient, eventBus, cfg)
+	accountEmailService := service.NewAccountEmailService(emailTokenRepo, userRepo, sessionService, mailer, rateLimitStore, cfg)
+
+	// Enforce link limits after tier changes
+	linkLimitService := service.NewLinkLimitService(linkRepo, orgRepo, linkCache, eventBus, cfg)
+	eventBus.Subscribe(linkLimitService.HandleEvent)
+	linkLimitService.Start()
+
+	// Announce links as their expiry passes
+	linkExpiryService := service.NewLinkExpiryService(linkRepo, eventBus, cfg)
+	linkExpiryService.Start()
+
+	// Fill in link titles and favicons from their destinations
+	linkMetadataService := service.NewLinkMetadataService(linkRepo, metadata.NewFetcher(cfg), cfg)
+	eventBus.Subscribe(linkMetadataService.HandleEvent)
+	linkMetadataService.Start()
 
 	// Initialize handlers
-	authHandler := api.NewAuthHandler(userRepo, jwtService)
-	linkHandler := api.NewLinkHandler(linkService, tracker, cfg)
-	analyticsHandler := api.NewAnalyticsHandler(tracker)
+	authHandler := api.NewAuthHandler(userRepo, jwtService, sessionService, accountEmailService)
+	linkHandler := api.NewLinkHandler(linkService, domainService, tracker, cfg)
+	analyticsHandler := api.NewAnalyticsHandler(tracker, authorizer)
+	webhookHandler := api.NewWebhookHandler(webhookService)
+	domainHandler := api.NewDomainHandler(domainService)
+	orgHandler := api.NewOrganizationHandler(orgService)
+	apiUsageHandler := api.NewAPIUsageHandler(rateLimiter, userRepo)
+	apiKeyHandler := api.NewAPIKeyHandler(apiKeyService)
+	twoFactorHandler := api.NewTwoFactorHandler(twoFactorService, sessionService, userRepo, jwtService)
+	sessionHandler := api.NewSessionHandler(sessionService)
+	accountEmailHandler := api.NewAccountEmailHandler(accountEmailService, userRepo)
+	billingHandler := api.NewBillingHandler(billingService)
+	appLinksHandler := api.NewAppLinksHandler(cfg)
 
 	// Setup Gin router
 	if cfg.Env == "production" {
@@ -68,18 +186,26 @@ func main() {
 	corsConfig := cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:5173"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key", authz.OrganizationHeader},
+		ExposeHeaders:    []string{"Content-Length", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 	}
 	router.Use(cors.New(corsConfig))
 
 	// Public routes
 	router.GET("/:code", linkHandler.Redirect)
+	router.POST("/:code", linkHandler.Unlock)
+
+	// App link verification files for deep links
+	router.GET("/.well-known/apple-app-site-association", appLinksHandler.AppleAppSiteAssociation)
+	router.GET("/.well-known/assetlinks.json", appLinksHandler.AssetLinks)
+
+	// Stripe webhooks, authenticated by their signature
+	router.POST("/webhooks/stripe", billingHandler.StripeWebhook)
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
+		c.JSON(200, gin.H{"status": "ok", "click_pipeline": clickPipeline.Stats()})
 	})
 
 	// API v1 routes
@@ -90,40 +216,98 @@ func main() {
 		{
 			authRoutes.POST("/signup", authHandler.Signup)
 			authRoutes.POST("/login", authHandler.Login)
+			authRoutes.POST("/login/2fa", twoFactorHandler.CompleteLogin)
+			authRoutes.POST("/refresh", sessionHandler.Refresh)
+			authRoutes.POST("/verify-email", accountEmailHandler.VerifyEmail)
+			authRoutes.POST("/forgot-password", accountEmailHandler.ForgotPassword)
+			authRoutes.POST("/reset-password", accountEmailHandler.ResetPassword)
 		}
 
 		// Protected routes (require JWT)
 		protected := v1.Group("")
-		protected.Use(auth.AuthMiddleware(jwtService))
+		protected.Use(auth.AuthMiddleware(jwtService, sessionRepo))
 		{
 			// User routes
 			protected.GET("/profile", authHandler.GetProfile)
 			protected.PUT("/profile", authHandler.UpdateProfile)
-			protected.POST("/api-key", authHandler.GenerateAPIKey)
+			protected.GET("/api-usage", apiUsageHandler.GetUsage)
+
+			// API key routes
+			protected.GET("/api-keys", apiKeyHandler.ListAPIKeys)
+			protected.POST("/api-keys", apiKeyHandler.CreateAPIKey)
+			protected.DELETE("/api-keys/:id", apiKeyHandler.RevokeAPIKey)
 			protected.POST("/auth/change-password", authHandler.ChangePassword)
+			protected.POST("/auth/logout", sessionHandler.Logout)
+			protected.POST("/auth/verify-email/resend", accountEmailHandler.ResendVerification)
+
+			// Session routes
+			protected.GET("/sessions", sessionHandler.ListSessions)
+			protected.DELETE("/sessions", sessionHandler.RevokeOtherSessions)
+			protected.DELETE("/sessions/:id", sessionHandler.RevokeSession)
 
-			// Link routes
-			protected.POST("/links", linkHandler.CreateLink)
-			protected.GET("/links", linkHandler.ListLinks)
-			protected.GET("/links/:id", linkHandler.GetLink)
-			protected.PATCH("/links/:id", linkHandler.UpdateLink)
-			protected.DELETE("/links/:id", linkHandler.DeleteLink)
-			protected.GET("/links/:id/stats", linkHandler.GetLinkStats)
+			// Billing routes
+			protected.GET("/billing", billingHandler.GetBilling)
+			protected.GET("/billing/invoices", billingHandler.ListInvoices)
+			protected.POST("/billing/checkout", billingHandler.CreateCheckout)
+			protected.POST("/billing/portal", billingHandler.CreatePortal)
 
-			// Tag routes
-			protected.GET("/tags", linkHandler.GetUserTags)
+			// Two-factor authentication routes
+			protected.GET("/auth/2fa", twoFactorHandler.GetStatus)
+			protected.POST("/auth/2fa/setup", twoFactorHandler.BeginSetup)
+			protected.POST("/auth/2fa/confirm", twoFactorHandler.ConfirmSetup)
+			protected.POST("/auth/2fa/disable", twoFactorHandler.Disable)
+			protected.POST("/auth/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
 
-			// Analytics routes
-			protected.GET("/analytics", analyticsHandler.GetUserAnalytics)
+			// Organization routes
+			protected.GET("/organizations", orgHandler.ListOrganizations)
+			protected.POST("/organizations", orgHandler.CreateOrganization)
+			protected.GET("/organizations/:id", orgHandler.GetOrganization)
+			protected.PATCH("/organizations/:id", orgHandler.UpdateOrganization)
+			protected.DELETE("/organizations/:id", orgHandler.DeleteOrganization)
+			protected.PUT("/organizations/:id/billing", billingHandler.SetOrganizationCoverage)
+			protected.GET("/organizations/:id/members", orgHandler.ListMembers)
+			protected.PATCH("/organizations/:id/members/:userId", orgHandler.UpdateMember)
+			protected.DELETE("/organizations/:id/members/:userId", orgHandler.RemoveMember)
+			protected.GET("/organizations/:id/invitations", orgHandler.ListInvitations)
+			protected.POST("/organizations/:id/invitations", orgHandler.CreateInvitation)
+			protected.DELETE("/organizations/:id/invitations/:invitationId", orgHandler.RevokeInvitation)
+			protected.POST("/invitations/accept", orgHandler.AcceptInvitation)
 		}
 
-		// API Key protected routes (for external API access)
-		apiKeyProtected := v1.Group("/api")
-		apiKeyProtected.Use(auth.APIKeyMiddleware(userRepo))
+		// Link, tag and analytics routes, shared by both auth modes
+		orgRoutes := api.OrganizationRoutes(linkHandler, analyticsHandler)
+
+		// Organization-scoped routes (X-Organization-ID, defaulting to the personal organization)
+		orgScoped := protected.Group("")
+		orgScoped.Use(authz.OrganizationMiddleware(authorizer, userRepo))
+		api.RegisterRoutes(orgScoped, orgRoutes)
 		{
-			apiKeyProtected.POST("/links", linkHandler.CreateLink)
-			apiKeyProtected.GET("/links", linkHandler.ListLinks)
+			// Webhook routes
+			orgScoped.GET("/webhooks", webhookHandler.ListWebhooks)
+			orgScoped.POST("/webhooks", webhookHandler.CreateWebhook)
+			orgScoped.GET("/webhooks/stats", webhookHandler.GetStats)
+			orgScoped.GET("/webhooks/:id", webhookHandler.GetWebhook)
+			orgScoped.PATCH("/webhooks/:id", webhookHandler.UpdateWebhook)
+			orgScoped.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+			orgScoped.POST("/webhooks/:id/rotate-secret", webhookHandler.RotateSecret)
+			orgScoped.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+
+			// Custom domain routes
+			orgScoped.GET("/domains", domainHandler.ListDomains)
+			orgScoped.POST("/domains", domainHandler.CreateDomain)
+			orgScoped.GET("/domains/:id", domainHandler.GetDomain)
+			orgScoped.POST("/domains/:id/verify", domainHandler.VerifyDomain)
+			orgScoped.DELETE("/domains/:id", domainHandler.DeleteDomain)
 		}
+
+		// API Key protected routes (for external API access)
+		apiKeyProtected := v1.Group("/api")
+		apiKeyProtected.Use(
+			auth.APIKeyMiddleware(apiKeyRepo, userRepo),
+			ratelimit.Middleware(rateLimiter),
+			authz.OrganizationMiddleware(authorizer, userRepo),
+		)
+		api.RegisterRoutes(apiKeyProtected, orgRoutes)
 	}
 
 	// Start server
@@ -132,8 +316,80 @@ func main() {
 	logger.Infof(ctx, "📊 Environment: %s", cfg.Env)
 	logger.Infof(ctx, "🔗 Base URL: %s", cfg.Server.BaseURL)
 
-	if err := router.Run(addr); err != nil {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           router,
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeoutSeconds) * time.Second,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeoutSeconds) * time.Second,
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeoutSeconds) * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	// Wait for a termination signal or a fatal server error
+	signalCtx, stopSignals := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	select {
+	case <-signalCtx.Done():
+		logger.Infof(ctx, "Shutdown signal received, draining (deadline: %ds)", cfg.Server.ShutdownTimeoutSeconds)
+	case err := <-serverErr:
 		logger.Errorf(ctx, "Failed to start server: %+v", err)
 		log.Fatalf("Failed to start server: %v", err)
 	}
+	stopSignals()
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Server.ShutdownTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	// Stop accepting connections and let in-flight requests finish, then
+	// drain click ingestion, link limit checks, expiry scans and metadata
+	// fetches before webhooks so their events are still queued, and close
+	// the database and Redis last
+	clean := true
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Errorf(ctx, "HTTP server shutdown: %+v", err)
+		clean = false
+	}
+	if err := linkLimitService.Stop(shutdownCtx); err != nil {
+		logger.Errorf(ctx, "Link limit checks shutdown: %+v", err)
+		clean = false
+	}
+	if err := linkExpiryService.Stop(shutdownCtx); err != nil {
+		logger.Errorf(ctx, "Link expiry scans shutdown: %+v", err)
+		clean = false
+	}
+	if err := linkMetadataService.Stop(shutdownCtx); err != nil {
+		logger.Errorf(ctx, "Link metadata fetching shutdown: %+v", err)
+		clean = false
+	}
+	if err := clickPipeline.Stop(shutdownCtx); err != nil {
+		logger.Errorf(ctx, "Click pipeline shutdown: %+v", err)
+		clean = false
+	}
+	if err := webhookDispatcher.Stop(shutdownCtx); err != nil {
+		logger.Errorf(ctx, "Webhook dispatcher shutdown: %+v", err)
+		clean = false
+	}
+	if err := gormDB.Close(); err != nil {
+		logger.Errorf(ctx, "Database close: %+v", err)
+		clean = false
+	}
+	if redisClient != nil {
+		if err := redisClient.Close(); err != nil {
+			logger.Errorf(ctx, "Redis close: %+v", err)
+			clean = false
+		}
+	}
+
+	if !clean {
+		os.Exit(1)
+	}
+	logger.Infof(ctx, "✓ Server stopped gracefully")
 }
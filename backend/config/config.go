@@ -4,24 +4,49 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	Stripe   StripeConfig
-	Email    EmailConfig
-	Env      string
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	JWT       JWTConfig
+	Stripe    StripeConfig
+	Email     EmailConfig
+	Webhook   WebhookConfig
+	Cache     CacheConfig
+	Clicks    ClickPipelineConfig
+	Links     LinkLimitConfig
+	Unlock    LinkUnlockConfig
+	Expiry    LinkExpiryConfig
+	Metadata  MetadataConfig
+	URLSafety URLSafetyConfig
+	Geo       GeoConfig
+	AppLinks  AppLinksConfig
+	RateLimit RateLimitConfig
+	TwoFactor TwoFactorConfig
+	Env       string
 }
 
+// ServerConfig holds the HTTP server settings. BaseURL is the public
+// address of short links; AppURL is the frontend address used in emailed
+// links and billing redirects.
 type ServerConfig struct {
 	Port    string
 	Host    string
 	BaseURL string
+	AppURL  string
+
+	ReadTimeoutSeconds       int
+	ReadHeaderTimeoutSeconds int
+	WriteTimeoutSeconds      int
+	IdleTimeoutSeconds       int
+	// ShutdownTimeoutSeconds bounds the whole shutdown: draining requests,
+	// background workers and closing the database
+	ShutdownTimeoutSeconds int
 }
 
 type DatabaseConfig struct {
@@ -41,19 +66,136 @@ type RedisConfig struct {
 	Enabled  bool
 }
 
+// JWTConfig sets token lifetimes. Access tokens are short-lived; refresh
+// tokens keep a session alive and rotate on every use.
 type JWTConfig struct {
-	Secret      string
-	ExpiryHours int
+	Secret           string
+	AccessTTLMinutes int
+	RefreshTTLHours  int
 }
 
+// StripeConfig enables subscription billing. ProPriceID and
+// BusinessPriceID are the Stripe prices sold for each paid tier. Billing is
+// disabled when SecretKey is empty.
 type StripeConfig struct {
-	SecretKey     string
-	WebhookSecret string
+	SecretKey       string
+	WebhookSecret   string
+	ProPriceID      string
+	BusinessPriceID string
 }
 
+// EmailConfig selects how mail is sent. Driver is "sendgrid", "file" to
+// write messages under FileDir, or "memory" for tests.
 type EmailConfig struct {
+	Driver         string
 	SendGridAPIKey string
 	FromEmail      string
+	FromName       string
+	FileDir        string
+
+	VerificationTTLHours    int
+	PasswordResetTTLMinutes int
+}
+
+type WebhookConfig struct {
+	Workers          int
+	MaxAttempts      int
+	TimeoutSeconds   int
+	RetryBaseSeconds int
+}
+
+type CacheConfig struct {
+	LinkTTLSeconds     int
+	NegativeTTLSeconds int
+	LocalSize          int
+}
+
+// ClickPipelineConfig tunes click ingestion. OverflowPolicy is "drop" to
+// discard clicks when the queue is full, or "block" to make redirects wait
+// for room. QuotaPolicy is "aggregate" to keep only daily counts for clicks
+// beyond the monthly quota, or "none" to stop recording them.
+type ClickPipelineConfig struct {
+	QueueSize            int
+	Workers              int
+	BatchSize            int
+	FlushIntervalSeconds int
+	OverflowPolicy       string
+	QuotaPolicy          string
+}
+
+// LinkLimitConfig controls what happens when an organization has more links
+// than its tier allows after a downgrade. The newest links over the limit
+// are archived once GraceDays have passed; CheckIntervalMinutes is how often
+// organizations in a grace period are re-checked.
+type LinkLimitConfig struct {
+	GraceDays            int
+	CheckIntervalMinutes int
+}
+
+// LinkUnlockConfig controls password-protected links. An unlock lasts
+// TTLMinutes; each IP may try MaxAttempts passwords per link within
+// WindowMinutes.
+type LinkUnlockConfig struct {
+	TTLMinutes    int
+	MaxAttempts   int
+	WindowMinutes int
+}
+
+// LinkExpiryConfig controls the scanner that publishes link.expired events.
+// Links past their expiry are picked up every ScanIntervalSeconds.
+type LinkExpiryConfig struct {
+	ScanIntervalSeconds int
+}
+
+// MetadataConfig controls fetching destination pages for link titles,
+// descriptions and favicons. Each fetch gets TimeoutSeconds and reads at
+// most MaxBytes of the page; links still waiting are picked up again every
+// ScanIntervalSeconds.
+type MetadataConfig struct {
+	Workers             int
+	TimeoutSeconds      int
+	MaxBytes            int
+	ScanIntervalSeconds int
+}
+
+// URLSafetyConfig lists what link destinations are checked against.
+// BlocklistFiles are local domain lists; links to a listed domain are
+// flagged and don't redirect. ShortenerDomains are URL shorteners, in
+// addition to the well-known ones, that links may not point at.
+type URLSafetyConfig struct {
+	BlocklistFiles   []string
+	ShortenerDomains []string
+}
+
+// GeoConfig tells where visitors' countries come from. CountryHeader names a
+// request header holding an ISO country code, set by a CDN or proxy such as
+// Cloudflare's CF-IPCountry; it is only trustworthy behind that proxy.
+type GeoConfig struct {
+	CountryHeader string
+}
+
+// AppLinksConfig lists the mobile apps allowed to open short links directly.
+// IOSAppIDs ("TEAMID.bundle.id") are published in
+// apple-app-site-association; AndroidPackage and the SHA-256 fingerprints
+// of its signing certificates in assetlinks.json.
+type AppLinksConfig struct {
+	IOSAppIDs           []string
+	AndroidPackage      string
+	AndroidFingerprints []string
+}
+
+// RateLimitConfig holds the API burst limit; daily quotas come from the
+// subscription tier
+type RateLimitConfig struct {
+	PerMinute int
+}
+
+// TwoFactorConfig controls two-factor login. Issuer is the account label
+// shown in authenticator apps.
+type TwoFactorConfig struct {
+	Issuer              string
+	ChallengeTTLSeconds int
+	MaxAttempts         int
 }
 
 func Load() *Config {
@@ -64,13 +206,59 @@ func Load() *Config {
 
 	redisDB, _ := strconv.Atoi(getEnv("REDIS_DB", "0"))
 	redisEnabled, _ := strconv.ParseBool(getEnv("REDIS_ENABLED", "false"))
-	jwtExpiry, _ := strconv.Atoi(getEnv("JWT_EXPIRY_HOURS", "168"))
+	jwtAccessTTL, _ := strconv.Atoi(getEnv("JWT_ACCESS_TTL_MINUTES", "15"))
+	jwtRefreshTTL, _ := strconv.Atoi(getEnv("JWT_REFRESH_TTL_HOURS", "720"))
+	readTimeout, _ := strconv.Atoi(getEnv("SERVER_READ_TIMEOUT_SECONDS", "15"))
+	readHeaderTimeout, _ := strconv.Atoi(getEnv("SERVER_READ_HEADER_TIMEOUT_SECONDS", "5"))
+	writeTimeout, _ := strconv.Atoi(getEnv("SERVER_WRITE_TIMEOUT_SECONDS", "30"))
+	idleTimeout, _ := strconv.Atoi(getEnv("SERVER_IDLE_TIMEOUT_SECONDS", "120"))
+	shutdownTimeout, _ := strconv.Atoi(getEnv("SERVER_SHUTDOWN_TIMEOUT_SECONDS", "30"))
+	webhookWorkers, _ := strconv.Atoi(getEnv("WEBHOOK_WORKERS", "4"))
+	webhookMaxAttempts, _ := strconv.Atoi(getEnv("WEBHOOK_MAX_ATTEMPTS", "5"))
+	webhookTimeout, _ := strconv.Atoi(getEnv("WEBHOOK_TIMEOUT_SECONDS", "10"))
+	webhookRetryBase, _ := strconv.Atoi(getEnv("WEBHOOK_RETRY_BASE_SECONDS", "30"))
+	cacheLinkTTL, _ := strconv.Atoi(getEnv("CACHE_LINK_TTL_SECONDS", "300"))
+	cacheNegativeTTL, _ := strconv.Atoi(getEnv("CACHE_NEGATIVE_TTL_SECONDS", "30"))
+	cacheLocalSize, _ := strconv.Atoi(getEnv("CACHE_LOCAL_SIZE", "10000"))
+	clickQueueSize, _ := strconv.Atoi(getEnv("CLICK_QUEUE_SIZE", "10000"))
+	clickWorkers, _ := strconv.Atoi(getEnv("CLICK_WORKERS", "2"))
+	clickBatchSize, _ := strconv.Atoi(getEnv("CLICK_BATCH_SIZE", "500"))
+	clickFlushInterval, _ := strconv.Atoi(getEnv("CLICK_FLUSH_INTERVAL_SECONDS", "1"))
+	linkGraceDays, _ := strconv.Atoi(getEnv("LINK_LIMIT_GRACE_DAYS", "14"))
+	linkCheckInterval, _ := strconv.Atoi(getEnv("LINK_LIMIT_CHECK_INTERVAL_MINUTES", "60"))
+	unlockTTL, _ := strconv.Atoi(getEnv("LINK_UNLOCK_TTL_MINUTES", "60"))
+	unlockMaxAttempts, _ := strconv.Atoi(getEnv("LINK_UNLOCK_MAX_ATTEMPTS", "5"))
+	unlockWindow, _ := strconv.Atoi(getEnv("LINK_UNLOCK_WINDOW_MINUTES", "15"))
+	expiryScanInterval, _ := strconv.Atoi(getEnv("LINK_EXPIRY_SCAN_INTERVAL_SECONDS", "60"))
+	metadataWorkers, _ := strconv.Atoi(getEnv("METADATA_FETCH_WORKERS", "2"))
+	metadataTimeout, _ := strconv.Atoi(getEnv("METADATA_FETCH_TIMEOUT_SECONDS", "5"))
+	metadataMaxBytes, _ := strconv.Atoi(getEnv("METADATA_FETCH_MAX_BYTES", "524288"))
+	metadataScanInterval, _ := strconv.Atoi(getEnv("METADATA_SCAN_INTERVAL_SECONDS", "60"))
+	rateLimitPerMinute, _ := strconv.Atoi(getEnv("RATE_LIMIT_PER_MINUTE", "100"))
+	emailVerificationTTL, _ := strconv.Atoi(getEnv("EMAIL_VERIFICATION_TTL_HOURS", "48"))
+	passwordResetTTL, _ := strconv.Atoi(getEnv("PASSWORD_RESET_TTL_MINUTES", "60"))
+	twoFactorChallengeTTL, _ := strconv.Atoi(getEnv("TWO_FACTOR_CHALLENGE_TTL_SECONDS", "300"))
+	twoFactorMaxAttempts, _ := strconv.Atoi(getEnv("TWO_FACTOR_MAX_ATTEMPTS", "5"))
+
+	// Send through SendGrid when it is configured, otherwise write to files
+	sendGridAPIKey := getEnv("SENDGRID_API_KEY", "")
+	defaultEmailDriver := "file"
+	if sendGridAPIKey != "" {
+		defaultEmailDriver = "sendgrid"
+	}
 
 	return &Config{
 		Server: ServerConfig{
 			Port:    getEnv("SERVER_PORT", "8080"),
 			Host:    getEnv("SERVER_HOST", "localhost"),
 			BaseURL: getEnv("BASE_URL", "http://localhost:8080"),
+			AppURL:  getEnv("APP_URL", "http://localhost:5173"),
+
+			ReadTimeoutSeconds:       readTimeout,
+			ReadHeaderTimeoutSeconds: readHeaderTimeout,
+			WriteTimeoutSeconds:      writeTimeout,
+			IdleTimeoutSeconds:       idleTimeout,
+			ShutdownTimeoutSeconds:   shutdownTimeout,
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -88,16 +276,82 @@ func Load() *Config {
 			Enabled:  redisEnabled,
 		},
 		JWT: JWTConfig{
-			Secret:      getEnv("JWT_SECRET", "change_this_secret"),
-			ExpiryHours: jwtExpiry,
+			Secret:           getEnv("JWT_SECRET", "change_this_secret"),
+			AccessTTLMinutes: jwtAccessTTL,
+			RefreshTTLHours:  jwtRefreshTTL,
 		},
 		Stripe: StripeConfig{
-			SecretKey:     getEnv("STRIPE_SECRET_KEY", ""),
-			WebhookSecret: getEnv("STRIPE_WEBHOOK_SECRET", ""),
+			SecretKey:       getEnv("STRIPE_SECRET_KEY", ""),
+			WebhookSecret:   getEnv("STRIPE_WEBHOOK_SECRET", ""),
+			ProPriceID:      getEnv("STRIPE_PRICE_PRO", ""),
+			BusinessPriceID: getEnv("STRIPE_PRICE_BUSINESS", ""),
 		},
 		Email: EmailConfig{
-			SendGridAPIKey: getEnv("SENDGRID_API_KEY", ""),
+			Driver:         getEnv("EMAIL_DRIVER", defaultEmailDriver),
+			SendGridAPIKey: sendGridAPIKey,
 			FromEmail:      getEnv("FROM_EMAIL", "noreply@yourdomain.com"),
+			FromName:       getEnv("FROM_NAME", "URL Shortener"),
+			FileDir:        getEnv("EMAIL_FILE_DIR", "tmp/mail"),
+
+			VerificationTTLHours:    emailVerificationTTL,
+			PasswordResetTTLMinutes: passwordResetTTL,
+		},
+		Webhook: WebhookConfig{
+			Workers:          webhookWorkers,
+			MaxAttempts:      webhookMaxAttempts,
+			TimeoutSeconds:   webhookTimeout,
+			RetryBaseSeconds: webhookRetryBase,
+		},
+		Cache: CacheConfig{
+			LinkTTLSeconds:     cacheLinkTTL,
+			NegativeTTLSeconds: cacheNegativeTTL,
+			LocalSize:          cacheLocalSize,
+		},
+		Clicks: ClickPipelineConfig{
+			QueueSize:            clickQueueSize,
+			Workers:              clickWorkers,
+			BatchSize:            clickBatchSize,
+			FlushIntervalSeconds: clickFlushInterval,
+			OverflowPolicy:       getEnv("CLICK_OVERFLOW_POLICY", "drop"),
+			QuotaPolicy:          getEnv("CLICK_QUOTA_POLICY", "aggregate"),
+		},
+		Links: LinkLimitConfig{
+			GraceDays:            linkGraceDays,
+			CheckIntervalMinutes: linkCheckInterval,
+		},
+		Unlock: LinkUnlockConfig{
+			TTLMinutes:    unlockTTL,
+			MaxAttempts:   unlockMaxAttempts,
+			WindowMinutes: unlockWindow,
+		},
+		Expiry: LinkExpiryConfig{
+			ScanIntervalSeconds: expiryScanInterval,
+		},
+		Metadata: MetadataConfig{
+			Workers:             metadataWorkers,
+			TimeoutSeconds:      metadataTimeout,
+			MaxBytes:            metadataMaxBytes,
+			ScanIntervalSeconds: metadataScanInterval,
+		},
+		URLSafety: URLSafetyConfig{
+			BlocklistFiles:   getEnvList("URL_BLOCKLIST_FILES"),
+			ShortenerDomains: getEnvList("URL_SHORTENER_DOMAINS"),
+		},
+		Geo: GeoConfig{
+			CountryHeader: getEnv("GEO_COUNTRY_HEADER", ""),
+		},
+		AppLinks: AppLinksConfig{
+			IOSAppIDs:           getEnvList("IOS_APP_IDS"),
+			AndroidPackage:      getEnv("ANDROID_APP_PACKAGE", ""),
+			AndroidFingerprints: getEnvList("ANDROID_APP_FINGERPRINTS"),
+		},
+		RateLimit: RateLimitConfig{
+			PerMinute: rateLimitPerMinute,
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer:              getEnv("TWO_FACTOR_ISSUER", "URL Shortener"),
+			ChallengeTTLSeconds: twoFactorChallengeTTL,
+			MaxAttempts:         twoFactorMaxAttempts,
 		},
 		Env: getEnv("ENV", "development"),
 	}
@@ -110,6 +364,17 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvList splits a comma-separated variable, skipping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func (c *Config) GetDSN() string {
 	return "host=" + c.Database.Host +
 		" port=" + c.Database.Port +
@@ -0,0 +1,62 @@
+package config
+
+import "testing"
+
+func TestLoadServerLifecycle(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want ServerConfig
+	}{
+		{
+			name: "defaults",
+			want: ServerConfig{
+				ReadTimeoutSeconds:       15,
+				ReadHeaderTimeoutSeconds: 5,
+				WriteTimeoutSeconds:      30,
+				IdleTimeoutSeconds:       120,
+				ShutdownTimeoutSeconds:   30,
+			},
+		},
+		{
+			name: "overridden",
+			env: map[string]string{
+				"SERVER_READ_TIMEOUT_SECONDS":        "10",
+				"SERVER_READ_HEADER_TIMEOUT_SECONDS": "2",
+				"SERVER_WRITE_TIMEOUT_SECONDS":       "20",
+				"SERVER_IDLE_TIMEOUT_SECONDS":        "60",
+				"SERVER_SHUTDOWN_TIMEOUT_SECONDS":    "45",
+			},
+			want: ServerConfig{
+				ReadTimeoutSeconds:       10,
+				ReadHeaderTimeoutSeconds: 2,
+				WriteTimeoutSeconds:      20,
+				IdleTimeoutSeconds:       60,
+				ShutdownTimeoutSeconds:   45,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{
+				"SERVER_READ_TIMEOUT_SECONDS",
+				"SERVER_READ_HEADER_TIMEOUT_SECONDS",
+				"SERVER_WRITE_TIMEOUT_SECONDS",
+				"SERVER_IDLE_TIMEOUT_SECONDS",
+				"SERVER_SHUTDOWN_TIMEOUT_SECONDS",
+			} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			got := Load().Server
+			if got.ReadTimeoutSeconds != tt.want.ReadTimeoutSeconds ||
+				got.ReadHeaderTimeoutSeconds != tt.want.ReadHeaderTimeoutSeconds ||
+				got.WriteTimeoutSeconds != tt.want.WriteTimeoutSeconds ||
+				got.IdleTimeoutSeconds != tt.want.IdleTimeoutSeconds ||
+				got.ShutdownTimeoutSeconds != tt.want.ShutdownTimeoutSeconds {
+				t.Errorf("Load().Server timeouts = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// Overflow policies applied when the click queue is full
+const (
+	OverflowDrop  = "drop"
+	OverflowBlock = "block"
+)
+
+var (
+	// ErrQueueFull is returned when a click is dropped because the queue is full
+	ErrQueueFull = errors.New("click queue is full")
+	// ErrPipelineStopped is returned when a click arrives after shutdown began
+	ErrPipelineStopped = errors.New("click pipeline is stopped")
+)
+
+// clickJob is a click waiting to be persisted, along with the link fields
+// needed to publish its event. Clicks beyond the monthly quota are only
+// counted in the daily aggregates, without a detail row or event. Bot
+// clicks are only kept as detail rows.
+type clickJob struct {
+	click     *models.Click
+	userID    int64
+	orgID     int64
+	shortCode string
+	detail    bool
+}
+
+// dailyKey identifies a row of link_analytics_daily
+type dailyKey struct {
+	linkID int64
+	date   time.Time
+}
+
+// PipelineStats reports queue pressure and throughput since startup
+type PipelineStats struct {
+	QueueDepth     int    `json:"queue_depth"`
+	QueueCapacity  int    `json:"queue_capacity"`
+	OverflowPolicy string `json:"overflow_policy"`
+	Enqueued       int64  `json:"enqueued"`
+	Dropped        int64  `json:"dropped"`
+	Blocked        int64  `json:"blocked"`
+	Persisted      int64  `json:"persisted"`
+	Failed         int64  `json:"failed"`
+	OverQuota      int64  `json:"over_quota"`
+	PendingDaily   int    `json:"pending_daily_counters"`
+}
+
+// Pipeline ingests clicks through a bounded queue. Workers insert clicks in
+// batches and daily counters are aggregated in memory and flushed
+// periodically, so a burst of redirects costs a handful of statements
+// instead of two per click.
+type Pipeline struct {
+	analyticsRepo *database.AnalyticsRepository
+	quota         *ClickQuota
+	eventBus      *events.Bus
+	workers       int
+	batchSize     int
+	flushInterval time.Duration
+	policy        string
+
+	queue chan clickJob
+	stop  chan struct{}
+	wg    sync.WaitGroup
+
+	mu       sync.RWMutex
+	stopped  bool
+	stopOnce sync.Once
+
+	dailyMu sync.Mutex
+	daily   map[dailyKey]int
+	flushed chan struct{}
+
+	enqueued  atomic.Int64
+	dropped   atomic.Int64
+	blocked   atomic.Int64
+	persisted atomic.Int64
+	failed    atomic.Int64
+	overQuota atomic.Int64
+}
+
+func NewPipeline(analyticsRepo *database.AnalyticsRepository, quota *ClickQuota, eventBus *events.Bus, cfg *config.Config) *Pipeline {
+	queueSize := cfg.Clicks.QueueSize
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	workers := cfg.Clicks.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	batchSize := cfg.Clicks.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	flushInterval := time.Duration(cfg.Clicks.FlushIntervalSeconds) * time.Second
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+	policy := cfg.Clicks.OverflowPolicy
+	if policy != OverflowBlock {
+		policy = OverflowDrop
+	}
+
+	return &Pipeline{
+		analyticsRepo: analyticsRepo,
+		quota:         quota,
+		eventBus:      eventBus,
+		workers:       workers,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		policy:        policy,
+		queue:         make(chan clickJob, queueSize),
+		stop:          make(chan struct{}),
+		daily:         make(map[dailyKey]int),
+		flushed:       make(chan struct{}),
+	}
+}
+
+// Start launches the batch workers and the daily counter flusher
+func (p *Pipeline) Start() {
+	logger.Infof(context.Background(), "Starting click pipeline with %d workers (queue: %d, batch: %d, policy: %s)",
+		p.workers, cap(p.queue), p.batchSize, p.policy)
+
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	go p.dailyFlusher()
+}
+
+// Stop stops accepting clicks, waits for queued clicks to be written and
+// flushes the daily counters, giving up when ctx expires
+func (p *Pipeline) Stop(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		// Release producers blocked on a full queue before closing it
+		close(p.stop)
+		p.mu.Lock()
+		p.stopped = true
+		close(p.queue)
+		p.mu.Unlock()
+	})
+
+	select {
+	case <-p.flushed:
+		stats := p.Stats()
+		logger.Infof(ctx, "Click pipeline stopped (persisted: %d, dropped: %d, failed: %d)",
+			stats.Persisted, stats.Dropped, stats.Failed)
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("click pipeline did not drain in time (%d clicks queued): %w", len(p.queue), ctx.Err())
+	}
+}
+
+// Enqueue counts a click towards the monthly quota, unless it's a bot's,
+// and queues it for persistence. When the queue is full the click is
+// dropped or, under the block policy, waits until there is room, ctx is
+// done or the pipeline stops.
+func (p *Pipeline) Enqueue(ctx context.Context, link *models.Link, click *models.Click) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		p.dropped.Add(1)
+		return ErrPipelineStopped
+	}
+
+	overQuota := !click.IsBot && p.quota.Record(link, click.ClickedAt)
+	if overQuota {
+		p.overQuota.Add(1)
+		if p.quota.Policy() == QuotaRecordNothing {
+			return nil
+		}
+	}
+
+	job := clickJob{click: click, userID: link.UserID, orgID: link.OrganizationID, shortCode: link.ShortCode, detail: !overQuota}
+	select {
+	case p.queue <- job:
+		p.enqueued.Add(1)
+		return nil
+	default:
+	}
+
+	if p.policy != OverflowBlock {
+		p.dropped.Add(1)
+		return ErrQueueFull
+	}
+
+	p.blocked.Add(1)
+	select {
+	case p.queue <- job:
+		p.enqueued.Add(1)
+		return nil
+	case <-ctx.Done():
+		p.dropped.Add(1)
+		return ctx.Err()
+	case <-p.stop:
+		p.dropped.Add(1)
+		return ErrPipelineStopped
+	}
+}
+
+// Stats returns a snapshot of the pipeline counters
+func (p *Pipeline) Stats() PipelineStats {
+	p.dailyMu.Lock()
+	pendingDaily := len(p.daily)
+	p.dailyMu.Unlock()
+
+	return PipelineStats{
+		QueueDepth:     len(p.queue),
+		QueueCapacity:  cap(p.queue),
+		OverflowPolicy: p.policy,
+		Enqueued:       p.enqueued.Load(),
+		Dropped:        p.dropped.Load(),
+		Blocked:        p.blocked.Load(),
+		Persisted:      p.persisted.Load(),
+		Failed:         p.failed.Load(),
+		OverQuota:      p.overQuota.Load(),
+		PendingDaily:   pendingDaily,
+	}
+}
+
+func (p *Pipeline) worker() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]clickJob, 0, p.batchSize)
+	for {
+		select {
+		case job, ok := <-p.queue:
+			if !ok {
+				p.writeBatch(batch)
+				return
+			}
+			batch = append(batch, job)
+			if len(batch) >= p.batchSize {
+				p.writeBatch(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			p.writeBatch(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// writeBatch inserts the detail rows of a batch of clicks, then counts the
+// human ones towards the daily totals and publishes their events
+func (p *Pipeline) writeBatch(batch []clickJob) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	clicks := make([]*models.Click, 0, len(batch))
+	for _, job := range batch {
+		if job.detail {
+			clicks = append(clicks, job.click)
+		}
+	}
+
+	if err := p.analyticsRepo.CreateClicks(clicks); err != nil {
+		p.failed.Add(int64(len(batch)))
+		logger.Errorf(ctx, "Failed to persist batch of %d clicks: %+v", len(batch), err)
+		return
+	}
+	p.persisted.Add(int64(len(batch)))
+
+	p.dailyMu.Lock()
+	for _, job := range batch {
+		if job.click.IsBot {
+			continue
+		}
+		year, month, day := job.click.ClickedAt.UTC().Date()
+		p.daily[dailyKey{linkID: job.click.LinkID, date: time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}]++
+	}
+	p.dailyMu.Unlock()
+
+	for _, job := range batch {
+		if !job.detail || job.click.IsBot {
+			continue
+		}
+		p.eventBus.Publish(ctx, events.Event{
+			Type:           events.LinkClicked,
+			UserID:         job.userID,
+			OrganizationID: job.orgID,
+			Data: map[string]interface{}{
+				"link_id":        job.click.LinkID,
+				"short_code":     job.shortCode,
+				"clicked_at":     job.click.ClickedAt,
+				"referer":        job.click.Referer,
+				"device_type":    job.click.DeviceType,
+				"country_code":   job.click.CountryCode,
+				"redirect_rule":  job.click.RedirectRule,
+				"variant":        job.click.Variant,
+				"utm_campaign":   job.click.UTMCampaign,
+				"deep_link_path": job.click.DeepLinkPath,
+			},
+		})
+	}
+}
+
+// dailyFlusher writes the aggregated daily counters and monthly quota usage
+// every flush interval, and a final time once the workers have drained the
+// queue
+func (p *Pipeline) dailyFlusher() {
+	defer close(p.flushed)
+
+	workersDone := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(workersDone)
+	}()
+
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.flushDaily()
+			p.quota.Flush()
+		case <-workersDone:
+			p.flushDaily()
+			p.quota.Flush()
+			return
+		}
+	}
+}
+
+func (p *Pipeline) flushDaily() {
+	p.dailyMu.Lock()
+	pending := p.daily
+	p.daily = make(map[dailyKey]int)
+	p.dailyMu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	counts := make([]models.AnalyticsDaily, 0, len(pending))
+	for key, count := range pending {
+		counts = append(counts, models.AnalyticsDaily{LinkID: key.linkID, Date: key.date, ClickCount: count})
+	}
+
+	if err := p.analyticsRepo.IncrementDailyAnalytics(counts); err != nil {
+		logger.Errorf(context.Background(), "Failed to flush %d daily counters, retrying next interval: %+v", len(counts), err)
+
+		// Merge the counts back so they are retried on the next flush
+		p.dailyMu.Lock()
+		for key, count := range pending {
+			p.daily[key] += count
+		}
+		p.dailyMu.Unlock()
+	}
+}
@@ -0,0 +1,177 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("creating mock database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	return db, mock
+}
+
+// newTestPipeline returns an unstarted pipeline and the events it publishes
+func newTestPipeline(t *testing.T, clicks config.ClickPipelineConfig) (*Pipeline, sqlmock.Sqlmock, *[]events.Event) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	cfg := &config.Config{Clicks: clicks}
+
+	bus := events.NewBus()
+	published := recordEvents(bus)
+
+	analyticsRepo := database.NewAnalyticsRepository(db)
+	quota := NewClickQuota(analyticsRepo, database.NewOrganizationRepository(db), bus, cfg)
+	return NewPipeline(analyticsRepo, quota, bus, cfg), mock, published
+}
+
+// recordEvents collects the events published on bus
+func recordEvents(bus *events.Bus) *[]events.Event {
+	var mu sync.Mutex
+	published := []events.Event{}
+	bus.Subscribe(func(_ context.Context, event events.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		published = append(published, event)
+	})
+	return &published
+}
+
+func TestPipelineOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		wantErr     error
+		wantBlocked int64
+	}{
+		{name: "drop", policy: OverflowDrop, wantErr: ErrQueueFull},
+		{name: "block", policy: OverflowBlock, wantErr: context.DeadlineExceeded, wantBlocked: 1},
+		{name: "unknown policies drop", policy: "spill", wantErr: ErrQueueFull},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _, _ := newTestPipeline(t, config.ClickPipelineConfig{QueueSize: 1, OverflowPolicy: tt.policy})
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			// Bot clicks skip the quota, so nothing touches the database
+			link := &models.Link{ID: 10, OrganizationID: 3}
+			if err := p.Enqueue(ctx, link, &models.Click{LinkID: 10, IsBot: true}); err != nil {
+				t.Fatalf("first Enqueue() error = %v", err)
+			}
+			if err := p.Enqueue(ctx, link, &models.Click{LinkID: 10, IsBot: true}); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("second Enqueue() error = %v, want %v", err, tt.wantErr)
+			}
+
+			stats := p.Stats()
+			if stats.Enqueued != 1 || stats.Dropped != 1 || stats.Blocked != tt.wantBlocked {
+				t.Errorf("stats = enqueued %d, dropped %d, blocked %d; want 1, 1, %d",
+					stats.Enqueued, stats.Dropped, stats.Blocked, tt.wantBlocked)
+			}
+		})
+	}
+}
+
+func TestPipelineStopDrainsQueuedClicks(t *testing.T) {
+	p, mock, published := newTestPipeline(t, config.ClickPipelineConfig{QueueSize: 10, BatchSize: 10, FlushIntervalSeconds: 60})
+	link := &models.Link{ID: 10, UserID: 7, OrganizationID: 3, ShortCode: "sale"}
+	clickedAt := time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	ctx := context.Background()
+	for _, isBot := range []bool{false, false, true} {
+		if err := p.Enqueue(ctx, link, &models.Click{LinkID: 10, ClickedAt: clickedAt, IsBot: isBot}); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+
+	// One insert for the batch, one upsert for the day's human clicks, then
+	// the monthly usage
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "clicks"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "link_analytics_daily" .* ON CONFLICT`).
+		WithArgs(10, time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC), 2, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`INSERT INTO click_usage_monthly`).
+		WithArgs(3, "2026-03-01", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"organization_id", "month", "click_count", "notified_percent"}).
+			AddRow(3, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), 2, 0))
+	mock.ExpectQuery(`SELECT \* FROM "organizations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "subscription_tier"}).AddRow(3, 7, models.TierFree))
+
+	p.Start()
+	stopCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := p.Stop(stopCtx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := p.Stats(); stats.Persisted != 3 || stats.Failed != 0 || stats.PendingDaily != 0 {
+		t.Errorf("stats = persisted %d, failed %d, pending daily %d; want 3, 0, 0", stats.Persisted, stats.Failed, stats.PendingDaily)
+	}
+	// Bot clicks are stored without an event
+	if len(*published) != 2 {
+		t.Errorf("published %d events, want 2", len(*published))
+	}
+
+	if err := p.Enqueue(ctx, link, &models.Click{LinkID: 10}); !errors.Is(err, ErrPipelineStopped) {
+		t.Errorf("Enqueue() after Stop error = %v, want %v", err, ErrPipelineStopped)
+	}
+}
+
+func TestPipelineStopGivesUpAtTheDeadline(t *testing.T) {
+	p, mock, _ := newTestPipeline(t, config.ClickPipelineConfig{QueueSize: 10, BatchSize: 10, FlushIntervalSeconds: 60})
+	link := &models.Link{ID: 10, OrganizationID: 3}
+	if err := p.Enqueue(context.Background(), link, &models.Click{LinkID: 10, IsBot: true}); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "clicks"`).
+		WillDelayFor(200 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	p.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stop() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// Stopping again waits for the same drain to finish
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatalf("second Stop() error = %v", err)
+	}
+	if stats := p.Stats(); stats.Persisted != 1 {
+		t.Errorf("persisted %d clicks, want 1", stats.Persisted)
+	}
+}
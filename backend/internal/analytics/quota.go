@@ -0,0 +1,259 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// Quota policies applied to clicks beyond an organization's monthly limit.
+// Redirects keep working under both; only what is recorded changes.
+const (
+	// QuotaRecordAggregates keeps daily and monthly counts but stops storing
+	// per-click detail and publishing click events
+	QuotaRecordAggregates = "aggregate"
+	// QuotaRecordNothing stops recording clicks beyond the monthly counter
+	QuotaRecordNothing = "none"
+)
+
+// quotaThresholds are the usage percentages announced on the event bus,
+// highest first
+var quotaThresholds = []int{100, 80}
+
+// limitRefreshInterval is how often cached plan limits are reloaded so
+// upgrades and downgrades take effect without a restart
+const limitRefreshInterval = 5 * time.Minute
+
+type usageKey struct {
+	orgID int64
+	month time.Time
+}
+
+// orgUsage is the in-memory view of an organization's monthly usage.
+// recorded is the last total read back from the database and pending the
+// clicks seen locally since then. Until the plan limit has been loaded the
+// organization is treated as within quota.
+type orgUsage struct {
+	limit    int
+	ownerID  int64
+	loaded   bool
+	loadedAt time.Time
+	recorded int64
+	pending  int64
+}
+
+func (u *orgUsage) overQuota() bool {
+	return u.loaded && u.recorded+u.pending > int64(u.limit)
+}
+
+// ClickQuota tracks monthly click usage per organization. Clicks are
+// counted in memory on the redirect path and reconciled with the database
+// on each pipeline flush, so enforcement never waits on a query; counts
+// from other instances become visible after their next flush.
+type ClickQuota struct {
+	analyticsRepo *database.AnalyticsRepository
+	orgRepo       *database.OrganizationRepository
+	eventBus      *events.Bus
+	policy        string
+
+	mu    sync.Mutex
+	usage map[usageKey]*orgUsage
+}
+
+func NewClickQuota(analyticsRepo *database.AnalyticsRepository, orgRepo *database.OrganizationRepository, eventBus *events.Bus, cfg *config.Config) *ClickQuota {
+	policy := cfg.Clicks.QuotaPolicy
+	if policy != QuotaRecordNothing {
+		policy = QuotaRecordAggregates
+	}
+
+	return &ClickQuota{
+		analyticsRepo: analyticsRepo,
+		orgRepo:       orgRepo,
+		eventBus:      eventBus,
+		policy:        policy,
+		usage:         make(map[usageKey]*orgUsage),
+	}
+}
+
+func startOfMonth(t time.Time) time.Time {
+	year, month, _ := t.UTC().Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Record counts a click against the link's organization and reports
+// whether it falls beyond the monthly quota
+func (q *ClickQuota) Record(link *models.Link, clickedAt time.Time) bool {
+	key := usageKey{orgID: link.OrganizationID, month: startOfMonth(clickedAt)}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	usage, ok := q.usage[key]
+	if !ok {
+		usage = &orgUsage{}
+		q.usage[key] = usage
+	}
+	usage.pending++
+	return usage.overQuota()
+}
+
+// Policy returns the policy applied to clicks beyond the quota
+func (q *ClickQuota) Policy() string {
+	return q.policy
+}
+
+// Flush writes locally counted clicks to the database, refreshes plan
+// limits and announces newly crossed thresholds
+func (q *ClickQuota) Flush() {
+	ctx := context.Background()
+	now := time.Now()
+	currentMonth := startOfMonth(now)
+
+	type flushItem struct {
+		key         usageKey
+		pending     int64
+		needsLimits bool
+	}
+
+	q.mu.Lock()
+	items := make([]flushItem, 0, len(q.usage))
+	for key, usage := range q.usage {
+		needsLimits := now.Sub(usage.loadedAt) >= limitRefreshInterval
+		if usage.pending == 0 && !needsLimits {
+			if key.month.Before(currentMonth) {
+				delete(q.usage, key)
+			}
+			continue
+		}
+		items = append(items, flushItem{key: key, pending: usage.pending, needsLimits: needsLimits})
+	}
+	q.mu.Unlock()
+
+	for _, item := range items {
+		row, err := q.analyticsRepo.IncrementClickUsage(item.key.orgID, item.key.month, item.pending)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to flush click usage for organization %d, retrying next interval: %+v", item.key.orgID, err)
+			continue
+		}
+
+		var org *models.Organization
+		if item.needsLimits {
+			org, err = q.orgRepo.GetByID(item.key.orgID)
+			if err != nil {
+				logger.Warnf(ctx, "Failed to load click quota for organization %d: %+v", item.key.orgID, err)
+			}
+		}
+
+		q.mu.Lock()
+		usage, ok := q.usage[item.key]
+		if !ok {
+			usage = &orgUsage{}
+			q.usage[item.key] = usage
+		}
+		usage.pending -= item.pending
+		usage.recorded = row.ClickCount
+		if item.needsLimits {
+			// Failed loads are retried on the next refresh, not every flush
+			usage.loadedAt = now
+		}
+		if org != nil {
+			usage.limit = org.GetClickLimit()
+			usage.ownerID = org.OwnerID
+			usage.loaded = true
+		}
+		limit, ownerID, loaded := usage.limit, usage.ownerID, usage.loaded
+		q.mu.Unlock()
+
+		if loaded {
+			q.notify(ctx, item.key, row, limit, ownerID)
+		}
+	}
+}
+
+// notify publishes a quota event the first time usage crosses a threshold
+// in the month
+func (q *ClickQuota) notify(ctx context.Context, key usageKey, row *models.ClickUsage, limit int, ownerID int64) {
+	if limit <= 0 {
+		return
+	}
+
+	percent := int(row.ClickCount * 100 / int64(limit))
+	for _, threshold := range quotaThresholds {
+		if percent < threshold {
+			continue
+		}
+		if row.NotifiedPercent >= threshold {
+			return
+		}
+
+		marked, err := q.analyticsRepo.MarkClickUsageNotified(key.orgID, key.month, threshold)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to record click quota notification for organization %d: %+v", key.orgID, err)
+			return
+		}
+		if !marked {
+			return
+		}
+
+		eventType := events.ClickQuotaWarning
+		if threshold >= 100 {
+			eventType = events.ClickQuotaExceeded
+		}
+		logger.Infof(ctx, "Organization %d reached %d%% of its monthly click quota", key.orgID, threshold)
+		q.eventBus.Publish(ctx, events.Event{
+			Type:           eventType,
+			UserID:         ownerID,
+			OrganizationID: key.orgID,
+			Data: map[string]interface{}{
+				"organization_id": key.orgID,
+				"month":           key.month.Format("2006-01"),
+				"clicks":          row.ClickCount,
+				"limit":           limit,
+				"threshold":       threshold,
+				"policy":          q.policy,
+			},
+		})
+		return
+	}
+}
+
+// Status returns the organization's click usage for the current month,
+// including clicks counted locally but not yet flushed
+func (q *ClickQuota) Status(orgID int64) (*models.ClickQuotaStatus, error) {
+	month := startOfMonth(time.Now())
+
+	org, err := q.orgRepo.GetByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	row, err := q.analyticsRepo.GetClickUsage(orgID, month)
+	if err != nil {
+		return nil, err
+	}
+
+	used := row.ClickCount
+	q.mu.Lock()
+	if usage, ok := q.usage[usageKey{orgID: orgID, month: month}]; ok {
+		used += usage.pending
+	}
+	q.mu.Unlock()
+
+	limit := org.GetClickLimit()
+	status := &models.ClickQuotaStatus{
+		Used:        used,
+		Limit:       limit,
+		OverQuota:   used > int64(limit),
+		PeriodStart: month,
+		PeriodEnd:   month.AddDate(0, 1, 0),
+	}
+	if limit > 0 {
+		status.Percent = int(used * 100 / int64(limit))
+	}
+	return status, nil
+}
@@ -0,0 +1,150 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+func newTestQuota(t *testing.T, policy string) (*ClickQuota, sqlmock.Sqlmock, *[]events.Event) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	bus := events.NewBus()
+	published := recordEvents(bus)
+	cfg := &config.Config{Clicks: config.ClickPipelineConfig{QuotaPolicy: policy}}
+	return NewClickQuota(database.NewAnalyticsRepository(db), database.NewOrganizationRepository(db), bus, cfg), mock, published
+}
+
+// expectUsageFlush expects organization 3's pending clicks to be added to
+// the month's usage, which the database reports as clicks and notified,
+// followed by a reload of its free plan limits
+func expectUsageFlush(mock sqlmock.Sqlmock, pending, clicks int64, notified int) {
+	month := startOfMonth(time.Now())
+	mock.ExpectQuery(`INSERT INTO click_usage_monthly`).
+		WithArgs(3, month.Format("2006-01-02"), pending).
+		WillReturnRows(sqlmock.NewRows([]string{"organization_id", "month", "click_count", "notified_percent"}).
+			AddRow(3, month, clicks, notified))
+	mock.ExpectQuery(`SELECT \* FROM "organizations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "subscription_tier"}).AddRow(3, 7, models.TierFree))
+}
+
+func TestClickQuotaFlushAnnouncesThresholds(t *testing.T) {
+	tests := []struct {
+		name      string
+		clicks    int64
+		notified  int
+		mark      int  // threshold marked as notified, 0 for none
+		marked    bool // whether this instance won the mark
+		wantEvent string
+	}{
+		{name: "below 80%", clicks: 799},
+		{name: "crosses 80%", clicks: 800, mark: 80, marked: true, wantEvent: events.ClickQuotaWarning},
+		{name: "80% already announced", clicks: 900, notified: 80},
+		{name: "crosses 100%", clicks: 1001, notified: 80, mark: 100, marked: true, wantEvent: events.ClickQuotaExceeded},
+		{name: "jumps past both", clicks: 1200, mark: 100, marked: true, wantEvent: events.ClickQuotaExceeded},
+		{name: "announced by another instance", clicks: 850, mark: 80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, mock, published := newTestQuota(t, QuotaRecordAggregates)
+			expectUsageFlush(mock, 1, tt.clicks, tt.notified)
+			if tt.mark > 0 {
+				affected := int64(0)
+				if tt.marked {
+					affected = 1
+				}
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "click_usage_monthly" SET "notified_percent"=\$1 WHERE organization_id = \$2 AND month = \$3 AND notified_percent < \$4`).
+					WithArgs(tt.mark, 3, startOfMonth(time.Now()).Format("2006-01-02"), tt.mark).
+					WillReturnResult(sqlmock.NewResult(0, affected))
+				mock.ExpectCommit()
+			}
+
+			q.Record(&models.Link{ID: 10, OrganizationID: 3}, time.Now())
+			q.Flush()
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantEvent == "" {
+				if len(*published) != 0 {
+					t.Errorf("published %v, want no events", *published)
+				}
+				return
+			}
+			if len(*published) != 1 {
+				t.Fatalf("published %d events, want 1", len(*published))
+			}
+			event := (*published)[0]
+			if event.Type != tt.wantEvent || event.UserID != 7 || event.OrganizationID != 3 ||
+				event.Data.(map[string]interface{})["threshold"] != tt.mark {
+				t.Errorf("published %+v, want %s at %d%% for owner 7", event, tt.wantEvent, tt.mark)
+			}
+		})
+	}
+}
+
+func TestClickQuotaRecordEnforcesLoadedLimits(t *testing.T) {
+	q, mock, _ := newTestQuota(t, QuotaRecordAggregates)
+	link := &models.Link{ID: 10, OrganizationID: 3}
+
+	// Clicks are within quota until the plan limit has been loaded
+	if q.Record(link, time.Now()) {
+		t.Fatal("Record() before limits load = over quota, want within")
+	}
+
+	expectUsageFlush(mock, 1, 1000, 100)
+	q.Flush()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !q.Record(link, time.Now()) {
+		t.Error("Record() past the free plan's 1000 clicks = within quota, want over")
+	}
+	// Another organization's usage is counted separately
+	if q.Record(&models.Link{ID: 11, OrganizationID: 4}, time.Now()) {
+		t.Error("Record() for another organization = over quota, want within")
+	}
+}
+
+func TestPipelineQuotaPolicies(t *testing.T) {
+	tests := []struct {
+		policy       string
+		wantEnqueued int64
+	}{
+		{policy: QuotaRecordAggregates, wantEnqueued: 1},
+		{policy: QuotaRecordNothing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			p, mock, _ := newTestPipeline(t, config.ClickPipelineConfig{QueueSize: 10, QuotaPolicy: tt.policy})
+			expectUsageFlush(mock, 1, 1000, 100)
+			link := &models.Link{ID: 10, OrganizationID: 3}
+			p.quota.Record(link, time.Now())
+			p.quota.Flush()
+
+			if err := p.Enqueue(context.Background(), link, &models.Click{LinkID: 10, ClickedAt: time.Now()}); err != nil {
+				t.Fatalf("Enqueue() error = %v", err)
+			}
+			stats := p.Stats()
+			if stats.OverQuota != 1 || stats.Enqueued != tt.wantEnqueued {
+				t.Fatalf("stats = over quota %d, enqueued %d; want 1, %d", stats.OverQuota, stats.Enqueued, tt.wantEnqueued)
+			}
+			// Over-quota clicks only count towards the aggregates
+			if tt.wantEnqueued > 0 {
+				if job := <-p.queue; job.detail {
+					t.Error("over-quota click queued with its detail row")
+				}
+			}
+		})
+	}
+}
@@ -3,26 +3,39 @@ package analytics
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/shafikshaon/url_shortener/internal/database"
 	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/routing"
 )
 
 type Tracker struct {
 	analyticsRepo *database.AnalyticsRepository
+	pipeline      *Pipeline
+	quota         *ClickQuota
 }
 
-func NewTracker(analyticsRepo *database.AnalyticsRepository) *Tracker {
+func NewTracker(analyticsRepo *database.AnalyticsRepository, pipeline *Pipeline, quota *ClickQuota) *Tracker {
 	return &Tracker{
 		analyticsRepo: analyticsRepo,
+		pipeline:      pipeline,
+		quota:         quota,
 	}
 }
 
-// TrackClick records a click event
-func (t *Tracker) TrackClick(linkID int64, ipAddress string, r *http.Request) error {
+// TrackClick queues a click for batched persistence, along with the
+// visitor's country, the redirect rule or A/B variant that routed it, the
+// destination's UTM campaign and the path taken through the deep link page.
+// Clicks from link preview crawlers are flagged as bot traffic. It returns
+// without waiting for the database, except under the block overflow policy
+// when the queue is full.
+func (t *Tracker) TrackClick(link *models.Link, ipAddress string, r *http.Request, route *routing.Route) error {
 	click := &models.Click{
-		LinkID:    linkID,
+		LinkID:    link.ID,
+		ClickedAt: time.Now().UTC(),
 		IPAddress: ipAddress,
+		IsBot:     route.Visitor.Crawler,
 	}
 
 	// Extract referer
@@ -33,31 +46,51 @@ func (t *Tracker) TrackClick(linkID int64, ipAddress string, r *http.Request) er
 	// Extract user agent
 	if userAgent := r.Header.Get("User-Agent"); userAgent != "" {
 		click.UserAgent = &userAgent
-		deviceType := detectDeviceType(userAgent)
+		deviceType := route.Visitor.DeviceType
 		click.DeviceType = &deviceType
 	}
 
-	// Extract country (would require GeoIP database in production)
-	// For now, we'll leave it as nil
-	// countryCode := getCountryFromIP(ipAddress)
-	// click.CountryCode = &countryCode
-
-	return t.analyticsRepo.CreateClick(click)
-}
-
-// detectDeviceType determines device type from user agent
-func detectDeviceType(userAgent string) string {
-	ua := strings.ToLower(userAgent)
+	// The country is only known when a proxy in front of the app reports it
+	if countryCode := route.Visitor.CountryCode; countryCode != "" {
+		click.CountryCode = &countryCode
+	}
 
-	if strings.Contains(ua, "mobile") || strings.Contains(ua, "android") || strings.Contains(ua, "iphone") {
-		return "mobile"
+	if route.Rule != "" {
+		rule := route.Rule
+		click.RedirectRule = &rule
+	}
+	if route.Variant != "" {
+		variant := route.Variant
+		click.Variant = &variant
+	}
+	if route.Campaign != "" {
+		campaign := campaignLabel(route.Campaign)
+		click.UTMCampaign = &campaign
+	}
+	if route.DeepLinkPath != "" {
+		path := route.DeepLinkPath
+		click.DeepLinkPath = &path
 	}
 
-	if strings.Contains(ua, "tablet") || strings.Contains(ua, "ipad") {
-		return "tablet"
+	return t.pipeline.Enqueue(r.Context(), link, click)
+}
+
+// campaignLabel makes a utm_campaign, which may come from the visitor's
+// query string, safe to store: valid UTF-8 without NUL bytes, which
+// Postgres rejects, and at most models.MaxUTMParamLength bytes
+func campaignLabel(campaign string) string {
+	campaign = strings.ReplaceAll(campaign, "\x00", "")
+	if len(campaign) > models.MaxUTMParamLength {
+		campaign = campaign[:models.MaxUTMParamLength]
 	}
+	return strings.ToValidUTF8(campaign, "")
+}
 
-	return "desktop"
+// TrackFailedUnlock counts a wrong password entered for a protected link.
+// Failed attempts are rate limited, so they are written directly rather
+// than through the click pipeline.
+func (t *Tracker) TrackFailedUnlock(link *models.Link) error {
+	return t.analyticsRepo.IncrementFailedUnlocks(link.ID, time.Now().UTC())
 }
 
 // GetStats retrieves analytics for a link
@@ -65,7 +98,22 @@ func (t *Tracker) GetStats(linkID int64) (*models.ClickStats, error) {
 	return t.analyticsRepo.GetLinkStats(linkID)
 }
 
-// GetUserAnalytics retrieves overall analytics for a user
-func (t *Tracker) GetUserAnalytics(userID int64) (map[string]interface{}, error) {
-	return t.analyticsRepo.GetUserAnalytics(userID)
+// GetOrganizationAnalytics retrieves overall analytics for an organization,
+// including its monthly click usage against the plan quota
+func (t *Tracker) GetOrganizationAnalytics(orgID int64) (map[string]interface{}, error) {
+	stats, err := t.analyticsRepo.GetOrganizationAnalytics(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := t.quota.Status(orgID)
+	if err != nil {
+		return nil, err
+	}
+	// The quota counter includes clicks recorded only as aggregates
+	stats["clicks_this_month"] = usage.Used
+	stats["click_usage"] = usage
+	stats["over_quota"] = usage.OverQuota
+
+	return stats, nil
 }
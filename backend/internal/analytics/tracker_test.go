@@ -0,0 +1,70 @@
+package analytics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/routing"
+)
+
+func TestTrackClickFlagsCrawlers(t *testing.T) {
+	p, mock, _ := newTestPipeline(t, config.ClickPipelineConfig{QueueSize: 1})
+	tracker := NewTracker(nil, p, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/sale", nil)
+	r.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0")
+	r.Header.Set("Referer", "https://slack.com")
+	route := &routing.Route{
+		Visitor:  routing.NewVisitor(r, ""),
+		Rule:     "desktop",
+		Campaign: "spring",
+	}
+
+	// Bot clicks skip the quota, so nothing touches the database
+	if err := tracker.TrackClick(&models.Link{ID: 10, OrganizationID: 3}, "203.0.113.7", r, route); err != nil {
+		t.Fatalf("TrackClick() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	click := (<-p.queue).click
+	if !click.IsBot || click.LinkID != 10 || click.IPAddress != "203.0.113.7" {
+		t.Errorf("click = bot %v, link %d, IP %s; want a bot click on link 10 from 203.0.113.7", click.IsBot, click.LinkID, click.IPAddress)
+	}
+	if click.Referer == nil || *click.Referer != "https://slack.com" {
+		t.Errorf("referer = %v, want https://slack.com", click.Referer)
+	}
+	if click.RedirectRule == nil || *click.RedirectRule != "desktop" || click.UTMCampaign == nil || *click.UTMCampaign != "spring" {
+		t.Errorf("click rule %v, campaign %v; want desktop, spring", click.RedirectRule, click.UTMCampaign)
+	}
+	if click.Variant != nil || click.CountryCode != nil || click.DeepLinkPath != nil {
+		t.Errorf("click variant %v, country %v, deep link path %v; want none", click.Variant, click.CountryCode, click.DeepLinkPath)
+	}
+}
+
+func TestCampaignLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		campaign string
+		want     string
+	}{
+		{name: "plain", campaign: "spring sale", want: "spring sale"},
+		{name: "NUL bytes", campaign: "spring\x00sale", want: "springsale"},
+		{name: "invalid UTF-8", campaign: "spring\xffsale", want: "springsale"},
+		{name: "overlong", campaign: strings.Repeat("a", models.MaxUTMParamLength+10), want: strings.Repeat("a", models.MaxUTMParamLength)},
+		{name: "cut inside a character", campaign: strings.Repeat("a", models.MaxUTMParamLength-1) + "é", want: strings.Repeat("a", models.MaxUTMParamLength-1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := campaignLabel(tt.campaign); got != tt.want {
+				t.Errorf("campaignLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
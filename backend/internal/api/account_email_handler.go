@@ -0,0 +1,105 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/middleware"
+	"github.com/shafikshaon/url_shortener/internal/service"
+)
+
+type AccountEmailHandler struct {
+	accountEmailService *service.AccountEmailService
+	userRepo            *database.UserRepository
+}
+
+func NewAccountEmailHandler(accountEmailService *service.AccountEmailService, userRepo *database.UserRepository) *AccountEmailHandler {
+	return &AccountEmailHandler{
+		accountEmailService: accountEmailService,
+		userRepo:            userRepo,
+	}
+}
+
+type EmailTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// VerifyEmail confirms the user's email address from an emailed link
+func (h *AccountEmailHandler) VerifyEmail(c *gin.Context) {
+	var req EmailTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	if err := h.accountEmailService.VerifyEmail(req.Token); err != nil {
+		respondAccountEmailError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ResendVerification emails the current user a new verification link
+func (h *AccountEmailHandler) ResendVerification(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	if err := h.accountEmailService.SendVerification(middleware.GetContext(c), user); err != nil {
+		respondAccountEmailError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the address has an account.
+func (h *AccountEmailHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	h.accountEmailService.RequestPasswordReset(middleware.GetContext(c), req.Email)
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for that email, a reset link is on its way"})
+}
+
+// ResetPassword sets a new password using an emailed reset token. Every
+// session is signed out, so the user logs in again afterwards.
+func (h *AccountEmailHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	if err := h.accountEmailService.ResetPassword(req.Token, req.NewPassword); err != nil {
+		respondAccountEmailError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
@@ -5,30 +5,45 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/shafikshaon/url_shortener/internal/analytics"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
 	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/authz"
 )
 
 type AnalyticsHandler struct {
-	tracker *analytics.Tracker
+	tracker    *analytics.Tracker
+	authorizer *authz.Authorizer
 }
 
-func NewAnalyticsHandler(tracker *analytics.Tracker) *AnalyticsHandler {
+func NewAnalyticsHandler(tracker *analytics.Tracker, authorizer *authz.Authorizer) *AnalyticsHandler {
 	return &AnalyticsHandler{
-		tracker: tracker,
+		tracker:    tracker,
+		authorizer: authorizer,
 	}
 }
 
-// GetUserAnalytics retrieves overall analytics for the current user
+// GetUserAnalytics retrieves overall analytics for the current organization
 func (h *AnalyticsHandler) GetUserAnalytics(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	stats, err := h.tracker.GetUserAnalytics(userID)
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	if _, err := h.authorizer.Authorize(userID, orgID, authz.ActionViewAnalytics); err != nil {
+		respondAuthzError(c, err)
+		return
+	}
+
+	stats, err := h.tracker.GetOrganizationAnalytics(orgID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve analytics"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve analytics")
 		return
 	}
 
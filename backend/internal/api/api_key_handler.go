@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/service"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []string `json:"scopes,omitempty"`
+	ExpiresAt *string  `json:"expires_at,omitempty"`
+}
+
+type CreateAPIKeyResponse struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey issues a new API key. The key itself is only returned here.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil && *req.ExpiresAt != "" {
+		parsed, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid expiration date format")
+			return
+		}
+		expiresAt = &parsed
+	}
+
+	key, plaintext, err := h.apiKeyService.CreateKey(userID, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKey: key, Key: plaintext})
+}
+
+// ListAPIKeys lists the current user's API keys
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	keys, err := h.apiKeyService.ListKeys(userID)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"api_keys":         keys,
+		"available_scopes": models.APIKeyScopes,
+	})
+}
+
+// RevokeAPIKey revokes one of the current user's API keys
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	keyID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(keyID, userID); err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/middleware"
+	"github.com/shafikshaon/url_shortener/internal/ratelimit"
+)
+
+type APIUsageHandler struct {
+	limiter  *ratelimit.Limiter
+	userRepo *database.UserRepository
+}
+
+func NewAPIUsageHandler(limiter *ratelimit.Limiter, userRepo *database.UserRepository) *APIUsageHandler {
+	return &APIUsageHandler{
+		limiter:  limiter,
+		userRepo: userRepo,
+	}
+}
+
+// GetUsage returns the user's API rate limits and current usage
+func (h *APIUsageHandler) GetUsage(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	usage, err := h.limiter.Usage(middleware.GetContext(c), ratelimit.Subject(user.ID), user.GetAPIRateLimit())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/config"
+)
+
+// AppLinksHandler serves the files iOS and Android fetch to verify that the
+// short link domain may open links directly in the app
+type AppLinksHandler struct {
+	cfg *config.AppLinksConfig
+}
+
+func NewAppLinksHandler(cfg *config.Config) *AppLinksHandler {
+	return &AppLinksHandler{cfg: &cfg.AppLinks}
+}
+
+// AppleAppSiteAssociation serves the iOS universal links file, claiming
+// every path on the domain for the configured apps
+func (h *AppLinksHandler) AppleAppSiteAssociation(c *gin.Context) {
+	if len(h.cfg.IOSAppIDs) == 0 {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"applinks": gin.H{
+			"details": []gin.H{{
+				"appIDs":     h.cfg.IOSAppIDs,
+				"components": []gin.H{{"/": "/*"}},
+			}},
+		},
+	})
+}
+
+// AssetLinks serves the Android app links file for the configured package
+func (h *AppLinksHandler) AssetLinks(c *gin.Context) {
+	if h.cfg.AndroidPackage == "" || len(h.cfg.AndroidFingerprints) == 0 {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, []gin.H{{
+		"relation": []string{"delegate_permission/common.handle_all_urls"},
+		"target": gin.H{
+			"namespace":                "android_app",
+			"package_name":             h.cfg.AndroidPackage,
+			"sha256_cert_fingerprints": h.cfg.AndroidFingerprints,
+		},
+	}})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/config"
+)
+
+// serveAppLinks runs serve with the app links config and returns the response
+func serveAppLinks(cfg config.AppLinksConfig, serve func(*AppLinksHandler, *gin.Context)) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	h := NewAppLinksHandler(&config.Config{AppLinks: cfg})
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) { serve(h, c) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w
+}
+
+func TestAppLinksNotFoundWithoutApps(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   config.AppLinksConfig
+		serve func(*AppLinksHandler, *gin.Context)
+	}{
+		{name: "no iOS apps", serve: (*AppLinksHandler).AppleAppSiteAssociation},
+		{name: "no Android package", cfg: config.AppLinksConfig{AndroidFingerprints: []string{"AB:CD"}}, serve: (*AppLinksHandler).AssetLinks},
+		{name: "no Android fingerprints", cfg: config.AppLinksConfig{AndroidPackage: "com.example.app"}, serve: (*AppLinksHandler).AssetLinks},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serveAppLinks(tt.cfg, tt.serve); w.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+			}
+		})
+	}
+}
+
+func TestAppleAppSiteAssociation(t *testing.T) {
+	w := serveAppLinks(config.AppLinksConfig{IOSAppIDs: []string{"TEAM.com.example.app"}}, (*AppLinksHandler).AppleAppSiteAssociation)
+
+	var body struct {
+		AppLinks struct {
+			Details []struct {
+				AppIDs     []string            `json:"appIDs"`
+				Components []map[string]string `json:"components"`
+			} `json:"details"`
+		} `json:"applinks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	details := body.AppLinks.Details
+	if len(details) != 1 || len(details[0].AppIDs) != 1 || details[0].AppIDs[0] != "TEAM.com.example.app" {
+		t.Fatalf("details = %+v, want the configured app", details)
+	}
+	if len(details[0].Components) != 1 || details[0].Components[0]["/"] != "/*" {
+		t.Errorf("components = %v, want every path", details[0].Components)
+	}
+}
+
+func TestAssetLinks(t *testing.T) {
+	w := serveAppLinks(config.AppLinksConfig{AndroidPackage: "com.example.app", AndroidFingerprints: []string{"AB:CD"}}, (*AppLinksHandler).AssetLinks)
+
+	var body []struct {
+		Relation []string `json:"relation"`
+		Target   struct {
+			Namespace    string   `json:"namespace"`
+			PackageName  string   `json:"package_name"`
+			Fingerprints []string `json:"sha256_cert_fingerprints"`
+		} `json:"target"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(body) != 1 || body[0].Target.Namespace != "android_app" || body[0].Target.PackageName != "com.example.app" ||
+		len(body[0].Target.Fingerprints) != 1 || body[0].Target.Fingerprints[0] != "AB:CD" {
+		t.Errorf("asset links = %+v, want the configured package", body)
+	}
+}
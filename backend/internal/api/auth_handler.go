@@ -1,25 +1,32 @@
 package api
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
 	"github.com/shafikshaon/url_shortener/internal/auth"
 	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/middleware"
 	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/service"
 )
 
 type AuthHandler struct {
-	userRepo   *database.UserRepository
-	jwtService *auth.JWTService
+	userRepo            *database.UserRepository
+	jwtService          *auth.JWTService
+	sessionService      *service.SessionService
+	accountEmailService *service.AccountEmailService
 }
 
-func NewAuthHandler(userRepo *database.UserRepository, jwtService *auth.JWTService) *AuthHandler {
+func NewAuthHandler(userRepo *database.UserRepository, jwtService *auth.JWTService, sessionService *service.SessionService, accountEmailService *service.AccountEmailService) *AuthHandler {
 	return &AuthHandler{
-		userRepo:   userRepo,
-		jwtService: jwtService,
+		userRepo:            userRepo,
+		jwtService:          jwtService,
+		sessionService:      sessionService,
+		accountEmailService: accountEmailService,
 	}
 }
 
@@ -34,29 +41,57 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// AuthResponse carries a new session's tokens. Token is the access token,
+// valid for ExpiresIn seconds; RefreshToken obtains the next one.
 type AuthResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refresh_token"`
+	ExpiresIn    int          `json:"expires_in"`
+	User         *models.User `json:"user"`
+}
+
+func newAuthResponse(pair *service.TokenPair, user *models.User) AuthResponse {
+	return AuthResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+		User:         user,
+	}
+}
+
+// clientInfo describes the device making the request, for session records
+func clientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// TwoFactorChallengeResponse is returned instead of a token when the user
+// must complete a two-factor login at /auth/login/2fa
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
 }
 
 // Signup handles user registration
 func (h *AuthHandler) Signup(c *gin.Context) {
 	var req SignupRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalidRequest(c, err)
 		return
 	}
 
 	// Check if user already exists
 	if _, err := h.userRepo.GetByEmail(req.Email); err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		apierror.Respond(c, http.StatusConflict, apierror.CodeEmailTaken, "Email already registered")
 		return
 	}
 
 	// Hash password
 	passwordHash, err := auth.HashPassword(req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
+		respondInternalError(c, err)
 		return
 	}
 
@@ -69,74 +104,92 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 	}
 
 	if err := h.userRepo.Create(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		respondInternalError(c, err)
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.jwtService.GenerateToken(user)
+	// Send the verification email. The account is usable without it, and
+	// the user can ask for another, so a failure doesn't fail the signup.
+	if err := h.accountEmailService.SendVerification(middleware.GetContext(c), user); err != nil {
+		logger.Errorf(middleware.GetContext(c), "Failed to send verification email to user ID %d: %+v", user.ID, err)
+	}
+
+	// Start a session
+	pair, err := h.sessionService.Start(user, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		respondInternalError(c, err)
 		return
 	}
 
 	// Clear password hash before sending response
 	user.PasswordHash = ""
 
-	c.JSON(http.StatusCreated, AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusCreated, newAuthResponse(pair, user))
 }
 
 // Login handles user authentication
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalidRequest(c, err)
 		return
 	}
 
 	// Get user by email
 	user, err := h.userRepo.GetByEmail(req.Email)
+	if errors.Is(err, database.ErrUserNotFound) {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid credentials")
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		respondInternalError(c, err)
 		return
 	}
 
 	// Check password
 	if !auth.CheckPassword(req.Password, user.PasswordHash) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid credentials")
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.jwtService.GenerateToken(user)
+	// With two-factor on, the password alone only earns a challenge token
+	if user.TwoFactorEnabled {
+		challengeToken, err := h.jwtService.GenerateChallengeToken(user)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+		})
+		return
+	}
+
+	// Start a session
+	pair, err := h.sessionService.Start(user, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		respondInternalError(c, err)
 		return
 	}
 
 	// Clear password hash before sending response
 	user.PasswordHash = ""
 
-	c.JSON(http.StatusOK, AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusOK, newAuthResponse(pair, user))
 }
 
 // GetProfile returns the current user's profile
 func (h *AuthHandler) GetProfile(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	user, err := h.userRepo.GetByID(userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondUserError(c, err)
 		return
 	}
 
@@ -146,47 +199,11 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-// GenerateAPIKey generates a new API key for the user
-func (h *AuthHandler) GenerateAPIKey(c *gin.Context) {
-	userID, exists := auth.GetUserID(c)
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	user, err := h.userRepo.GetByID(userID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	// Check if user has API access (not free tier)
-	if user.SubscriptionTier == models.TierFree {
-		c.JSON(http.StatusForbidden, gin.H{"error": "API access requires Pro or Business subscription"})
-		return
-	}
-
-	// Generate random API key
-	apiKey, err := generateAPIKey()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
-		return
-	}
-
-	user.APIKey = &apiKey
-	if err := h.userRepo.Update(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"api_key": apiKey})
-}
-
 // UpdateProfile updates the user's profile information
 func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -195,13 +212,13 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalidRequest(c, err)
 		return
 	}
 
 	user, err := h.userRepo.GetByID(userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondUserError(c, err)
 		return
 	}
 
@@ -209,7 +226,7 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	user.FullName = req.FullName
 
 	if err := h.userRepo.Update(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		respondInternalError(c, err)
 		return
 	}
 
@@ -223,7 +240,7 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -233,44 +250,54 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalidRequest(c, err)
 		return
 	}
 
 	user, err := h.userRepo.GetByID(userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondUserError(c, err)
 		return
 	}
 
-	// Verify current password
+	// Verify current password. 403 rather than 401: the session is fine,
+	// only the confirmation failed.
 	if !auth.CheckPassword(req.CurrentPassword, user.PasswordHash) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeInvalidCredentials, "Current password is incorrect")
 		return
 	}
 
 	// Hash new password
 	newPasswordHash, err := auth.HashPassword(req.NewPassword)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process new password"})
+		respondInternalError(c, err)
 		return
 	}
 
 	// Update password
-	user.PasswordHash = newPasswordHash
-	if err := h.userRepo.Update(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+	if err := h.userRepo.UpdatePassword(user.ID, newPasswordHash); err != nil {
+		respondInternalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
-}
-
-// generateAPIKey creates a random API key
-func generateAPIKey() (string, error) {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
+	// Sign out every session, since any of them may be the reason for the
+	// change, and start a fresh one for this device
+	if err := h.sessionService.RevokeAll(user.ID); err != nil {
+		respondInternalError(c, err)
+		return
 	}
-	return hex.EncodeToString(bytes), nil
+	pair, err := h.sessionService.Start(user, clientInfo(c))
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	user.PasswordHash = ""
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Password changed successfully",
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
+		"user":          user,
+	})
 }
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/billing"
+	"github.com/shafikshaon/url_shortener/internal/middleware"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/service"
+)
+
+// maxStripeWebhookBytes bounds the webhook body read into memory
+const maxStripeWebhookBytes = 1 << 20
+
+type BillingHandler struct {
+	billingService *service.BillingService
+}
+
+func NewBillingHandler(billingService *service.BillingService) *BillingHandler {
+	return &BillingHandler{
+		billingService: billingService,
+	}
+}
+
+type CheckoutRequest struct {
+	Tier models.SubscriptionTier `json:"tier" binding:"required"`
+}
+
+type CoverageRequest struct {
+	Covered *bool `json:"covered" binding:"required"`
+}
+
+// GetBilling returns the current user's plan and subscription
+func (h *BillingHandler) GetBilling(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	status, err := h.billingService.GetStatus(userID)
+	if err != nil {
+		respondBillingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// ListInvoices returns the current user's billing history
+func (h *BillingHandler) ListInvoices(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	invoices, err := h.billingService.ListInvoices(userID)
+	if err != nil {
+		respondBillingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invoices": invoices})
+}
+
+// CreateCheckout starts a Stripe Checkout for a paid tier and returns the
+// URL to send the user to
+func (h *BillingHandler) CreateCheckout(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	session, err := h.billingService.StartCheckout(middleware.GetContext(c), userID, req.Tier)
+	if err != nil {
+		respondBillingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": session.URL})
+}
+
+// CreatePortal opens the Stripe billing portal and returns its URL
+func (h *BillingHandler) CreatePortal(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	session, err := h.billingService.OpenPortal(middleware.GetContext(c), userID)
+	if err != nil {
+		respondBillingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": session.URL})
+}
+
+// SetOrganizationCoverage has the current user's subscription start or
+// stop covering one of their team organizations
+func (h *BillingHandler) SetOrganizationCoverage(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	var req CoverageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	org, err := h.billingService.SetOrganizationCoverage(middleware.GetContext(c), orgID, userID, *req.Covered)
+	if err != nil {
+		respondBillingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// StripeWebhook receives Stripe's webhook events. Any error other than a
+// bad signature answers 5xx so Stripe retries the delivery.
+func (h *BillingHandler) StripeWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxStripeWebhookBytes))
+	if err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	if err := h.billingService.HandleWebhook(middleware.GetContext(c), payload, c.GetHeader(billing.SignatureHeader)); err != nil {
+		respondBillingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
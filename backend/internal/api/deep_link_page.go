@@ -0,0 +1,79 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deepLinkPage is served instead of a redirect to visitors whose platform
+// has an app URI. It tries to open the app, reports back if the page is
+// hidden because the app opened, and otherwise continues to the fallback
+// after a short wait.
+var deepLinkPage = template.Must(template.New("deep_link").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Opening app</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; background: #f5f6f8; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+main { background: #fff; padding: 32px; border-radius: 8px; box-shadow: 0 1px 4px rgba(0,0,0,.1); width: 100%; max-width: 320px; text-align: center; }
+h1 { font-size: 18px; margin: 0 0 16px; }
+a { display: block; padding: 10px; margin-top: 12px; border-radius: 4px; font-size: 14px; text-decoration: none; }
+.primary { background: #4f46e5; color: #fff; }
+.secondary { color: #4f46e5; }
+</style>
+</head>
+<body>
+<main>
+<h1>Opening the app&hellip;</h1>
+<a class="primary" href="{{.AppURI}}">Open in app</a>
+<a class="secondary" href="{{.FallbackURL}}">Continue without the app</a>
+</main>
+<script>
+(function () {
+  var openedURL = {{.OpenedURL}};
+  var fallbackURL = {{.FallbackURL}};
+  var done = false;
+
+  document.addEventListener("visibilitychange", function () {
+    if (document.hidden && !done) {
+      done = true;
+      fetch(openedURL, { keepalive: true, credentials: "same-origin" });
+    }
+  });
+
+  window.location.href = {{.AppURI}};
+  setTimeout(function () {
+    if (!done) {
+      done = true;
+      window.location.replace(fallbackURL);
+    }
+  }, 2000);
+})();
+</script>
+</body>
+</html>
+`))
+
+// renderDeepLinkPage writes the page that tries to open appURI. openedURL
+// and fallbackURL are where the page reports back. The app URI is trusted
+// as a URL, custom scheme and all, because app URIs are checked when the
+// link is saved.
+func renderDeepLinkPage(c *gin.Context, appURI, openedURL, fallbackURL string) {
+	data := struct {
+		AppURI      template.URL
+		OpenedURL   string
+		FallbackURL string
+	}{template.URL(appURI), openedURL, fallbackURL}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := deepLinkPage.Execute(c.Writer, data); err != nil {
+		c.Error(err)
+	}
+}
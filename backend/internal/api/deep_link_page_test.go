@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRenderDeepLinkPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/sale?ref=mail", nil)
+
+	renderDeepLinkPage(c, "myapp://product/42", deepLinkStepURL(c, deepLinkStepOpened), deepLinkStepURL(c, deepLinkStepFallback))
+
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("status %d, Cache-Control %q; want 200, no-store", w.Code, w.Header().Get("Cache-Control"))
+	}
+	body := w.Body.String()
+	// The custom scheme survives escaping, and the callbacks keep the query
+	// so redirect rules still match
+	for _, want := range []string{
+		`href="myapp://product/42"`,
+		`href="/sale?dl=fallback&amp;ref=mail"`,
+		`var openedURL = "/sale?dl=opened\u0026ref=mail"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page doesn't contain %s", want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/service"
+)
+
+type DomainHandler struct {
+	domainService *service.DomainService
+}
+
+func NewDomainHandler(domainService *service.DomainService) *DomainHandler {
+	return &DomainHandler{
+		domainService: domainService,
+	}
+}
+
+type CreateDomainRequest struct {
+	Hostname string `json:"hostname" binding:"required"`
+}
+
+type DomainResponse struct {
+	*models.Domain
+	VerificationRecordName  string `json:"verification_record_name"`
+	VerificationRecordValue string `json:"verification_record_value"`
+}
+
+func newDomainResponse(domain *models.Domain) *DomainResponse {
+	return &DomainResponse{
+		Domain:                  domain,
+		VerificationRecordName:  domain.VerificationRecordName(),
+		VerificationRecordValue: domain.VerificationRecordValue(),
+	}
+}
+
+// CreateDomain registers a custom domain for the organization
+func (h *DomainHandler) CreateDomain(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	var req CreateDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	domain, err := h.domainService.AddDomain(orgID, userID, req.Hostname)
+	if err != nil {
+		respondDomainError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newDomainResponse(domain))
+}
+
+// ListDomains lists the organization's custom domains
+func (h *DomainHandler) ListDomains(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	domains, err := h.domainService.ListDomains(orgID, userID)
+	if err != nil {
+		respondDomainError(c, err)
+		return
+	}
+
+	responses := make([]*DomainResponse, len(domains))
+	for i, domain := range domains {
+		responses[i] = newDomainResponse(domain)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"domains": responses})
+}
+
+// GetDomain retrieves a single custom domain
+func (h *DomainHandler) GetDomain(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	domainID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid domain ID")
+		return
+	}
+
+	domain, err := h.domainService.GetDomain(orgID, domainID, userID)
+	if err != nil {
+		respondDomainError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newDomainResponse(domain))
+}
+
+// VerifyDomain checks the domain's DNS TXT record
+func (h *DomainHandler) VerifyDomain(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	domainID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid domain ID")
+		return
+	}
+
+	domain, err := h.domainService.VerifyDomain(orgID, domainID, userID)
+	if err != nil {
+		respondDomainError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newDomainResponse(domain))
+}
+
+// DeleteDomain deletes a custom domain
+func (h *DomainHandler) DeleteDomain(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	domainID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid domain ID")
+		return
+	}
+
+	if err := h.domainService.DeleteDomain(orgID, domainID, userID); err != nil {
+		respondDomainError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Domain deleted successfully"})
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/billing"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/middleware"
+	"github.com/shafikshaon/url_shortener/internal/service"
+)
+
+// respondLinkError maps link service errors to error responses. Errors the
+// client can't act on are logged and reported without their details.
+func respondLinkError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrLinkNotFound), errors.Is(err, authz.ErrNotMember):
+		// Links in other organizations are reported as missing
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeLinkNotFound, "Link not found")
+	case errors.Is(err, authz.ErrForbidden):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Insufficient permissions")
+	case errors.Is(err, service.ErrInvalidShortCode):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidShortCode, err.Error())
+	case errors.Is(err, service.ErrShortCodeReserved):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeShortCodeReserved, "Short code is reserved")
+	case errors.Is(err, service.ErrShortCodeTaken):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeShortCodeTaken, "Short code already exists")
+	case errors.Is(err, service.ErrLinkLimitReached):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeLinkLimitReached, "Link limit reached for your subscription tier")
+	case errors.Is(err, database.ErrDomainNotFound):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeDomainNotFound, "Domain not found")
+	case errors.Is(err, service.ErrDomainNotVerified):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeDomainNotVerified, "Domain is not verified")
+	case errors.Is(err, service.ErrInvalidLinkPassword), errors.Is(err, service.ErrInvalidActivation),
+		errors.Is(err, service.ErrInvalidRedirectRule), errors.Is(err, service.ErrInvalidVariants),
+		errors.Is(err, service.ErrInvalidDeepLink), errors.Is(err, service.ErrInvalidQueryOptions),
+		errors.Is(err, service.ErrInvalidPreview), errors.Is(err, service.ErrInvalidDestination):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+	default:
+		respondInternalError(c, err)
+	}
+}
+
+// respondAuthzError maps authorization errors to error responses
+func respondAuthzError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, authz.ErrNotMember):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Not a member of this organization")
+	case errors.Is(err, authz.ErrForbidden):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Insufficient permissions")
+	default:
+		respondInternalError(c, err)
+	}
+}
+
+// respondOrganizationError maps organization service errors to error
+// responses. Organizations the user doesn't belong to are reported as missing.
+func respondOrganizationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrOrganizationNotFound), errors.Is(err, authz.ErrNotMember):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeOrganizationNotFound, "Organization not found")
+	case errors.Is(err, authz.ErrForbidden):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Insufficient permissions")
+	case errors.Is(err, database.ErrMemberNotFound):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeMemberNotFound, "Member not found")
+	case errors.Is(err, database.ErrInvitationNotFound):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeInvitationNotFound, "Invitation not found")
+	case errors.Is(err, service.ErrInvalidOrganizationName), errors.Is(err, service.ErrInvalidRole):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+	case errors.Is(err, service.ErrPersonalOrganization):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodePersonalOrganization, "Personal organizations cannot be deleted or shared")
+	case errors.Is(err, service.ErrLastOwner):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeLastOwner, "Organization must have at least one owner")
+	case errors.Is(err, service.ErrInvalidInvitation):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidInvitation, "Invitation is invalid or has expired")
+	case errors.Is(err, service.ErrInvitationEmailMismatch):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeInvalidInvitation, "Invitation was sent to a different email address")
+	case errors.Is(err, service.ErrAlreadyMember):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeAlreadyMember, "Already a member of this organization")
+	default:
+		respondInternalError(c, err)
+	}
+}
+
+// respondDomainError maps domain service errors to error responses
+func respondDomainError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrDomainNotFound):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeDomainNotFound, "Domain not found")
+	case errors.Is(err, service.ErrInvalidHostname):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+	case errors.Is(err, service.ErrDomainTaken):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeDomainTaken, "Domain already registered")
+	case errors.Is(err, service.ErrDomainInUse):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeDomainInUse, "Domain still has links; move or delete them first")
+	default:
+		respondAuthzError(c, err)
+	}
+}
+
+// respondWebhookError maps webhook service errors to error responses
+func respondWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrWebhookNotFound):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeWebhookNotFound, "Webhook not found")
+	case errors.Is(err, service.ErrInvalidWebhook):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+	case errors.Is(err, service.ErrWebhookLimitReached):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeWebhookLimitReached, "Webhook limit reached")
+	default:
+		respondAuthzError(c, err)
+	}
+}
+
+// respondAPIKeyError maps API key service errors to error responses
+func respondAPIKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrAPIKeyNotFound):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeAPIKeyNotFound, "API key not found")
+	case errors.Is(err, service.ErrAPIAccessRequired):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodePlanRequired, "API access requires Pro or Business subscription")
+	case errors.Is(err, service.ErrEmailNotVerified):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeEmailNotVerified, "Verify your email address to create API keys")
+	case errors.Is(err, service.ErrInvalidAPIKeyRequest):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+	case errors.Is(err, service.ErrAPIKeyLimitReached):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeAPIKeyLimitReached, "API key limit reached")
+	default:
+		respondInternalError(c, err)
+	}
+}
+
+// respondUserError reports a failed lookup of the signed-in user
+func respondUserError(c *gin.Context, err error) {
+	if errors.Is(err, database.ErrUserNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeUserNotFound, "User not found")
+		return
+	}
+	respondInternalError(c, err)
+}
+
+// respondTwoFactorError maps two-factor service errors to error responses.
+// Failed re-authentication is 403 rather than 401 so the session survives.
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidTwoFactorCode):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeInvalidTwoFactorCode, "Invalid two-factor code")
+	case errors.Is(err, service.ErrInvalidPassword):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeInvalidCredentials, "Password is incorrect")
+	case errors.Is(err, service.ErrTooManyTwoFactorAttempts):
+		apierror.Respond(c, http.StatusTooManyRequests, apierror.CodeTooManyAttempts, "Too many attempts, try again later")
+	case errors.Is(err, service.ErrTwoFactorEnabled):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeTwoFactorEnabled, "Two-factor authentication is already enabled")
+	case errors.Is(err, service.ErrTwoFactorNotEnabled):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeTwoFactorNotEnabled, "Two-factor authentication is not enabled")
+	case errors.Is(err, service.ErrTwoFactorSetupRequired):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeTwoFactorSetupRequired, "Start two-factor setup first")
+	default:
+		respondInternalError(c, err)
+	}
+}
+
+// respondAccountEmailError maps email verification and password reset errors
+// to error responses
+func respondAccountEmailError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrEmailTokenInvalid):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidEmailToken, "This link is invalid or has expired")
+	case errors.Is(err, service.ErrEmailAlreadyVerified):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeEmailAlreadyVerified, "Email is already verified")
+	case errors.Is(err, service.ErrTooManyEmails):
+		apierror.Respond(c, http.StatusTooManyRequests, apierror.CodeTooManyEmails, "Too many emails requested, try again later")
+	default:
+		respondInternalError(c, err)
+	}
+}
+
+// respondBillingError maps billing service errors to error responses
+func respondBillingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrBillingDisabled):
+		apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeBillingDisabled, "Billing is not available")
+	case errors.Is(err, service.ErrInvalidPlan):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidPlan, "Plan is not available")
+	case errors.Is(err, service.ErrAlreadySubscribed):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeAlreadySubscribed, "You already have a subscription; change plans from the billing portal")
+	case errors.Is(err, service.ErrNoBillingAccount):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNoBillingAccount, "No billing account yet")
+	case errors.Is(err, billing.ErrInvalidSignature):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidSignature, "Invalid webhook signature")
+	case errors.Is(err, database.ErrOrganizationNotFound), errors.Is(err, authz.ErrNotMember):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeOrganizationNotFound, "Organization not found")
+	case errors.Is(err, authz.ErrForbidden):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Only the organization's owner can change its plan")
+	case errors.Is(err, service.ErrPersonalOrganizationCovered):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Your subscription always covers your personal organization")
+	case errors.Is(err, database.ErrCoverageLimitReached):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeCoverageLimitReached, "Your plan covers no more team organizations")
+	default:
+		respondInternalError(c, err)
+	}
+}
+
+// respondInternalError logs an unexpected error and hides it from the client
+func respondInternalError(c *gin.Context, err error) {
+	logger.Errorf(middleware.GetContext(c), "Request failed: %+v", err)
+	apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Internal server error")
+}
+
+// respondInvalidRequest reports a request body that failed binding or validation
+func respondInvalidRequest(c *gin.Context, err error) {
+	apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body: "+err.Error())
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/service"
+)
+
+// errorResponse is the body of an error response
+type errorResponse struct {
+	Error string        `json:"error"`
+	Code  apierror.Code `json:"code"`
+}
+
+// respondWith runs respond for err and returns the status and decoded body
+func respondWith(t *testing.T, respond func(*gin.Context, error), err error) (int, errorResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	respond(c, err)
+
+	var body errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return w.Code, body
+}
+
+func TestRespondLinkError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   apierror.Code
+	}{
+		{name: "missing link", err: database.ErrLinkNotFound, wantStatus: http.StatusNotFound, wantCode: apierror.CodeLinkNotFound},
+		{name: "other organization's link", err: authz.ErrNotMember, wantStatus: http.StatusNotFound, wantCode: apierror.CodeLinkNotFound},
+		{name: "viewer editing", err: authz.ErrForbidden, wantStatus: http.StatusForbidden, wantCode: apierror.CodeForbidden},
+		{name: "code taken", err: service.ErrShortCodeTaken, wantStatus: http.StatusConflict, wantCode: apierror.CodeShortCodeTaken},
+		{name: "link limit", err: service.ErrLinkLimitReached, wantStatus: http.StatusForbidden, wantCode: apierror.CodeLinkLimitReached},
+		{name: "wrapped validation error", err: fmt.Errorf("%w: max_clicks must be positive", service.ErrInvalidActivation), wantStatus: http.StatusBadRequest, wantCode: apierror.CodeInvalidRequest},
+		{name: "unexpected error", err: errors.New("pq: connection refused"), wantStatus: http.StatusInternalServerError, wantCode: apierror.CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := respondWith(t, respondLinkError, tt.err)
+			if status != tt.wantStatus || body.Code != tt.wantCode {
+				t.Errorf("response = %d %s, want %d %s", status, body.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRespondErrorsHideUnexpectedErrors(t *testing.T) {
+	responders := map[string]func(*gin.Context, error){
+		"link":         respondLinkError,
+		"authz":        respondAuthzError,
+		"organization": respondOrganizationError,
+		"domain":       respondDomainError,
+		"webhook":      respondWebhookError,
+		"api key":      respondAPIKeyError,
+		"user":         respondUserError,
+	}
+
+	for name, respond := range responders {
+		t.Run(name, func(t *testing.T) {
+			status, body := respondWith(t, respond, errors.New("pq: password authentication failed"))
+			if status != http.StatusInternalServerError || body.Code != apierror.CodeInternal {
+				t.Errorf("response = %d %s, want %d %s", status, body.Code, http.StatusInternalServerError, apierror.CodeInternal)
+			}
+			if strings.Contains(body.Error, "pq:") {
+				t.Errorf("error message %q leaks the cause", body.Error)
+			}
+		})
+	}
+}
+
+func TestRespondAuthzFallbacks(t *testing.T) {
+	responders := map[string]func(*gin.Context, error){
+		"domain":  respondDomainError,
+		"webhook": respondWebhookError,
+	}
+
+	for name, respond := range responders {
+		t.Run(name, func(t *testing.T) {
+			status, body := respondWith(t, respond, authz.ErrForbidden)
+			if status != http.StatusForbidden || body.Code != apierror.CodeForbidden {
+				t.Errorf("response = %d %s, want %d %s", status, body.Code, http.StatusForbidden, apierror.CodeForbidden)
+			}
+		})
+	}
+}
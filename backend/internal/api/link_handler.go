@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -8,33 +9,55 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/shafikshaon/url_shortener/config"
 	"github.com/shafikshaon/url_shortener/internal/analytics"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
 	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/cache"
 	"github.com/shafikshaon/url_shortener/internal/logger"
 	"github.com/shafikshaon/url_shortener/internal/middleware"
 	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/routing"
 	"github.com/shafikshaon/url_shortener/internal/service"
 )
 
+// The deep link page calls back to the short URL with deepLinkStepParam set
+// to report whether the app opened or the visitor needs the fallback
+const (
+	deepLinkStepParam    = "dl"
+	deepLinkStepOpened   = "opened"
+	deepLinkStepFallback = "fallback"
+)
+
 type LinkHandler struct {
-	linkService *service.LinkService
-	tracker     *analytics.Tracker
-	config      *config.Config
+	linkService   *service.LinkService
+	domainService *service.DomainService
+	tracker       *analytics.Tracker
+	config        *config.Config
 }
 
-func NewLinkHandler(linkService *service.LinkService, tracker *analytics.Tracker, cfg *config.Config) *LinkHandler {
+func NewLinkHandler(linkService *service.LinkService, domainService *service.DomainService, tracker *analytics.Tracker, cfg *config.Config) *LinkHandler {
 	return &LinkHandler{
-		linkService: linkService,
-		tracker:     tracker,
-		config:      cfg,
+		linkService:   linkService,
+		domainService: domainService,
+		tracker:       tracker,
+		config:        cfg,
 	}
 }
 
 type CreateLinkRequest struct {
-	DestinationURL string    `json:"destination_url" binding:"required,url"`
-	ShortCode      string    `json:"short_code,omitempty"`
-	Title          string    `json:"title,omitempty"`
-	Tags           []string  `json:"tags,omitempty"`
-	ExpiresAt      *string   `json:"expires_at,omitempty"`
+	DestinationURL string               `json:"destination_url" binding:"required,url"`
+	ShortCode      string               `json:"short_code,omitempty"`
+	DomainID       *int64               `json:"domain_id,omitempty"`
+	Title          string               `json:"title,omitempty"`
+	Tags           []string             `json:"tags,omitempty"`
+	ExpiresAt      *string              `json:"expires_at,omitempty"`
+	Password       string               `json:"password,omitempty"`
+	RedirectRules  models.RedirectRules `json:"redirect_rules,omitempty"`
+	Variants       models.LinkVariants  `json:"variants,omitempty"`
+	DeepLink       *models.DeepLink     `json:"deep_link,omitempty"`
+	Preview        *models.LinkPreview  `json:"preview,omitempty"`
+	LinkQueryRequest
+	LinkActivationRequest
 }
 
 type UpdateLinkRequest struct {
@@ -42,11 +65,107 @@ type UpdateLinkRequest struct {
 	Title          string   `json:"title,omitempty"`
 	Tags           []string `json:"tags,omitempty"`
 	ExpiresAt      *string  `json:"expires_at,omitempty"`
+	LinkQueryRequest
+	LinkActivationRequest
+	// RedirectRules replaces the link's rules; [] removes them and
+	// omitting it leaves them unchanged
+	RedirectRules models.RedirectRules `json:"redirect_rules"`
+	// Variants replaces the link's A/B variants; [] removes them and
+	// omitting it leaves them unchanged
+	Variants models.LinkVariants `json:"variants"`
+	// DeepLink replaces the link's app URIs and store URLs; {} removes
+	// them and omitting it leaves them unchanged
+	DeepLink *models.DeepLink `json:"deep_link"`
+	// Preview replaces the link's preview overrides; {} removes them and
+	// omitting it leaves them unchanged
+	Preview *models.LinkPreview `json:"preview"`
+	// Password replaces the link's password; "" removes it and omitting
+	// it leaves it unchanged
+	Password *string `json:"password,omitempty"`
+}
+
+// LinkQueryRequest holds the settings for the query string a link's
+// destination gets. On update, UTM replaces the link's parameters; {}
+// removes them, and omitting any field leaves it unchanged.
+type LinkQueryRequest struct {
+	UTM              *models.UTMParams `json:"utm,omitempty"`
+	QueryPassthrough *bool             `json:"query_passthrough,omitempty"`
+	QueryConflict    *string           `json:"query_conflict,omitempty"`
+}
+
+// applyQuery sets the query string settings that were given on link
+func (r *LinkQueryRequest) applyQuery(link *models.Link) {
+	link.UTM = r.UTM
+	if r.QueryPassthrough != nil {
+		link.QueryPassthrough = *r.QueryPassthrough
+	}
+	if r.QueryConflict != nil {
+		link.QueryConflict = *r.QueryConflict
+	}
+}
+
+// LinkActivationRequest holds the optional settings that limit when a link
+// redirects. On update, omitting StartsAt, MaxClicks or FallbackURL leaves
+// them unchanged, and "", 0 or "" removes them.
+type LinkActivationRequest struct {
+	StartsAt    *string `json:"starts_at,omitempty"`
+	MaxClicks   *int    `json:"max_clicks,omitempty"`
+	FallbackURL *string `json:"fallback_url,omitempty" binding:"omitempty,url"`
+}
+
+// apply sets the activation settings on link, reporting malformed times
+func (r *LinkActivationRequest) apply(link *models.Link) error {
+	if r.StartsAt != nil {
+		// The zero time asks for the start to be removed
+		var startsAt time.Time
+		if *r.StartsAt != "" {
+			parsed, err := time.Parse(time.RFC3339, *r.StartsAt)
+			if err != nil {
+				return err
+			}
+			startsAt = parsed
+		}
+		link.StartsAt = &startsAt
+	}
+	link.MaxClicks = r.MaxClicks
+	link.FallbackURL = r.FallbackURL
+	return nil
 }
 
 type LinkResponse struct {
 	*models.Link
-	ShortURL string `json:"short_url"`
+	ShortURL          string           `json:"short_url"`
+	PasswordProtected bool             `json:"password_protected"`
+	State             models.LinkState `json:"state"`
+	RemainingClicks   *int64           `json:"remaining_clicks,omitempty"`
+}
+
+// LinkStatsResponse is a link's analytics along with its current state
+type LinkStatsResponse struct {
+	*models.ClickStats
+	State           models.LinkState `json:"state"`
+	RedirectCount   int64            `json:"redirect_count"`
+	MaxClicks       *int             `json:"max_clicks,omitempty"`
+	RemainingClicks *int64           `json:"remaining_clicks,omitempty"`
+}
+
+// shortURL returns the link's short URL, on its custom domain when set
+func (h *LinkHandler) shortURL(link *models.Link) string {
+	if link.Domain != nil {
+		return link.Domain.ShortURL(link.ShortCode)
+	}
+	return h.config.Server.BaseURL + "/" + link.ShortCode
+}
+
+// newLinkResponse builds the response for a link
+func (h *LinkHandler) newLinkResponse(link *models.Link) *LinkResponse {
+	return &LinkResponse{
+		Link:              link,
+		ShortURL:          h.shortURL(link),
+		PasswordProtected: link.IsPasswordProtected(),
+		State:             link.State(time.Now()),
+		RemainingClicks:   link.RemainingClicks(),
+	}
 }
 
 // CreateLink handles link creation
@@ -57,22 +176,33 @@ func (h *LinkHandler) CreateLink(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
 		logger.Warnf(ctx, "Unauthorized access attempt to create link")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
 		return
 	}
 
-	logger.Infof(ctx, "Creating link for user ID: %d", userID)
+	logger.Infof(ctx, "Creating link for user ID: %d in organization ID: %d", userID, orgID)
 
 	var req CreateLinkRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Errorf(ctx, "Invalid request body: %+v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalidRequest(c, err)
 		return
 	}
 
 	link := &models.Link{
 		UserID:         userID,
+		OrganizationID: orgID,
+		DomainID:       req.DomainID,
 		DestinationURL: req.DestinationURL,
+		RedirectRules:  req.RedirectRules,
+		Variants:       req.Variants,
+		DeepLink:       req.DeepLink,
 		Tags:           req.Tags,
 	}
 
@@ -84,23 +214,26 @@ func (h *LinkHandler) CreateLink(c *gin.Context) {
 	if req.ExpiresAt != nil && *req.ExpiresAt != "" {
 		expiresAt, err := time.Parse(time.RFC3339, *req.ExpiresAt)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiration date format"})
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid expiration date format")
 			return
 		}
 		link.ExpiresAt = &expiresAt
 	}
 
+	req.applyQuery(link)
+	if err := req.apply(link); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid start date format")
+		return
+	}
+
 	// Create link with optional custom short code
-	if err := h.linkService.CreateLink(link, req.ShortCode); err != nil {
+	if err := h.linkService.CreateLink(link, req.ShortCode, req.Password); err != nil {
 		logger.Errorf(ctx, "Failed to create link: %+v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondLinkError(c, err)
 		return
 	}
 
-	response := &LinkResponse{
-		Link:     link,
-		ShortURL: h.config.Server.BaseURL + "/" + link.ShortCode,
-	}
+	response := h.newLinkResponse(link)
 
 	logger.Infof(ctx, "Successfully created link with ID: %d, short code: %s", link.ID, link.ShortCode)
 	c.JSON(http.StatusCreated, response)
@@ -110,35 +243,38 @@ func (h *LinkHandler) CreateLink(c *gin.Context) {
 func (h *LinkHandler) GetLink(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	linkID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid link ID")
 		return
 	}
 
 	link, err := h.linkService.GetLink(linkID, userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+		respondLinkError(c, err)
 		return
 	}
 
-	response := &LinkResponse{
-		Link:     link,
-		ShortURL: h.config.Server.BaseURL + "/" + link.ShortCode,
-	}
+	response := h.newLinkResponse(link)
 
 	c.JSON(http.StatusOK, response)
 }
 
-// ListLinks retrieves all links for the current user
+// ListLinks retrieves all links in the current organization
 func (h *LinkHandler) ListLinks(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
 		return
 	}
 
@@ -146,25 +282,28 @@ func (h *LinkHandler) ListLinks(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 	search := c.Query("search")
+	status := c.Query("status")
 	sortBy := c.DefaultQuery("sort", "created_desc")
 
+	if status != "" && !models.IsLinkState(status) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid status")
+		return
+	}
+
 	if limit > 100 {
 		limit = 100
 	}
 
-	links, total, err := h.linkService.ListLinks(userID, limit, offset, search, sortBy)
+	links, total, err := h.linkService.ListLinks(userID, orgID, limit, offset, search, status, sortBy)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve links"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve links")
 		return
 	}
 
 	// Convert to response format with short URLs
 	responses := make([]*LinkResponse, len(links))
 	for i, link := range links {
-		responses[i] = &LinkResponse{
-			Link:     link,
-			ShortURL: h.config.Server.BaseURL + "/" + link.ShortCode,
-		}
+		responses[i] = h.newLinkResponse(link)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -179,25 +318,28 @@ func (h *LinkHandler) ListLinks(c *gin.Context) {
 func (h *LinkHandler) UpdateLink(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	linkID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid link ID")
 		return
 	}
 
 	var req UpdateLinkRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalidRequest(c, err)
 		return
 	}
 
 	link := &models.Link{
 		ID:             linkID,
 		DestinationURL: req.DestinationURL,
+		RedirectRules:  req.RedirectRules,
+		Variants:       req.Variants,
+		DeepLink:       req.DeepLink,
 		Tags:           req.Tags,
 	}
 
@@ -209,28 +351,31 @@ func (h *LinkHandler) UpdateLink(c *gin.Context) {
 	if req.ExpiresAt != nil && *req.ExpiresAt != "" {
 		expiresAt, err := time.Parse(time.RFC3339, *req.ExpiresAt)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiration date format"})
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid expiration date format")
 			return
 		}
 		link.ExpiresAt = &expiresAt
 	}
 
-	if err := h.linkService.UpdateLink(link, userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	req.applyQuery(link)
+	if err := req.apply(link); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid start date format")
+		return
+	}
+
+	if err := h.linkService.UpdateLink(link, userID, req.Password, req.QueryPassthrough); err != nil {
+		respondLinkError(c, err)
 		return
 	}
 
 	// Get updated link
 	updatedLink, err := h.linkService.GetLink(linkID, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve updated link"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve updated link")
 		return
 	}
 
-	response := &LinkResponse{
-		Link:     updatedLink,
-		ShortURL: h.config.Server.BaseURL + "/" + updatedLink.ShortCode,
-	}
+	response := h.newLinkResponse(updatedLink)
 
 	c.JSON(http.StatusOK, response)
 }
@@ -239,97 +384,416 @@ func (h *LinkHandler) UpdateLink(c *gin.Context) {
 func (h *LinkHandler) DeleteLink(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	linkID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid link ID")
 		return
 	}
 
 	if err := h.linkService.DeleteLink(linkID, userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondLinkError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Link deleted successfully"})
 }
 
+// ArchiveLink archives a link, freeing its slot under the link limit
+func (h *LinkHandler) ArchiveLink(c *gin.Context) {
+	h.setArchived(c, h.linkService.ArchiveLink)
+}
+
+// UnarchiveLink makes an archived link redirect again
+func (h *LinkHandler) UnarchiveLink(c *gin.Context) {
+	h.setArchived(c, h.linkService.UnarchiveLink)
+}
+
+func (h *LinkHandler) setArchived(c *gin.Context, apply func(linkID, userID int64) (*models.Link, error)) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	linkID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid link ID")
+		return
+	}
+
+	link, err := apply(linkID, userID)
+	if err != nil {
+		respondLinkError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.newLinkResponse(link))
+}
+
 // GetLinkStats retrieves analytics for a link
 func (h *LinkHandler) GetLinkStats(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	linkID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid link ID")
 		return
 	}
 
 	// Verify ownership
-	_, err = h.linkService.GetLink(linkID, userID)
+	link, err := h.linkService.GetLink(linkID, userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+		respondLinkError(c, err)
 		return
 	}
 
 	stats, err := h.tracker.GetStats(linkID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stats"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve stats")
 		return
 	}
 
-	c.JSON(http.StatusOK, stats)
+	c.JSON(http.StatusOK, LinkStatsResponse{
+		ClickStats:      stats,
+		State:           link.State(time.Now()),
+		RedirectCount:   link.RedirectCount,
+		MaxClicks:       link.MaxClicks,
+		RemainingClicks: link.RemainingClicks(),
+	})
 }
 
-// GetUserTags retrieves all tags for the current user
+// GetUserTags retrieves all tags in the current organization
 func (h *LinkHandler) GetUserTags(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
 		return
 	}
 
-	tags, err := h.linkService.GetUserTags(userID)
+	tags, err := h.linkService.GetTags(userID, orgID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve tags")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"tags": tags})
 }
 
-// Redirect handles short code redirection
+// Redirect handles short code redirection. Password-protected links serve
+// an unlock form until the visitor has unlocked them; crawlers get their
+// preview without the destination instead.
 func (h *LinkHandler) Redirect(c *gin.Context) {
 	ctx := middleware.GetContext(c)
+
+	link, ok := h.resolveLink(c)
+	if !ok {
+		return
+	}
+
+	locked := link.IsPasswordProtected() && !h.isUnlocked(c, link)
+	if visitor := routing.NewVisitor(c.Request, h.config.Geo.CountryHeader); visitor.Crawler {
+		h.serveCrawler(c, link, visitor, http.StatusFound, locked)
+		return
+	}
+	if locked {
+		logger.Infof(ctx, "Serving unlock form for link ID: %d", link.ID)
+		renderUnlockPage(c, http.StatusOK, "")
+		return
+	}
+
+	h.redirect(c, link, http.StatusFound)
+}
+
+// Unlock checks the password posted from the unlock form, then remembers
+// the unlock in a short-lived signed cookie and redirects
+func (h *LinkHandler) Unlock(c *gin.Context) {
+	ctx := middleware.GetContext(c)
+
+	link, ok := h.resolveLink(c)
+	if !ok {
+		return
+	}
+	if !link.IsPasswordProtected() {
+		h.redirect(c, link, http.StatusSeeOther)
+		return
+	}
+
+	err := h.linkService.UnlockLink(ctx, link, c.ClientIP(), c.PostForm("password"))
+	switch {
+	case errors.Is(err, service.ErrTooManyUnlockAttempts):
+		logger.Warnf(ctx, "Too many unlock attempts for link ID: %d", link.ID)
+		renderUnlockPage(c, http.StatusTooManyRequests, "Too many attempts, try again later")
+		return
+	case errors.Is(err, service.ErrIncorrectLinkPassword):
+		if err := h.tracker.TrackFailedUnlock(link); err != nil {
+			logger.Warnf(ctx, "Failed unlock not tracked for link ID %d: %v", link.ID, err)
+		}
+		renderUnlockPage(c, http.StatusUnauthorized, "Incorrect password")
+		return
+	case err != nil:
+		respondInternalError(c, err)
+		return
+	}
+
+	ttl := time.Duration(h.config.Unlock.TTLMinutes) * time.Minute
+	value := auth.SignLinkUnlock(h.config.JWT.Secret, link.ID, link.PasswordHash, time.Now().Add(ttl))
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(auth.LinkUnlockCookie(link.ID), value, int(ttl.Seconds()), "/"+link.ShortCode, "", isSecure(c), true)
+
+	h.redirect(c, link, http.StatusSeeOther)
+}
+
+// resolveLink finds the link for the request's host and short code. It
+// writes the error response and reports false when there is nothing to
+// redirect to.
+func (h *LinkHandler) resolveLink(c *gin.Context) (*models.Link, bool) {
+	ctx := middleware.GetContext(c)
 	shortCode := c.Param("code")
 
-	logger.Infof(ctx, "Redirect request for short code: %s", shortCode)
+	logger.Infof(ctx, "Redirect request for short code: %s on host: %s", shortCode, c.Request.Host)
 
-	link, err := h.linkService.GetLinkByShortCode(shortCode)
+	var domainID *int64
+	domain, ok, err := h.domainService.ResolveHost(c.Request.Host)
 	if err != nil {
+		logger.Errorf(ctx, "Failed to resolve host %s: %+v", c.Request.Host, err)
+		apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Service temporarily unavailable")
+		return nil, false
+	}
+	if !ok {
+		logger.Warnf(ctx, "Redirect requested on unverified domain: %s", c.Request.Host)
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeLinkNotFound, "Link not found")
+		return nil, false
+	}
+	if domain != nil {
+		domainID = &domain.ID
+	}
+
+	link, err := h.linkService.GetLinkByShortCode(domainID, shortCode)
+	if errors.Is(err, cache.ErrNotFound) {
 		logger.Warnf(ctx, "Link not found with short code: %s", shortCode)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeLinkNotFound, "Link not found")
+		return nil, false
+	}
+	if err != nil {
+		logger.Errorf(ctx, "Failed to look up short code %s: %+v", shortCode, err)
+		apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Service temporarily unavailable")
+		return nil, false
+	}
+
+	if !h.checkLinkState(c, link) {
+		return nil, false
+	}
+	return link, true
+}
+
+// checkLinkState writes the response for a link that is archived, flagged,
+// not started, expired or out of clicks, and reports whether the link can
+// be visited
+func (h *LinkHandler) checkLinkState(c *gin.Context, link *models.Link) bool {
+	ctx := middleware.GetContext(c)
+	shortCode := link.ShortCode
+
+	switch state := link.State(time.Now()); state {
+	case models.LinkStateArchived:
+		// Archived links keep their short code but don't redirect
+		logger.Warnf(ctx, "Link archived: short code %s, link ID: %d", shortCode, link.ID)
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeLinkArchived, "Link is archived")
+		return false
+	case models.LinkStateFlagged:
+		// Flagged links stay with their owners but don't send anyone on
+		logger.Warnf(ctx, "Link flagged: short code %s, link ID: %d (%s)", shortCode, link.ID, link.FlagReason)
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeLinkFlagged, "Link has been disabled because its destination was flagged as unsafe")
+		return false
+	case models.LinkStateScheduled:
+		logger.Warnf(ctx, "Link not started: short code %s, link ID: %d", shortCode, link.ID)
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeLinkNotStarted, "Link is not active yet")
+		return false
+	case models.LinkStateExpired, models.LinkStateExhausted:
+		h.respondInactive(c, link, state)
+		return false
+	}
+	return true
+}
+
+// respondInactive answers for a link that has expired or used up its clicks,
+// sending visitors to its fallback URL when it has one
+func (h *LinkHandler) respondInactive(c *gin.Context, link *models.Link, state models.LinkState) {
+	ctx := middleware.GetContext(c)
+	logger.Warnf(ctx, "Link %s: short code %s, link ID: %d", state, link.ShortCode, link.ID)
+
+	if link.FallbackURL != nil {
+		c.Redirect(http.StatusFound, *link.FallbackURL)
+		return
+	}
+	if state == models.LinkStateExhausted {
+		apierror.Respond(c, http.StatusGone, apierror.CodeLinkExhausted, "Link has reached its click limit")
 		return
 	}
+	apierror.Respond(c, http.StatusGone, apierror.CodeLinkExpired, "Link has expired")
+}
+
+// isSecure reports whether the visitor reached the app over HTTPS, directly
+// or through a proxy, so cookies for short links can be marked Secure
+func isSecure(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+// isUnlocked checks for a valid unlock cookie for a protected link
+func (h *LinkHandler) isUnlocked(c *gin.Context, link *models.Link) bool {
+	value, err := c.Cookie(auth.LinkUnlockCookie(link.ID))
+	if err != nil {
+		return false
+	}
+	return auth.VerifyLinkUnlock(h.config.JWT.Secret, link.ID, link.PasswordHash, value, time.Now())
+}
 
-	// Check if link has expired
-	if link.IsExpired() {
-		logger.Warnf(ctx, "Link expired: short code %s, link ID: %d", shortCode, link.ID)
-		c.JSON(http.StatusGone, gin.H{"error": "Link has expired"})
+// redirect counts the redirect against the link's click limit, routes the
+// visitor by the link's redirect rules, tracks the click and sends the
+// visitor on. Visitors on a platform the link has an app for are served the
+// deep link page first; the click is tracked when the page reports back.
+func (h *LinkHandler) redirect(c *gin.Context, link *models.Link, status int) {
+	ctx := middleware.GetContext(c)
+
+	visitor := routing.NewVisitor(c.Request, h.config.Geo.CountryHeader)
+	if visitor.Crawler {
+		h.serveCrawler(c, link, visitor, status, false)
 		return
 	}
 
-	logger.Infof(ctx, "Redirecting short code %s to: %s (link ID: %d)", shortCode, link.DestinationURL, link.ID)
+	appURI, storeURL := link.DeepLink.ForPlatform(visitor.Platform)
+	step := c.Query(deepLinkStepParam)
+	// The step is for this handler, not the destination
+	visitor.Query.Del(deepLinkStepParam)
+	if appURI != "" && step == "" {
+		logger.Infof(ctx, "Serving deep link page for link ID: %d on %s", link.ID, visitor.Platform)
+		renderDeepLinkPage(c, appURI, deepLinkStepURL(c, deepLinkStepOpened), deepLinkStepURL(c, deepLinkStepFallback))
+		return
+	}
 
-	// Track click asynchronously (don't block redirect)
-	go h.tracker.TrackClick(link.ID, c.ClientIP(), c.Request)
+	ok, err := h.linkService.ConsumeRedirect(ctx, link)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if !ok {
+		h.respondInactive(c, link, models.LinkStateExhausted)
+		return
+	}
+
+	if variant, err := c.Cookie(routing.VariantCookie(link.ID)); err == nil {
+		visitor.Variant = variant
+	}
+	route := routing.Resolve(link, visitor, time.Now())
+	if appURI != "" {
+		switch {
+		case step == deepLinkStepOpened:
+			route.DeepLinkPath = models.DeepLinkPathApp
+		case storeURL != "":
+			route.DeepLinkPath = models.DeepLinkPathStore
+			route.DestinationURL = storeURL
+		default:
+			route.DeepLinkPath = models.DeepLinkPathWeb
+		}
+	}
+
+	switch {
+	case route.DeepLinkPath == models.DeepLinkPathApp:
+		logger.Infof(ctx, "Short code %s opened in the app (link ID: %d)", link.ShortCode, link.ID)
+	case route.Rule != "":
+		logger.Infof(ctx, "Redirecting short code %s to: %s by rule %s (link ID: %d)", link.ShortCode, route.DestinationURL, route.Rule, link.ID)
+	case route.Variant != "":
+		logger.Infof(ctx, "Redirecting short code %s to: %s as variant %s (link ID: %d)", link.ShortCode, route.DestinationURL, route.Variant, link.ID)
+	default:
+		logger.Infof(ctx, "Redirecting short code %s to: %s (link ID: %d)", link.ShortCode, route.DestinationURL, link.ID)
+	}
+
+	// Keep repeat visitors on the variant they first saw
+	if route.Variant != "" && route.Variant != visitor.Variant {
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(routing.VariantCookie(link.ID), route.Variant, int(routing.VariantCookieMaxAge.Seconds()), "/"+link.ShortCode, "", isSecure(c), true)
+	}
+
+	// Queue the click for batched persistence (don't block redirect)
+	if err := h.tracker.TrackClick(link, c.ClientIP(), c.Request, route); err != nil {
+		logger.Warnf(ctx, "Click not tracked for link ID %d: %v", link.ID, err)
+	}
+
+	// The app already opened; the page only reported it
+	if route.DeepLinkPath == models.DeepLinkPathApp {
+		c.Status(http.StatusNoContent)
+		return
+	}
 
 	// Perform redirect
-	c.Redirect(http.StatusFound, link.DestinationURL)
+	c.Redirect(status, route.DestinationURL)
+}
+
+// serveCrawler answers a link preview crawler with the link's preview page,
+// or redirects it when the link has no preview so it reads the
+// destination's own tags. Crawlers get no further than visitors: links that
+// can't be visited get the visitor's response, and locked links or links
+// out of clicks only give away their preview, without the destination. The
+// hit is tracked as a bot click and doesn't count against the click limit.
+func (h *LinkHandler) serveCrawler(c *gin.Context, link *models.Link, visitor *routing.Visitor, status int, locked bool) {
+	ctx := middleware.GetContext(c)
+
+	if !h.checkLinkState(c, link) {
+		return
+	}
+	// The cached link may not have seen the last clicks
+	left, err := h.linkService.HasRedirectsLeft(link)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	route := routing.Resolve(link, visitor, time.Now())
+	if err := h.tracker.TrackClick(link, c.ClientIP(), c.Request, route); err != nil {
+		logger.Warnf(ctx, "Bot click not tracked for link ID %d: %v", link.ID, err)
+	}
+
+	if locked || !left {
+		if link.Preview.IsZero() {
+			if locked {
+				renderUnlockPage(c, http.StatusOK, "")
+			} else {
+				h.respondInactive(c, link, models.LinkStateExhausted)
+			}
+			return
+		}
+		logger.Infof(ctx, "Serving preview page without destination to crawler for link ID: %d", link.ID)
+		renderLinkPreviewPage(c, link, h.shortURL(link), "")
+		return
+	}
+
+	if link.Preview.IsZero() {
+		logger.Infof(ctx, "Redirecting crawler for short code %s to: %s (link ID: %d)", link.ShortCode, route.DestinationURL, link.ID)
+		c.Redirect(status, route.DestinationURL)
+		return
+	}
+
+	logger.Infof(ctx, "Serving preview page to crawler for link ID: %d", link.ID)
+	renderLinkPreviewPage(c, link, h.shortURL(link), route.DestinationURL)
+}
+
+// deepLinkStepURL returns the short URL the deep link page calls back with
+// step, keeping the original query so redirect rules still match
+func deepLinkStepURL(c *gin.Context, step string) string {
+	query := c.Request.URL.Query()
+	query.Set(deepLinkStepParam, step)
+	return c.Request.URL.Path + "?" + query.Encode()
 }
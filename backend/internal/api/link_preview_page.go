@@ -0,0 +1,79 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// linkPreviewPage is served to link preview crawlers instead of a redirect,
+// carrying the link's Open Graph and Twitter card tags. Anyone else who gets
+// it is sent on to the destination, when the page has one.
+var linkPreviewPage = template.Must(template.New("link_preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<meta property="og:type" content="website">
+<meta property="og:url" content="{{.ShortURL}}">
+<meta property="og:title" content="{{.Title}}">
+<meta name="twitter:title" content="{{.Title}}">
+{{- if .Description}}
+<meta name="description" content="{{.Description}}">
+<meta property="og:description" content="{{.Description}}">
+<meta name="twitter:description" content="{{.Description}}">
+{{- end}}
+{{- if .ImageURL}}
+<meta property="og:image" content="{{.ImageURL}}">
+<meta name="twitter:image" content="{{.ImageURL}}">
+<meta name="twitter:card" content="summary_large_image">
+{{- else}}
+<meta name="twitter:card" content="summary">
+{{- end}}
+{{- if .DestinationURL}}
+<meta http-equiv="refresh" content="0; url={{.DestinationURL}}">
+{{- end}}
+</head>
+<body>
+{{- if .DestinationURL}}
+<a href="{{.DestinationURL}}">{{.Title}}</a>
+{{- else}}
+<p>{{.Title}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// renderLinkPreviewPage writes the preview page for a link. The title
+// falls back to the link's own title, then to the short URL. An empty
+// destinationURL leaves the destination out of the page.
+func renderLinkPreviewPage(c *gin.Context, link *models.Link, shortURL, destinationURL string) {
+	data := struct {
+		Title          string
+		Description    string
+		ImageURL       string
+		ShortURL       string
+		DestinationURL string
+	}{
+		Title:          link.Preview.Title,
+		Description:    link.Preview.Description,
+		ImageURL:       link.Preview.ImageURL,
+		ShortURL:       shortURL,
+		DestinationURL: destinationURL,
+	}
+	if data.Title == "" && link.Title != nil {
+		data.Title = *link.Title
+	}
+	if data.Title == "" {
+		data.Title = shortURL
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := linkPreviewPage.Execute(c.Writer, data); err != nil {
+		c.Error(err)
+	}
+}
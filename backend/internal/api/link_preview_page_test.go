@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// previewPage renders the link's preview page and returns the HTML
+func previewPage(link *models.Link, destinationURL string) string {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/sale", nil)
+	renderLinkPreviewPage(c, link, "https://sho.rt/sale", destinationURL)
+	return w.Body.String()
+}
+
+func TestRenderLinkPreviewPage(t *testing.T) {
+	title := "Link title"
+
+	tests := []struct {
+		name        string
+		link        *models.Link
+		destination string
+		want        []string
+		wantMissing []string
+	}{
+		{
+			name: "every tag",
+			link: &models.Link{Title: &title, Preview: &models.LinkPreview{
+				Title: "Spring sale", Description: "Half price", ImageURL: "https://cdn.example.com/card.png",
+			}},
+			destination: "https://example.com/sale",
+			want: []string{
+				`<meta property="og:title" content="Spring sale">`,
+				`<meta name="twitter:description" content="Half price">`,
+				`<meta property="og:image" content="https://cdn.example.com/card.png">`,
+				`<meta name="twitter:card" content="summary_large_image">`,
+				`<meta property="og:url" content="https://sho.rt/sale">`,
+				`<a href="https://example.com/sale">`,
+			},
+		},
+		{
+			name:        "falls back to the link title",
+			link:        &models.Link{Title: &title, Preview: &models.LinkPreview{Description: "Half price"}},
+			destination: "https://example.com/sale",
+			want:        []string{`<title>Link title</title>`, `<meta name="twitter:card" content="summary">`},
+			wantMissing: []string{`og:image`},
+		},
+		{
+			name:        "falls back to the short URL",
+			link:        &models.Link{Preview: &models.LinkPreview{ImageURL: "https://cdn.example.com/card.png"}},
+			destination: "https://example.com/sale",
+			want:        []string{`<title>https://sho.rt/sale</title>`},
+			wantMissing: []string{`og:description`},
+		},
+		{
+			name:        "without the destination",
+			link:        &models.Link{Preview: &models.LinkPreview{Title: "Members only"}},
+			want:        []string{`<p>Members only</p>`},
+			wantMissing: []string{`http-equiv="refresh"`, `<a href`},
+		},
+		{
+			name:        "text is escaped",
+			link:        &models.Link{Preview: &models.LinkPreview{Title: `"><script>alert(1)</script>`}},
+			destination: "https://example.com/sale",
+			want:        []string{`content="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;"`},
+			wantMissing: []string{`<script>`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := previewPage(tt.link, tt.destination)
+			for _, want := range tt.want {
+				if !strings.Contains(page, want) {
+					t.Errorf("page doesn't contain %s", want)
+				}
+			}
+			for _, missing := range tt.wantMissing {
+				if strings.Contains(page, missing) {
+					t.Errorf("page contains %s", missing)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/service"
+)
+
+type OrganizationHandler struct {
+	orgService *service.OrganizationService
+}
+
+func NewOrganizationHandler(orgService *service.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+	}
+}
+
+type OrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type InvitationRequest struct {
+	Email string                  `json:"email" binding:"required,email"`
+	Role  models.OrganizationRole `json:"role" binding:"required"`
+}
+
+type UpdateMemberRequest struct {
+	Role models.OrganizationRole `json:"role" binding:"required"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// parseOrganizationID reads the :id path parameter
+func parseOrganizationID(c *gin.Context) (int64, bool) {
+	orgID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid organization ID")
+		return 0, false
+	}
+	return orgID, true
+}
+
+// CreateOrganization creates a team organization
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	org, err := h.orgService.CreateOrganization(userID, req.Name)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// ListOrganizations lists the current user's organizations
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgs, err := h.orgService.ListOrganizations(userID)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
+
+// GetOrganization retrieves an organization and the caller's role in it
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	org, member, err := h.orgService.GetOrganization(orgID, userID)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization": org,
+		"role":         member.Role,
+	})
+}
+
+// UpdateOrganization renames an organization
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	org, err := h.orgService.UpdateOrganization(orgID, userID, req.Name)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// DeleteOrganization deletes a team organization
+func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.orgService.DeleteOrganization(orgID, userID); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+}
+
+// ListMembers lists an organization's members
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.orgService.ListMembers(orgID, userID)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// UpdateMember changes a member's role
+func (h *OrganizationHandler) UpdateMember(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	memberUserID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid user ID")
+		return
+	}
+
+	var req UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	if err := h.orgService.UpdateMemberRole(orgID, userID, memberUserID, req.Role); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member updated successfully"})
+}
+
+// RemoveMember removes a member from an organization
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	memberUserID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.orgService.RemoveMember(orgID, userID, memberUserID); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// CreateInvitation invites a user to an organization
+func (h *OrganizationHandler) CreateInvitation(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	var req InvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	invitation, token, err := h.orgService.CreateInvitation(orgID, userID, req.Email, req.Role)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"invitation": invitation,
+		"token":      token,
+	})
+}
+
+// ListInvitations lists an organization's pending invitations
+func (h *OrganizationHandler) ListInvitations(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.orgService.ListInvitations(orgID, userID)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// RevokeInvitation deletes a pending invitation
+func (h *OrganizationHandler) RevokeInvitation(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, ok := parseOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	invitationID, err := strconv.ParseInt(c.Param("invitationId"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid invitation ID")
+		return
+	}
+
+	if err := h.orgService.RevokeInvitation(orgID, userID, invitationID); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// AcceptInvitation joins the organization an invitation token belongs to
+func (h *OrganizationHandler) AcceptInvitation(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	org, err := h.orgService.AcceptInvitation(userID, req.Token)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// Route is an endpoint served under both JWT and API-key authentication
+type Route struct {
+	Method string
+	Path   string
+	// Scope is the API key scope the route requires. JWT requests carry the
+	// user's full permissions and are not checked.
+	Scope   string
+	Handler gin.HandlerFunc
+}
+
+// OrganizationRoutes lists the organization-scoped link, tag and analytics
+// routes. Both auth modes register this one table so they can't drift apart.
+func OrganizationRoutes(linkHandler *LinkHandler, analyticsHandler *AnalyticsHandler) []Route {
+	return []Route{
+		// Link routes
+		{http.MethodPost, "/links", models.ScopeLinksWrite, linkHandler.CreateLink},
+		{http.MethodGet, "/links", models.ScopeLinksRead, linkHandler.ListLinks},
+		{http.MethodGet, "/links/:id", models.ScopeLinksRead, linkHandler.GetLink},
+		{http.MethodPatch, "/links/:id", models.ScopeLinksWrite, linkHandler.UpdateLink},
+		{http.MethodDelete, "/links/:id", models.ScopeLinksWrite, linkHandler.DeleteLink},
+		{http.MethodPost, "/links/:id/archive", models.ScopeLinksWrite, linkHandler.ArchiveLink},
+		{http.MethodPost, "/links/:id/unarchive", models.ScopeLinksWrite, linkHandler.UnarchiveLink},
+		{http.MethodGet, "/links/:id/stats", models.ScopeAnalyticsRead, linkHandler.GetLinkStats},
+
+		// Tag routes
+		{http.MethodGet, "/tags", models.ScopeLinksRead, linkHandler.GetUserTags},
+
+		// Analytics routes
+		{http.MethodGet, "/analytics", models.ScopeAnalyticsRead, analyticsHandler.GetUserAnalytics},
+	}
+}
+
+// RegisterRoutes registers routes on group, enforcing each route's scope
+func RegisterRoutes(group gin.IRoutes, routes []Route) {
+	for _, route := range routes {
+		group.Handle(route.Method, route.Path, auth.RequireScope(route.Scope), route.Handler)
+	}
+}
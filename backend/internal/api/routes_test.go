@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+func TestOrganizationRoutesRequireKnownScopes(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, route := range OrganizationRoutes(nil, nil) {
+		endpoint := route.Method + " " + route.Path
+		if seen[endpoint] {
+			t.Errorf("%s is registered twice", endpoint)
+		}
+		seen[endpoint] = true
+
+		if !models.IsValidAPIKeyScope(route.Scope) {
+			t.Errorf("%s requires unknown scope %q", endpoint, route.Scope)
+		}
+		// Reads must not need write access
+		if route.Method == http.MethodGet && route.Scope == models.ScopeLinksWrite {
+			t.Errorf("%s requires %s", endpoint, route.Scope)
+		}
+	}
+}
+
+func TestRegisterRoutesEnforcesScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	routes := []Route{
+		{http.MethodGet, "/links", models.ScopeLinksRead, ok},
+		{http.MethodPost, "/links", models.ScopeLinksWrite, ok},
+	}
+
+	tests := []struct {
+		name       string
+		key        *models.APIKey // nil for requests authenticated with a JWT
+		method     string
+		wantStatus int
+	}{
+		{name: "read-only key reads", key: &models.APIKey{Scopes: models.StringList{models.ScopeLinksRead}}, method: http.MethodGet, wantStatus: http.StatusOK},
+		{name: "read-only key writes", key: &models.APIKey{Scopes: models.StringList{models.ScopeLinksRead}}, method: http.MethodPost, wantStatus: http.StatusForbidden},
+		{name: "full key writes", key: &models.APIKey{Scopes: models.APIKeyScopes}, method: http.MethodPost, wantStatus: http.StatusOK},
+		{name: "JWT writes", method: http.MethodPost, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			group := router.Group("/api/v1/api/orgs/:org_id", func(c *gin.Context) {
+				if tt.key != nil {
+					c.Set("api_key", tt.key)
+				}
+			})
+			RegisterRoutes(group, routes)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, "/api/v1/api/orgs/3/links", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/service"
+)
+
+type SessionHandler struct {
+	sessionService *service.SessionService
+}
+
+func NewSessionHandler(sessionService *service.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type SessionResponse struct {
+	*models.Session
+	Current bool `json:"current"`
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token
+func (h *SessionHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	pair, user, err := h.sessionService.Refresh(req.RefreshToken, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired refresh token")
+			return
+		}
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAuthResponse(pair, user))
+}
+
+// Logout revokes the current session
+func (h *SessionHandler) Logout(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	sessionID, hasSession := auth.GetSessionID(c)
+	if !exists || !hasSession {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.sessionService.Revoke(userID, sessionID); err != nil && !errors.Is(err, database.ErrSessionNotFound) {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Signed out"})
+}
+
+// ListSessions lists the current user's active sessions, marking the one
+// making the request
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+	currentID, _ := auth.GetSessionID(c)
+
+	sessions, err := h.sessionService.ListSessions(userID)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	responses := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		responses[i] = SessionResponse{Session: session, Current: session.ID == currentID}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": responses})
+}
+
+// RevokeSession signs out one of the current user's sessions
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid session ID")
+		return
+	}
+
+	if err := h.sessionService.Revoke(userID, sessionID); err != nil {
+		if errors.Is(err, database.ErrSessionNotFound) {
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeSessionNotFound, "Session not found")
+			return
+		}
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions signs out every session except the current one
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	sessionID, hasSession := auth.GetSessionID(c)
+	if !exists || !hasSession {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	revoked, err := h.sessionService.RevokeOthers(userID, sessionID)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
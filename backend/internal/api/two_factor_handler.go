@@ -0,0 +1,184 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/service"
+)
+
+type TwoFactorHandler struct {
+	twoFactorService *service.TwoFactorService
+	sessionService   *service.SessionService
+	userRepo         *database.UserRepository
+	jwtService       *auth.JWTService
+}
+
+func NewTwoFactorHandler(twoFactorService *service.TwoFactorService, sessionService *service.SessionService, userRepo *database.UserRepository, jwtService *auth.JWTService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		sessionService:   sessionService,
+		userRepo:         userRepo,
+		jwtService:       jwtService,
+	}
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorReauthRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// CompleteLogin exchanges a login challenge token and a TOTP or recovery
+// code for an access token
+func (h *TwoFactorHandler) CompleteLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	claims, err := h.jwtService.ValidateChallengeToken(req.ChallengeToken)
+	if err != nil {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired challenge, sign in again")
+		return
+	}
+
+	user, err := h.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired challenge, sign in again")
+		return
+	}
+
+	if err := h.twoFactorService.Verify(user, req.Code); err != nil {
+		if errors.Is(err, service.ErrInvalidTwoFactorCode) {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidTwoFactorCode, "Invalid two-factor code")
+			return
+		}
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	pair, err := h.sessionService.Start(user, clientInfo(c))
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAuthResponse(pair, user))
+}
+
+// GetStatus reports the current user's two-factor state
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	status, err := h.twoFactorService.Status(userID)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// BeginSetup starts enrollment, returning the secret and otpauth URI
+func (h *TwoFactorHandler) BeginSetup(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	setup, err := h.twoFactorService.BeginSetup(userID)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// ConfirmSetup enables two-factor with a code from the authenticator app.
+// The recovery codes are only returned here.
+func (h *TwoFactorHandler) ConfirmSetup(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	codes, err := h.twoFactorService.ConfirmSetup(userID, req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// Disable turns off two-factor after re-authentication
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req TwoFactorReauthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	if err := h.twoFactorService.Disable(userID, req.Password, req.Code); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after re-authentication
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req TwoFactorReauthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(userID, req.Password, req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"html/template"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unlockPage is the form served instead of a redirect for password-protected
+// links. It posts back to the short URL itself.
+var unlockPage = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; background: #f5f6f8; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+form { background: #fff; padding: 32px; border-radius: 8px; box-shadow: 0 1px 4px rgba(0,0,0,.1); width: 100%; max-width: 320px; }
+h1 { font-size: 18px; margin: 0 0 16px; }
+input { width: 100%; box-sizing: border-box; padding: 10px; margin-bottom: 12px; border: 1px solid #ccc; border-radius: 4px; font-size: 14px; }
+button { width: 100%; padding: 10px; border: 0; border-radius: 4px; background: #4f46e5; color: #fff; font-size: 14px; cursor: pointer; }
+.error { color: #b91c1c; font-size: 13px; margin: 0 0 12px; }
+</style>
+</head>
+<body>
+<form method="post">
+<h1>This link is password protected</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<input type="password" name="password" placeholder="Password" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// renderUnlockPage writes the unlock form with an optional error message
+func renderUnlockPage(c *gin.Context, status int, message string) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := unlockPage.Execute(c.Writer, struct{ Error string }{message}); err != nil {
+		c.Error(err)
+	}
+}
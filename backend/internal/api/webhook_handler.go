@@ -0,0 +1,303 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/service"
+)
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+type WebhookRequest struct {
+	URL         string   `json:"url" binding:"required,url"`
+	Description string   `json:"description,omitempty"`
+	Events      []string `json:"events" binding:"required,min=1"`
+	IsActive    *bool    `json:"is_active,omitempty"`
+}
+
+// WebhookSecretResponse is a webhook along with its signing secret, which
+// is only returned when the webhook is created or its secret rotated
+type WebhookSecretResponse struct {
+	*models.Webhook
+	Secret string `json:"secret"`
+}
+
+// CreateWebhook registers a new webhook endpoint
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	webhook := &models.Webhook{
+		UserID:         userID,
+		OrganizationID: orgID,
+		URL:            req.URL,
+		Events:         req.Events,
+	}
+	if req.Description != "" {
+		webhook.Description = &req.Description
+	}
+
+	if err := h.webhookService.CreateWebhook(webhook); err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, WebhookSecretResponse{Webhook: webhook, Secret: webhook.Secret})
+}
+
+// ListWebhooks lists the organization's webhooks
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	webhooks, err := h.webhookService.ListWebhooks(orgID, userID)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks":         webhooks,
+		"available_events": events.WebhookEvents,
+	})
+}
+
+// GetWebhook retrieves a single webhook
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	webhookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid webhook ID")
+		return
+	}
+
+	webhook, err := h.webhookService.GetWebhook(orgID, webhookID, userID)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook updates a webhook's URL, events or active status
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	webhookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid webhook ID")
+		return
+	}
+
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	webhook := &models.Webhook{
+		ID:       webhookID,
+		URL:      req.URL,
+		Events:   req.Events,
+		IsActive: true,
+	}
+	if req.Description != "" {
+		webhook.Description = &req.Description
+	}
+	if req.IsActive != nil {
+		webhook.IsActive = *req.IsActive
+	}
+
+	if err := h.webhookService.UpdateWebhook(orgID, webhook, userID); err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	updated, err := h.webhookService.GetWebhook(orgID, webhookID, userID)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// RotateSecret replaces a webhook's signing secret and returns the new one
+func (h *WebhookHandler) RotateSecret(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	webhookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid webhook ID")
+		return
+	}
+
+	webhook, err := h.webhookService.RotateSecret(orgID, webhookID, userID)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebhookSecretResponse{Webhook: webhook, Secret: webhook.Secret})
+}
+
+// DeleteWebhook deletes a webhook
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	webhookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid webhook ID")
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(orgID, webhookID, userID); err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// ListDeliveries returns the delivery log for a webhook
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	webhookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid webhook ID")
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit > 100 {
+		limit = 100
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(orgID, webhookID, userID, limit, offset)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// GetStats returns delivery statistics for the organization's webhooks
+func (h *WebhookHandler) GetStats(c *gin.Context) {
+	userID, exists := auth.GetUserID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	orgID, exists := authz.GetOrganizationID(c)
+	if !exists {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNotMember, "Organization required")
+		return
+	}
+
+	stats, err := h.webhookService.GetStats(orgID, userID)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,106 @@
+// Package apierror defines the machine-readable error codes returned by the
+// API. Every error body has the form {"error": "<message>", "code": "<code>"};
+// messages are for people and may change, codes are stable.
+package apierror
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Code identifies an error condition for API clients
+type Code string
+
+const (
+	// Authentication and access
+	CodeUnauthorized      Code = "unauthorized"
+	CodeInvalidToken      Code = "invalid_token"
+	CodeSessionRevoked    Code = "session_revoked"
+	CodeInvalidAPIKey     Code = "invalid_api_key"
+	CodeAPIKeyInactive    Code = "api_key_inactive"
+	CodeInsufficientScope Code = "insufficient_scope"
+	CodePlanRequired      Code = "plan_required"
+	CodeRateLimited       Code = "rate_limited"
+	CodeNotMember         Code = "not_member"
+	CodeForbidden         Code = "forbidden"
+
+	// Accounts
+	CodeUserNotFound Code = "user_not_found"
+	CodeEmailTaken   Code = "email_taken"
+
+	// Two-factor authentication
+	CodeInvalidCredentials     Code = "invalid_credentials"
+	CodeInvalidTwoFactorCode   Code = "invalid_two_factor_code"
+	CodeTooManyAttempts        Code = "too_many_attempts"
+	CodeTwoFactorEnabled       Code = "two_factor_enabled"
+	CodeTwoFactorNotEnabled    Code = "two_factor_not_enabled"
+	CodeTwoFactorSetupRequired Code = "two_factor_setup_required"
+
+	// Sessions
+	CodeSessionNotFound Code = "session_not_found"
+
+	// Email verification and password reset
+	CodeInvalidEmailToken    Code = "invalid_or_expired_token"
+	CodeEmailNotVerified     Code = "email_not_verified"
+	CodeEmailAlreadyVerified Code = "email_already_verified"
+	CodeTooManyEmails        Code = "too_many_emails"
+
+	// Billing
+	CodeBillingDisabled      Code = "billing_disabled"
+	CodeInvalidPlan          Code = "invalid_plan"
+	CodeAlreadySubscribed    Code = "already_subscribed"
+	CodeNoBillingAccount     Code = "no_billing_account"
+	CodeInvalidSignature     Code = "invalid_signature"
+	CodeCoverageLimitReached Code = "coverage_limit_reached"
+
+	// Requests
+	CodeInvalidRequest Code = "invalid_request"
+
+	// Links
+	CodeLinkNotFound      Code = "link_not_found"
+	CodeLinkLimitReached  Code = "link_limit_reached"
+	CodeInvalidShortCode  Code = "invalid_short_code"
+	CodeShortCodeReserved Code = "short_code_reserved"
+	CodeShortCodeTaken    Code = "short_code_taken"
+	CodeLinkArchived      Code = "link_archived"
+	CodeLinkExpired       Code = "link_expired"
+	CodeLinkExhausted     Code = "link_exhausted"
+	CodeLinkNotStarted    Code = "link_not_started"
+	CodeLinkFlagged       Code = "link_flagged"
+
+	// Organizations
+	CodeOrganizationNotFound Code = "organization_not_found"
+	CodeMemberNotFound       Code = "member_not_found"
+	CodeInvitationNotFound   Code = "invitation_not_found"
+	CodeInvalidInvitation    Code = "invalid_invitation"
+	CodeAlreadyMember        Code = "already_member"
+	CodeLastOwner            Code = "last_owner"
+	CodePersonalOrganization Code = "personal_organization"
+
+	// Domains
+	CodeDomainNotFound    Code = "domain_not_found"
+	CodeDomainNotVerified Code = "domain_not_verified"
+	CodeDomainTaken       Code = "domain_taken"
+	CodeDomainInUse       Code = "domain_in_use"
+
+	// Webhooks
+	CodeWebhookNotFound     Code = "webhook_not_found"
+	CodeWebhookLimitReached Code = "webhook_limit_reached"
+
+	// API keys
+	CodeAPIKeyNotFound     Code = "api_key_not_found"
+	CodeAPIKeyLimitReached Code = "api_key_limit_reached"
+
+	// Server
+	CodeInternal    Code = "internal_error"
+	CodeUnavailable Code = "service_unavailable"
+)
+
+// Respond writes an error response
+func Respond(c *gin.Context, status int, code Code, message string) {
+	c.JSON(status, gin.H{"error": message, "code": code})
+}
+
+// Abort writes an error response and stops the handler chain
+func Abort(c *gin.Context, status int, code Code, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"error": message, "code": code})
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// API keys look like sk_<12 hex chars>_<48 hex chars>. The first
+// apiKeyPrefixLength characters are stored in the clear to find the key;
+// the whole key is only ever stored as a SHA-256 hash. Keys issued before
+// named keys existed are 64 hex characters and are looked up the same way.
+const (
+	apiKeyTag          = "sk_"
+	apiKeyPrefixLength = 15
+)
+
+// GenerateAPIKey creates a new API key, returning the key to show the user
+// once, its lookup prefix and the hash to store
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", "", err
+	}
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+
+	key = apiKeyTag + hex.EncodeToString(id) + "_" + hex.EncodeToString(secret)
+	return key, key[:apiKeyPrefixLength], HashAPIKey(key), nil
+}
+
+// APIKeyPrefix returns the lookup prefix of a presented key
+func APIKeyPrefix(key string) (string, bool) {
+	if len(key) <= apiKeyPrefixLength {
+		return "", false
+	}
+	return key[:apiKeyPrefixLength], true
+}
+
+// HashAPIKey returns the hex SHA-256 of an API key. Keys carry enough
+// entropy that a fast hash is sufficient.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIKey compares a presented key with a stored hash in constant time
+func CheckAPIKey(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(hash)) == 1
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("creating mock database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	return db, mock
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey() error = %v", err)
+	}
+
+	if !regexp.MustCompile(`^sk_[0-9a-f]{12}_[0-9a-f]{48}$`).MatchString(key) {
+		t.Errorf("key = %q, want sk_<12 hex>_<48 hex>", key)
+	}
+	if got, ok := APIKeyPrefix(key); !ok || got != prefix {
+		t.Errorf("APIKeyPrefix(key) = %q, %v; want %q", got, ok, prefix)
+	}
+	if !CheckAPIKey(key, hash) {
+		t.Error("CheckAPIKey(key, hash) = false, want true")
+	}
+
+	other, _, _, _ := GenerateAPIKey()
+	if other == key {
+		t.Error("GenerateAPIKey() returned the same key twice")
+	}
+	if CheckAPIKey(other, hash) {
+		t.Error("CheckAPIKey(other key, hash) = true, want false")
+	}
+}
+
+func TestAPIKeyPrefixRejectsShortKeys(t *testing.T) {
+	for _, key := range []string{"", "sk_", "sk_0123456789a"} {
+		if _, ok := APIKeyPrefix(key); ok {
+			t.Errorf("APIKeyPrefix(%q) ok = true, want false", key)
+		}
+	}
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey() error = %v", err)
+	}
+	revokedAt := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name       string
+		header     string
+		hash       string     // stored hash, empty when no key has the prefix
+		revokedAt  *time.Time // when the stored key was revoked
+		wantStatus int
+		wantCode   apierror.Code
+	}{
+		{name: "valid key", header: key, hash: hash, wantStatus: http.StatusOK},
+		{name: "missing key", wantStatus: http.StatusUnauthorized, wantCode: apierror.CodeUnauthorized},
+		{name: "malformed key", header: "sk_123", wantStatus: http.StatusUnauthorized, wantCode: apierror.CodeInvalidAPIKey},
+		{name: "unknown prefix", header: key, wantStatus: http.StatusUnauthorized, wantCode: apierror.CodeInvalidAPIKey},
+		{name: "wrong secret", header: prefix + "_000000", hash: hash, wantStatus: http.StatusUnauthorized, wantCode: apierror.CodeInvalidAPIKey},
+		{name: "revoked key", header: key, hash: hash, revokedAt: &revokedAt, wantStatus: http.StatusUnauthorized, wantCode: apierror.CodeAPIKeyInactive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			if len(tt.header) > apiKeyPrefixLength {
+				rows := sqlmock.NewRows([]string{"id", "user_id", "prefix", "secret_hash", "scopes", "revoked_at"})
+				if tt.hash != "" {
+					rows.AddRow(5, 7, prefix, tt.hash, "{links:read}", tt.revokedAt)
+				}
+				mock.ExpectQuery(`SELECT \* FROM "api_keys" WHERE prefix = \$1`).
+					WithArgs(prefix, 1).
+					WillReturnRows(rows)
+			}
+			if tt.wantStatus == http.StatusOK {
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).
+					WithArgs(7, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "ada@example.com"))
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "api_keys" SET "last_used_at"=\$1`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			router := gin.New()
+			router.Use(APIKeyMiddleware(database.NewAPIKeyRepository(db), database.NewUserRepository(db)))
+			router.GET("/api/v1/links", func(c *gin.Context) {
+				userID, _ := GetUserID(c)
+				apiKey, _ := GetAPIKey(c)
+				c.JSON(http.StatusOK, gin.H{"user_id": userID, "key_id": apiKey.ID})
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/links", nil)
+			if tt.header != "" {
+				req.Header.Set("X-API-Key", tt.header)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+			var body struct {
+				Code   apierror.Code `json:"code"`
+				UserID int64         `json:"user_id"`
+				KeyID  int64         `json:"key_id"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if body.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", body.Code, tt.wantCode)
+			}
+			if tt.wantStatus == http.StatusOK && (body.UserID != 7 || body.KeyID != 5) {
+				t.Errorf("authenticated as user %d with key %d, want user 7 with key 5", body.UserID, body.KeyID)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		key        *models.APIKey // nil for requests authenticated with a JWT
+		wantStatus int
+	}{
+		{name: "key with the scope", key: &models.APIKey{Scopes: models.StringList{models.ScopeLinksRead, models.ScopeLinksWrite}}, wantStatus: http.StatusOK},
+		{name: "key without the scope", key: &models.APIKey{Scopes: models.StringList{models.ScopeLinksRead}}, wantStatus: http.StatusForbidden},
+		{name: "JWT", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.key != nil {
+					c.Set("api_key", tt.key)
+				}
+			})
+			router.POST("/api/v1/links", RequireScope(models.ScopeLinksWrite), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/links", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -12,9 +12,19 @@ import (
 type Claims struct {
 	UserID int64  `json:"user_id"`
 	Email  string `json:"email"`
+	// SessionID ties an access token to the session it was issued for, so
+	// revoking the session revokes the token
+	SessionID int64 `json:"sid,omitempty"`
+	// Purpose is empty for access tokens. Other tokens are only accepted by
+	// the endpoint they were issued for.
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// purposeTwoFactor marks a challenge token proving the password step of a
+// two-factor login
+const purposeTwoFactor = "2fa_challenge"
+
 type JWTService struct {
 	config *config.Config
 }
@@ -23,12 +33,14 @@ func NewJWTService(cfg *config.Config) *JWTService {
 	return &JWTService{config: cfg}
 }
 
-func (s *JWTService) GenerateToken(user *models.User) (string, error) {
-	expirationTime := time.Now().Add(time.Duration(s.config.JWT.ExpiryHours) * time.Hour)
+// GenerateToken issues a short-lived access token for a session
+func (s *JWTService) GenerateToken(user *models.User, sessionID int64) (string, error) {
+	expirationTime := time.Now().Add(s.AccessTokenTTL())
 
 	claims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -45,7 +57,60 @@ func (s *JWTService) GenerateToken(user *models.User) (string, error) {
 	return tokenString, nil
 }
 
+// AccessTokenTTL is the lifetime of access tokens
+func (s *JWTService) AccessTokenTTL() time.Duration {
+	return time.Duration(s.config.JWT.AccessTTLMinutes) * time.Minute
+}
+
+// GenerateChallengeToken issues a short-lived token that can only be
+// exchanged, together with a second factor, for an access token
+func (s *JWTService) GenerateChallengeToken(user *models.User) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:  user.ID,
+		Email:   user.Email,
+		Purpose: purposeTwoFactor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(s.config.TwoFactor.ChallengeTTLSeconds) * time.Second)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "url-shortener",
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(s.config.JWT.Secret))
+	if err != nil {
+		return "", fmt.Errorf("error signing token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// ValidateToken validates an access token
 func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" || claims.SessionID == 0 {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
+
+// ValidateChallengeToken validates a two-factor challenge token
+func (s *JWTService) ValidateChallengeToken(tokenString string) (*Claims, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purposeTwoFactor {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
+
+func (s *JWTService) parseToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
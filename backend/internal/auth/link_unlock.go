@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LinkUnlockCookie returns the name of the cookie that unlocks a
+// password-protected link
+func LinkUnlockCookie(linkID int64) string {
+	return "link_unlock_" + strconv.FormatInt(linkID, 10)
+}
+
+// SignLinkUnlock returns a cookie value proving the link's password was
+// entered, valid until expiresAt. The password hash is part of the
+// signature, so changing the password invalidates earlier unlocks.
+func SignLinkUnlock(secret string, linkID int64, passwordHash string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return expires + "." + linkUnlockSignature(secret, linkID, passwordHash, expires)
+}
+
+// VerifyLinkUnlock checks a cookie value made by SignLinkUnlock
+func VerifyLinkUnlock(secret string, linkID int64, passwordHash, value string, now time.Time) bool {
+	expires, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() >= expiresAt {
+		return false
+	}
+	expected := linkUnlockSignature(secret, linkID, passwordHash, expires)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+func linkUnlockSignature(secret string, linkID int64, passwordHash, expires string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(linkID, 10) + ":" + expires + ":" + passwordHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
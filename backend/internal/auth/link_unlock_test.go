@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifyLinkUnlock(t *testing.T) {
+	now := time.Now()
+	value := SignLinkUnlock("secret", 10, "hash", now.Add(time.Hour))
+
+	tests := []struct {
+		name         string
+		secret       string
+		linkID       int64
+		passwordHash string
+		value        string
+		now          time.Time
+		want         bool
+	}{
+		{name: "valid", secret: "secret", linkID: 10, passwordHash: "hash", value: value, now: now, want: true},
+		{name: "expired", secret: "secret", linkID: 10, passwordHash: "hash", value: value, now: now.Add(2 * time.Hour)},
+		{name: "another link", secret: "secret", linkID: 11, passwordHash: "hash", value: value, now: now},
+		{name: "password changed", secret: "secret", linkID: 10, passwordHash: "new hash", value: value, now: now},
+		{name: "another secret", secret: "rotated", linkID: 10, passwordHash: "hash", value: value, now: now},
+		{name: "extended expiry", secret: "secret", linkID: 10, passwordHash: "hash", value: "9999999999" + value[len("0000000000"):], now: now},
+		{name: "malformed", secret: "secret", linkID: 10, passwordHash: "hash", value: "unlocked", now: now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyLinkUnlock(tt.secret, tt.linkID, tt.passwordHash, tt.value, tt.now); got != tt.want {
+				t.Errorf("VerifyLinkUnlock() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,75 +1,158 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
 	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
 )
 
-// AuthMiddleware validates JWT tokens
-func AuthMiddleware(jwtService *JWTService) gin.HandlerFunc {
+// sessionTouchInterval limits how often a session's last-seen time is written
+const sessionTouchInterval = time.Minute
+
+// AuthMiddleware validates JWT access tokens and rejects those whose
+// session has been revoked or has expired
+func AuthMiddleware(jwtService *JWTService, sessionRepo *database.SessionRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Authorization header required")
 			return
 		}
 
 		// Extract token from "Bearer <token>"
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid authorization header format")
 			return
 		}
 
 		tokenString := parts[1]
 		claims, err := jwtService.ValidateToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired token")
+			return
+		}
+
+		active, err := checkSession(c, sessionRepo, claims)
+		if err != nil {
+			logger.Errorf(c.Request.Context(), "Failed to load session ID %d: %+v", claims.SessionID, err)
+			apierror.Abort(c, http.StatusInternalServerError, apierror.CodeInternal, "Internal server error")
+			return
+		}
+		if !active {
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeSessionRevoked, "Session has been revoked")
 			return
 		}
 
 		// Store user info in context
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
+		c.Set("session_id", claims.SessionID)
 
 		c.Next()
 	}
 }
 
-// APIKeyMiddleware validates API keys
-func APIKeyMiddleware(userRepo *database.UserRepository) gin.HandlerFunc {
+// checkSession reports whether the token's session is still active,
+// recording activity on it
+func checkSession(c *gin.Context, sessionRepo *database.SessionRepository, claims *Claims) (bool, error) {
+	session, err := sessionRepo.GetByID(claims.SessionID)
+	if errors.Is(err, database.ErrSessionNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if session.UserID != claims.UserID || !session.IsActive() {
+		return false, nil
+	}
+	if err := sessionRepo.TouchLastSeen(session.ID, time.Now(), sessionTouchInterval); err != nil {
+		logger.Warnf(c.Request.Context(), "Failed to record activity for session ID %d: %v", session.ID, err)
+	}
+	return true, nil
+}
+
+// apiKeyTouchInterval limits how often a key's last-used time is written
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyMiddleware authenticates requests by the X-API-Key header. The key
+// is found by its prefix and verified against the stored hash in constant
+// time.
+func APIKeyMiddleware(apiKeyRepo *database.APIKeyRepository, userRepo *database.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		apiKey := c.GetHeader("X-API-Key")
-		if apiKey == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "API key required"})
-			c.Abort()
+		presented := c.GetHeader("X-API-Key")
+		if presented == "" {
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "API key required")
+			return
+		}
+
+		prefix, ok := APIKeyPrefix(presented)
+		if !ok {
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "Invalid API key")
 			return
 		}
 
-		user, err := userRepo.GetByAPIKey(apiKey)
+		key, err := apiKeyRepo.GetByPrefix(prefix)
+		if err != nil || !CheckAPIKey(presented, key.SecretHash) {
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "Invalid API key")
+			return
+		}
+		if !key.IsActive() {
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeAPIKeyInactive, "API key has been revoked or has expired")
+			return
+		}
+
+		user, err := userRepo.GetByID(key.UserID)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
-			c.Abort()
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "Invalid API key")
 			return
 		}
 
+		if err := apiKeyRepo.TouchLastUsed(key.ID, time.Now(), apiKeyTouchInterval); err != nil {
+			logger.Warnf(c.Request.Context(), "Failed to record API key usage for key ID %d: %v", key.ID, err)
+		}
+
 		// Store user info in context
 		c.Set("user_id", user.ID)
 		c.Set("user_email", user.Email)
 		c.Set("user", user)
+		c.Set("api_key", key)
+
+		c.Next()
+	}
+}
 
+// RequireScope rejects API-key requests whose key lacks scope. Requests
+// authenticated with a JWT carry the user's full permissions and pass.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key, ok := GetAPIKey(c); ok && !key.HasScope(scope) {
+			apierror.Abort(c, http.StatusForbidden, apierror.CodeInsufficientScope, "API key is missing the "+scope+" scope")
+			return
+		}
 		c.Next()
 	}
 }
 
+// GetAPIKey retrieves the API key that authenticated the request, if any
+func GetAPIKey(c *gin.Context) (*models.APIKey, bool) {
+	value, exists := c.Get("api_key")
+	if !exists {
+		return nil, false
+	}
+	key, ok := value.(*models.APIKey)
+	return key, ok
+}
+
 // OptionalAuthMiddleware checks for JWT but doesn't require it
-func OptionalAuthMiddleware(jwtService *JWTService) gin.HandlerFunc {
+func OptionalAuthMiddleware(jwtService *JWTService, sessionRepo *database.SessionRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader != "" {
@@ -78,8 +161,11 @@ func OptionalAuthMiddleware(jwtService *JWTService) gin.HandlerFunc {
 				tokenString := parts[1]
 				claims, err := jwtService.ValidateToken(tokenString)
 				if err == nil {
-					c.Set("user_id", claims.UserID)
-					c.Set("user_email", claims.Email)
+					if active, _ := checkSession(c, sessionRepo, claims); active {
+						c.Set("user_id", claims.UserID)
+						c.Set("user_email", claims.Email)
+						c.Set("session_id", claims.SessionID)
+					}
 				}
 			}
 		}
@@ -97,6 +183,16 @@ func GetUserID(c *gin.Context) (int64, bool) {
 	return id, ok
 }
 
+// GetSessionID retrieves the session of a JWT-authenticated request
+func GetSessionID(c *gin.Context) (int64, bool) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		return 0, false
+	}
+	id, ok := sessionID.(int64)
+	return id, ok
+}
+
 // GetUserEmail retrieves user email from context
 func GetUserEmail(c *gin.Context) (string, bool) {
 	email, exists := c.Get("user_email")
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtService := NewJWTService(&config.Config{
+		JWT:       config.JWTConfig{Secret: "test-secret", AccessTTLMinutes: 15},
+		TwoFactor: config.TwoFactorConfig{ChallengeTTLSeconds: 300},
+	})
+	user := &models.User{ID: 7, Email: "ada@example.com"}
+	token, err := jwtService.GenerateToken(user, 4)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	challenge, err := jwtService.GenerateChallengeToken(user)
+	if err != nil {
+		t.Fatalf("GenerateChallengeToken() error = %v", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name          string
+		header        string
+		sessionUserID int64 // 0 when the session doesn't exist
+		expiresAt     time.Time
+		revokedAt     *time.Time
+		wantStatus    int
+		wantCode      apierror.Code
+	}{
+		{name: "active session", header: "Bearer " + token, sessionUserID: 7, expiresAt: future, wantStatus: http.StatusOK},
+		{name: "missing header", wantStatus: http.StatusUnauthorized, wantCode: apierror.CodeUnauthorized},
+		{name: "not a bearer token", header: "Token " + token, wantStatus: http.StatusUnauthorized, wantCode: apierror.CodeInvalidToken},
+		{name: "challenge token", header: "Bearer " + challenge, wantStatus: http.StatusUnauthorized, wantCode: apierror.CodeInvalidToken},
+		{name: "revoked session", header: "Bearer " + token, sessionUserID: 7, expiresAt: future, revokedAt: &past, wantStatus: http.StatusUnauthorized, wantCode: apierror.CodeSessionRevoked},
+		{name: "expired session", header: "Bearer " + token, sessionUserID: 7, expiresAt: past, wantStatus: http.StatusUnauthorized, wantCode: apierror.CodeSessionRevoked},
+		{name: "another user's session", header: "Bearer " + token, sessionUserID: 8, expiresAt: future, wantStatus: http.StatusUnauthorized, wantCode: apierror.CodeSessionRevoked},
+		{name: "deleted session", header: "Bearer " + token, wantStatus: http.StatusUnauthorized, wantCode: apierror.CodeSessionRevoked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			if tt.header == "Bearer "+token {
+				rows := sqlmock.NewRows([]string{"id", "user_id", "expires_at", "revoked_at"})
+				if tt.sessionUserID != 0 {
+					rows.AddRow(4, tt.sessionUserID, tt.expiresAt, tt.revokedAt)
+				}
+				mock.ExpectQuery(`SELECT \* FROM "sessions" WHERE "sessions"."id" = \$1`).
+					WithArgs(4, 1).
+					WillReturnRows(rows)
+			}
+			if tt.wantStatus == http.StatusOK {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "sessions" SET "last_seen_at"=\$1`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			router := gin.New()
+			router.Use(AuthMiddleware(jwtService, database.NewSessionRepository(db)))
+			router.GET("/api/v1/auth/me", func(c *gin.Context) {
+				sessionID, _ := GetSessionID(c)
+				c.JSON(http.StatusOK, gin.H{"session_id": sessionID})
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+			var body struct {
+				Code      apierror.Code `json:"code"`
+				SessionID int64         `json:"session_id"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if body.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", body.Code, tt.wantCode)
+			}
+			if tt.wantStatus == http.StatusOK && body.SessionID != 4 {
+				t.Errorf("session ID = %d, want 4", body.SessionID)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
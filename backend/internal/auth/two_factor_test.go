@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"encoding/base32"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// rfcSecret is the SHA-1 seed from the RFC 6238 test vectors
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	// The RFC lists 8-digit codes; these are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+
+	for _, tt := range tests {
+		if got := totpCode(key, tt.unix/totpPeriod); got != tt.want {
+			t.Errorf("code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := now.Unix() / totpPeriod
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current code", secret: rfcSecret, code: "081804", wantStep: step, wantOK: true},
+		{name: "surrounding spaces", secret: rfcSecret, code: " 081804 ", wantStep: step, wantOK: true},
+		{name: "lowercase secret", secret: strings.ToLower(rfcSecret), code: "081804", wantStep: step, wantOK: true},
+		{name: "previous step", secret: rfcSecret, code: totpCode(key, step-1), wantStep: step - 1, wantOK: true},
+		{name: "next step", secret: rfcSecret, code: totpCode(key, step+1), wantStep: step + 1, wantOK: true},
+		{name: "two steps old", secret: rfcSecret, code: totpCode(key, step-2)},
+		{name: "wrong length", secret: rfcSecret, code: "81804"},
+		{name: "invalid secret", secret: "not base32!", code: "081804"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := ValidateTOTP(tt.secret, tt.code, now)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("ValidateTOTP() = %d, %v; want %d, %v", gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != totpSecretSize {
+		t.Errorf("secret %q decodes to %d bytes (%v), want %d", secret, len(key), err, totpSecretSize)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Sho Rt", "ada@example.com", rfcSecret))
+	if err != nil {
+		t.Fatalf("parsing URI: %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Sho Rt:ada@example.com" {
+		t.Errorf("URI = %s, want otpauth://totp/Sho Rt:ada@example.com", uri)
+	}
+	if strings.Contains(uri.RawQuery, "+") {
+		t.Errorf("query %q encodes spaces as +", uri.RawQuery)
+	}
+	query := uri.Query()
+	if query.Get("secret") != rfcSecret || query.Get("issuer") != "Sho Rt" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("query = %v", query)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes and %d hashes, want %d", len(codes), len(hashes), RecoveryCodeCount)
+	}
+
+	format := regexp.MustCompile(`^[a-z2-9]{5}-[a-z2-9]{5}$`)
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q does not look like xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q issued twice", code)
+		}
+		seen[code] = true
+		if hashes[i] != HashRecoveryCode(code) {
+			t.Errorf("hash %d does not match its code", i)
+		}
+	}
+}
+
+func TestHashRecoveryCodeIgnoresFormatting(t *testing.T) {
+	want := HashRecoveryCode("abcde-fghjk")
+	for _, typed := range []string{"ABCDE-FGHJK", "abcdefghjk", " abcde fghjk "} {
+		if got := HashRecoveryCode(typed); got != want {
+			t.Errorf("HashRecoveryCode(%q) differs from the issued code's hash", typed)
+		}
+	}
+	if HashRecoveryCode("abcde-fghjm") == want {
+		t.Error("different codes have the same hash")
+	}
+}
+
+func TestChallengeTokensAreNotAccessTokens(t *testing.T) {
+	s := NewJWTService(&config.Config{
+		JWT:       config.JWTConfig{Secret: "test-secret", AccessTTLMinutes: 15},
+		TwoFactor: config.TwoFactorConfig{ChallengeTTLSeconds: 300},
+	})
+	user := &models.User{ID: 7, Email: "ada@example.com"}
+
+	challenge, err := s.GenerateChallengeToken(user)
+	if err != nil {
+		t.Fatalf("GenerateChallengeToken() error = %v", err)
+	}
+	if _, err := s.ValidateToken(challenge); err == nil {
+		t.Error("ValidateToken() accepted a challenge token")
+	}
+	if claims, err := s.ValidateChallengeToken(challenge); err != nil || claims.UserID != 7 {
+		t.Errorf("ValidateChallengeToken() = %v, %v; want user 7", claims, err)
+	}
+
+	access, err := s.GenerateToken(user, 42)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	if _, err := s.ValidateChallengeToken(access); err == nil {
+		t.Error("ValidateChallengeToken() accepted an access token")
+	}
+}
@@ -0,0 +1,90 @@
+package authz
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// Action is an operation that can be performed within an organization
+type Action string
+
+const (
+	ActionViewLinks          Action = "links:view"
+	ActionEditLinks          Action = "links:edit"
+	ActionViewAnalytics      Action = "analytics:view"
+	ActionViewMembers        Action = "members:view"
+	ActionManageMembers      Action = "members:manage"
+	ActionManageOrganization Action = "organization:manage"
+	ActionDeleteOrganization Action = "organization:delete"
+	ActionManageBilling      Action = "billing:manage"
+	ActionManageDomains      Action = "domains:manage"
+	ActionManageWebhooks     Action = "webhooks:manage"
+)
+
+// minimumRole is the least privileged role allowed to perform each action
+var minimumRole = map[Action]models.OrganizationRole{
+	ActionViewLinks:          models.RoleViewer,
+	ActionEditLinks:          models.RoleEditor,
+	ActionViewAnalytics:      models.RoleViewer,
+	ActionViewMembers:        models.RoleViewer,
+	ActionManageMembers:      models.RoleAdmin,
+	ActionManageOrganization: models.RoleAdmin,
+	ActionDeleteOrganization: models.RoleOwner,
+	ActionManageBilling:      models.RoleOwner,
+	ActionManageDomains:      models.RoleAdmin,
+	ActionManageWebhooks:     models.RoleAdmin,
+}
+
+var (
+	// ErrNotMember is returned when the user does not belong to the organization
+	ErrNotMember = errors.New("not a member of this organization")
+	// ErrForbidden is returned when the user's role does not permit the action
+	ErrForbidden = errors.New("insufficient permissions")
+)
+
+// Can checks if a role is permitted to perform an action
+func Can(role models.OrganizationRole, action Action) bool {
+	required, ok := minimumRole[action]
+	if !ok {
+		return false
+	}
+	return role.AtLeast(required)
+}
+
+// Authorizer is the single place where access to organization resources is decided
+type Authorizer struct {
+	orgRepo *database.OrganizationRepository
+}
+
+func NewAuthorizer(orgRepo *database.OrganizationRepository) *Authorizer {
+	return &Authorizer{orgRepo: orgRepo}
+}
+
+// Authorize checks that the user may perform the action in the organization
+// and returns their membership
+func (a *Authorizer) Authorize(userID, orgID int64, action Action) (*models.OrganizationMember, error) {
+	ctx := context.Background()
+
+	member, err := a.orgRepo.GetMember(orgID, userID)
+	if err != nil {
+		logger.Warnf(ctx, "User ID %d is not a member of organization ID %d", userID, orgID)
+		return nil, ErrNotMember
+	}
+
+	if !Can(member.Role, action) {
+		logger.Warnf(ctx, "User ID %d (%s) denied %s in organization ID %d", userID, member.Role, action, orgID)
+		return nil, ErrForbidden
+	}
+
+	return member, nil
+}
+
+// AuthorizeLink checks that the user may perform the action on a link
+func (a *Authorizer) AuthorizeLink(userID int64, link *models.Link, action Action) error {
+	_, err := a.Authorize(userID, link.OrganizationID, action)
+	return err
+}
@@ -0,0 +1,97 @@
+package authz
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("creating mock database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	return db, mock
+}
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		role   models.OrganizationRole
+		action Action
+		want   bool
+	}{
+		{role: models.RoleViewer, action: ActionViewLinks, want: true},
+		{role: models.RoleViewer, action: ActionViewAnalytics, want: true},
+		{role: models.RoleViewer, action: ActionEditLinks, want: false},
+		{role: models.RoleEditor, action: ActionEditLinks, want: true},
+		{role: models.RoleEditor, action: ActionManageMembers, want: false},
+		{role: models.RoleEditor, action: ActionManageDomains, want: false},
+		{role: models.RoleAdmin, action: ActionManageMembers, want: true},
+		{role: models.RoleAdmin, action: ActionManageWebhooks, want: true},
+		{role: models.RoleAdmin, action: ActionDeleteOrganization, want: false},
+		{role: models.RoleAdmin, action: ActionManageBilling, want: false},
+		{role: models.RoleOwner, action: ActionDeleteOrganization, want: true},
+		{role: models.RoleOwner, action: ActionManageBilling, want: true},
+		{role: models.RoleOwner, action: Action("links:launch"), want: false},
+		{role: models.OrganizationRole("guest"), action: ActionViewLinks, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.role)+" "+string(tt.action), func(t *testing.T) {
+			if got := Can(tt.role, tt.action); got != tt.want {
+				t.Errorf("Can(%s, %s) = %v, want %v", tt.role, tt.action, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    models.OrganizationRole // empty when the user isn't a member
+		action  Action
+		wantErr error
+	}{
+		{name: "editor edits links", role: models.RoleEditor, action: ActionEditLinks},
+		{name: "viewer edits links", role: models.RoleViewer, action: ActionEditLinks, wantErr: ErrForbidden},
+		{name: "admin deletes the organization", role: models.RoleAdmin, action: ActionDeleteOrganization, wantErr: ErrForbidden},
+		{name: "outsider views links", action: ActionViewLinks, wantErr: ErrNotMember},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			rows := sqlmock.NewRows([]string{"organization_id", "user_id", "role"})
+			if tt.role != "" {
+				rows.AddRow(3, 7, tt.role)
+			}
+			mock.ExpectQuery(`SELECT \* FROM "organization_members" WHERE organization_id = \$1 AND user_id = \$2`).
+				WithArgs(3, 7, 1).
+				WillReturnRows(rows)
+
+			member, err := NewAuthorizer(database.NewOrganizationRepository(db)).Authorize(7, 3, tt.action)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authorize() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && member.Role != tt.role {
+				t.Errorf("member role = %s, want %s", member.Role, tt.role)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
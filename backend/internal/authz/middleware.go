@@ -0,0 +1,69 @@
+package authz
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+)
+
+// OrganizationHeader selects the organization a request acts on
+const OrganizationHeader = "X-Organization-ID"
+
+// OrganizationMiddleware resolves the organization for the request from the
+// X-Organization-ID header, defaulting to the user's personal organization
+func OrganizationMiddleware(authorizer *Authorizer, userRepo *database.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := auth.GetUserID(c)
+		if !exists {
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var orgID int64
+		if header := c.GetHeader(OrganizationHeader); header != "" {
+			id, err := strconv.ParseInt(header, 10, 64)
+			if err != nil {
+				apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid organization ID")
+				return
+			}
+			orgID = id
+		} else {
+			user, err := userRepo.GetByID(userID)
+			if err != nil {
+				apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "User not found")
+				return
+			}
+			org, err := authorizer.orgRepo.EnsurePersonal(user)
+			if err != nil {
+				apierror.Abort(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to resolve organization")
+				return
+			}
+			orgID = org.ID
+		}
+
+		member, err := authorizer.Authorize(userID, orgID, ActionViewLinks)
+		if err != nil {
+			apierror.Abort(c, http.StatusForbidden, apierror.CodeNotMember, "Not a member of this organization")
+			return
+		}
+
+		c.Set("organization_id", orgID)
+		c.Set("organization_role", member.Role)
+
+		c.Next()
+	}
+}
+
+// GetOrganizationID retrieves the resolved organization ID from context
+func GetOrganizationID(c *gin.Context) (int64, bool) {
+	orgID, exists := c.Get("organization_id")
+	if !exists {
+		return 0, false
+	}
+	id, ok := orgID.(int64)
+	return id, ok
+}
@@ -0,0 +1,41 @@
+// Package billing talks to Stripe for subscription billing. The rest of the
+// application uses the Client interface, so a FakeClient can stand in for
+// Stripe in development and tests.
+package billing
+
+import (
+	"context"
+)
+
+// Client is the part of the Stripe API used for billing
+type Client interface {
+	// CreateCustomer creates a customer for a user and returns its ID
+	CreateCustomer(ctx context.Context, params CustomerParams) (string, error)
+	// CreateCheckoutSession starts a hosted checkout for a subscription
+	CreateCheckoutSession(ctx context.Context, params CheckoutParams) (*Session, error)
+	// CreatePortalSession opens the hosted page where customers change
+	// plan, update payment details or cancel
+	CreatePortalSession(ctx context.Context, customerID, returnURL string) (*Session, error)
+}
+
+// CustomerParams describes a new customer
+type CustomerParams struct {
+	UserID int64
+	Email  string
+	Name   string
+}
+
+// CheckoutParams describes a subscription checkout
+type CheckoutParams struct {
+	UserID     int64
+	CustomerID string
+	PriceID    string
+	SuccessURL string
+	CancelURL  string
+}
+
+// Session is a hosted Stripe page the user is sent to
+type Session struct {
+	ID  string
+	URL string
+}
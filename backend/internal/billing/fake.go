@@ -0,0 +1,56 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FakeClient is an in-memory Client for development and tests. It records
+// what was asked of it; checkout and portal sessions point straight at the
+// URLs the caller would be returned to.
+type FakeClient struct {
+	mu        sync.Mutex
+	nextID    int
+	Customers map[string]CustomerParams
+	Checkouts []CheckoutParams
+}
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{Customers: make(map[string]CustomerParams)}
+}
+
+func (f *FakeClient) CreateCustomer(ctx context.Context, params CustomerParams) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.newID("cus_fake")
+	f.Customers[id] = params
+	return id, nil
+}
+
+func (f *FakeClient) CreateCheckoutSession(ctx context.Context, params CheckoutParams) (*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.Customers[params.CustomerID]; !ok {
+		return nil, fmt.Errorf("no such customer: %s", params.CustomerID)
+	}
+	f.Checkouts = append(f.Checkouts, params)
+	return &Session{ID: f.newID("cs_fake"), URL: params.SuccessURL}, nil
+}
+
+func (f *FakeClient) CreatePortalSession(ctx context.Context, customerID, returnURL string) (*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.Customers[customerID]; !ok {
+		return nil, fmt.Errorf("no such customer: %s", customerID)
+	}
+	return &Session{ID: f.newID("bps_fake"), URL: returnURL}, nil
+}
+
+func (f *FakeClient) newID(prefix string) string {
+	f.nextID++
+	return fmt.Sprintf("%s_%d", prefix, f.nextID)
+}
@@ -0,0 +1,117 @@
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const stripeAPIBase = "https://api.stripe.com/v1"
+
+// StripeClient calls the Stripe REST API
+type StripeClient struct {
+	secretKey string
+	baseURL   string
+	client    *http.Client
+}
+
+func NewStripeClient(secretKey string) *StripeClient {
+	return &StripeClient{
+		secretKey: secretKey,
+		baseURL:   stripeAPIBase,
+		client:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type stripeObject struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+type stripeError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (c *StripeClient) CreateCustomer(ctx context.Context, params CustomerParams) (string, error) {
+	form := url.Values{}
+	form.Set("email", params.Email)
+	if params.Name != "" {
+		form.Set("name", params.Name)
+	}
+	form.Set("metadata[user_id]", strconv.FormatInt(params.UserID, 10))
+
+	var customer stripeObject
+	if err := c.post(ctx, "/customers", form, &customer); err != nil {
+		return "", fmt.Errorf("error creating Stripe customer: %w", err)
+	}
+	return customer.ID, nil
+}
+
+func (c *StripeClient) CreateCheckoutSession(ctx context.Context, params CheckoutParams) (*Session, error) {
+	userID := strconv.FormatInt(params.UserID, 10)
+	form := url.Values{}
+	form.Set("mode", "subscription")
+	form.Set("customer", params.CustomerID)
+	form.Set("line_items[0][price]", params.PriceID)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("success_url", params.SuccessURL)
+	form.Set("cancel_url", params.CancelURL)
+	form.Set("client_reference_id", userID)
+	form.Set("subscription_data[metadata][user_id]", userID)
+
+	var session stripeObject
+	if err := c.post(ctx, "/checkout/sessions", form, &session); err != nil {
+		return nil, fmt.Errorf("error creating Stripe checkout session: %w", err)
+	}
+	return &Session{ID: session.ID, URL: session.URL}, nil
+}
+
+func (c *StripeClient) CreatePortalSession(ctx context.Context, customerID, returnURL string) (*Session, error) {
+	form := url.Values{}
+	form.Set("customer", customerID)
+	form.Set("return_url", returnURL)
+
+	var session stripeObject
+	if err := c.post(ctx, "/billing_portal/sessions", form, &session); err != nil {
+		return nil, fmt.Errorf("error creating Stripe portal session: %w", err)
+	}
+	return &Session{ID: session.ID, URL: session.URL}, nil
+}
+
+// post sends a form-encoded request and decodes the JSON response into out
+func (c *StripeClient) post(ctx context.Context, path string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.secretKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr stripeError
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("Stripe returned %d: %s", resp.StatusCode, apiErr.Error.Message)
+		}
+		return fmt.Errorf("Stripe returned %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
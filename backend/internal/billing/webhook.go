@@ -0,0 +1,188 @@
+package billing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries Stripe's webhook signature
+const SignatureHeader = "Stripe-Signature"
+
+// signatureTolerance is how old a signed webhook may be before it is
+// treated as a replay
+const signatureTolerance = 5 * time.Minute
+
+// ErrInvalidSignature is returned for webhooks that fail verification
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Event is a Stripe webhook event. Data.Object holds the object the event
+// is about, decoded with the Subscription or Invoice helpers.
+type Event struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// CreatedAt returns when Stripe created the event
+func (e *Event) CreatedAt() time.Time {
+	return time.Unix(e.Created, 0)
+}
+
+// Subscription is the part of a Stripe subscription used for billing
+type Subscription struct {
+	ID                string `json:"id"`
+	Customer          string `json:"customer"`
+	Status            string `json:"status"`
+	CancelAtPeriodEnd bool   `json:"cancel_at_period_end"`
+	CurrentPeriodEnd  int64  `json:"current_period_end"`
+	Items             struct {
+		Data []struct {
+			CurrentPeriodEnd int64 `json:"current_period_end"`
+			Price            struct {
+				ID string `json:"id"`
+			} `json:"price"`
+		} `json:"data"`
+	} `json:"items"`
+}
+
+// PriceID returns the price of the subscription's first item
+func (s *Subscription) PriceID() string {
+	if len(s.Items.Data) == 0 {
+		return ""
+	}
+	return s.Items.Data[0].Price.ID
+}
+
+// PeriodEnd returns the end of the current billing period. Newer API
+// versions report it per item rather than on the subscription.
+func (s *Subscription) PeriodEnd() *time.Time {
+	end := s.CurrentPeriodEnd
+	if end == 0 && len(s.Items.Data) > 0 {
+		end = s.Items.Data[0].CurrentPeriodEnd
+	}
+	if end == 0 {
+		return nil
+	}
+	t := time.Unix(end, 0)
+	return &t
+}
+
+// Invoice is the part of a Stripe invoice shown in billing history
+type Invoice struct {
+	ID               string `json:"id"`
+	Customer         string `json:"customer"`
+	Number           string `json:"number"`
+	Status           string `json:"status"`
+	AmountDue        int64  `json:"amount_due"`
+	AmountPaid       int64  `json:"amount_paid"`
+	Currency         string `json:"currency"`
+	HostedInvoiceURL string `json:"hosted_invoice_url"`
+	Created          int64  `json:"created"`
+	PeriodStart      int64  `json:"period_start"`
+	PeriodEnd        int64  `json:"period_end"`
+	Lines            struct {
+		Data []struct {
+			Description string `json:"description"`
+		} `json:"data"`
+	} `json:"lines"`
+}
+
+// Description returns the description of the invoice's first line
+func (i *Invoice) Description() string {
+	if len(i.Lines.Data) == 0 {
+		return ""
+	}
+	return i.Lines.Data[0].Description
+}
+
+// Subscription decodes the event's object as a subscription
+func (e *Event) Subscription() (*Subscription, error) {
+	var sub Subscription
+	if err := json.Unmarshal(e.Data.Object, &sub); err != nil {
+		return nil, fmt.Errorf("error decoding subscription: %w", err)
+	}
+	return &sub, nil
+}
+
+// Invoice decodes the event's object as an invoice
+func (e *Event) Invoice() (*Invoice, error) {
+	var invoice Invoice
+	if err := json.Unmarshal(e.Data.Object, &invoice); err != nil {
+		return nil, fmt.Errorf("error decoding invoice: %w", err)
+	}
+	return &invoice, nil
+}
+
+// ConstructEvent verifies a webhook's Stripe-Signature header against the
+// endpoint secret and decodes the event
+func ConstructEvent(payload []byte, header, secret string, now time.Time) (*Event, error) {
+	timestamp, signatures := parseSignatureHeader(header)
+	if timestamp == 0 || len(signatures) == 0 {
+		return nil, ErrInvalidSignature
+	}
+
+	expected := computeSignature(secret, timestamp, payload)
+	valid := false
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("error decoding event: %w", err)
+	}
+	return &event, nil
+}
+
+// SignPayload builds a Stripe-Signature header for a payload, for sending
+// test webhooks
+func SignPayload(payload []byte, secret string, timestamp int64) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, computeSignature(secret, timestamp, payload))
+}
+
+func computeSignature(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseSignatureHeader reads "t=<unix>,v1=<hex>[,v1=<hex>...]". Stripe
+// sends several v1 signatures while an endpoint secret is being rolled.
+func parseSignatureHeader(header string) (int64, []string) {
+	var timestamp int64
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	return timestamp, signatures
+}
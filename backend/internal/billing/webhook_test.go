@@ -0,0 +1,100 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret = "whsec_test"
+	// testPayload is a trimmed customer.subscription.updated event
+	testPayload = `{"id":"evt_1","type":"customer.subscription.updated","created":1700000000,` +
+		`"data":{"object":{"id":"sub_1","customer":"cus_1","status":"active"}}}`
+)
+
+func TestConstructEvent(t *testing.T) {
+	signedAt := time.Unix(1700000000, 0)
+	signed := SignPayload([]byte(testPayload), testSecret, signedAt.Unix())
+	otherSignature := computeSignature("whsec_old", signedAt.Unix(), []byte(testPayload))
+
+	tests := []struct {
+		name    string
+		payload string
+		header  string
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid", payload: testPayload, header: signed, now: signedAt},
+		{name: "within tolerance", payload: testPayload, header: signed, now: signedAt.Add(signatureTolerance)},
+		{name: "clock skew within tolerance", payload: testPayload, header: signed, now: signedAt.Add(-signatureTolerance)},
+		{name: "too old", payload: testPayload, header: signed, now: signedAt.Add(signatureTolerance + time.Second), wantErr: ErrInvalidSignature},
+		{name: "too far in the future", payload: testPayload, header: signed, now: signedAt.Add(-signatureTolerance - time.Second), wantErr: ErrInvalidSignature},
+		{name: "wrong secret", payload: testPayload, header: SignPayload([]byte(testPayload), "whsec_other", signedAt.Unix()), now: signedAt, wantErr: ErrInvalidSignature},
+		{name: "tampered payload", payload: `{"id":"evt_2"}`, header: signed, now: signedAt, wantErr: ErrInvalidSignature},
+		{name: "timestamp changed", payload: testPayload, header: fmt.Sprintf("t=%d,v1=%s", signedAt.Unix()+1, computeSignature(testSecret, signedAt.Unix(), []byte(testPayload))), now: signedAt, wantErr: ErrInvalidSignature},
+		{name: "secret being rolled", payload: testPayload, header: fmt.Sprintf("t=%d,v1=%s,v1=%s", signedAt.Unix(), otherSignature, computeSignature(testSecret, signedAt.Unix(), []byte(testPayload))), now: signedAt},
+		{name: "only old secret", payload: testPayload, header: fmt.Sprintf("t=%d,v1=%s", signedAt.Unix(), otherSignature), now: signedAt, wantErr: ErrInvalidSignature},
+		{name: "missing timestamp", payload: testPayload, header: "v1=" + computeSignature(testSecret, signedAt.Unix(), []byte(testPayload)), now: signedAt, wantErr: ErrInvalidSignature},
+		{name: "missing signature", payload: testPayload, header: fmt.Sprintf("t=%d", signedAt.Unix()), now: signedAt, wantErr: ErrInvalidSignature},
+		{name: "empty header", payload: testPayload, header: "", now: signedAt, wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := ConstructEvent([]byte(tt.payload), tt.header, testSecret, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConstructEvent() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if event.ID != "evt_1" || event.Type != "customer.subscription.updated" || !event.CreatedAt().Equal(signedAt) {
+				t.Errorf("ConstructEvent() = %+v, want evt_1 customer.subscription.updated", event)
+			}
+			sub, err := event.Subscription()
+			if err != nil {
+				t.Fatalf("Subscription() error = %v", err)
+			}
+			if sub.ID != "sub_1" || sub.Customer != "cus_1" || sub.Status != "active" {
+				t.Errorf("Subscription() = %+v", sub)
+			}
+		})
+	}
+}
+
+func TestConstructEventRejectsMalformedPayload(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	payload := []byte(`{"id":`)
+
+	_, err := ConstructEvent(payload, SignPayload(payload, testSecret, now.Unix()), testSecret, now)
+	if err == nil || errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("ConstructEvent() error = %v, want a decoding error", err)
+	}
+}
+
+func TestParseSignatureHeader(t *testing.T) {
+	tests := []struct {
+		header        string
+		wantTimestamp int64
+		wantCount     int
+	}{
+		{header: "t=1700000000,v1=abc", wantTimestamp: 1700000000, wantCount: 1},
+		{header: "t=1700000000, v1=abc, v1=def", wantTimestamp: 1700000000, wantCount: 2},
+		{header: "t=1700000000,v0=abc,v1=def", wantTimestamp: 1700000000, wantCount: 1},
+		{header: "v1=abc,t=1700000000", wantTimestamp: 1700000000, wantCount: 1},
+		{header: "t=soon,v1=abc", wantTimestamp: 0, wantCount: 1},
+		{header: "garbage", wantTimestamp: 0, wantCount: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			timestamp, signatures := parseSignatureHeader(tt.header)
+			if timestamp != tt.wantTimestamp || len(signatures) != tt.wantCount {
+				t.Errorf("parseSignatureHeader(%q) = %d, %d signatures; want %d, %d",
+					tt.header, timestamp, len(signatures), tt.wantTimestamp, tt.wantCount)
+			}
+		})
+	}
+}
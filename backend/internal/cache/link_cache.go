@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// LinkCache is a read-through cache of the lookups made on every redirect:
+// short code to link, and custom domain hostname to domain
+type LinkCache struct {
+	store       Store
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+func NewLinkCache(store Store, ttl, negativeTTL time.Duration) *LinkCache {
+	return &LinkCache{
+		store:       store,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}
+}
+
+// linkKey builds the cache key for a short code within a domain's namespace
+func linkKey(domainID *int64, shortCode string) string {
+	domain := "0"
+	if domainID != nil {
+		domain = strconv.FormatInt(*domainID, 10)
+	}
+	return "link:" + domain + ":" + shortCode
+}
+
+func domainKey(hostname string) string {
+	return "domain:" + hostname
+}
+
+// GetLink returns the link for a short code, calling load on a cache miss
+func (c *LinkCache) GetLink(ctx context.Context, domainID *int64, shortCode string, load func() (*models.Link, error)) (*models.Link, error) {
+	return readThrough(ctx, c.store, linkKey(domainID, shortCode), c.ttl, c.negativeTTL, load)
+}
+
+// InvalidateLink removes the cached entry for a short code
+func (c *LinkCache) InvalidateLink(ctx context.Context, domainID *int64, shortCode string) {
+	invalidate(ctx, c.store, linkKey(domainID, shortCode))
+}
+
+// GetDomain returns the custom domain for a hostname, calling load on a cache miss
+func (c *LinkCache) GetDomain(ctx context.Context, hostname string, load func() (*models.Domain, error)) (*models.Domain, error) {
+	return readThrough(ctx, c.store, domainKey(hostname), c.ttl, c.negativeTTL, load)
+}
+
+// InvalidateDomain removes the cached entry for a hostname
+func (c *LinkCache) InvalidateDomain(ctx context.Context, hostname string) {
+	invalidate(ctx, c.store, domainKey(hostname))
+}
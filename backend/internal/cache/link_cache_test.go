@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+func TestLinkCacheNamespacesShortCodesByDomain(t *testing.T) {
+	domainA, domainB := int64(1), int64(2)
+	links := map[string]*models.Link{
+		linkKey(nil, "sale"):      {ID: 10, ShortCode: "sale"},
+		linkKey(&domainA, "sale"): {ID: 11, ShortCode: "sale", DomainID: &domainA},
+		linkKey(&domainB, "sale"): {ID: 12, ShortCode: "sale", DomainID: &domainB},
+	}
+
+	tests := []struct {
+		name     string
+		domainID *int64
+		code     string
+		wantID   int64
+		wantErr  error
+	}{
+		{name: "default domain", code: "sale", wantID: 10},
+		{name: "first custom domain", domainID: &domainA, code: "sale", wantID: 11},
+		{name: "second custom domain", domainID: &domainB, code: "sale", wantID: 12},
+		{name: "code missing on domain", domainID: &domainA, code: "spring", wantErr: ErrNotFound},
+	}
+
+	c := NewLinkCache(NewLRUStore(100), time.Minute, time.Minute)
+	ctx := context.Background()
+	// Each lookup runs twice: once loading, once from the cache
+	for round := 0; round < 2; round++ {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				loads := 0
+				link, err := c.GetLink(ctx, tt.domainID, tt.code, func() (*models.Link, error) {
+					loads++
+					if link, ok := links[linkKey(tt.domainID, tt.code)]; ok {
+						return link, nil
+					}
+					return nil, ErrNotFound
+				})
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetLink() error = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr == nil && link.ID != tt.wantID {
+					t.Errorf("GetLink() link ID = %d, want %d", link.ID, tt.wantID)
+				}
+				if wantLoads := 1 - round; loads != wantLoads {
+					t.Errorf("round %d: load called %d times, want %d", round, loads, wantLoads)
+				}
+			})
+		}
+	}
+}
+
+func TestLinkCacheInvalidateLinkOnlyEvictsItsDomain(t *testing.T) {
+	domainID := int64(1)
+	c := NewLinkCache(NewLRUStore(100), time.Minute, time.Minute)
+	ctx := context.Background()
+
+	load := func(id int64) func() (*models.Link, error) {
+		return func() (*models.Link, error) { return &models.Link{ID: id}, nil }
+	}
+	c.GetLink(ctx, nil, "sale", load(10))
+	c.GetLink(ctx, &domainID, "sale", load(11))
+
+	c.InvalidateLink(ctx, &domainID, "sale")
+
+	if link, _ := c.GetLink(ctx, nil, "sale", load(20)); link.ID != 10 {
+		t.Errorf("default domain link ID = %d, want cached 10", link.ID)
+	}
+	if link, _ := c.GetLink(ctx, &domainID, "sale", load(21)); link.ID != 21 {
+		t.Errorf("custom domain link ID = %d, want reloaded 21", link.ID)
+	}
+}
+
+func TestLinkCacheDoesNotCacheLoadErrors(t *testing.T) {
+	c := NewLinkCache(NewLRUStore(100), time.Minute, time.Minute)
+	ctx := context.Background()
+	loadErr := errors.New("database unavailable")
+
+	if _, err := c.GetDomain(ctx, "go.example.com", func() (*models.Domain, error) { return nil, loadErr }); !errors.Is(err, loadErr) {
+		t.Fatalf("GetDomain() error = %v, want %v", err, loadErr)
+	}
+	domain, err := c.GetDomain(ctx, "go.example.com", func() (*models.Domain, error) {
+		return &models.Domain{ID: 1, Hostname: "go.example.com"}, nil
+	})
+	if err != nil || domain.ID != 1 {
+		t.Errorf("GetDomain() after a failed load = %v, %v; want domain 1", domain, err)
+	}
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRUStore is an in-process, size-bounded Store used when Redis is disabled
+type LRUStore struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+func NewLRUStore(capacity int) *LRUStore {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRUStore{
+		capacity: capacity,
+		items:    make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (s *LRUStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		s.removeElement(elem)
+		return nil, ErrMiss
+	}
+
+	s.order.MoveToFront(elem)
+	return entry.value, nil
+}
+
+func (s *LRUStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if elem, ok := s.items[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		s.order.MoveToFront(elem)
+		return nil
+	}
+
+	s.items[key] = s.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for s.order.Len() > s.capacity {
+		s.removeElement(s.order.Back())
+	}
+	return nil
+}
+
+func (s *LRUStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := s.items[key]; ok {
+			s.removeElement(elem)
+		}
+	}
+	return nil
+}
+
+// removeElement must be called with the lock held
+func (s *LRUStore) removeElement(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.items, elem.Value.(*lruEntry).key)
+}
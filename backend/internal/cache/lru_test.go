@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLRUStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	s := NewLRUStore(2)
+	ctx := context.Background()
+
+	s.Set(ctx, "a", []byte("1"), time.Minute)
+	s.Set(ctx, "b", []byte("2"), time.Minute)
+	// Reading a makes b the least recently used
+	if _, err := s.Get(ctx, "a"); err != nil {
+		t.Fatalf("Get(a) error = %v", err)
+	}
+	s.Set(ctx, "c", []byte("3"), time.Minute)
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr error
+	}{
+		{key: "a", want: "1"},
+		{key: "b", wantErr: ErrMiss},
+		{key: "c", want: "3"},
+	}
+	for _, tt := range tests {
+		got, err := s.Get(ctx, tt.key)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Get(%s) error = %v, want %v", tt.key, err, tt.wantErr)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Get(%s) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLRUStoreExpiresEntries(t *testing.T) {
+	s := NewLRUStore(10)
+	ctx := context.Background()
+
+	s.Set(ctx, "short", []byte("1"), time.Millisecond)
+	s.Set(ctx, "long", []byte("2"), time.Minute)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := s.Get(ctx, "short"); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get(short) error = %v, want %v", err, ErrMiss)
+	}
+	if _, err := s.Get(ctx, "long"); err != nil {
+		t.Errorf("Get(long) error = %v", err)
+	}
+}
+
+func TestLRUStoreSetReplacesValueAndTTL(t *testing.T) {
+	s := NewLRUStore(10)
+	ctx := context.Background()
+
+	s.Set(ctx, "key", []byte("old"), time.Millisecond)
+	s.Set(ctx, "key", []byte("new"), time.Minute)
+	time.Sleep(5 * time.Millisecond)
+
+	got, err := s.Get(ctx, "key")
+	if err != nil || string(got) != "new" {
+		t.Errorf("Get(key) = %q, %v; want %q", got, err, "new")
+	}
+}
+
+func TestLRUStoreDelete(t *testing.T) {
+	s := NewLRUStore(10)
+	ctx := context.Background()
+
+	s.Set(ctx, "a", []byte("1"), time.Minute)
+	s.Set(ctx, "b", []byte("2"), time.Minute)
+	s.Delete(ctx, "a", "missing")
+
+	if _, err := s.Get(ctx, "a"); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get(a) error = %v, want %v", err, ErrMiss)
+	}
+	if _, err := s.Get(ctx, "b"); err != nil {
+		t.Errorf("Get(b) error = %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/internal/logger"
+)
+
+// ErrNotFound is returned by loaders when the requested record does not
+// exist; it is cached so repeated lookups of unknown keys skip the database
+var ErrNotFound = errors.New("not found")
+
+// entry is the stored form of a lookup result
+type entry[T any] struct {
+	Missing bool
+	Value   *T
+}
+
+// readThrough returns the cached value for key or calls load on a miss and
+// caches the result, including negative results. Cache failures are logged
+// and treated as misses so that lookups never fail because of the cache.
+func readThrough[T any](ctx context.Context, store Store, key string, ttl, negativeTTL time.Duration, load func() (*T, error)) (*T, error) {
+	data, err := store.Get(ctx, key)
+	if err == nil {
+		var cached entry[T]
+		if decodeErr := gob.NewDecoder(bytes.NewReader(data)).Decode(&cached); decodeErr == nil {
+			if cached.Missing {
+				return nil, ErrNotFound
+			}
+			return cached.Value, nil
+		}
+		logger.Warnf(ctx, "Discarding undecodable cache entry %s", key)
+	} else if !errors.Is(err, ErrMiss) {
+		logger.Warnf(ctx, "Cache read failed for %s: %+v", key, err)
+	}
+
+	value, loadErr := load()
+	switch {
+	case loadErr == nil:
+		write(ctx, store, key, entry[T]{Value: value}, ttl)
+	case errors.Is(loadErr, ErrNotFound):
+		write(ctx, store, key, entry[T]{Missing: true}, negativeTTL)
+	}
+	return value, loadErr
+}
+
+func write[T any](ctx context.Context, store Store, key string, value entry[T], ttl time.Duration) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		logger.Warnf(ctx, "Failed to encode cache entry %s: %+v", key, err)
+		return
+	}
+	if err := store.Set(ctx, key, buf.Bytes(), ttl); err != nil {
+		logger.Warnf(ctx, "Cache write failed for %s: %+v", key, err)
+	}
+}
+
+// invalidate removes keys, logging rather than returning failures
+func invalidate(ctx context.Context, store Store, keys ...string) {
+	if err := store.Delete(ctx, keys...); err != nil {
+		logger.Warnf(ctx, "Cache invalidation failed for %v: %+v", keys, err)
+	}
+}
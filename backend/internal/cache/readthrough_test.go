@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// failingStore fails every operation, as when Redis is down
+type failingStore struct{}
+
+var errStoreDown = errors.New("store unavailable")
+
+func (failingStore) Get(context.Context, string) ([]byte, error) { return nil, errStoreDown }
+
+func (failingStore) Set(context.Context, string, []byte, time.Duration) error { return errStoreDown }
+
+func (failingStore) Delete(context.Context, ...string) error { return errStoreDown }
+
+func TestReadThroughServesLookupsWhenTheStoreFails(t *testing.T) {
+	c := NewLinkCache(failingStore{}, time.Minute, time.Minute)
+	ctx := context.Background()
+
+	loads := 0
+	load := func() (*models.Link, error) {
+		loads++
+		return &models.Link{ID: 10}, nil
+	}
+	for i := 0; i < 2; i++ {
+		link, err := c.GetLink(ctx, nil, "sale", load)
+		if err != nil || link.ID != 10 {
+			t.Fatalf("GetLink() = %v, %v; want link 10", link, err)
+		}
+	}
+	if loads != 2 {
+		t.Errorf("load called %d times, want 2", loads)
+	}
+}
+
+func TestReadThroughDiscardsUndecodableEntries(t *testing.T) {
+	store := NewLRUStore(10)
+	c := NewLinkCache(store, time.Minute, time.Minute)
+	ctx := context.Background()
+
+	store.Set(ctx, linkKey(nil, "sale"), []byte("not gob"), time.Minute)
+
+	link, err := c.GetLink(ctx, nil, "sale", func() (*models.Link, error) { return &models.Link{ID: 10}, nil })
+	if err != nil || link.ID != 10 {
+		t.Fatalf("GetLink() = %v, %v; want link 10", link, err)
+	}
+	// The fresh value replaced the bad entry
+	link, err = c.GetLink(ctx, nil, "sale", func() (*models.Link, error) { return &models.Link{ID: 20}, nil })
+	if err != nil || link.ID != 10 {
+		t.Errorf("GetLink() = %v, %v; want cached link 10", link, err)
+	}
+}
+
+func TestReadThroughNegativeEntriesExpire(t *testing.T) {
+	c := NewLinkCache(NewLRUStore(10), time.Minute, time.Millisecond)
+	ctx := context.Background()
+
+	if _, err := c.GetLink(ctx, nil, "sale", func() (*models.Link, error) { return nil, ErrNotFound }); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetLink() error = %v, want %v", err, ErrNotFound)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// A link created since is found once the negative entry expires
+	link, err := c.GetLink(ctx, nil, "sale", func() (*models.Link, error) { return &models.Link{ID: 10}, nil })
+	if err != nil || link.ID != 10 {
+		t.Errorf("GetLink() = %v, %v; want link 10", link, err)
+	}
+}
@@ -0,0 +1,17 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss is returned by Store.Get when the key is not cached
+var ErrMiss = errors.New("cache miss")
+
+// Store is a byte-oriented key/value cache with per-entry expiry
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// ErrAPIKeyNotFound is returned when no API key matches
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// APIKeyRepository implementation using GORM
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) Create(key *models.APIKey) error {
+	ctx := context.Background()
+	logger.Infof(ctx, "Creating API key %s for user ID: %d", key.Prefix, key.UserID)
+
+	if err := r.db.WithContext(ctx).Create(key).Error; err != nil {
+		logger.Errorf(ctx, "Failed to create API key: %+v", err)
+		return fmt.Errorf("error creating API key: %w", err)
+	}
+
+	logger.Infof(ctx, "Successfully created API key with ID: %d", key.ID)
+	return nil
+}
+
+func (r *APIKeyRepository) GetByPrefix(prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("error getting API key: %w", err)
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) GetByID(id int64) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.First(&key, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("error getting API key: %w", err)
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) GetByUserID(userID int64) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	if err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("error getting API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// CountActiveByUserID counts the user's keys that are neither revoked nor expired
+func (r *APIKeyRepository) CountActiveByUserID(userID int64) (int64, error) {
+	var count int64
+	if err := r.db.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("error counting API keys: %w", err)
+	}
+	return count, nil
+}
+
+// Revoke marks a key as revoked. Revoked keys are kept so they still show
+// in the key list.
+func (r *APIKeyRepository) Revoke(id, userID int64) error {
+	result := r.db.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("error revoking API key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// TouchLastUsed records that the key was used, writing at most once per
+// interval to keep authentication off the write path
+func (r *APIKeyRepository) TouchLastUsed(id int64, usedAt time.Time, interval time.Duration) error {
+	return r.db.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, usedAt.Add(-interval)).
+		Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,295 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+var (
+	// ErrSubscriptionNotFound is returned when no billing record matches a
+	// user or Stripe customer
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrCoverageLimitReached is returned when the owner's tier covers no
+	// more team organizations
+	ErrCoverageLimitReached = errors.New("subscription covers no more team organizations")
+)
+
+// SubscriptionUpdate is the state of a Stripe subscription as of a webhook
+// event, with the tier it grants
+type SubscriptionUpdate struct {
+	EventID           string
+	EventType         string
+	OccurredAt        time.Time
+	CustomerID        string
+	SubscriptionID    string
+	PriceID           string
+	Status            string
+	Tier              models.SubscriptionTier
+	CurrentPeriodEnd  *time.Time
+	CancelAtPeriodEnd bool
+}
+
+// TierChange reports a user's subscription tier changing
+type TierChange struct {
+	UserID int64
+	From   models.SubscriptionTier
+	To     models.SubscriptionTier
+}
+
+// OrganizationTierChange reports a team organization's tier changing as the
+// owner's subscription starts or stops covering it
+type OrganizationTierChange struct {
+	OrganizationID int64
+	OwnerID        int64
+	From           models.SubscriptionTier
+	To             models.SubscriptionTier
+}
+
+// BillingRepository implementation using GORM
+type BillingRepository struct {
+	db *gorm.DB
+}
+
+func NewBillingRepository(db *gorm.DB) *BillingRepository {
+	return &BillingRepository{db: db}
+}
+
+func (r *BillingRepository) GetSubscriptionByUserID(userID int64) (*models.Subscription, error) {
+	var sub models.Subscription
+	if err := r.db.Where("user_id = ?", userID).First(&sub).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("error getting subscription: %w", err)
+	}
+	return &sub, nil
+}
+
+// CreateCustomer records the Stripe customer created for a user
+func (r *BillingRepository) CreateCustomer(sub *models.Subscription) error {
+	ctx := context.Background()
+	logger.Infof(ctx, "Recording Stripe customer %s for user ID: %d", sub.StripeCustomerID, sub.UserID)
+
+	if err := r.db.WithContext(ctx).Create(sub).Error; err != nil {
+		logger.Errorf(ctx, "Failed to record Stripe customer: %+v", err)
+		return fmt.Errorf("error creating subscription: %w", err)
+	}
+	return nil
+}
+
+// ApplySubscriptionUpdate applies a subscription webhook event and moves
+// the user, their personal organization and the team organizations the
+// subscription covers to the granted tier. Covered organizations beyond
+// what the new tier covers, most recently created first, drop to free.
+// Events already processed, older than the last applied one, or about an
+// inactive subscription the user has since replaced change nothing. The
+// returned change is nil unless the tier changed.
+func (r *BillingRepository) ApplySubscriptionUpdate(update *SubscriptionUpdate) (*TierChange, error) {
+	var change *TierChange
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		recorded, err := recordBillingEvent(tx, update.EventID, update.EventType)
+		if err != nil || !recorded {
+			return err
+		}
+
+		var sub models.Subscription
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("stripe_customer_id = ?", update.CustomerID).
+			First(&sub).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrSubscriptionNotFound
+			}
+			return fmt.Errorf("error getting subscription: %w", err)
+		}
+
+		if sub.LastEventAt != nil && update.OccurredAt.Before(*sub.LastEventAt) {
+			return nil
+		}
+		if sub.StripeSubscriptionID != "" && sub.StripeSubscriptionID != update.SubscriptionID &&
+			sub.IsActive() && !models.IsActiveSubscriptionStatus(update.Status) {
+			return nil
+		}
+
+		if err := tx.Model(&sub).Updates(map[string]interface{}{
+			"stripe_subscription_id": update.SubscriptionID,
+			"price_id":               update.PriceID,
+			"tier":                   update.Tier,
+			"status":                 update.Status,
+			"current_period_end":     update.CurrentPeriodEnd,
+			"cancel_at_period_end":   update.CancelAtPeriodEnd,
+			"last_event_at":          update.OccurredAt,
+		}).Error; err != nil {
+			return fmt.Errorf("error updating subscription: %w", err)
+		}
+
+		var user models.User
+		if err := tx.Select("id", "subscription_tier").First(&user, sub.UserID).Error; err != nil {
+			return fmt.Errorf("error getting user: %w", err)
+		}
+		if user.SubscriptionTier == update.Tier {
+			return nil
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", sub.UserID).
+			Update("subscription_tier", update.Tier).Error; err != nil {
+			return fmt.Errorf("error updating user tier: %w", err)
+		}
+
+		var covered []int64
+		if err := tx.Model(&models.Organization{}).
+			Where("owner_id = ? AND is_personal = ? AND covered_by_owner = ?", sub.UserID, false, true).
+			Order("id ASC").
+			Pluck("id", &covered).Error; err != nil {
+			return fmt.Errorf("error getting covered organizations: %w", err)
+		}
+		if limit := update.Tier.TeamOrganizationLimit(); len(covered) > limit {
+			if err := tx.Model(&models.Organization{}).Where("id IN ?", covered[limit:]).
+				Updates(map[string]interface{}{
+					"covered_by_owner":  false,
+					"subscription_tier": models.TierFree,
+				}).Error; err != nil {
+				return fmt.Errorf("error uncovering organizations: %w", err)
+			}
+		}
+
+		if err := tx.Model(&models.Organization{}).
+			Where("owner_id = ? AND (is_personal = ? OR covered_by_owner = ?)", sub.UserID, true, true).
+			Update("subscription_tier", update.Tier).Error; err != nil {
+			return fmt.Errorf("error updating organization tier: %w", err)
+		}
+		change = &TierChange{UserID: sub.UserID, From: user.SubscriptionTier, To: update.Tier}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// SetOrganizationCoverage has the owner's subscription start or stop
+// covering a team organization. A covered organization takes the owner's
+// tier, as long as the tier covers another team organization; an
+// uncovered one drops to free. org is updated in place. The returned
+// change is nil unless the organization's tier changed.
+func (r *BillingRepository) SetOrganizationCoverage(org *models.Organization, covered bool) (*OrganizationTierChange, error) {
+	var change *OrganizationTierChange
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the owner before the organization, in the order subscription
+		// updates take them, so coverage is counted against the current tier
+		var owner models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "subscription_tier").
+			First(&owner, org.OwnerID).Error; err != nil {
+			return fmt.Errorf("error getting owner: %w", err)
+		}
+
+		var current models.Organization
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, org.ID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrOrganizationNotFound
+			}
+			return fmt.Errorf("error getting organization: %w", err)
+		}
+		if current.CoveredByOwner == covered {
+			*org = current
+			return nil
+		}
+
+		tier := models.TierFree
+		if covered {
+			var count int64
+			if err := tx.Model(&models.Organization{}).
+				Where("owner_id = ? AND is_personal = ? AND covered_by_owner = ?", owner.ID, false, true).
+				Count(&count).Error; err != nil {
+				return fmt.Errorf("error counting covered organizations: %w", err)
+			}
+			if count >= int64(owner.SubscriptionTier.TeamOrganizationLimit()) {
+				return ErrCoverageLimitReached
+			}
+			tier = owner.SubscriptionTier
+		}
+
+		// Updates writes the new values into current, so note the old tier first
+		from := current.SubscriptionTier
+		if err := tx.Model(&current).Updates(map[string]interface{}{
+			"covered_by_owner":  covered,
+			"subscription_tier": tier,
+		}).Error; err != nil {
+			return fmt.Errorf("error updating organization coverage: %w", err)
+		}
+		if from != tier {
+			change = &OrganizationTierChange{OrganizationID: current.ID, OwnerID: owner.ID, From: from, To: tier}
+		}
+		*org = current
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// RecordInvoice stores or updates an invoice from a webhook event. As with
+// subscriptions, repeated and out-of-date events change nothing.
+func (r *BillingRepository) RecordInvoice(eventID, eventType, customerID string, invoice *models.Invoice) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		recorded, err := recordBillingEvent(tx, eventID, eventType)
+		if err != nil || !recorded {
+			return err
+		}
+
+		var sub models.Subscription
+		if err := tx.Where("stripe_customer_id = ?", customerID).First(&sub).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrSubscriptionNotFound
+			}
+			return fmt.Errorf("error getting subscription: %w", err)
+		}
+		invoice.UserID = sub.UserID
+
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "stripe_invoice_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"number", "description", "status", "amount_due", "amount_paid", "currency",
+				"hosted_invoice_url", "period_start", "period_end", "last_event_at", "updated_at",
+			}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: "invoices.last_event_at <= EXCLUDED.last_event_at"},
+			}},
+		}).Create(invoice).Error; err != nil {
+			return fmt.Errorf("error saving invoice: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListInvoices returns the user's invoices, newest first
+func (r *BillingRepository) ListInvoices(userID int64, limit int) ([]*models.Invoice, error) {
+	var invoices []*models.Invoice
+	if err := r.db.Where("user_id = ?", userID).
+		Order("issued_at DESC").
+		Limit(limit).
+		Find(&invoices).Error; err != nil {
+		return nil, fmt.Errorf("error getting invoices: %w", err)
+	}
+	return invoices, nil
+}
+
+// recordBillingEvent marks a webhook event as processed, reporting false if
+// it already was
+func recordBillingEvent(tx *gorm.DB, eventID, eventType string) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.BillingEvent{ID: eventID, Type: eventType})
+	if result.Error != nil {
+		return false, fmt.Errorf("error recording billing event: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -72,11 +72,29 @@ func (d *GormDatabase) AutoMigrate() error {
 	ctx := context.Background()
 	logger.Infof(ctx, "Starting database auto-migration...")
 
+	// Users from before email verification existed are treated as verified
+	grandfatherEmails := d.DB.Migrator().HasTable(&models.User{}) &&
+		!d.DB.Migrator().HasColumn(&models.User{}, "email_verified")
+
 	err := d.DB.AutoMigrate(
 		&models.User{},
+		&models.Organization{},
+		&models.OrganizationMember{},
+		&models.OrganizationInvitation{},
+		&models.Domain{},
 		&models.Link{},
 		&models.Click{},
 		&models.AnalyticsDaily{},
+		&models.ClickUsage{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.APIKey{},
+		&models.RecoveryCode{},
+		&models.Session{},
+		&models.EmailToken{},
+		&models.Subscription{},
+		&models.Invoice{},
+		&models.BillingEvent{},
 	)
 
 	if err != nil {
@@ -84,10 +102,89 @@ func (d *GormDatabase) AutoMigrate() error {
 		return fmt.Errorf("failed to run auto-migration: %w", err)
 	}
 
+	// Short codes used to be globally unique; they are now unique per domain
+	if d.DB.Migrator().HasIndex(&models.Link{}, "idx_links_short_code") {
+		if err := d.DB.Migrator().DropIndex(&models.Link{}, "idx_links_short_code"); err != nil {
+			logger.Errorf(ctx, "Failed to drop legacy short code index: %v", err)
+			return fmt.Errorf("failed to drop legacy short code index: %w", err)
+		}
+	}
+
+	// API keys used to be stored in plaintext on users; move them to
+	// api_keys as hashed keys with full scopes
+	if d.DB.Migrator().HasColumn("users", "api_key") {
+		err := d.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(legacyAPIKeyMigration).Error; err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn("users", "api_key")
+		})
+		if err != nil {
+			logger.Errorf(ctx, "Failed to migrate legacy API keys: %v", err)
+			return fmt.Errorf("failed to migrate legacy API keys: %w", err)
+		}
+	}
+
+	// Domains and webhooks used to belong to users; move those from before
+	// organizations to their creator's personal organization
+	if err := d.DB.Exec(adoptDomainsMigration).Error; err != nil {
+		logger.Errorf(ctx, "Failed to move domains to organizations: %v", err)
+		return fmt.Errorf("failed to move domains to organizations: %w", err)
+	}
+	if err := d.DB.Exec(adoptWebhooksMigration).Error; err != nil {
+		logger.Errorf(ctx, "Failed to move webhooks to organizations: %v", err)
+		return fmt.Errorf("failed to move webhooks to organizations: %w", err)
+	}
+
+	if grandfatherEmails {
+		if err := d.DB.Exec(grandfatherEmailVerification).Error; err != nil {
+			logger.Errorf(ctx, "Failed to mark existing users as verified: %v", err)
+			return fmt.Errorf("failed to mark existing users as verified: %w", err)
+		}
+	}
+
 	logger.Infof(ctx, "Database auto-migration completed successfully")
 	return nil
 }
 
+// legacyAPIKeyMigration copies plaintext user API keys into api_keys. It
+// mirrors migrations/000007_create_api_keys.up.sql.
+const legacyAPIKeyMigration = `
+	INSERT INTO api_keys (user_id, name, prefix, secret_hash, scopes, created_at, updated_at)
+	SELECT id, 'Default key', LEFT(api_key, 15), encode(sha256(convert_to(api_key, 'UTF8')), 'hex'),
+	       ARRAY['links:read', 'links:write', 'analytics:read'], NOW(), NOW()
+	FROM users
+	WHERE api_key IS NOT NULL AND api_key <> '' AND deleted_at IS NULL
+	ON CONFLICT (prefix) DO NOTHING
+`
+
+// grandfatherEmailVerification marks users created before email
+// verification as verified. It mirrors
+// migrations/000010_add_email_verification.up.sql.
+const grandfatherEmailVerification = `
+	UPDATE users SET email_verified = TRUE, email_verified_at = created_at
+	WHERE email_verified = FALSE
+`
+
+// adoptDomainsMigration and adoptWebhooksMigration move domains and
+// webhooks without an organization to their creator's personal
+// organization. They mirror
+// migrations/000026_scope_domains_and_webhooks_to_organizations.up.sql.
+const (
+	adoptDomainsMigration = `
+	UPDATE domains SET organization_id = organizations.id
+	FROM organizations
+	WHERE organizations.owner_id = domains.user_id AND organizations.is_personal
+	    AND organizations.deleted_at IS NULL AND domains.organization_id = 0
+`
+	adoptWebhooksMigration = `
+	UPDATE webhooks SET organization_id = organizations.id
+	FROM organizations
+	WHERE organizations.owner_id = webhooks.user_id AND organizations.is_personal
+	    AND organizations.deleted_at IS NULL AND webhooks.organization_id = 0
+`
+)
+
 // Close closes the database connection
 func (d *GormDatabase) Close() error {
 	sqlDB, err := d.DB.DB()
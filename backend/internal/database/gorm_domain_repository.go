@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// ErrDomainNotFound is returned when a domain lookup matches no rows
+var ErrDomainNotFound = errors.New("domain not found")
+
+// DomainRepository implementation using GORM
+type DomainRepository struct {
+	db *gorm.DB
+}
+
+func NewDomainRepository(db *gorm.DB) *DomainRepository {
+	return &DomainRepository{db: db}
+}
+
+func (r *DomainRepository) Create(domain *models.Domain) error {
+	ctx := context.Background()
+	logger.Infof(ctx, "Creating domain: %s for organization ID: %d", domain.Hostname, domain.OrganizationID)
+
+	if err := r.db.WithContext(ctx).Create(domain).Error; err != nil {
+		logger.Errorf(ctx, "Failed to create domain: %+v", err)
+		return fmt.Errorf("error creating domain: %w", err)
+	}
+
+	logger.Infof(ctx, "Successfully created domain with ID: %d", domain.ID)
+	return nil
+}
+
+func (r *DomainRepository) GetByID(id int64) (*models.Domain, error) {
+	var domain models.Domain
+	if err := r.db.First(&domain, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrDomainNotFound
+		}
+		return nil, fmt.Errorf("error getting domain: %w", err)
+	}
+	return &domain, nil
+}
+
+func (r *DomainRepository) GetByHostname(hostname string) (*models.Domain, error) {
+	var domain models.Domain
+	if err := r.db.Where("hostname = ?", hostname).First(&domain).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrDomainNotFound
+		}
+		return nil, fmt.Errorf("error getting domain: %w", err)
+	}
+	return &domain, nil
+}
+
+// GetByOrganizationID returns the organization's domains, newest first
+func (r *DomainRepository) GetByOrganizationID(orgID int64) ([]*models.Domain, error) {
+	var domains []*models.Domain
+	if err := r.db.Where("organization_id = ?", orgID).Order("created_at DESC").Find(&domains).Error; err != nil {
+		return nil, fmt.Errorf("error getting domains: %w", err)
+	}
+	return domains, nil
+}
+
+func (r *DomainRepository) HostnameExists(hostname string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.Domain{}).Where("hostname = ?", hostname).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("error checking hostname: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *DomainRepository) UpdateVerification(domain *models.Domain) error {
+	return r.db.Model(domain).Updates(map[string]interface{}{
+		"status":          domain.Status,
+		"verified_at":     domain.VerifiedAt,
+		"last_checked_at": domain.LastCheckedAt,
+	}).Error
+}
+
+func (r *DomainRepository) CountLinks(domainID int64) (int, error) {
+	var count int64
+	if err := r.db.Model(&models.Link{}).Where("domain_id = ?", domainID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("error counting domain links: %w", err)
+	}
+	return int(count), nil
+}
+
+func (r *DomainRepository) Delete(id int64, orgID int64) error {
+	result := r.db.Where("id = ? AND organization_id = ?", id, orgID).Delete(&models.Domain{})
+	if result.Error != nil {
+		return fmt.Errorf("error deleting domain: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// ErrEmailTokenInvalid is returned for unknown, expired or used email tokens
+var ErrEmailTokenInvalid = errors.New("invalid or expired token")
+
+// EmailTokenRepository implementation using GORM
+type EmailTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailTokenRepository(db *gorm.DB) *EmailTokenRepository {
+	return &EmailTokenRepository{db: db}
+}
+
+// Replace stores a new token, spending the user's outstanding tokens for
+// the same purpose so only the latest email works
+func (r *EmailTokenRepository) Replace(token *models.EmailToken) error {
+	ctx := context.Background()
+	logger.Infof(ctx, "Creating %s token for user ID: %d", token.Purpose, token.UserID)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.EmailToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("error invalidating email tokens: %w", err)
+		}
+		if err := tx.Create(token).Error; err != nil {
+			return fmt.Errorf("error creating email token: %w", err)
+		}
+		return nil
+	})
+}
+
+// Consume marks an unused, unexpired token as used and returns it. A token
+// can be consumed only once, even by concurrent requests.
+func (r *EmailTokenRepository) Consume(tokenHash string, purpose models.EmailTokenPurpose) (*models.EmailToken, error) {
+	token := &models.EmailToken{}
+	err := r.db.Raw(`
+		UPDATE email_tokens SET used_at = ?
+		WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?
+		RETURNING id, user_id, purpose, token_hash, expires_at, used_at, created_at
+	`, time.Now(), tokenHash, purpose, time.Now()).Scan(token).Error
+	if err != nil {
+		return nil, fmt.Errorf("error consuming email token: %w", err)
+	}
+	if token.ID == 0 {
+		return nil, ErrEmailTokenInvalid
+	}
+	return token, nil
+}
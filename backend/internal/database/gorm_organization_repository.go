@@ -0,0 +1,344 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+var (
+	// ErrOrganizationNotFound is returned when no organization matches an ID
+	ErrOrganizationNotFound = errors.New("organization not found")
+	// ErrMemberNotFound is returned when the user is not a member of the organization
+	ErrMemberNotFound = errors.New("member not found")
+	// ErrInvitationNotFound is returned when no pending invitation matches
+	ErrInvitationNotFound = errors.New("invitation not found")
+)
+
+// OrganizationRepository implementation using GORM
+type OrganizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// Create creates an organization together with its owner membership
+func (r *OrganizationRepository) Create(org *models.Organization) error {
+	ctx := context.Background()
+	logger.Infof(ctx, "Creating organization %q for owner ID: %d", org.Name, org.OwnerID)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         org.OwnerID,
+			Role:           models.RoleOwner,
+		}).Error
+	})
+	if err != nil {
+		logger.Errorf(ctx, "Failed to create organization: %+v", err)
+		return fmt.Errorf("error creating organization: %w", err)
+	}
+
+	logger.Infof(ctx, "Successfully created organization with ID: %d", org.ID)
+	return nil
+}
+
+// EnsurePersonal returns the user's personal organization, creating it and
+// adopting any of the user's links, domains and webhooks that predate
+// organizations
+func (r *OrganizationRepository) EnsurePersonal(user *models.User) (*models.Organization, error) {
+	ctx := context.Background()
+
+	var org models.Organization
+	err := r.db.WithContext(ctx).Where("owner_id = ? AND is_personal = ?", user.ID, true).First(&org).Error
+	if err == nil {
+		return &org, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("error getting personal organization: %w", err)
+	}
+
+	logger.Infof(ctx, "Provisioning personal organization for user ID: %d", user.ID)
+	name := user.FullName
+	if name == "" {
+		name = user.Email
+	}
+	org = models.Organization{
+		Name:             name,
+		OwnerID:          user.ID,
+		IsPersonal:       true,
+		SubscriptionTier: user.SubscriptionTier,
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         user.ID,
+			Role:           models.RoleOwner,
+		}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.Link{}, &models.Domain{}, &models.Webhook{}} {
+			if err := tx.Model(model).
+				Where("user_id = ? AND organization_id = 0", user.ID).
+				Update("organization_id", org.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// A concurrent request may have provisioned it first; the unique
+		// index on personal organizations lets only one of them through
+		var existing models.Organization
+		if r.db.WithContext(ctx).Where("owner_id = ? AND is_personal = ?", user.ID, true).First(&existing).Error == nil {
+			return &existing, nil
+		}
+		logger.Errorf(ctx, "Failed to provision personal organization: %+v", err)
+		return nil, fmt.Errorf("error creating personal organization: %w", err)
+	}
+
+	return &org, nil
+}
+
+func (r *OrganizationRepository) GetByID(id int64) (*models.Organization, error) {
+	var org models.Organization
+	if err := r.db.First(&org, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("error getting organization: %w", err)
+	}
+	return &org, nil
+}
+
+// GetByUserID returns every organization the user is a member of
+func (r *OrganizationRepository) GetByUserID(userID int64) ([]*models.Organization, error) {
+	var orgs []*models.Organization
+	if err := r.db.Joins("INNER JOIN organization_members m ON m.organization_id = organizations.id").
+		Where("m.user_id = ?", userID).
+		Order("organizations.is_personal DESC, organizations.created_at ASC").
+		Find(&orgs).Error; err != nil {
+		return nil, fmt.Errorf("error getting organizations: %w", err)
+	}
+	return orgs, nil
+}
+
+// GetByOwnerID returns every organization the user owns
+func (r *OrganizationRepository) GetByOwnerID(ownerID int64) ([]*models.Organization, error) {
+	var orgs []*models.Organization
+	if err := r.db.Where("owner_id = ?", ownerID).Order("created_at ASC").Find(&orgs).Error; err != nil {
+		return nil, fmt.Errorf("error getting organizations: %w", err)
+	}
+	return orgs, nil
+}
+
+// GetInLinkGrace returns the organizations in a link limit grace period
+func (r *OrganizationRepository) GetInLinkGrace() ([]*models.Organization, error) {
+	var orgs []*models.Organization
+	if err := r.db.Where("link_grace_ends_at IS NOT NULL").Order("link_grace_ends_at ASC").Find(&orgs).Error; err != nil {
+		return nil, fmt.Errorf("error getting organizations: %w", err)
+	}
+	return orgs, nil
+}
+
+// StartLinkGrace starts a link limit grace period ending at endsAt. It
+// reports false if the organization is already in one.
+func (r *OrganizationRepository) StartLinkGrace(orgID int64, endsAt time.Time) (bool, error) {
+	result := r.db.Model(&models.Organization{}).
+		Where("id = ? AND link_grace_ends_at IS NULL", orgID).
+		Update("link_grace_ends_at", endsAt)
+	if result.Error != nil {
+		return false, fmt.Errorf("error starting link grace period: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ClearLinkGrace ends the organization's link limit grace period, if any
+func (r *OrganizationRepository) ClearLinkGrace(orgID int64) error {
+	return r.db.Model(&models.Organization{}).
+		Where("id = ? AND link_grace_ends_at IS NOT NULL", orgID).
+		Update("link_grace_ends_at", nil).Error
+}
+
+func (r *OrganizationRepository) Update(org *models.Organization) error {
+	return r.db.Model(org).Updates(map[string]interface{}{
+		"name":              org.Name,
+		"subscription_tier": org.SubscriptionTier,
+	}).Error
+}
+
+// Delete deletes an organization with its members, invitations, links,
+// webhooks and domains, and returns the deleted links and domains so they
+// can be evicted from caches
+func (r *OrganizationRepository) Delete(id int64) ([]*models.Link, []*models.Domain, error) {
+	var links []*models.Link
+	var domains []*models.Domain
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ?", id).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&models.OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Select("id", "domain_id", "short_code").Where("organization_id = ?", id).Find(&links).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&models.Link{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&models.Webhook{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Select("id", "hostname").Where("organization_id = ?", id).Find(&domains).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&models.Domain{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Organization{}, id).Error
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error deleting organization: %w", err)
+	}
+	return links, domains, nil
+}
+
+func (r *OrganizationRepository) GetMember(orgID, userID int64) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	if err := r.db.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrMemberNotFound
+		}
+		return nil, fmt.Errorf("error getting member: %w", err)
+	}
+	return &member, nil
+}
+
+func (r *OrganizationRepository) GetMembers(orgID int64) ([]*models.OrganizationMember, error) {
+	var members []*models.OrganizationMember
+	if err := r.db.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "email", "full_name", "created_at")
+	}).Where("organization_id = ?", orgID).Order("created_at ASC").Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("error getting members: %w", err)
+	}
+	return members, nil
+}
+
+func (r *OrganizationRepository) CountMembersWithRole(orgID int64, role models.OrganizationRole) (int, error) {
+	var count int64
+	if err := r.db.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role = ?", orgID, role).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("error counting members: %w", err)
+	}
+	return int(count), nil
+}
+
+func (r *OrganizationRepository) AddMember(member *models.OrganizationMember) error {
+	if err := r.db.Create(member).Error; err != nil {
+		return fmt.Errorf("error adding member: %w", err)
+	}
+	return nil
+}
+
+func (r *OrganizationRepository) UpdateMemberRole(orgID, userID int64, role models.OrganizationRole) error {
+	result := r.db.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		Update("role", role)
+	if result.Error != nil {
+		return fmt.Errorf("error updating member: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+func (r *OrganizationRepository) RemoveMember(orgID, userID int64) error {
+	result := r.db.Where("organization_id = ? AND user_id = ?", orgID, userID).Delete(&models.OrganizationMember{})
+	if result.Error != nil {
+		return fmt.Errorf("error removing member: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+func (r *OrganizationRepository) CreateInvitation(invitation *models.OrganizationInvitation) error {
+	if err := r.db.Create(invitation).Error; err != nil {
+		return fmt.Errorf("error creating invitation: %w", err)
+	}
+	return nil
+}
+
+func (r *OrganizationRepository) GetInvitationByTokenHash(tokenHash string) (*models.OrganizationInvitation, error) {
+	var invitation models.OrganizationInvitation
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&invitation).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("error getting invitation: %w", err)
+	}
+	return &invitation, nil
+}
+
+// GetPendingInvitations returns unaccepted, unexpired invitations for an organization
+func (r *OrganizationRepository) GetPendingInvitations(orgID int64) ([]*models.OrganizationInvitation, error) {
+	var invitations []*models.OrganizationInvitation
+	if err := r.db.Where("organization_id = ? AND accepted_at IS NULL AND expires_at > ?", orgID, time.Now().UTC()).
+		Order("created_at DESC").
+		Find(&invitations).Error; err != nil {
+		return nil, fmt.Errorf("error getting invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+// AcceptInvitation marks the invitation accepted and adds the member atomically
+func (r *OrganizationRepository) AcceptInvitation(invitation *models.OrganizationInvitation, userID int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		result := tx.Model(invitation).
+			Where("accepted_at IS NULL").
+			Update("accepted_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("error accepting invitation: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationNotFound
+		}
+		invitation.AcceptedAt = &now
+
+		return tx.Create(&models.OrganizationMember{
+			OrganizationID: invitation.OrganizationID,
+			UserID:         userID,
+			Role:           invitation.Role,
+		}).Error
+	})
+}
+
+func (r *OrganizationRepository) DeleteInvitation(id, orgID int64) error {
+	result := r.db.Where("id = ? AND organization_id = ?", id, orgID).Delete(&models.OrganizationInvitation{})
+	if result.Error != nil {
+		return fmt.Errorf("error deleting invitation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
@@ -2,15 +2,40 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/shafikshaon/url_shortener/internal/logger"
 	"github.com/shafikshaon/url_shortener/internal/models"
 )
 
+// ErrUserNotFound is returned when a user lookup matches no rows
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrLinkNotFound is returned when a link lookup matches no rows
+var ErrLinkNotFound = errors.New("link not found")
+
+// ErrShortCodeConflict is returned when another link took the short code in
+// the same domain's namespace first
+var ErrShortCodeConflict = errors.New("short code already exists")
+
+// shortCodeIndexes are the unique indexes that keep short codes unique per domain
+var shortCodeIndexes = map[string]bool{
+	"idx_links_domain_short_code":  true,
+	"idx_links_default_short_code": true,
+}
+
+// uniqueViolation is the Postgres error code for a unique index violation
+const uniqueViolation = "23505"
+
+// linkExpiryLock names the advisory lock held while marking expired links
+const linkExpiryLock = "link_expiry"
+
 // UserRepository implementation using GORM
 type UserRepository struct {
 	db *gorm.DB
@@ -38,7 +63,7 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	var user models.User
 	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("error getting user: %w", err)
 	}
@@ -50,19 +75,7 @@ func (r *UserRepository) GetByID(id int64) (*models.User, error) {
 	var user models.User
 	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("user not found")
-		}
-		return nil, fmt.Errorf("error getting user: %w", err)
-	}
-	return &user, nil
-}
-
-func (r *UserRepository) GetByAPIKey(apiKey string) (*models.User, error) {
-	ctx := context.Background()
-	var user models.User
-	if err := r.db.WithContext(ctx).Where("api_key = ?", apiKey).First(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("error getting user: %w", err)
 	}
@@ -73,7 +86,6 @@ func (r *UserRepository) Update(user *models.User) error {
 	return r.db.Model(user).Updates(map[string]interface{}{
 		"email":             user.Email,
 		"subscription_tier": user.SubscriptionTier,
-		"api_key":           user.APIKey,
 	}).Error
 }
 
@@ -81,6 +93,14 @@ func (r *UserRepository) UpdatePassword(userID int64, passwordHash string) error
 	return r.db.Model(&models.User{}).Where("id = ?", userID).Update("password_hash", passwordHash).Error
 }
 
+// MarkEmailVerified records that the user confirmed their email address
+func (r *UserRepository) MarkEmailVerified(userID int64) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"email_verified":    true,
+		"email_verified_at": time.Now(),
+	}).Error
+}
+
 func (r *UserRepository) Delete(id int64) error {
 	return r.db.Delete(&models.User{}, id).Error
 }
@@ -99,6 +119,11 @@ func (r *LinkRepository) Create(link *models.Link) error {
 	logger.Infof(ctx, "Creating link with short code: %s", link.ShortCode)
 
 	if err := r.db.WithContext(ctx).Create(link).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && shortCodeIndexes[pgErr.ConstraintName] {
+			logger.Warnf(ctx, "Short code %s was taken by a concurrent request", link.ShortCode)
+			return ErrShortCodeConflict
+		}
 		logger.Errorf(ctx, "Failed to create link: %+v", err)
 		return fmt.Errorf("error creating link: %w", err)
 	}
@@ -107,12 +132,21 @@ func (r *LinkRepository) Create(link *models.Link) error {
 	return nil
 }
 
-func (r *LinkRepository) GetByShortCode(shortCode string) (*models.Link, error) {
+// scopeDomain restricts a query to a domain's namespace; a nil domain is the default namespace
+func scopeDomain(query *gorm.DB, domainID *int64) *gorm.DB {
+	if domainID == nil {
+		return query.Where("domain_id IS NULL")
+	}
+	return query.Where("domain_id = ?", *domainID)
+}
+
+func (r *LinkRepository) GetByShortCode(domainID *int64, shortCode string) (*models.Link, error) {
 	ctx := context.Background()
 	var link models.Link
-	if err := r.db.WithContext(ctx).Where("short_code = ?", shortCode).First(&link).Error; err != nil {
+	query := scopeDomain(r.db.WithContext(ctx).Preload("Domain"), domainID)
+	if err := query.Where("short_code = ?", shortCode).First(&link).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("link not found")
+			return nil, ErrLinkNotFound
 		}
 		return nil, fmt.Errorf("error getting link: %w", err)
 	}
@@ -121,27 +155,21 @@ func (r *LinkRepository) GetByShortCode(shortCode string) (*models.Link, error)
 
 func (r *LinkRepository) GetByID(id int64) (*models.Link, error) {
 	var link models.Link
-	if err := r.db.First(&link, id).Error; err != nil {
+	if err := r.db.Preload("Domain").First(&link, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("link not found")
+			return nil, ErrLinkNotFound
 		}
 		return nil, fmt.Errorf("error getting link: %w", err)
 	}
 	return &link, nil
 }
 
-func (r *LinkRepository) GetByUserID(userID int64, limit, offset int, search string, sortBy string) ([]*models.Link, error) {
-	query := r.db.Where("user_id = ?", userID)
-
-	if search != "" {
-		searchPattern := "%" + search + "%"
-		query = query.Where("short_code ILIKE ? OR destination_url ILIKE ? OR title ILIKE ?",
-			searchPattern, searchPattern, searchPattern)
-	}
+func (r *LinkRepository) GetByOrganizationID(orgID int64, limit, offset int, search, status string, sortBy string) ([]*models.Link, error) {
+	query := filterLinks(r.db.Preload("Domain").Where("organization_id = ?", orgID), search, status)
 
 	switch sortBy {
 	case "clicks":
-		query = query.Select("links.*, (SELECT COUNT(*) FROM clicks WHERE link_id = links.id) as click_count").
+		query = query.Select("links.*, (SELECT COUNT(*) FROM clicks WHERE link_id = links.id AND NOT is_bot) as click_count").
 			Order("click_count DESC")
 	case "created_asc":
 		query = query.Order("created_at ASC")
@@ -156,22 +184,18 @@ func (r *LinkRepository) GetByUserID(userID int64, limit, offset int, search str
 	return links, nil
 }
 
-func (r *LinkRepository) CountByUserID(userID int64) (int, error) {
+// CountByOrganizationID counts the organization's active links, the ones
+// that count towards its link limit
+func (r *LinkRepository) CountByOrganizationID(orgID int64) (int, error) {
 	var count int64
-	if err := r.db.Model(&models.Link{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+	if err := r.db.Model(&models.Link{}).Where("organization_id = ? AND archived_at IS NULL", orgID).Count(&count).Error; err != nil {
 		return 0, fmt.Errorf("error counting links: %w", err)
 	}
 	return int(count), nil
 }
 
-func (r *LinkRepository) CountByUserIDWithSearch(userID int64, search string) (int, error) {
-	query := r.db.Model(&models.Link{}).Where("user_id = ?", userID)
-
-	if search != "" {
-		searchPattern := "%" + search + "%"
-		query = query.Where("short_code ILIKE ? OR destination_url ILIKE ? OR title ILIKE ?",
-			searchPattern, searchPattern, searchPattern)
-	}
+func (r *LinkRepository) CountByOrganizationIDWithSearch(orgID int64, search, status string) (int, error) {
+	query := filterLinks(r.db.Model(&models.Link{}).Where("organization_id = ?", orgID), search, status)
 
 	var count int64
 	if err := query.Count(&count).Error; err != nil {
@@ -180,47 +204,276 @@ func (r *LinkRepository) CountByUserIDWithSearch(userID int64, search string) (i
 	return int(count), nil
 }
 
+// filterLinks narrows a link query to links matching search and, if status
+// is set, to links in that state. The conditions mirror models.Link.State.
+func filterLinks(query *gorm.DB, search, status string) *gorm.DB {
+	if search != "" {
+		searchPattern := "%" + search + "%"
+		query = query.Where("short_code ILIKE ? OR destination_url ILIKE ? OR title ILIKE ? OR description ILIKE ?",
+			searchPattern, searchPattern, searchPattern, searchPattern)
+	}
+
+	now := time.Now()
+	const (
+		unblocked    = "archived_at IS NULL AND flagged_at IS NULL"
+		notExpired   = "(expires_at IS NULL OR expires_at >= ?)"
+		notExhausted = "(max_clicks IS NULL OR redirect_count < max_clicks)"
+	)
+	switch models.LinkState(status) {
+	case models.LinkStateArchived:
+		query = query.Where("archived_at IS NOT NULL")
+	case models.LinkStateFlagged:
+		query = query.Where("archived_at IS NULL AND flagged_at IS NOT NULL")
+	case models.LinkStateExpired:
+		query = query.Where(unblocked+" AND expires_at < ?", now)
+	case models.LinkStateExhausted:
+		query = query.Where(unblocked+" AND "+notExpired+" AND redirect_count >= max_clicks", now)
+	case models.LinkStateScheduled:
+		query = query.Where(unblocked+" AND "+notExpired+" AND "+notExhausted+" AND starts_at > ?", now, now)
+	case models.LinkStateActive:
+		query = query.Where(unblocked+" AND "+notExpired+" AND "+notExhausted+" AND (starts_at IS NULL OR starts_at <= ?)", now, now)
+	}
+	return query
+}
+
+// Update saves a link's editable fields. Changing the expiry clears
+// ExpiredAt so the link can expire, and be announced, again, and changing
+// the destination clears the metadata fetched for the old one and queues a
+// new fetch.
 func (r *LinkRepository) Update(link *models.Link) error {
+	sameDestination := func(column string) interface{} {
+		return gorm.Expr("CASE WHEN destination_url = ? THEN "+column+" END", link.DestinationURL)
+	}
 	return r.db.Model(link).Updates(map[string]interface{}{
-		"destination_url": link.DestinationURL,
-		"title":           link.Title,
-		"tags":            link.Tags,
-		"expires_at":      link.ExpiresAt,
+		"destination_url":     link.DestinationURL,
+		"redirect_rules":      link.RedirectRules,
+		"variants":            link.Variants,
+		"deep_link":           link.DeepLink,
+		"utm":                 link.UTM,
+		"query_passthrough":   link.QueryPassthrough,
+		"query_conflict":      link.QueryConflict,
+		"preview":             link.Preview,
+		"description":         sameDestination("description"),
+		"favicon_url":         sameDestination("favicon_url"),
+		"canonical_url":       sameDestination("canonical_url"),
+		"metadata_fetched_at": sameDestination("metadata_fetched_at"),
+		"metadata_status":     gorm.Expr("CASE WHEN destination_url = ? THEN metadata_status ELSE ? END", link.DestinationURL, models.MetadataPending),
+		"title":               link.Title,
+		"tags":                link.Tags,
+		"expires_at":          link.ExpiresAt,
+		"expired_at":          gorm.Expr("CASE WHEN expires_at IS NOT DISTINCT FROM ? THEN expired_at END", link.ExpiresAt),
+		"starts_at":           link.StartsAt,
+		"max_clicks":          link.MaxClicks,
+		"fallback_url":        link.FallbackURL,
+		"password_hash":       link.PasswordHash,
+		"flagged_at":          link.FlaggedAt,
+		"flag_reason":         link.FlagReason,
 	}).Error
 }
 
-func (r *LinkRepository) Delete(id int64, userID int64) error {
-	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Link{})
+// GetPendingMetadata returns up to limit links waiting for their
+// destination's metadata that haven't changed since before, oldest first
+func (r *LinkRepository) GetPendingMetadata(before time.Time, limit int) ([]*models.Link, error) {
+	var links []*models.Link
+	if err := r.db.Where("metadata_status = ? AND updated_at < ?", models.MetadataPending, before).
+		Order("id ASC").Limit(limit).Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("error getting links pending metadata: %w", err)
+	}
+	return links, nil
+}
+
+// SaveMetadata stores the outcome of fetching a link's destination: its
+// MetadataStatus, MetadataFetchedAt and, when fetched, its Description,
+// FaviconURL and CanonicalURL. Title is only filled in for links without
+// one. It reports false, writing nothing, if the link is no longer pending
+// for destinationURL.
+func (r *LinkRepository) SaveMetadata(link *models.Link, destinationURL string) (bool, error) {
+	result := r.db.Model(&models.Link{}).
+		Where("id = ? AND destination_url = ? AND metadata_status = ?", link.ID, destinationURL, models.MetadataPending).
+		UpdateColumns(map[string]interface{}{
+			"title":               gorm.Expr("COALESCE(title, ?)", link.Title),
+			"description":         link.Description,
+			"favicon_url":         link.FaviconURL,
+			"canonical_url":       link.CanonicalURL,
+			"metadata_status":     link.MetadataStatus,
+			"metadata_fetched_at": link.MetadataFetchedAt,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("error saving link metadata: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// IncrementRedirectCount counts a redirect of a link with a click limit. It
+// reports false, without counting, once the limit has been reached; the
+// check and increment are one statement so concurrent redirects can't
+// overshoot it.
+func (r *LinkRepository) IncrementRedirectCount(id int64) (bool, error) {
+	result := r.db.Model(&models.Link{}).
+		Where("id = ? AND (max_clicks IS NULL OR redirect_count < max_clicks)", id).
+		UpdateColumn("redirect_count", gorm.Expr("redirect_count + 1"))
+	if result.Error != nil {
+		return false, fmt.Errorf("error counting redirect: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// HasRedirectsLeft reports whether the link is under its click limit,
+// reading the current count rather than a cached copy
+func (r *LinkRepository) HasRedirectsLeft(id int64) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.Link{}).
+		Where("id = ? AND (max_clicks IS NULL OR redirect_count < max_clicks)", id).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("error checking redirects left: %w", err)
+	}
+	return count > 0, nil
+}
+
+// MarkExpired marks up to limit links whose expiry passed before now as
+// expired and returns them. It runs under a transaction-scoped advisory lock
+// so only one instance marks links at a time; locked is false, with no
+// links, when another instance holds the lock.
+func (r *LinkRepository) MarkExpired(now time.Time, limit int) (links []*models.Link, locked bool, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(hashtext(?))", linkExpiryLock).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		return tx.Raw(`UPDATE links SET expired_at = ?
+			WHERE id IN (
+				SELECT id FROM links
+				WHERE expires_at < ? AND expired_at IS NULL AND deleted_at IS NULL
+				ORDER BY expires_at ASC, id ASC
+				LIMIT ?
+			)
+			RETURNING *`, now, now, limit).
+			Scan(&links).Error
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("error marking expired links: %w", err)
+	}
+	return links, locked, nil
+}
+
+// SetArchived archives a link with the given reason, or restores it when
+// reason is empty
+func (r *LinkRepository) SetArchived(link *models.Link, reason string) error {
+	link.ArchivedAt = nil
+	if reason != "" {
+		now := time.Now()
+		link.ArchivedAt = &now
+	}
+	link.ArchiveReason = reason
+	return r.db.Model(link).Updates(map[string]interface{}{
+		"archived_at":    link.ArchivedAt,
+		"archive_reason": link.ArchiveReason,
+	}).Error
+}
+
+// ArchiveOverLimit archives the organization's active links beyond the
+// oldest limit, newest first, and returns the archived links. The
+// organization row is locked so concurrent calls archive the same set.
+func (r *LinkRepository) ArchiveOverLimit(orgID int64, limit int) ([]*models.Link, error) {
+	var archived []*models.Link
+	now := time.Now()
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, orgID); err != nil {
+			return err
+		}
+		return tx.Raw(`UPDATE links SET archived_at = ?, archive_reason = ?, updated_at = ?
+			WHERE id IN (
+				SELECT id FROM links
+				WHERE organization_id = ? AND archived_at IS NULL AND deleted_at IS NULL
+				ORDER BY created_at ASC, id ASC
+				OFFSET ?
+			)
+			RETURNING *`, now, models.ArchiveReasonPlanLimit, now, orgID, limit).
+			Scan(&archived).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error archiving links: %w", err)
+	}
+	return archived, nil
+}
+
+// RestoreWithinLimit restores the organization's links archived for its plan
+// limit, oldest first, while it has room under limit, and returns the
+// restored links. Links archived by members stay archived.
+func (r *LinkRepository) RestoreWithinLimit(orgID int64, limit int) ([]*models.Link, error) {
+	var restored []*models.Link
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, orgID); err != nil {
+			return err
+		}
+
+		var active int64
+		if err := tx.Model(&models.Link{}).Where("organization_id = ? AND archived_at IS NULL", orgID).Count(&active).Error; err != nil {
+			return err
+		}
+		room := limit - int(active)
+		if room <= 0 {
+			return nil
+		}
+
+		return tx.Raw(`UPDATE links SET archived_at = NULL, archive_reason = '', updated_at = ?
+			WHERE id IN (
+				SELECT id FROM links
+				WHERE organization_id = ? AND archive_reason = ? AND archived_at IS NOT NULL AND deleted_at IS NULL
+				ORDER BY created_at ASC, id ASC
+				LIMIT ?
+			)
+			RETURNING *`, time.Now(), orgID, models.ArchiveReasonPlanLimit, room).
+			Scan(&restored).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error restoring links: %w", err)
+	}
+	return restored, nil
+}
+
+// lockOrganization serializes link limit changes for an organization within tx
+func lockOrganization(tx *gorm.DB, orgID int64) error {
+	var org models.Organization
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&org, orgID).Error
+}
+
+func (r *LinkRepository) Delete(id int64) error {
+	result := r.db.Delete(&models.Link{}, id)
 	if result.Error != nil {
 		return fmt.Errorf("error deleting link: %w", result.Error)
 	}
 	if result.RowsAffected == 0 {
-		return fmt.Errorf("link not found or unauthorized")
+		return fmt.Errorf("link not found")
 	}
 	return nil
 }
 
-func (r *LinkRepository) ShortCodeExists(shortCode string) (bool, error) {
+func (r *LinkRepository) ShortCodeExists(domainID *int64, shortCode string) (bool, error) {
 	var count int64
-	if err := r.db.Model(&models.Link{}).Where("short_code = ?", shortCode).Count(&count).Error; err != nil {
+	query := scopeDomain(r.db.Model(&models.Link{}), domainID)
+	if err := query.Where("short_code = ?", shortCode).Count(&count).Error; err != nil {
 		return false, fmt.Errorf("error checking short code: %w", err)
 	}
 	return count > 0, nil
 }
 
-func (r *LinkRepository) GetByTag(userID int64, tag string) ([]*models.Link, error) {
+func (r *LinkRepository) GetByTag(orgID int64, tag string) ([]*models.Link, error) {
 	var links []*models.Link
-	if err := r.db.Where("user_id = ? AND ? = ANY(tags)", userID, tag).Order("created_at DESC").Find(&links).Error; err != nil {
+	if err := r.db.Preload("Domain").Where("organization_id = ? AND ? = ANY(tags)", orgID, tag).Order("created_at DESC").Find(&links).Error; err != nil {
 		return nil, fmt.Errorf("error getting links by tag: %w", err)
 	}
 	return links, nil
 }
 
-func (r *LinkRepository) GetUserTags(userID int64) ([]string, error) {
+func (r *LinkRepository) GetOrganizationTags(orgID int64) ([]string, error) {
 	var tags []string
-	if err := r.db.Raw("SELECT DISTINCT unnest(tags) as tag FROM links WHERE user_id = ? AND tags IS NOT NULL ORDER BY tag", userID).
+	if err := r.db.Raw("SELECT DISTINCT unnest(tags) as tag FROM links WHERE organization_id = ? AND tags IS NOT NULL AND deleted_at IS NULL ORDER BY tag", orgID).
 		Pluck("tag", &tags).Error; err != nil {
-		return nil, fmt.Errorf("error getting user tags: %w", err)
+		return nil, fmt.Errorf("error getting organization tags: %w", err)
 	}
 
 	filteredTags := []string{}
@@ -241,42 +494,118 @@ func NewAnalyticsRepository(db *gorm.DB) *AnalyticsRepository {
 	return &AnalyticsRepository{db: db}
 }
 
-func (r *AnalyticsRepository) CreateClick(click *models.Click) error {
+// clickInsertBatchSize keeps multi-row click inserts well under Postgres'
+// limit of 65535 bind parameters per statement
+const clickInsertBatchSize = 1000
+
+// CreateClicks inserts a batch of clicks using multi-row inserts
+func (r *AnalyticsRepository) CreateClicks(clicks []*models.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
 	ctx := context.Background()
-	logger.Infof(ctx, "Creating click for link ID: %d", click.LinkID)
+	if err := r.db.WithContext(ctx).CreateInBatches(&clicks, clickInsertBatchSize).Error; err != nil {
+		logger.Errorf(ctx, "Failed to create %d clicks: %+v", len(clicks), err)
+		return fmt.Errorf("error creating clicks: %w", err)
+	}
+	return nil
+}
 
-	if err := r.db.WithContext(ctx).Create(click).Error; err != nil {
-		logger.Errorf(ctx, "Failed to create click: %+v", err)
-		return fmt.Errorf("error creating click: %w", err)
+// IncrementDailyAnalytics adds the given click counts to the daily
+// counters, creating rows as needed
+func (r *AnalyticsRepository) IncrementDailyAnalytics(counts []models.AnalyticsDaily) error {
+	if len(counts) == 0 {
+		return nil
 	}
 
-	logger.Infof(ctx, "Successfully created click with ID: %d", click.ID)
-	go r.UpdateDailyAnalytics(click.LinkID, click.ClickedAt)
+	ctx := context.Background()
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "link_id"}, {Name: "date"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"click_count": gorm.Expr("link_analytics_daily.click_count + excluded.click_count"),
+		}),
+	}).Create(&counts).Error
+	if err != nil {
+		logger.Errorf(ctx, "Failed to update daily analytics: %+v", err)
+		return fmt.Errorf("error updating daily analytics: %w", err)
+	}
 	return nil
 }
 
-func (r *AnalyticsRepository) UpdateDailyAnalytics(linkID int64, clickedAt time.Time) error {
-	date := clickedAt.Format("2006-01-02")
-	return r.db.Exec(`
-		INSERT INTO link_analytics_daily (link_id, date, click_count)
-		VALUES (?, ?, 1)
+// IncrementFailedUnlocks counts a wrong password entered for a protected
+// link on the given day
+func (r *AnalyticsRepository) IncrementFailedUnlocks(linkID int64, day time.Time) error {
+	err := r.db.Exec(`
+		INSERT INTO link_analytics_daily (link_id, date, click_count, failed_unlocks)
+		VALUES (?, ?, 0, 1)
 		ON CONFLICT (link_id, date)
-		DO UPDATE SET click_count = link_analytics_daily.click_count + 1
-	`, linkID, date).Error
+		DO UPDATE SET failed_unlocks = link_analytics_daily.failed_unlocks + 1
+	`, linkID, day.Format("2006-01-02")).Error
+	if err != nil {
+		return fmt.Errorf("error counting failed unlock: %w", err)
+	}
+	return nil
+}
+
+// IncrementClickUsage adds delta to an organization's click count for the
+// month and returns the updated row
+func (r *AnalyticsRepository) IncrementClickUsage(orgID int64, month time.Time, delta int64) (*models.ClickUsage, error) {
+	usage := &models.ClickUsage{}
+	err := r.db.Raw(`
+		INSERT INTO click_usage_monthly (organization_id, month, click_count, notified_percent)
+		VALUES (?, ?, ?, 0)
+		ON CONFLICT (organization_id, month)
+		DO UPDATE SET click_count = click_usage_monthly.click_count + excluded.click_count
+		RETURNING organization_id, month, click_count, notified_percent
+	`, orgID, month.Format("2006-01-02"), delta).Scan(usage).Error
+	if err != nil {
+		return nil, fmt.Errorf("error updating click usage: %w", err)
+	}
+	return usage, nil
+}
+
+// GetClickUsage returns an organization's click usage for the month, or a
+// zero count if nothing has been recorded yet
+func (r *AnalyticsRepository) GetClickUsage(orgID int64, month time.Time) (*models.ClickUsage, error) {
+	usage := &models.ClickUsage{OrganizationID: orgID, Month: month}
+	err := r.db.Where("organization_id = ? AND month = ?", orgID, month.Format("2006-01-02")).
+		Limit(1).Find(usage).Error
+	if err != nil {
+		return nil, fmt.Errorf("error fetching click usage: %w", err)
+	}
+	return usage, nil
+}
+
+// MarkClickUsageNotified records that the percent threshold was announced.
+// It returns false if that threshold (or a higher one) was already marked,
+// so each threshold is announced once per month across instances.
+func (r *AnalyticsRepository) MarkClickUsageNotified(orgID int64, month time.Time, percent int) (bool, error) {
+	result := r.db.Model(&models.ClickUsage{}).
+		Where("organization_id = ? AND month = ? AND notified_percent < ?", orgID, month.Format("2006-01-02"), percent).
+		Update("notified_percent", percent)
+	if result.Error != nil {
+		return false, fmt.Errorf("error updating click usage: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
 }
 
 func (r *AnalyticsRepository) GetLinkStats(linkID int64) (*models.ClickStats, error) {
 	stats := &models.ClickStats{}
 
 	var totalClicks int64
-	r.db.Model(&models.Click{}).Where("link_id = ?", linkID).Count(&totalClicks)
+	r.db.Model(&models.Click{}).Where("link_id = ? AND NOT is_bot", linkID).Count(&totalClicks)
 	stats.TotalClicks = totalClicks
 
 	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
 	var last30Days int64
-	r.db.Model(&models.Click{}).Where("link_id = ? AND clicked_at >= ?", linkID, thirtyDaysAgo).Count(&last30Days)
+	r.db.Model(&models.Click{}).Where("link_id = ? AND NOT is_bot AND clicked_at >= ?", linkID, thirtyDaysAgo).Count(&last30Days)
 	stats.Last30Days = last30Days
 
+	if err := r.db.Model(&models.Click{}).Where("link_id = ? AND is_bot", linkID).Count(&stats.BotClicks).Error; err != nil {
+		return nil, fmt.Errorf("error counting bot clicks: %w", err)
+	}
+
 	var err error
 	stats.DailyClicks, err = r.GetDailyClicks(linkID, 30)
 	if err != nil {
@@ -298,6 +627,26 @@ func (r *AnalyticsRepository) GetLinkStats(linkID int64) (*models.ClickStats, er
 		return nil, err
 	}
 
+	stats.RedirectRules, err = r.GetRedirectRuleStats(linkID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Variants, err = r.GetVariantStats(linkID, 30)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Campaigns, err = r.GetCampaignStats(linkID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.db.Model(&models.AnalyticsDaily{}).Where("link_id = ?", linkID).
+		Select("COALESCE(SUM(failed_unlocks), 0)").Scan(&stats.FailedUnlocks).Error; err != nil {
+		return nil, fmt.Errorf("error getting failed unlocks: %w", err)
+	}
+
 	return stats, nil
 }
 
@@ -332,7 +681,7 @@ func (r *AnalyticsRepository) GetCountryStats(linkID int64) ([]models.CountrySta
 	var countries []models.CountryStats
 	if err := r.db.Model(&models.Click{}).
 		Select("COALESCE(country_code, 'Unknown') as country_code, COUNT(*) as count").
-		Where("link_id = ?", linkID).
+		Where("link_id = ? AND NOT is_bot", linkID).
 		Group("country_code").
 		Order("count DESC").
 		Limit(10).
@@ -346,7 +695,7 @@ func (r *AnalyticsRepository) GetRefererStats(linkID int64) ([]models.RefererSta
 	var referers []models.RefererStats
 	if err := r.db.Model(&models.Click{}).
 		Select("COALESCE(referer, 'Direct') as referer, COUNT(*) as count").
-		Where("link_id = ?", linkID).
+		Where("link_id = ? AND NOT is_bot", linkID).
 		Group("referer").
 		Order("count DESC").
 		Limit(10).
@@ -360,7 +709,7 @@ func (r *AnalyticsRepository) GetDeviceTypeStats(linkID int64) ([]models.DeviceT
 	var deviceTypes []models.DeviceTypeStats
 	if err := r.db.Model(&models.Click{}).
 		Select("COALESCE(device_type, 'Unknown') as device_type, COUNT(*) as count").
-		Where("link_id = ?", linkID).
+		Where("link_id = ? AND NOT is_bot", linkID).
 		Group("device_type").
 		Order("count DESC").
 		Scan(&deviceTypes).Error; err != nil {
@@ -369,17 +718,100 @@ func (r *AnalyticsRepository) GetDeviceTypeStats(linkID int64) ([]models.DeviceT
 	return deviceTypes, nil
 }
 
-func (r *AnalyticsRepository) GetUserAnalytics(userID int64) (map[string]interface{}, error) {
+// GetRedirectRuleStats counts a link's clicks by the redirect rule that
+// routed them
+func (r *AnalyticsRepository) GetRedirectRuleStats(linkID int64) ([]models.RedirectRuleStats, error) {
+	var rules []models.RedirectRuleStats
+	if err := r.db.Model(&models.Click{}).
+		Select("COALESCE(redirect_rule, 'default') as rule, COUNT(*) as count").
+		Where("link_id = ? AND NOT is_bot", linkID).
+		Group("redirect_rule").
+		Order("count DESC").
+		Scan(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// GetCampaignStats counts a link's clicks by the utm_campaign of the URL
+// they were sent to, for the top 10 campaigns
+func (r *AnalyticsRepository) GetCampaignStats(linkID int64) ([]models.CampaignStats, error) {
+	var campaigns []models.CampaignStats
+	if err := r.db.Model(&models.Click{}).
+		Select("utm_campaign as campaign, COUNT(*) as count").
+		Where("link_id = ? AND NOT is_bot AND utm_campaign IS NOT NULL", linkID).
+		Group("utm_campaign").
+		Order("count DESC").
+		Limit(10).
+		Scan(&campaigns).Error; err != nil {
+		return nil, fmt.Errorf("error getting campaign stats: %w", err)
+	}
+	return campaigns, nil
+}
+
+// GetVariantStats counts a link's clicks by A/B variant, with a daily series
+// over the last days
+func (r *AnalyticsRepository) GetVariantStats(linkID int64, days int) ([]models.VariantStats, error) {
+	var totals []struct {
+		Variant string
+		Count   int
+	}
+	if err := r.db.Model(&models.Click{}).
+		Select("variant, COUNT(*) as count").
+		Where("link_id = ? AND NOT is_bot AND variant IS NOT NULL", linkID).
+		Group("variant").
+		Order("count DESC").
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("error getting variant stats: %w", err)
+	}
+	if len(totals) == 0 {
+		return []models.VariantStats{}, nil
+	}
+
+	var daily []struct {
+		Variant string
+		Date    time.Time
+		Count   int
+	}
+	if err := r.db.Model(&models.Click{}).
+		Select("variant, DATE(clicked_at) as date, COUNT(*) as count").
+		Where("link_id = ? AND NOT is_bot AND variant IS NOT NULL AND clicked_at >= ?", linkID, time.Now().AddDate(0, 0, -days)).
+		Group("variant, DATE(clicked_at)").
+		Order("date ASC").
+		Scan(&daily).Error; err != nil {
+		return nil, fmt.Errorf("error getting daily variant stats: %w", err)
+	}
+
+	series := make(map[string][]models.DailyClickCount, len(totals))
+	for _, d := range daily {
+		series[d.Variant] = append(series[d.Variant], models.DailyClickCount{
+			Date:  d.Date.Format("2006-01-02"),
+			Count: d.Count,
+		})
+	}
+
+	variants := make([]models.VariantStats, len(totals))
+	for i, t := range totals {
+		dailyClicks := series[t.Variant]
+		if dailyClicks == nil {
+			dailyClicks = []models.DailyClickCount{}
+		}
+		variants[i] = models.VariantStats{Variant: t.Variant, Count: t.Count, DailyClicks: dailyClicks}
+	}
+	return variants, nil
+}
+
+func (r *AnalyticsRepository) GetOrganizationAnalytics(orgID int64) (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
 
 	var totalLinks int64
-	r.db.Model(&models.Link{}).Where("user_id = ?", userID).Count(&totalLinks)
+	r.db.Model(&models.Link{}).Where("organization_id = ?", orgID).Count(&totalLinks)
 	stats["total_links"] = totalLinks
 
 	var totalClicks int64
 	r.db.Table("clicks c").
 		Joins("INNER JOIN links l ON c.link_id = l.id").
-		Where("l.user_id = ?", userID).
+		Where("l.organization_id = ? AND NOT c.is_bot", orgID).
 		Count(&totalClicks)
 	stats["total_clicks"] = totalClicks
 
@@ -389,7 +821,7 @@ func (r *AnalyticsRepository) GetUserAnalytics(userID int64) (map[string]interfa
 	var monthClicks int64
 	r.db.Table("clicks c").
 		Joins("INNER JOIN links l ON c.link_id = l.id").
-		Where("l.user_id = ? AND c.clicked_at >= ?", userID, firstOfMonth).
+		Where("l.organization_id = ? AND NOT c.is_bot AND c.clicked_at >= ?", orgID, firstOfMonth).
 		Count(&monthClicks)
 	stats["clicks_this_month"] = monthClicks
 
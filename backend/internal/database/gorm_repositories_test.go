@@ -0,0 +1,75 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("creating mock database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	return db, mock
+}
+
+func TestLinkRepositoryCreateReportsShortCodeConflicts(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantConflict bool
+	}{
+		{
+			name:         "code taken on the default domain",
+			err:          &pgconn.PgError{Code: "23505", ConstraintName: "idx_links_default_short_code"},
+			wantConflict: true,
+		},
+		{
+			name:         "code taken on a custom domain",
+			err:          &pgconn.PgError{Code: "23505", ConstraintName: "idx_links_domain_short_code"},
+			wantConflict: true,
+		},
+		{
+			name: "other unique index",
+			err:  &pgconn.PgError{Code: "23505", ConstraintName: "links_pkey"},
+		},
+		{
+			name: "other error",
+			err:  errors.New("connection reset"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO "links"`).WillReturnError(tt.err)
+			mock.ExpectRollback()
+
+			err := NewLinkRepository(db).Create(&models.Link{UserID: 7, OrganizationID: 3, ShortCode: "sale", DestinationURL: "https://example.com/"})
+			if err == nil {
+				t.Fatal("Create() succeeded, want an error")
+			}
+			if errors.Is(err, ErrShortCodeConflict) != tt.wantConflict {
+				t.Errorf("Create() error = %v, want conflict %v", err, tt.wantConflict)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// ErrSessionNotFound is returned when a session lookup matches no rows
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionRepository implementation using GORM
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) Create(session *models.Session) error {
+	ctx := context.Background()
+	logger.Infof(ctx, "Creating session for user ID: %d", session.UserID)
+
+	if err := r.db.WithContext(ctx).Create(session).Error; err != nil {
+		logger.Errorf(ctx, "Failed to create session: %+v", err)
+		return fmt.Errorf("error creating session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) GetByID(id int64) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.First(&session, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("error getting session: %w", err)
+	}
+	return &session, nil
+}
+
+// GetByTokenHash finds the session whose current or previous refresh token
+// has the given hash
+func (r *SessionRepository) GetByTokenHash(hash string) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.Where("refresh_token_hash = ? OR previous_token_hash = ?", hash, hash).First(&session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("error getting session: %w", err)
+	}
+	return &session, nil
+}
+
+// ListActiveByUserID lists the user's sessions that are neither revoked nor expired
+func (r *SessionRepository) ListActiveByUserID(userID int64) ([]*models.Session, error) {
+	var sessions []*models.Session
+	if err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("error listing sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// Rotate swaps the session's refresh token. It only succeeds if currentHash
+// is still the active token, so two concurrent refreshes can't both win.
+func (r *SessionRepository) Rotate(session *models.Session, currentHash, newHash string) (bool, error) {
+	result := r.db.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.ID, currentHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  newHash,
+			"previous_token_hash": currentHash,
+			"ip_address":          session.IPAddress,
+			"user_agent":          session.UserAgent,
+			"device":              session.Device,
+			"last_seen_at":        session.LastSeenAt,
+			"expires_at":          session.ExpiresAt,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("error rotating session: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// TouchLastSeen records session activity, writing at most once per interval
+func (r *SessionRepository) TouchLastSeen(id int64, seenAt time.Time, interval time.Duration) error {
+	return r.db.Model(&models.Session{}).
+		Where("id = ? AND last_seen_at < ?", id, seenAt.Add(-interval)).
+		Update("last_seen_at", seenAt).Error
+}
+
+// Revoke revokes one of the user's sessions
+func (r *SessionRepository) Revoke(id, userID int64) error {
+	result := r.db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("error revoking session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeAllByUserID revokes all of the user's sessions except exceptID,
+// which may be zero to revoke every session
+func (r *SessionRepository) RevokeAllByUserID(userID, exceptID int64) (int64, error) {
+	result := r.db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return 0, fmt.Errorf("error revoking sessions: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
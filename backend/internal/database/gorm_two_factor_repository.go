@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// TwoFactorRepository stores users' TOTP enrollment and recovery codes
+type TwoFactorRepository struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorRepository(db *gorm.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+// SetSecret stores a pending TOTP secret. Two-factor stays disabled until
+// Enable is called.
+func (r *TwoFactorRepository) SetSecret(userID int64, secret string) error {
+	err := r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("error storing TOTP secret: %w", err)
+	}
+	return nil
+}
+
+// Enable turns on two-factor for the user, recording the step of the
+// confirming code and replacing any recovery codes
+func (r *TwoFactorRepository) Enable(userID int64, step int64, codeHashes []string) error {
+	ctx := context.Background()
+	logger.Infof(ctx, "Enabling two-factor authentication for user ID: %d", userID)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"two_factor_enabled": true,
+			"totp_last_step":     step,
+		}).Error; err != nil {
+			return fmt.Errorf("error enabling two-factor: %w", err)
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// Disable turns off two-factor, clearing the secret and recovery codes
+func (r *TwoFactorRepository) Disable(userID int64) error {
+	ctx := context.Background()
+	logger.Infof(ctx, "Disabling two-factor authentication for user ID: %d", userID)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"two_factor_enabled": false,
+			"totp_secret":        "",
+			"totp_last_step":     0,
+		}).Error; err != nil {
+			return fmt.Errorf("error disabling two-factor: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("error deleting recovery codes: %w", err)
+		}
+		return nil
+	})
+}
+
+// AdvanceStep records step as the user's last accepted TOTP step. It
+// reports false if that step, or a later one, was already used.
+func (r *TwoFactorRepository) AdvanceStep(userID int64, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, fmt.Errorf("error recording TOTP step: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReplaceRecoveryCodes discards the user's recovery codes and stores new ones
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID int64, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return fmt.Errorf("error deleting recovery codes: %w", err)
+	}
+
+	codes := make([]models.RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	if err := tx.Create(&codes).Error; err != nil {
+		return fmt.Errorf("error creating recovery codes: %w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used, reporting whether
+// one matched
+func (r *TwoFactorRepository) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("error using recovery code: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CountUnusedRecoveryCodes counts the user's remaining recovery codes
+func (r *TwoFactorRepository) CountUnusedRecoveryCodes(userID int64) (int64, error) {
+	var count int64
+	if err := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("error counting recovery codes: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// ErrWebhookNotFound is returned when no webhook matches an ID
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// WebhookRepository implementation using GORM
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) Create(webhook *models.Webhook) error {
+	ctx := context.Background()
+	logger.Infof(ctx, "Creating webhook for organization ID: %d", webhook.OrganizationID)
+
+	if err := r.db.WithContext(ctx).Create(webhook).Error; err != nil {
+		logger.Errorf(ctx, "Failed to create webhook: %+v", err)
+		return fmt.Errorf("error creating webhook: %w", err)
+	}
+
+	logger.Infof(ctx, "Successfully created webhook with ID: %d", webhook.ID)
+	return nil
+}
+
+func (r *WebhookRepository) GetByID(id int64) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := r.db.First(&webhook, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("error getting webhook: %w", err)
+	}
+	return &webhook, nil
+}
+
+// GetByOrganizationID returns the organization's webhooks, newest first
+func (r *WebhookRepository) GetByOrganizationID(orgID int64) ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	if err := r.db.Where("organization_id = ?", orgID).Order("created_at DESC").Find(&webhooks).Error; err != nil {
+		return nil, fmt.Errorf("error getting webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// GetActiveForEvent returns the organization's active webhooks subscribed
+// to an event
+func (r *WebhookRepository) GetActiveForEvent(orgID int64, event string) ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	if err := r.db.Where("organization_id = ? AND is_active = ? AND ? = ANY(events)", orgID, true, event).
+		Find(&webhooks).Error; err != nil {
+		return nil, fmt.Errorf("error getting webhooks for event: %w", err)
+	}
+	return webhooks, nil
+}
+
+func (r *WebhookRepository) Update(webhook *models.Webhook) error {
+	return r.db.Model(webhook).Updates(map[string]interface{}{
+		"url":         webhook.URL,
+		"description": webhook.Description,
+		"events":      webhook.Events,
+		"is_active":   webhook.IsActive,
+	}).Error
+}
+
+// UpdateSecret replaces a webhook's signing secret
+func (r *WebhookRepository) UpdateSecret(webhook *models.Webhook) error {
+	return r.db.Model(webhook).Update("secret", webhook.Secret).Error
+}
+
+func (r *WebhookRepository) Delete(id int64, orgID int64) error {
+	result := r.db.Where("id = ? AND organization_id = ?", id, orgID).Delete(&models.Webhook{})
+	if result.Error != nil {
+		return fmt.Errorf("error deleting webhook: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *WebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	if err := r.db.Create(delivery).Error; err != nil {
+		return fmt.Errorf("error creating webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Model(delivery).Updates(map[string]interface{}{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"response_status": delivery.ResponseStatus,
+		"last_error":      delivery.LastError,
+		"duration_ms":     delivery.DurationMs,
+		"next_retry_at":   delivery.NextRetryAt,
+		"delivered_at":    delivery.DeliveredAt,
+		"locked_until":    nil,
+	}).Error
+}
+
+// ClaimDelivery locks a pending delivery that is due for lease, returning
+// its current state. It returns nil when the delivery is finished, not yet
+// due, or locked by another instance, so each attempt is sent only once.
+func (r *WebhookRepository) ClaimDelivery(id int64, lease time.Duration) (*models.WebhookDelivery, error) {
+	now := time.Now().UTC()
+	delivery := &models.WebhookDelivery{}
+	err := r.db.Raw(`
+		UPDATE webhook_deliveries SET locked_until = ?
+		WHERE id = ? AND status = ?
+			AND (next_retry_at IS NULL OR next_retry_at <= ?)
+			AND (locked_until IS NULL OR locked_until <= ?)
+		RETURNING *
+	`, now.Add(lease), id, models.DeliveryPending, now, now).Scan(delivery).Error
+	if err != nil {
+		return nil, fmt.Errorf("error claiming webhook delivery: %w", err)
+	}
+	if delivery.ID == 0 {
+		return nil, nil
+	}
+	return delivery, nil
+}
+
+// GetPendingDeliveries returns deliveries that still have attempts outstanding
+func (r *WebhookRepository) GetPendingDeliveries(limit int) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	if err := r.db.Where("status = ?", models.DeliveryPending).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("error getting pending deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) GetDeliveries(webhookID int64, limit, offset int) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	if err := r.db.Where("webhook_id = ?", webhookID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("error getting webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) GetStats(orgID int64) (*models.WebhookStats, error) {
+	stats := &models.WebhookStats{}
+
+	if err := r.db.Model(&models.Webhook{}).Where("organization_id = ?", orgID).Count(&stats.TotalWebhooks).Error; err != nil {
+		return nil, fmt.Errorf("error counting webhooks: %w", err)
+	}
+	if err := r.db.Model(&models.Webhook{}).Where("organization_id = ? AND is_active = ?", orgID, true).
+		Count(&stats.ActiveWebhooks).Error; err != nil {
+		return nil, fmt.Errorf("error counting active webhooks: %w", err)
+	}
+
+	deliveries := r.db.Table("webhook_deliveries d").
+		Joins("INNER JOIN webhooks w ON d.webhook_id = w.id").
+		Where("w.organization_id = ?", orgID)
+
+	now := time.Now().UTC()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if err := deliveries.Session(&gorm.Session{}).
+		Where("d.created_at >= ?", startOfDay).
+		Count(&stats.EventsSentToday).Error; err != nil {
+		return nil, fmt.Errorf("error counting deliveries: %w", err)
+	}
+
+	// Success rate covers finished deliveries over the last 30 days
+	var outcome struct {
+		Succeeded int64
+		Finished  int64
+	}
+	if err := deliveries.Session(&gorm.Session{}).
+		Select("COUNT(*) FILTER (WHERE d.status = ?) AS succeeded, COUNT(*) FILTER (WHERE d.status <> ?) AS finished",
+			models.DeliverySucceeded, models.DeliveryPending).
+		Where("d.created_at >= ?", now.AddDate(0, 0, -30)).
+		Scan(&outcome).Error; err != nil {
+		return nil, fmt.Errorf("error computing success rate: %w", err)
+	}
+	if outcome.Finished > 0 {
+		stats.SuccessRate = float64(outcome.Succeeded) / float64(outcome.Finished) * 100
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/internal/logger"
+)
+
+// Event types published by the application
+const (
+	LinkCreated = "link.created"
+	LinkClicked = "link.clicked"
+	LinkDeleted = "link.deleted"
+	LinkExpired = "link.expired"
+
+	ClickQuotaWarning  = "quota.clicks_warning"
+	ClickQuotaExceeded = "quota.clicks_exceeded"
+	LinkQuotaExceeded  = "quota.links_exceeded"
+	LinkQuotaArchived  = "quota.links_archived"
+
+	SubscriptionChanged     = "subscription.changed"
+	OrganizationTierChanged = "organization.tier_changed"
+	LinkDestinationChanged  = "link.destination_changed"
+)
+
+// WebhookEvents lists the events that webhooks can subscribe to
+var WebhookEvents = []string{LinkCreated, LinkClicked, LinkDeleted, LinkExpired, ClickQuotaWarning, ClickQuotaExceeded, LinkQuotaExceeded, LinkQuotaArchived}
+
+// IsWebhookEvent reports whether the event type can be subscribed to by webhooks
+func IsWebhookEvent(eventType string) bool {
+	for _, e := range WebhookEvents {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is an internal application event
+type Event struct {
+	Type   string
+	UserID int64
+	// OrganizationID is the organization the event happened in, if any
+	OrganizationID int64
+	OccurredAt     time.Time
+	Data           interface{}
+}
+
+// Handler consumes published events. Handlers run synchronously on the
+// publisher's goroutine, so they must hand off any slow work.
+type Handler func(ctx context.Context, event Event)
+
+// Bus fans events out to all subscribed handlers
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a handler for all events
+func (b *Bus) Subscribe(handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Publish delivers an event to every subscribed handler
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	if b == nil {
+		return
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+
+	b.mu.RLock()
+	handlers := make([]Handler, len(b.handlers))
+	copy(handlers, b.handlers)
+	b.mu.RUnlock()
+
+	logger.Debugf(ctx, "Publishing event %s for user ID: %d to %d handlers", event.Type, event.UserID, len(handlers))
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
@@ -0,0 +1,46 @@
+// Package mail sends transactional email through a pluggable Mailer.
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shafikshaon/url_shortener/config"
+)
+
+// Message is a rendered email ready to send
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Mailer drivers
+const (
+	DriverSendGrid = "sendgrid"
+	DriverFile     = "file"
+	DriverMemory   = "memory"
+)
+
+// NewMailer builds the mailer selected by cfg.Email.Driver
+func NewMailer(cfg *config.Config) (Mailer, error) {
+	switch cfg.Email.Driver {
+	case DriverSendGrid:
+		if cfg.Email.SendGridAPIKey == "" {
+			return nil, fmt.Errorf("SENDGRID_API_KEY is required for the sendgrid mail driver")
+		}
+		return NewSendGridMailer(cfg.Email.SendGridAPIKey, cfg.Email.FromEmail, cfg.Email.FromName), nil
+	case DriverFile:
+		return NewFileMailer(cfg.Email.FileDir, cfg.Email.FromEmail)
+	case DriverMemory:
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.Email.Driver)
+	}
+}
@@ -0,0 +1,90 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const sendGridEndpoint = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGridMailer sends email through the SendGrid v3 API
+type SendGridMailer struct {
+	apiKey   string
+	from     string
+	fromName string
+	client   *http.Client
+}
+
+func NewSendGridMailer(apiKey, from, fromName string) *SendGridMailer {
+	return &SendGridMailer{
+		apiKey:   apiKey,
+		from:     from,
+		fromName: fromName,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+func (m *SendGridMailer) Send(ctx context.Context, msg *Message) error {
+	req := sendGridRequest{
+		Personalizations: []sendGridPersonalization{{To: []sendGridAddress{{Email: msg.To}}}},
+		From:             sendGridAddress{Email: m.from, Name: m.fromName},
+		Subject:          msg.Subject,
+	}
+	// SendGrid requires text/plain before text/html
+	if msg.Text != "" {
+		req.Content = append(req.Content, sendGridContent{Type: "text/plain", Value: msg.Text})
+	}
+	if msg.HTML != "" {
+		req.Content = append(req.Content, sendGridContent{Type: "text/html", Value: msg.HTML})
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("error encoding email: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, sendGridEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error building SendGrid request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+m.apiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("SendGrid returned %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	return nil
+}
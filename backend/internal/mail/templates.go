@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+// Template names. Each has a .txt and a .html file under templates/.
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+)
+
+var subjects = map[string]string{
+	TemplateVerifyEmail:   "Verify your email address",
+	TemplatePasswordReset: "Reset your password",
+}
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+)
+
+// Render builds a message to to from the named template
+func Render(name, to string, data any) (*Message, error) {
+	subject, ok := subjects[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template: %s", name)
+	}
+
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s text: %w", name, err)
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s HTML: %w", name, err)
+	}
+
+	return &Message{
+		To:      to,
+		Subject: subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,251 @@
+// Package metadata fetches a link's destination page and reads its title,
+// description, favicon and canonical URL.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/netguard"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	userAgent    = "Mozilla/5.0 (compatible; url-shortener-metadata/1.0)"
+	maxRedirects = 5
+
+	// Limits on what is kept from a page. Titles match the links column.
+	MaxTitleLength       = 500
+	MaxDescriptionLength = 1000
+	maxURLLength         = 2048
+)
+
+var (
+	// ErrNotHTML is returned when the destination isn't an HTML page
+	ErrNotHTML = errors.New("destination is not an HTML page")
+	// ErrTooManyRedirects is returned when the destination keeps redirecting
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+// Metadata is what a destination page says about itself. Fields the page
+// doesn't set are empty.
+type Metadata struct {
+	Title        string
+	Description  string
+	FaviconURL   string
+	CanonicalURL string
+}
+
+// Fetcher retrieves destination pages. Requests only reach public
+// addresses, give up after a timeout and read a bounded prefix of the page,
+// which is enough for its head.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+func NewFetcher(cfg *config.Config) *Fetcher {
+	timeout := time.Duration(cfg.Metadata.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	maxBytes := int64(cfg.Metadata.MaxBytes)
+	if maxBytes <= 0 {
+		maxBytes = 512 * 1024
+	}
+	return newFetcher(timeout, maxBytes, netguard.Control)
+}
+
+// newFetcher builds a fetcher whose connections are vetted by control
+func newFetcher(timeout time.Duration, maxBytes int64, control func(network, address string, c syscall.RawConn) error) *Fetcher {
+	dialer := &net.Dialer{Timeout: timeout, Control: control}
+	transport := &http.Transport{
+		// No proxy: it would connect to the destination on our behalf,
+		// past the address check
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	return &Fetcher{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return ErrTooManyRedirects
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+				}
+				return nil
+			},
+		},
+		maxBytes: maxBytes,
+	}
+}
+
+// Fetch retrieves rawURL and reads the metadata in its head
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Metadata, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", target.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil ||
+		(mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return nil, ErrNotHTML
+	}
+
+	var body io.Reader = io.LimitReader(resp.Body, f.maxBytes)
+	if decoded, err := charset.NewReader(body, contentType); err == nil {
+		body = decoded
+	}
+	return parse(body, resp.Request.URL), nil
+}
+
+// parse reads the metadata from a page's head. Open Graph titles and
+// descriptions are preferred over the plain ones, and relative URLs are
+// resolved against base, where the page was served from.
+func parse(r io.Reader, base *url.URL) *Metadata {
+	var title, ogTitle, description, ogDescription, favicon, canonical string
+
+	z := html.NewTokenizer(r)
+	for done := false; !done; {
+		switch z.Next() {
+		case html.ErrorToken:
+			done = true
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				done = true
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			switch token.Data {
+			case "body":
+				done = true
+			case "title":
+				if title == "" && z.Next() == html.TextToken {
+					title = z.Token().Data
+				}
+			case "meta":
+				content := attr(token, "content")
+				switch strings.ToLower(attr(token, "property") + attr(token, "name")) {
+				case "og:title":
+					ogTitle = content
+				case "description":
+					description = content
+				case "og:description":
+					ogDescription = content
+				}
+			case "link":
+				rels := strings.Fields(strings.ToLower(attr(token, "rel")))
+				for _, rel := range rels {
+					if rel == "icon" && favicon == "" {
+						favicon = resolve(base, attr(token, "href"))
+					}
+					if rel == "canonical" && canonical == "" {
+						canonical = resolve(base, attr(token, "href"))
+					}
+				}
+			}
+		}
+	}
+
+	if favicon == "" {
+		favicon = resolve(base, "/favicon.ico")
+	}
+	return &Metadata{
+		Title:        clean(firstNonEmpty(ogTitle, title), MaxTitleLength),
+		Description:  clean(firstNonEmpty(ogDescription, description), MaxDescriptionLength),
+		FaviconURL:   favicon,
+		CanonicalURL: canonical,
+	}
+}
+
+func attr(token html.Token, key string) string {
+	for _, a := range token.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// resolve returns ref as an absolute http(s) URL, or "" if it isn't one
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	resolved := u.String()
+	if len(resolved) > maxURLLength {
+		return ""
+	}
+	return resolved
+}
+
+// clean collapses whitespace, drops control characters and invalid UTF-8
+// and truncates s to max characters
+func clean(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(s, ""))
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > max {
+		s = strings.TrimSpace(string(runes[:max]))
+	}
+	return s
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
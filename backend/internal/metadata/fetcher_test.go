@@ -0,0 +1,233 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/internal/netguard"
+)
+
+// allowAll lets the test fetcher reach httptest servers on loopback
+func allowAll(string, string, syscall.RawConn) error { return nil }
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post?id=1")
+
+	tests := []struct {
+		name string
+		html string
+		want Metadata
+	}{
+		{
+			name: "plain tags",
+			html: `<html><head><title>Spring Sale</title>
+				<meta name="description" content="Everything 30% off">
+				<link rel="icon" href="/static/icon.png">
+				<link rel="canonical" href="https://example.com/sale">
+				</head><body>ignored</body></html>`,
+			want: Metadata{
+				Title:        "Spring Sale",
+				Description:  "Everything 30% off",
+				FaviconURL:   "https://example.com/static/icon.png",
+				CanonicalURL: "https://example.com/sale",
+			},
+		},
+		{
+			name: "open graph preferred",
+			html: `<head><title>Plain</title><meta property="og:title" content="OG Title">
+				<meta name="description" content="Plain description">
+				<meta property="og:description" content="OG description"></head>`,
+			want: Metadata{
+				Title:       "OG Title",
+				Description: "OG description",
+				FaviconURL:  "https://example.com/favicon.ico",
+			},
+		},
+		{
+			name: "relative and shortcut icon",
+			html: `<head><link rel="shortcut icon" href="icon.ico"><link rel="canonical" href="../post"></head>`,
+			want: Metadata{
+				FaviconURL:   "https://example.com/blog/icon.ico",
+				CanonicalURL: "https://example.com/post",
+			},
+		},
+		{
+			name: "unsafe URLs dropped",
+			html: `<head><link rel="icon" href="javascript:alert(1)"><link rel="canonical" href="data:text/html,hi"></head>`,
+			want: Metadata{FaviconURL: "https://example.com/favicon.ico"},
+		},
+		{
+			name: "whitespace and control characters cleaned",
+			html: "<head><title>\n  Spring\t\x01Sale \n</title></head>",
+			want: Metadata{Title: "Spring Sale", FaviconURL: "https://example.com/favicon.ico"},
+		},
+		{
+			name: "tags in the body ignored",
+			html: `<head></head><body><title>Not this</title></body>`,
+			want: Metadata{FaviconURL: "https://example.com/favicon.ico"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parse(strings.NewReader(tt.html), base)
+			if *got != tt.want {
+				t.Errorf("parse() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTruncatesLongValues(t *testing.T) {
+	base, _ := url.Parse("https://example.com/")
+	page := "<head><title>" + strings.Repeat("é", MaxTitleLength+10) + "</title>" +
+		`<meta name="description" content="` + strings.Repeat("a", MaxDescriptionLength+10) + `"></head>`
+
+	got := parse(strings.NewReader(page), base)
+	if n := len([]rune(got.Title)); n != MaxTitleLength {
+		t.Errorf("title has %d characters, want %d", n, MaxTitleLength)
+	}
+	if n := len([]rune(got.Description)); n != MaxDescriptionLength {
+		t.Errorf("description has %d characters, want %d", n, MaxDescriptionLength)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Landing</title><link rel="icon" href="/icon.png"></head></html>`))
+	})
+	mux.HandleFunc("/latin1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Write([]byte("<head><title>Caf\xe9</title></head>"))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		wantTitle   string
+		wantFavicon string
+		wantErr     error
+		wantAnyErr  bool
+	}{
+		{name: "html page", path: "/page", wantTitle: "Landing", wantFavicon: server.URL + "/icon.png"},
+		{name: "legacy charset", path: "/latin1", wantTitle: "Café", wantFavicon: server.URL + "/favicon.ico"},
+		{name: "redirect resolves against final URL", path: "/moved", wantTitle: "Landing", wantFavicon: server.URL + "/icon.png"},
+		{name: "redirect loop", path: "/loop", wantErr: ErrTooManyRedirects},
+		{name: "not html", path: "/image", wantErr: ErrNotHTML},
+		{name: "error status", path: "/missing", wantAnyErr: true},
+	}
+
+	f := newFetcher(5*time.Second, 64*1024, allowAll)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := f.Fetch(context.Background(), server.URL+tt.path)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Fetch() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantAnyErr:
+				if err == nil {
+					t.Fatal("Fetch() succeeded, want an error")
+				}
+				return
+			case err != nil:
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if meta.Title != tt.wantTitle || meta.FaviconURL != tt.wantFavicon {
+				t.Errorf("Fetch() = %+v, want title %q and favicon %q", *meta, tt.wantTitle, tt.wantFavicon)
+			}
+		})
+	}
+}
+
+func TestFetchRefusesPrivateAddresses(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<title>internal</title>"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "loopback address", url: server.URL},
+		{name: "localhost", url: strings.Replace(server.URL, "127.0.0.1", "localhost", 1)},
+	}
+
+	// The production fetcher vets every connection with netguard.Control
+	f := newFetcher(5*time.Second, 64*1024, netguard.Control)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.Fetch(context.Background(), tt.url); !errors.Is(err, netguard.ErrBlockedAddress) {
+				t.Fatalf("Fetch(%s) error = %v, want %v", tt.url, err, netguard.ErrBlockedAddress)
+			}
+		})
+	}
+	if hits != 0 {
+		t.Errorf("internal server received %d requests", hits)
+	}
+}
+
+func TestFetchRefusesRedirectsToPrivateAddresses(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<title>internal</title>"))
+	}))
+	defer internal.Close()
+
+	// The redirecting server stands in for a public page; only its own
+	// address is let through, as netguard.Control would for a public host
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusFound)
+	}))
+	defer public.Close()
+	publicAddr := strings.TrimPrefix(public.URL, "http://")
+
+	f := newFetcher(5*time.Second, 64*1024, func(network, address string, c syscall.RawConn) error {
+		if address != publicAddr {
+			return netguard.ErrBlockedAddress
+		}
+		return nil
+	})
+	if _, err := f.Fetch(context.Background(), public.URL); !errors.Is(err, netguard.ErrBlockedAddress) {
+		t.Fatalf("Fetch() error = %v, want %v", err, netguard.ErrBlockedAddress)
+	}
+}
+
+func TestFetchRejectsOtherSchemes(t *testing.T) {
+	f := newFetcher(time.Second, 1024, allowAll)
+	for _, rawURL := range []string{"ftp://example.com/", "file:///etc/passwd", "javascript:alert(1)"} {
+		t.Run(rawURL, func(t *testing.T) {
+			if _, err := f.Fetch(context.Background(), rawURL); err == nil {
+				t.Fatalf("Fetch(%s) succeeded, want an error", rawURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+// API key scopes
+const (
+	ScopeLinksRead     = "links:read"
+	ScopeLinksWrite    = "links:write"
+	ScopeAnalyticsRead = "analytics:read"
+)
+
+// APIKeyScopes lists every scope a key can be granted
+var APIKeyScopes = []string{ScopeLinksRead, ScopeLinksWrite, ScopeAnalyticsRead}
+
+// IsValidAPIKeyScope reports whether scope is a known API key scope
+func IsValidAPIKeyScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is a named credential for programmatic access. Only a hash of the
+// secret is stored; Prefix identifies the key for lookup and display.
+type APIKey struct {
+	ID         int64      `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	UserID     int64      `json:"user_id" db:"user_id" gorm:"not null;index"`
+	Name       string     `json:"name" db:"name" gorm:"not null;size:100"`
+	Prefix     string     `json:"prefix" db:"prefix" gorm:"uniqueIndex;not null;size:32"`
+	SecretHash string     `json:"-" db:"secret_hash" gorm:"not null;size:64"`
+	Scopes     StringList `json:"scopes" db:"scopes" gorm:"type:text[]"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for APIKey
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// IsExpired checks if the key has passed its expiry
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
+
+// IsActive reports whether the key can still authenticate
+func (k *APIKey) IsActive() bool {
+	return k.RevokedAt == nil && !k.IsExpired()
+}
+
+// HasScope checks if the key was granted scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
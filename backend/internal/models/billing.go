@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+)
+
+// Stripe subscription statuses that keep the paid tier. past_due keeps it
+// while Stripe retries the payment; Stripe then cancels or marks the
+// subscription unpaid.
+var activeSubscriptionStatuses = []string{"active", "trialing", "past_due"}
+
+// Subscription links a user to their Stripe customer and mirrors their
+// current Stripe subscription. LastEventAt is the creation time of the last
+// applied webhook event, so older events delivered late are ignored.
+type Subscription struct {
+	ID                   int64            `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	UserID               int64            `json:"user_id" db:"user_id" gorm:"uniqueIndex;not null"`
+	StripeCustomerID     string           `json:"-" db:"stripe_customer_id" gorm:"uniqueIndex;not null;size:255"`
+	StripeSubscriptionID string           `json:"-" db:"stripe_subscription_id" gorm:"size:255"`
+	PriceID              string           `json:"-" db:"price_id" gorm:"size:255"`
+	Tier                 SubscriptionTier `json:"tier" db:"tier" gorm:"type:varchar(50);default:'free'"`
+	Status               string           `json:"status" db:"status" gorm:"size:50"`
+	CurrentPeriodEnd     *time.Time       `json:"current_period_end,omitempty" db:"current_period_end"`
+	CancelAtPeriodEnd    bool             `json:"cancel_at_period_end" db:"cancel_at_period_end" gorm:"not null;default:false"`
+	LastEventAt          *time.Time       `json:"-" db:"last_event_at"`
+	CreatedAt            time.Time        `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt            time.Time        `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for Subscription
+func (Subscription) TableName() string {
+	return "subscriptions"
+}
+
+// IsActive reports whether the subscription currently grants a paid tier
+func (s *Subscription) IsActive() bool {
+	return IsActiveSubscriptionStatus(s.Status)
+}
+
+// IsActiveSubscriptionStatus reports whether a Stripe subscription status
+// keeps the paid tier
+func IsActiveSubscriptionStatus(status string) bool {
+	for _, s := range activeSubscriptionStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Invoice is a Stripe invoice kept for billing history. Amounts are in the
+// currency's smallest unit.
+type Invoice struct {
+	ID               int64      `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	UserID           int64      `json:"user_id" db:"user_id" gorm:"not null;index"`
+	StripeInvoiceID  string     `json:"-" db:"stripe_invoice_id" gorm:"uniqueIndex;not null;size:255"`
+	Number           string     `json:"number" db:"number" gorm:"size:100"`
+	Description      string     `json:"description" db:"description" gorm:"type:text"`
+	Status           string     `json:"status" db:"status" gorm:"size:50"`
+	AmountDue        int64      `json:"amount_due" db:"amount_due" gorm:"not null;default:0"`
+	AmountPaid       int64      `json:"amount_paid" db:"amount_paid" gorm:"not null;default:0"`
+	Currency         string     `json:"currency" db:"currency" gorm:"size:3"`
+	HostedInvoiceURL string     `json:"hosted_invoice_url,omitempty" db:"hosted_invoice_url" gorm:"type:text"`
+	PeriodStart      *time.Time `json:"period_start,omitempty" db:"period_start"`
+	PeriodEnd        *time.Time `json:"period_end,omitempty" db:"period_end"`
+	IssuedAt         time.Time  `json:"issued_at" db:"issued_at"`
+	LastEventAt      time.Time  `json:"-" db:"last_event_at"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for Invoice
+func (Invoice) TableName() string {
+	return "invoices"
+}
+
+// BillingEvent records a processed Stripe webhook event, so redelivered
+// events are applied only once
+type BillingEvent struct {
+	ID          string    `json:"id" db:"id" gorm:"primaryKey;size:255"`
+	Type        string    `json:"type" db:"type" gorm:"size:100;not null"`
+	ProcessedAt time.Time `json:"processed_at" db:"processed_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for BillingEvent
+func (BillingEvent) TableName() string {
+	return "billing_events"
+}
@@ -7,21 +7,29 @@ import (
 )
 
 type Click struct {
-	ID          int64          `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
-	LinkID      int64          `json:"link_id" db:"link_id" gorm:"not null;index"`
-	ClickedAt   time.Time      `json:"clicked_at" db:"clicked_at" gorm:"not null;index"`
-	IPAddress   string         `json:"ip_address" db:"ip_address" gorm:"size:45"`
-	CountryCode *string        `json:"country_code,omitempty" db:"country_code" gorm:"size:2;index"`
-	Referer     *string        `json:"referer,omitempty" db:"referer" gorm:"type:text"`
-	UserAgent   *string        `json:"user_agent,omitempty" db:"user_agent" gorm:"type:text"`
-	DeviceType  *string        `json:"device_type,omitempty" db:"device_type" gorm:"size:50"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           int64          `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	LinkID       int64          `json:"link_id" db:"link_id" gorm:"not null;index"`
+	ClickedAt    time.Time      `json:"clicked_at" db:"clicked_at" gorm:"not null;index"`
+	IPAddress    string         `json:"ip_address" db:"ip_address" gorm:"size:45"`
+	CountryCode  *string        `json:"country_code,omitempty" db:"country_code" gorm:"size:2;index"`
+	Referer      *string        `json:"referer,omitempty" db:"referer" gorm:"type:text"`
+	UserAgent    *string        `json:"user_agent,omitempty" db:"user_agent" gorm:"type:text"`
+	DeviceType   *string        `json:"device_type,omitempty" db:"device_type" gorm:"size:50"`
+	RedirectRule *string        `json:"redirect_rule,omitempty" db:"redirect_rule" gorm:"size:50"`
+	Variant      *string        `json:"variant,omitempty" db:"variant" gorm:"size:50"`
+	DeepLinkPath *string        `json:"deep_link_path,omitempty" db:"deep_link_path" gorm:"size:10"`
+	UTMCampaign  *string        `json:"utm_campaign,omitempty" db:"utm_campaign" gorm:"size:255"`
+	IsBot        bool           `json:"is_bot" db:"is_bot" gorm:"not null;default:false"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// AnalyticsDaily holds a link's daily counters. FailedUnlocks counts wrong
+// passwords entered for protected links; they are not clicks.
 type AnalyticsDaily struct {
-	LinkID     int64     `json:"link_id" db:"link_id" gorm:"primaryKey"`
-	Date       time.Time `json:"date" db:"date" gorm:"primaryKey;type:date"`
-	ClickCount int       `json:"click_count" db:"click_count" gorm:"default:0"`
+	LinkID        int64     `json:"link_id" db:"link_id" gorm:"primaryKey"`
+	Date          time.Time `json:"date" db:"date" gorm:"primaryKey;type:date"`
+	ClickCount    int       `json:"click_count" db:"click_count" gorm:"default:0"`
+	FailedUnlocks int       `json:"failed_unlocks" db:"failed_unlocks" gorm:"not null;default:0"`
 }
 
 // TableName specifies the table name for AnalyticsDaily
@@ -29,14 +37,45 @@ func (AnalyticsDaily) TableName() string {
 	return "link_analytics_daily"
 }
 
-// Analytics response structures
+// ClickUsage counts an organization's tracked clicks for a calendar month.
+// NotifiedPercent records the highest quota threshold already announced.
+type ClickUsage struct {
+	OrganizationID  int64     `json:"organization_id" db:"organization_id" gorm:"primaryKey"`
+	Month           time.Time `json:"month" db:"month" gorm:"primaryKey;type:date"`
+	ClickCount      int64     `json:"click_count" db:"click_count" gorm:"not null;default:0"`
+	NotifiedPercent int       `json:"-" db:"notified_percent" gorm:"not null;default:0"`
+}
+
+// TableName specifies the table name for ClickUsage
+func (ClickUsage) TableName() string {
+	return "click_usage_monthly"
+}
+
+// ClickQuotaStatus reports an organization's monthly click usage against
+// its plan limit
+type ClickQuotaStatus struct {
+	Used        int64     `json:"used"`
+	Limit       int       `json:"limit"`
+	Percent     int       `json:"percent"`
+	OverQuota   bool      `json:"over_quota"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+}
+
+// Analytics response structures. Bot clicks, from link preview crawlers,
+// are only counted in BotClicks.
 type ClickStats struct {
-	TotalClicks   int64                `json:"total_clicks"`
-	Last30Days    int64                `json:"last_30_days"`
-	DailyClicks   []DailyClickCount    `json:"daily_clicks"`
-	Countries     []CountryStats       `json:"countries"`
-	Referers      []RefererStats       `json:"referers"`
-	DeviceTypes   []DeviceTypeStats    `json:"device_types"`
+	TotalClicks   int64               `json:"total_clicks"`
+	Last30Days    int64               `json:"last_30_days"`
+	DailyClicks   []DailyClickCount   `json:"daily_clicks"`
+	Countries     []CountryStats      `json:"countries"`
+	Referers      []RefererStats      `json:"referers"`
+	DeviceTypes   []DeviceTypeStats   `json:"device_types"`
+	RedirectRules []RedirectRuleStats `json:"redirect_rules"`
+	Variants      []VariantStats      `json:"variants"`
+	Campaigns     []CampaignStats     `json:"campaigns"`
+	BotClicks     int64               `json:"bot_clicks"`
+	FailedUnlocks int64               `json:"failed_unlocks"`
 }
 
 type DailyClickCount struct {
@@ -58,3 +97,24 @@ type DeviceTypeStats struct {
 	DeviceType string `json:"device_type"`
 	Count      int    `json:"count"`
 }
+
+// VariantStats counts clicks sent to an A/B variant, in total and per day
+// over the last 30 days
+type VariantStats struct {
+	Variant     string            `json:"variant"`
+	Count       int               `json:"count"`
+	DailyClicks []DailyClickCount `json:"daily_clicks"`
+}
+
+// CampaignStats counts clicks whose destination carried a utm_campaign
+type CampaignStats struct {
+	Campaign string `json:"campaign"`
+	Count    int    `json:"count"`
+}
+
+// RedirectRuleStats counts clicks routed by a redirect rule; Rule is
+// "default" for clicks sent to the link's own destination
+type RedirectRuleStats struct {
+	Rule  string `json:"rule"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Platforms a deep link targets, as detected from the user agent
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformOther   = "other"
+)
+
+// Paths taken by visitors served the deep link page
+const (
+	// DeepLinkPathApp means the app opened
+	DeepLinkPathApp = "app"
+	// DeepLinkPathStore means the app didn't open and the visitor was sent
+	// to the app store
+	DeepLinkPathStore = "store"
+	// DeepLinkPathWeb means the app didn't open and there was no store URL,
+	// so the visitor was sent to the web destination
+	DeepLinkPathWeb = "web"
+)
+
+// DeepLink opens a link's content in the mobile app. Visitors on a platform
+// with an app URI get a page that tries the app first and falls back to the
+// platform's store URL, or to the web destination without one.
+type DeepLink struct {
+	IOSAppURI       string `json:"ios_app_uri,omitempty"`
+	IOSStoreURL     string `json:"ios_store_url,omitempty"`
+	AndroidAppURI   string `json:"android_app_uri,omitempty"`
+	AndroidStoreURL string `json:"android_store_url,omitempty"`
+}
+
+// ForPlatform returns the app URI and store URL for a platform; appURI is
+// empty when the link doesn't open in an app there
+func (d *DeepLink) ForPlatform(platform string) (appURI, storeURL string) {
+	if d == nil {
+		return "", ""
+	}
+	switch platform {
+	case PlatformIOS:
+		return d.IOSAppURI, d.IOSStoreURL
+	case PlatformAndroid:
+		return d.AndroidAppURI, d.AndroidStoreURL
+	default:
+		return "", ""
+	}
+}
+
+// IsZero reports whether no app or store URL is set
+func (d *DeepLink) IsZero() bool {
+	return d == nil || *d == DeepLink{}
+}
+
+// Scan implements the sql.Scanner interface
+func (d *DeepLink) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = DeepLink{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	default:
+		return fmt.Errorf("unsupported deep link type %T", src)
+	}
+}
+
+// Value implements the driver.Valuer interface
+func (d DeepLink) Value() (driver.Value, error) {
+	if d == (DeepLink{}) {
+		return nil, nil
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+type DomainStatus string
+
+const (
+	DomainPending  DomainStatus = "pending"
+	DomainVerified DomainStatus = "verified"
+	DomainFailed   DomainStatus = "failed"
+)
+
+// DomainVerificationPrefix is prepended to the hostname to form the TXT record name
+const DomainVerificationPrefix = "_urlshortener-verify"
+
+type Domain struct {
+	ID                int64        `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	UserID            int64        `json:"user_id" db:"user_id" gorm:"not null;index"`
+	OrganizationID    int64        `json:"organization_id" db:"organization_id" gorm:"not null;default:0;index"`
+	Hostname          string       `json:"hostname" db:"hostname" gorm:"uniqueIndex;not null;size:255"`
+	VerificationToken string       `json:"verification_token" db:"verification_token" gorm:"not null;size:64"`
+	Status            DomainStatus `json:"status" db:"status" gorm:"type:varchar(20);default:'pending'"`
+	VerifiedAt        *time.Time   `json:"verified_at,omitempty" db:"verified_at"`
+	LastCheckedAt     *time.Time   `json:"last_checked_at,omitempty" db:"last_checked_at"`
+	CreatedAt         time.Time    `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time    `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// IsVerified checks if the domain has passed DNS verification
+func (d *Domain) IsVerified() bool {
+	return d.Status == DomainVerified
+}
+
+// VerificationRecordName returns the DNS name where the TXT record must be published
+func (d *Domain) VerificationRecordName() string {
+	return DomainVerificationPrefix + "." + d.Hostname
+}
+
+// VerificationRecordValue returns the expected TXT record value
+func (d *Domain) VerificationRecordValue() string {
+	return "urlshortener-verify=" + d.VerificationToken
+}
+
+// ShortURL builds the short URL for a code on this domain
+func (d *Domain) ShortURL(shortCode string) string {
+	return "https://" + d.Hostname + "/" + shortCode
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// EmailTokenPurpose says what an email token may be used for
+type EmailTokenPurpose string
+
+const (
+	EmailTokenVerification  EmailTokenPurpose = "email_verification"
+	EmailTokenPasswordReset EmailTokenPurpose = "password_reset"
+)
+
+// EmailToken is a single-use, expiring token sent by email. Only a hash of
+// the token is stored.
+type EmailToken struct {
+	ID        int64             `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    int64             `json:"user_id" db:"user_id" gorm:"not null;index"`
+	Purpose   EmailTokenPurpose `json:"purpose" db:"purpose" gorm:"type:varchar(50);not null"`
+	TokenHash string            `json:"-" db:"token_hash" gorm:"uniqueIndex;not null;size:64"`
+	ExpiresAt time.Time         `json:"expires_at" db:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time        `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for EmailToken
+func (EmailToken) TableName() string {
+	return "email_tokens"
+}
@@ -8,17 +8,61 @@ import (
 	"gorm.io/gorm"
 )
 
+// Reasons a link was archived. Archived links don't redirect and don't count
+// towards the organization's link limit.
+const (
+	// ArchiveReasonPlanLimit marks links archived because the organization
+	// stayed over its plan's link limit; they are restored on upgrade
+	ArchiveReasonPlanLimit = "plan_limit"
+	// ArchiveReasonUser marks links archived by a member
+	ArchiveReasonUser = "user"
+)
+
+// Progress of fetching a link's destination page for its title,
+// description, favicon and canonical URL. Links created before fetching
+// existed have no status.
+const (
+	MetadataPending = "pending"
+	MetadataFetched = "fetched"
+	MetadataFailed  = "failed"
+)
+
 type Link struct {
-	ID             int64          `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
-	UserID         int64          `json:"user_id" db:"user_id" gorm:"not null;index"`
-	ShortCode      string         `json:"short_code" db:"short_code" gorm:"uniqueIndex;not null;size:255"`
-	DestinationURL string         `json:"destination_url" db:"destination_url" gorm:"not null;type:text"`
-	Title          *string        `json:"title,omitempty" db:"title" gorm:"size:500"`
-	Tags           StringList     `json:"tags" db:"tags" gorm:"type:text[]"`
-	ExpiresAt      *time.Time     `json:"expires_at,omitempty" db:"expires_at"`
-	CreatedAt      time.Time      `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt      time.Time      `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
-	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                int64          `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	UserID            int64          `json:"user_id" db:"user_id" gorm:"not null;index"`
+	OrganizationID    int64          `json:"organization_id" db:"organization_id" gorm:"not null;default:0;index"`
+	DomainID          *int64         `json:"domain_id,omitempty" db:"domain_id" gorm:"uniqueIndex:idx_links_domain_short_code,where:deleted_at IS NULL"`
+	Domain            *Domain        `json:"-" gorm:"foreignKey:DomainID;constraint:OnDelete:SET NULL"`
+	ShortCode         string         `json:"short_code" db:"short_code" gorm:"not null;size:255;uniqueIndex:idx_links_domain_short_code,where:deleted_at IS NULL;uniqueIndex:idx_links_default_short_code,where:domain_id IS NULL AND deleted_at IS NULL"`
+	DestinationURL    string         `json:"destination_url" db:"destination_url" gorm:"not null;type:text"`
+	RedirectRules     RedirectRules  `json:"redirect_rules" db:"redirect_rules" gorm:"type:jsonb;not null;default:'[]'"`
+	Variants          LinkVariants   `json:"variants" db:"variants" gorm:"type:jsonb;not null;default:'[]'"`
+	DeepLink          *DeepLink      `json:"deep_link,omitempty" db:"deep_link" gorm:"type:jsonb"`
+	UTM               *UTMParams     `json:"utm,omitempty" db:"utm" gorm:"type:jsonb"`
+	Preview           *LinkPreview   `json:"preview,omitempty" db:"preview" gorm:"type:jsonb"`
+	QueryPassthrough  bool           `json:"query_passthrough" db:"query_passthrough" gorm:"not null;default:false"`
+	QueryConflict     string         `json:"query_conflict" db:"query_conflict" gorm:"size:20;not null;default:'destination'"`
+	Title             *string        `json:"title,omitempty" db:"title" gorm:"size:500"`
+	Description       *string        `json:"description,omitempty" db:"description" gorm:"type:text"`
+	FaviconURL        *string        `json:"favicon_url,omitempty" db:"favicon_url" gorm:"type:text"`
+	CanonicalURL      *string        `json:"canonical_url,omitempty" db:"canonical_url" gorm:"type:text"`
+	MetadataStatus    string         `json:"metadata_status,omitempty" db:"metadata_status" gorm:"size:20;not null;default:'';index:idx_links_metadata_pending,where:metadata_status = 'pending' AND deleted_at IS NULL"`
+	MetadataFetchedAt *time.Time     `json:"metadata_fetched_at,omitempty" db:"metadata_fetched_at"`
+	Tags              StringList     `json:"tags" db:"tags" gorm:"type:text[]"`
+	ExpiresAt         *time.Time     `json:"expires_at,omitempty" db:"expires_at" gorm:"index:idx_links_pending_expiry,where:expired_at IS NULL AND deleted_at IS NULL"`
+	ExpiredAt         *time.Time     `json:"expired_at,omitempty" db:"expired_at"`
+	StartsAt          *time.Time     `json:"starts_at,omitempty" db:"starts_at"`
+	MaxClicks         *int           `json:"max_clicks,omitempty" db:"max_clicks"`
+	RedirectCount     int64          `json:"redirect_count" db:"redirect_count" gorm:"not null;default:0"`
+	FallbackURL       *string        `json:"fallback_url,omitempty" db:"fallback_url" gorm:"type:text"`
+	ArchivedAt        *time.Time     `json:"archived_at,omitempty" db:"archived_at" gorm:"index"`
+	ArchiveReason     string         `json:"archive_reason,omitempty" db:"archive_reason" gorm:"size:20;not null;default:''"`
+	FlaggedAt         *time.Time     `json:"flagged_at,omitempty" db:"flagged_at" gorm:"index"`
+	FlagReason        string         `json:"flag_reason,omitempty" db:"flag_reason" gorm:"size:500;not null;default:''"`
+	PasswordHash      string         `json:"-" db:"password_hash" gorm:"size:255;not null;default:''"`
+	CreatedAt         time.Time      `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time      `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt         gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // StringList is a custom type to handle PostgreSQL TEXT[] arrays
@@ -138,12 +182,73 @@ func escapeString(s string) string {
 	return result
 }
 
-// IsExpired checks if the link has expired
-func (l *Link) IsExpired() bool {
-	if l.ExpiresAt == nil {
-		return false
+// LinkState describes whether a link redirects and, if not, why
+type LinkState string
+
+const (
+	LinkStateActive    LinkState = "active"
+	LinkStateScheduled LinkState = "scheduled"
+	LinkStateExpired   LinkState = "expired"
+	LinkStateExhausted LinkState = "exhausted"
+	LinkStateArchived  LinkState = "archived"
+	LinkStateFlagged   LinkState = "flagged"
+)
+
+// IsLinkState reports whether s names a link state
+func IsLinkState(s string) bool {
+	switch LinkState(s) {
+	case LinkStateActive, LinkStateScheduled, LinkStateExpired, LinkStateExhausted, LinkStateArchived, LinkStateFlagged:
+		return true
+	}
+	return false
+}
+
+// State evaluates the link at now. RedirectCount is only counted for links
+// with MaxClicks, and may lag behind on cached links; redirects enforce the
+// limit in the database.
+func (l *Link) State(now time.Time) LinkState {
+	switch {
+	case l.IsArchived():
+		return LinkStateArchived
+	case l.IsFlagged():
+		return LinkStateFlagged
+	case l.ExpiresAt != nil && now.After(*l.ExpiresAt):
+		return LinkStateExpired
+	case l.MaxClicks != nil && l.RedirectCount >= int64(*l.MaxClicks):
+		return LinkStateExhausted
+	case l.StartsAt != nil && now.Before(*l.StartsAt):
+		return LinkStateScheduled
+	default:
+		return LinkStateActive
 	}
-	return time.Now().After(*l.ExpiresAt)
+}
+
+// RemainingClicks returns how many redirects are left under MaxClicks, or
+// nil when the link has no click limit
+func (l *Link) RemainingClicks() *int64 {
+	if l.MaxClicks == nil {
+		return nil
+	}
+	remaining := int64(*l.MaxClicks) - l.RedirectCount
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &remaining
+}
+
+// IsArchived checks if the link has been archived
+func (l *Link) IsArchived() bool {
+	return l.ArchivedAt != nil
+}
+
+// IsFlagged checks if the link's destination was found on a blocklist
+func (l *Link) IsFlagged() bool {
+	return l.FlaggedAt != nil
+}
+
+// IsPasswordProtected checks if visitors must enter a password to be redirected
+func (l *Link) IsPasswordProtected() bool {
+	return l.PasswordHash != ""
 }
 
 // LinkWithStats includes link data along with analytics
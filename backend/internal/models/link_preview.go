@@ -0,0 +1,54 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Limits on a link preview's text
+const (
+	MaxPreviewTitleLength       = 200
+	MaxPreviewDescriptionLength = 500
+)
+
+// LinkPreview overrides the card social networks and chat apps show for a
+// short link. Their crawlers get a page with these Open Graph and Twitter
+// tags instead of following the redirect.
+type LinkPreview struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+}
+
+// IsZero reports whether nothing is overridden
+func (p *LinkPreview) IsZero() bool {
+	return p == nil || *p == LinkPreview{}
+}
+
+// Scan implements the sql.Scanner interface
+func (p *LinkPreview) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*p = LinkPreview{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("unsupported link preview type %T", src)
+	}
+}
+
+// Value implements the driver.Valuer interface
+func (p LinkPreview) Value() (driver.Value, error) {
+	if p == (LinkPreview{}) {
+		return nil, nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLinkState(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	limit := 5
+
+	tests := []struct {
+		name string
+		link Link
+		want LinkState
+	}{
+		{name: "plain link", link: Link{}, want: LinkStateActive},
+		{name: "launched", link: Link{StartsAt: &past, ExpiresAt: &future}, want: LinkStateActive},
+		{name: "not launched yet", link: Link{StartsAt: &future}, want: LinkStateScheduled},
+		{name: "expired", link: Link{ExpiresAt: &past}, want: LinkStateExpired},
+		{name: "clicks left", link: Link{MaxClicks: &limit, RedirectCount: 4}, want: LinkStateActive},
+		{name: "out of clicks", link: Link{MaxClicks: &limit, RedirectCount: 5}, want: LinkStateExhausted},
+		{name: "expired before running out", link: Link{ExpiresAt: &past, MaxClicks: &limit, RedirectCount: 5}, want: LinkStateExpired},
+		{name: "out of clicks before launch", link: Link{StartsAt: &future, MaxClicks: &limit, RedirectCount: 5}, want: LinkStateExhausted},
+		{name: "flagged", link: Link{FlaggedAt: &past, ExpiresAt: &past}, want: LinkStateFlagged},
+		{name: "archived", link: Link{ArchivedAt: &past, FlaggedAt: &past}, want: LinkStateArchived},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.link.State(now); got != tt.want {
+				t.Errorf("State() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkRemainingClicks(t *testing.T) {
+	limit := 5
+
+	if got := (&Link{}).RemainingClicks(); got != nil {
+		t.Errorf("RemainingClicks() without a limit = %d, want nil", *got)
+	}
+	for _, tt := range []struct {
+		redirects int64
+		want      int64
+	}{
+		{redirects: 0, want: 5},
+		{redirects: 3, want: 2},
+		{redirects: 7, want: 0},
+	} {
+		link := &Link{MaxClicks: &limit, RedirectCount: tt.redirects}
+		if got := link.RemainingClicks(); got == nil || *got != tt.want {
+			t.Errorf("RemainingClicks() after %d redirects = %v, want %d", tt.redirects, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxLinkVariants bounds the A/B variants on a single link
+	MaxLinkVariants = 10
+	// MaxVariantWeight bounds a variant's relative share of traffic
+	MaxVariantWeight = 1000
+)
+
+// LinkVariant is one destination of an A/B split. Visitors not routed by a
+// redirect rule are spread across a link's variants in proportion to their
+// weights. Name identifies the variant in click analytics.
+type LinkVariant struct {
+	Name           string `json:"name"`
+	DestinationURL string `json:"destination_url"`
+	Weight         int    `json:"weight"`
+}
+
+// Validate checks the variant's name and weight, leaving the destination URL
+// to the caller
+func (v *LinkVariant) Validate() error {
+	if !ruleNamePattern.MatchString(v.Name) {
+		return errors.New("name must be 1-50 letters, digits, dashes or underscores")
+	}
+	if v.Weight < 1 || v.Weight > MaxVariantWeight {
+		return fmt.Errorf("weight must be between 1 and %d", MaxVariantWeight)
+	}
+	return nil
+}
+
+// LinkVariants is a link's A/B split, stored as JSONB
+type LinkVariants []LinkVariant
+
+// Scan implements the sql.Scanner interface
+func (v *LinkVariants) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(s, v)
+	case string:
+		return json.Unmarshal([]byte(s), v)
+	default:
+		return fmt.Errorf("unsupported link variants type %T", src)
+	}
+}
+
+// Value implements the driver.Valuer interface
+func (v LinkVariants) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return "[]", nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
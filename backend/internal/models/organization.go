@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OrganizationRole string
+
+const (
+	RoleOwner  OrganizationRole = "owner"
+	RoleAdmin  OrganizationRole = "admin"
+	RoleEditor OrganizationRole = "editor"
+	RoleViewer OrganizationRole = "viewer"
+)
+
+// roleRank orders roles from least to most privileged
+var roleRank = map[OrganizationRole]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// IsValid checks if the role is a known organization role
+func (r OrganizationRole) IsValid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// AtLeast checks if the role is at least as privileged as other
+func (r OrganizationRole) AtLeast(other OrganizationRole) bool {
+	return roleRank[r] >= roleRank[other]
+}
+
+type Organization struct {
+	ID               int64            `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	Name             string           `json:"name" db:"name" gorm:"not null;size:255"`
+	OwnerID          int64            `json:"owner_id" db:"owner_id" gorm:"not null;index;uniqueIndex:idx_organizations_personal_owner,where:is_personal AND deleted_at IS NULL"`
+	IsPersonal       bool             `json:"is_personal" db:"is_personal" gorm:"default:false"`
+	SubscriptionTier SubscriptionTier `json:"subscription_tier" db:"subscription_tier" gorm:"type:varchar(50);default:'free'"`
+	// CoveredByOwner is set on team organizations the owner's subscription
+	// pays for; they share the owner's tier. Personal organizations always
+	// share it.
+	CoveredByOwner bool `json:"covered_by_owner" db:"covered_by_owner" gorm:"not null;default:false"`
+	// LinkGraceEndsAt is set while the organization has more active links
+	// than its tier allows; the excess is archived when it passes
+	LinkGraceEndsAt *time.Time     `json:"link_grace_ends_at,omitempty" db:"link_grace_ends_at" gorm:"index"`
+	CreatedAt       time.Time      `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time      `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// GetLinkLimit returns the maximum number of links allowed for the organization's tier
+func (o *Organization) GetLinkLimit() int {
+	return o.SubscriptionTier.LinkLimit()
+}
+
+// GetClickLimit returns the monthly click tracking limit for the organization's tier
+func (o *Organization) GetClickLimit() int {
+	return o.SubscriptionTier.ClickLimit()
+}
+
+// GetAPIRateLimit returns the daily API request limit for the organization's tier
+func (o *Organization) GetAPIRateLimit() int {
+	return o.SubscriptionTier.APIRateLimit()
+}
+
+type OrganizationMember struct {
+	ID             int64            `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	OrganizationID int64            `json:"organization_id" db:"organization_id" gorm:"not null;uniqueIndex:idx_org_members_org_user"`
+	UserID         int64            `json:"user_id" db:"user_id" gorm:"not null;uniqueIndex:idx_org_members_org_user;index"`
+	Role           OrganizationRole `json:"role" db:"role" gorm:"type:varchar(20);not null"`
+	User           *User            `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	CreatedAt      time.Time        `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time        `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type OrganizationInvitation struct {
+	ID             int64            `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	OrganizationID int64            `json:"organization_id" db:"organization_id" gorm:"not null;index"`
+	Email          string           `json:"email" db:"email" gorm:"not null;size:255"`
+	Role           OrganizationRole `json:"role" db:"role" gorm:"type:varchar(20);not null"`
+	TokenHash      string           `json:"-" db:"token_hash" gorm:"uniqueIndex;not null;size:64"`
+	InvitedBy      int64            `json:"invited_by" db:"invited_by" gorm:"not null"`
+	ExpiresAt      time.Time        `json:"expires_at" db:"expires_at" gorm:"not null"`
+	AcceptedAt     *time.Time       `json:"accepted_at,omitempty" db:"accepted_at"`
+	CreatedAt      time.Time        `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+}
+
+// IsPending checks if the invitation can still be accepted
+func (i *OrganizationInvitation) IsPending() bool {
+	return i.AcceptedAt == nil && time.Now().Before(i.ExpiresAt)
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryCode is a single-use backup code for two-factor login. Only a
+// hash of the code is stored.
+type RecoveryCode struct {
+	ID        int64      `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    int64      `json:"user_id" db:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" db:"code_hash" gorm:"not null;size:64"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for RecoveryCode
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxRedirectRules bounds the routing rules on a single link
+	MaxRedirectRules = 20
+	// maxQueryMatchParams bounds the query parameters a rule matches on
+	maxQueryMatchParams = 10
+)
+
+// Device types a redirect rule can match, as detected from the user agent
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+var (
+	ruleNamePattern    = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+	countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+	languageTagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+	clockTimePattern   = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+)
+
+// RedirectRule sends visitors matching all of its conditions to an
+// alternate destination. Empty conditions match everyone, but a rule needs
+// at least one. Name identifies the rule in click analytics.
+type RedirectRule struct {
+	Name           string `json:"name"`
+	DestinationURL string `json:"destination_url"`
+	// DeviceTypes matches mobile, tablet or desktop visitors
+	DeviceTypes []string `json:"device_types,omitempty"`
+	// Countries matches ISO 3166-1 alpha-2 country codes
+	Countries []string `json:"countries,omitempty"`
+	// Languages matches the visitor's preferred Accept-Language. "en"
+	// matches any English variant; "en-gb" only British English.
+	Languages []string `json:"languages,omitempty"`
+	// Days matches weekdays, 0 for Sunday through 6 for Saturday
+	Days []int `json:"days,omitempty"`
+	// StartTime and EndTime ("HH:MM") bound the time of day; a window
+	// ending before it starts runs past midnight
+	StartTime string `json:"start_time,omitempty"`
+	EndTime   string `json:"end_time,omitempty"`
+	// Timezone is the IANA zone Days and the time window are read in,
+	// UTC by default
+	Timezone string `json:"timezone,omitempty"`
+	// Query matches query parameters by value; an empty value only needs
+	// the parameter to be present
+	Query map[string]string `json:"query,omitempty"`
+}
+
+// Normalize canonicalizes the rule's case-insensitive conditions
+func (r *RedirectRule) Normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	for i, d := range r.DeviceTypes {
+		r.DeviceTypes[i] = strings.ToLower(strings.TrimSpace(d))
+	}
+	for i, c := range r.Countries {
+		r.Countries[i] = strings.ToUpper(strings.TrimSpace(c))
+	}
+	for i, l := range r.Languages {
+		r.Languages[i] = strings.ToLower(strings.TrimSpace(l))
+	}
+}
+
+// Validate checks the rule's conditions, leaving the destination URL to the
+// caller
+func (r *RedirectRule) Validate() error {
+	if !ruleNamePattern.MatchString(r.Name) {
+		return errors.New("name must be 1-50 letters, digits, dashes or underscores")
+	}
+	if !r.hasCondition() {
+		return errors.New("at least one condition is required")
+	}
+	for _, d := range r.DeviceTypes {
+		if d != DeviceMobile && d != DeviceTablet && d != DeviceDesktop {
+			return fmt.Errorf("unknown device type %q", d)
+		}
+	}
+	for _, c := range r.Countries {
+		if !countryCodePattern.MatchString(c) {
+			return fmt.Errorf("invalid country code %q", c)
+		}
+	}
+	for _, l := range r.Languages {
+		if !languageTagPattern.MatchString(l) {
+			return fmt.Errorf("invalid language %q", l)
+		}
+	}
+	for _, d := range r.Days {
+		if d < 0 || d > 6 {
+			return fmt.Errorf("invalid day %d", d)
+		}
+	}
+	if (r.StartTime == "") != (r.EndTime == "") {
+		return errors.New("start_time and end_time must be set together")
+	}
+	if r.StartTime != "" && (!clockTimePattern.MatchString(r.StartTime) || !clockTimePattern.MatchString(r.EndTime)) {
+		return errors.New("start_time and end_time must be HH:MM")
+	}
+	if r.StartTime != "" && r.StartTime == r.EndTime {
+		return errors.New("start_time and end_time must differ")
+	}
+	if r.Timezone != "" {
+		if _, err := time.LoadLocation(r.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", r.Timezone)
+		}
+	}
+	if len(r.Query) > maxQueryMatchParams {
+		return fmt.Errorf("at most %d query parameters can be matched", maxQueryMatchParams)
+	}
+	for key := range r.Query {
+		if key == "" {
+			return errors.New("query parameter names can't be empty")
+		}
+	}
+	return nil
+}
+
+func (r *RedirectRule) hasCondition() bool {
+	return len(r.DeviceTypes) > 0 || len(r.Countries) > 0 || len(r.Languages) > 0 ||
+		len(r.Days) > 0 || r.StartTime != "" || len(r.Query) > 0
+}
+
+// RedirectRules is a link's ordered routing rules, stored as JSONB
+type RedirectRules []RedirectRule
+
+// Scan implements the sql.Scanner interface
+func (r *RedirectRules) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("unsupported redirect rules type %T", src)
+	}
+}
+
+// Value implements the driver.Valuer interface
+func (r RedirectRules) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return "[]", nil
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import "testing"
+
+func TestRedirectRuleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    RedirectRule
+		wantErr bool
+	}{
+		{name: "device rule", rule: RedirectRule{Name: "mobile", DeviceTypes: []string{DeviceMobile}}},
+		{name: "every condition", rule: RedirectRule{
+			Name: "evening_de", DeviceTypes: []string{DeviceDesktop}, Countries: []string{"DE"}, Languages: []string{"de", "en-gb"},
+			Days: []int{1, 5}, StartTime: "18:00", EndTime: "02:00", Timezone: "Europe/Berlin", Query: map[string]string{"ref": ""},
+		}},
+		{name: "no name", rule: RedirectRule{DeviceTypes: []string{DeviceMobile}}, wantErr: true},
+		{name: "name with spaces", rule: RedirectRule{Name: "mobile users", DeviceTypes: []string{DeviceMobile}}, wantErr: true},
+		{name: "no condition", rule: RedirectRule{Name: "everyone"}, wantErr: true},
+		{name: "unknown device", rule: RedirectRule{Name: "tv", DeviceTypes: []string{"tv"}}, wantErr: true},
+		{name: "three letter country", rule: RedirectRule{Name: "de", Countries: []string{"DEU"}}, wantErr: true},
+		{name: "invalid language", rule: RedirectRule{Name: "en", Languages: []string{"english!"}}, wantErr: true},
+		{name: "invalid day", rule: RedirectRule{Name: "day", Days: []int{7}}, wantErr: true},
+		{name: "start without end", rule: RedirectRule{Name: "night", StartTime: "22:00"}, wantErr: true},
+		{name: "malformed time", rule: RedirectRule{Name: "night", StartTime: "10pm", EndTime: "02:00"}, wantErr: true},
+		{name: "empty window", rule: RedirectRule{Name: "night", StartTime: "22:00", EndTime: "22:00"}, wantErr: true},
+		{name: "unknown timezone", rule: RedirectRule{Name: "night", Days: []int{1}, Timezone: "Mars/Olympus"}, wantErr: true},
+		{name: "empty query name", rule: RedirectRule{Name: "ref", Query: map[string]string{"": "mail"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedirectRuleNormalize(t *testing.T) {
+	rule := RedirectRule{Name: " mobile ", DeviceTypes: []string{" Mobile"}, Countries: []string{"de "}, Languages: []string{"EN-GB"}}
+	rule.Normalize()
+
+	if rule.Name != "mobile" || rule.DeviceTypes[0] != DeviceMobile || rule.Countries[0] != "DE" || rule.Languages[0] != "en-gb" {
+		t.Errorf("Normalize() = %+v", rule)
+	}
+}
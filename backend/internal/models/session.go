@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is a signed-in device. Access tokens carry the session ID and the
+// refresh token, stored only as a hash, rotates on every use.
+// PreviousTokenHash keeps the last rotated-out token so its reuse, a sign
+// the token was stolen, can be detected.
+type Session struct {
+	ID                int64      `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	UserID            int64      `json:"user_id" db:"user_id" gorm:"not null;index"`
+	RefreshTokenHash  string     `json:"-" db:"refresh_token_hash" gorm:"uniqueIndex;not null;size:64"`
+	PreviousTokenHash *string    `json:"-" db:"previous_token_hash" gorm:"index;size:64"`
+	Device            string     `json:"device" db:"device" gorm:"size:100"`
+	IPAddress         string     `json:"ip_address" db:"ip_address" gorm:"size:45"`
+	UserAgent         string     `json:"user_agent" db:"user_agent" gorm:"type:text"`
+	LastSeenAt        time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	ExpiresAt         time.Time  `json:"expires_at" db:"expires_at" gorm:"not null"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for Session
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// IsActive reports whether the session can still be used
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
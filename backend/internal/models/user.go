@@ -19,16 +19,23 @@ type User struct {
 	Email            string           `json:"email" db:"email" gorm:"uniqueIndex;not null;size:255"`
 	FullName         string           `json:"full_name" db:"full_name" gorm:"size:255"`
 	PasswordHash     string           `json:"-" db:"password_hash" gorm:"not null;size:255"`
-	APIKey           *string          `json:"api_key,omitempty" db:"api_key" gorm:"uniqueIndex;size:255"`
 	SubscriptionTier SubscriptionTier `json:"subscription_tier" db:"subscription_tier" gorm:"type:varchar(50);default:'free'"`
-	CreatedAt        time.Time        `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt        time.Time        `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
-	DeletedAt        gorm.DeletedAt   `json:"-" gorm:"index"`
+	EmailVerified    bool             `json:"email_verified" db:"email_verified" gorm:"not null;default:false"`
+	EmailVerifiedAt  *time.Time       `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	// TOTPSecret is set when enrollment starts; TwoFactorEnabled once the
+	// first code is confirmed. TOTPLastStep is the last accepted time step,
+	// so a code can't be replayed.
+	TOTPSecret       string         `json:"-" db:"totp_secret" gorm:"size:64"`
+	TwoFactorEnabled bool           `json:"two_factor_enabled" db:"two_factor_enabled" gorm:"not null;default:false"`
+	TOTPLastStep     int64          `json:"-" db:"totp_last_step" gorm:"not null;default:0"`
+	CreatedAt        time.Time      `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time      `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
-// GetLinkLimit returns the maximum number of links allowed for this tier
-func (u *User) GetLinkLimit() int {
-	switch u.SubscriptionTier {
+// LinkLimit returns the maximum number of links allowed for this tier
+func (t SubscriptionTier) LinkLimit() int {
+	switch t {
 	case TierFree:
 		return 50
 	case TierPro:
@@ -40,9 +47,9 @@ func (u *User) GetLinkLimit() int {
 	}
 }
 
-// GetClickLimit returns the monthly click tracking limit for this tier
-func (u *User) GetClickLimit() int {
-	switch u.SubscriptionTier {
+// ClickLimit returns the monthly click tracking limit for this tier
+func (t SubscriptionTier) ClickLimit() int {
+	switch t {
 	case TierFree:
 		return 1000
 	case TierPro:
@@ -54,9 +61,9 @@ func (u *User) GetClickLimit() int {
 	}
 }
 
-// GetAPIRateLimit returns the daily API request limit for this tier
-func (u *User) GetAPIRateLimit() int {
-	switch u.SubscriptionTier {
+// APIRateLimit returns the daily API request limit for this tier
+func (t SubscriptionTier) APIRateLimit() int {
+	switch t {
 	case TierFree:
 		return 0 // No API access
 	case TierPro:
@@ -67,3 +74,33 @@ func (u *User) GetAPIRateLimit() int {
 		return 0
 	}
 }
+
+// TeamOrganizationLimit returns how many team organizations a subscription
+// on this tier can cover besides the personal organization
+func (t SubscriptionTier) TeamOrganizationLimit() int {
+	switch t {
+	case TierFree:
+		return 0
+	case TierPro:
+		return 1
+	case TierBusiness:
+		return 5
+	default:
+		return 0
+	}
+}
+
+// GetLinkLimit returns the maximum number of links allowed for this tier
+func (u *User) GetLinkLimit() int {
+	return u.SubscriptionTier.LinkLimit()
+}
+
+// GetClickLimit returns the monthly click tracking limit for this tier
+func (u *User) GetClickLimit() int {
+	return u.SubscriptionTier.ClickLimit()
+}
+
+// GetAPIRateLimit returns the daily API request limit for this tier
+func (u *User) GetAPIRateLimit() int {
+	return u.SubscriptionTier.APIRateLimit()
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// MaxUTMParamLength bounds each stored UTM parameter
+const MaxUTMParamLength = 255
+
+// Policies for query parameters on the short URL that the destination also
+// sets, when the link passes its query string through
+const (
+	// QueryConflictShortLink replaces the destination's value with the
+	// short URL's
+	QueryConflictShortLink = "short_link"
+	// QueryConflictDestination keeps the destination's value
+	QueryConflictDestination = "destination"
+)
+
+// UTMParams are campaign parameters set on a link's destination when
+// redirecting, replacing any the destination already has
+type UTMParams struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// Values returns the parameters that are set as utm_* query parameters
+func (u *UTMParams) Values() url.Values {
+	values := url.Values{}
+	if u == nil {
+		return values
+	}
+	for key, value := range map[string]string{
+		"utm_source":   u.Source,
+		"utm_medium":   u.Medium,
+		"utm_campaign": u.Campaign,
+		"utm_term":     u.Term,
+		"utm_content":  u.Content,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values
+}
+
+// IsZero reports whether no parameter is set
+func (u *UTMParams) IsZero() bool {
+	return u == nil || *u == UTMParams{}
+}
+
+// Scan implements the sql.Scanner interface
+func (u *UTMParams) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*u = UTMParams{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, u)
+	case string:
+		return json.Unmarshal([]byte(v), u)
+	default:
+		return fmt.Errorf("unsupported UTM parameters type %T", src)
+	}
+}
+
+// Value implements the driver.Valuer interface
+func (u UTMParams) Value() (driver.Value, error) {
+	if u == (UTMParams{}) {
+		return nil, nil
+	}
+	b, err := json.Marshal(u)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
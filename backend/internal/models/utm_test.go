@@ -0,0 +1,18 @@
+package models
+
+import "testing"
+
+func TestUTMParamsValues(t *testing.T) {
+	var unset *UTMParams
+	if values := unset.Values(); len(values) != 0 || !unset.IsZero() {
+		t.Errorf("nil parameters = %v, want none", values)
+	}
+
+	utm := &UTMParams{Source: "newsletter", Medium: "email", Content: "hero"}
+	if got := utm.Values().Encode(); got != "utm_content=hero&utm_medium=email&utm_source=newsletter" {
+		t.Errorf("Values() = %s, want only the set parameters", got)
+	}
+	if utm.IsZero() {
+		t.Error("IsZero() = true for set parameters")
+	}
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook is an endpoint that receives an organization's events. UserID is
+// the member who created it. Its signing secret is only shown when it is
+// created or rotated.
+type Webhook struct {
+	ID             int64          `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	UserID         int64          `json:"user_id" db:"user_id" gorm:"not null;index"`
+	OrganizationID int64          `json:"organization_id" db:"organization_id" gorm:"not null;default:0;index"`
+	URL            string         `json:"url" db:"url" gorm:"not null;type:text"`
+	Description    *string        `json:"description,omitempty" db:"description" gorm:"size:255"`
+	Secret         string         `json:"-" db:"secret" gorm:"not null;size:255"`
+	Events         StringList     `json:"events" db:"events" gorm:"type:text[]"`
+	IsActive       bool           `json:"is_active" db:"is_active" gorm:"default:true"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time      `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Subscribes checks if the webhook is subscribed to an event type
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// WebhookDelivery records a single event delivery and its attempts
+type WebhookDelivery struct {
+	ID             int64          `json:"id" db:"id" gorm:"primaryKey;autoIncrement"`
+	WebhookID      int64          `json:"webhook_id" db:"webhook_id" gorm:"not null;uniqueIndex:idx_webhook_deliveries_event"`
+	EventID        string         `json:"event_id" db:"event_id" gorm:"not null;size:36;uniqueIndex:idx_webhook_deliveries_event"`
+	Event          string         `json:"event" db:"event" gorm:"not null;size:50"`
+	Payload        string         `json:"payload" db:"payload" gorm:"not null;type:text"`
+	Status         DeliveryStatus `json:"status" db:"status" gorm:"type:varchar(20);default:'pending';index"`
+	Attempts       int            `json:"attempts" db:"attempts" gorm:"default:0"`
+	ResponseStatus *int           `json:"response_status,omitempty" db:"response_status"`
+	LastError      *string        `json:"last_error,omitempty" db:"last_error" gorm:"type:text"`
+	DurationMs     *int64         `json:"duration_ms,omitempty" db:"duration_ms"`
+	NextRetryAt    *time.Time     `json:"next_retry_at,omitempty" db:"next_retry_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at" gorm:"autoCreateTime;index"`
+	UpdatedAt      time.Time      `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`
+	// LockedUntil is set while an instance is sending the delivery, so
+	// other instances don't send it too
+	LockedUntil *time.Time `json:"-" db:"locked_until"`
+}
+
+// WebhookStats summarises delivery activity for a user's webhooks
+type WebhookStats struct {
+	TotalWebhooks   int64   `json:"total_webhooks"`
+	ActiveWebhooks  int64   `json:"active_webhooks"`
+	EventsSentToday int64   `json:"events_sent_today"`
+	SuccessRate     float64 `json:"success_rate"`
+}
@@ -0,0 +1,62 @@
+package netguard
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{addr: "127.0.0.1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "169.254.169.254"}, // cloud metadata
+		{addr: "100.64.0.1"},
+		{addr: "0.0.0.0"},
+		{addr: "255.255.255.255"},
+		{addr: "224.0.0.1"},
+		{addr: "203.0.113.7"},
+		{addr: "::1"},
+		{addr: "fe80::1"},
+		{addr: "fc00::1"},
+		{addr: "::ffff:127.0.0.1"}, // IPv4-mapped loopback
+		{addr: "::ffff:93.184.216.34", want: true},
+		{addr: "64:ff9b::a00:1"}, // NAT64 of 10.0.0.1
+		{addr: "2002:a00:1::"},   // 6to4 of 10.0.0.1
+	}
+
+	for _, tt := range tests {
+		if got := IsPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("IsPublic(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestControl(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr error
+	}{
+		{address: "93.184.216.34:443"},
+		{address: "[2606:2800:220:1:248:1893:25c8:1946]:443"},
+		{address: "127.0.0.1:80", wantErr: ErrBlockedAddress},
+		{address: "[::1]:80", wantErr: ErrBlockedAddress},
+	}
+
+	for _, tt := range tests {
+		if err := Control("tcp", tt.address, nil); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Control(%s) error = %v, want %v", tt.address, err, tt.wantErr)
+		}
+	}
+
+	if err := Control("tcp", "example.com:443", nil); err == nil {
+		t.Error("Control() accepted an unresolved hostname")
+	}
+}
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// Result describes the outcome of a rate limit check for the most
+// constrained window
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// RetryAfter returns how long a rejected caller should wait
+func (r *Result) RetryAfter() time.Duration {
+	wait := time.Until(r.Reset)
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait
+}
+
+// Usage summarizes a subject's API usage for display
+type Usage struct {
+	DailyLimit     int       `json:"daily_limit"`
+	PerMinuteLimit int       `json:"per_minute_limit"`
+	UsedToday      int64     `json:"used_today"`
+	RemainingToday int64     `json:"remaining_today"`
+	UsedThisMonth  int64     `json:"used_this_month"`
+	ResetsAt       time.Time `json:"resets_at"`
+}
+
+// Limiter enforces a per-minute burst limit and a daily quota using fixed
+// windows aligned to UTC minutes and days. Accepted requests are also
+// counted per calendar month for reporting.
+type Limiter struct {
+	store     Store
+	perMinute int
+}
+
+func NewLimiter(store Store, perMinute int) *Limiter {
+	return &Limiter{store: store, perMinute: perMinute}
+}
+
+func minuteKey(subject string, start time.Time) string {
+	return fmt.Sprintf("ratelimit:%s:minute:%d", subject, start.Unix())
+}
+
+func dayKey(subject string, start time.Time) string {
+	return fmt.Sprintf("ratelimit:%s:day:%s", subject, start.Format("2006-01-02"))
+}
+
+func monthKey(subject string, start time.Time) string {
+	return fmt.Sprintf("ratelimit:%s:month:%s", subject, start.Format("2006-01"))
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, d := t.Date()
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Allow counts a request for subject against the per-minute limit and the
+// given daily limit
+func (l *Limiter) Allow(ctx context.Context, subject string, dailyLimit int) (*Result, error) {
+	now := time.Now().UTC()
+	minuteStart := now.Truncate(time.Minute)
+	dayStart := startOfDay(now)
+
+	minuteResult := &Result{Allowed: true, Limit: l.perMinute, Reset: minuteStart.Add(time.Minute)}
+	if l.perMinute > 0 {
+		count, err := l.store.Increment(ctx, minuteKey(subject, minuteStart), 2*time.Minute)
+		if err != nil {
+			return nil, err
+		}
+		if count > int64(l.perMinute) {
+			minuteResult.Allowed = false
+			return minuteResult, nil
+		}
+		minuteResult.Remaining = l.perMinute - int(count)
+	}
+
+	count, err := l.store.Increment(ctx, dayKey(subject, dayStart), day+time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	dayResult := &Result{Allowed: true, Limit: dailyLimit, Reset: dayStart.Add(day)}
+	if count > int64(dailyLimit) {
+		dayResult.Allowed = false
+		return dayResult, nil
+	}
+	dayResult.Remaining = dailyLimit - int(count)
+
+	monthStart := startOfMonth(now)
+	monthTTL := monthStart.AddDate(0, 1, 0).Sub(now) + day
+	if _, err := l.store.Increment(ctx, monthKey(subject, monthStart), monthTTL); err != nil {
+		return nil, err
+	}
+
+	if l.perMinute > 0 && minuteResult.Remaining < dayResult.Remaining {
+		return minuteResult, nil
+	}
+	return dayResult, nil
+}
+
+// Usage reports the subject's current usage without counting a request
+func (l *Limiter) Usage(ctx context.Context, subject string, dailyLimit int) (*Usage, error) {
+	now := time.Now().UTC()
+	dayStart := startOfDay(now)
+
+	usedToday, err := l.store.Get(ctx, dayKey(subject, dayStart))
+	if err != nil {
+		return nil, err
+	}
+	usedThisMonth, err := l.store.Get(ctx, monthKey(subject, startOfMonth(now)))
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := int64(dailyLimit) - usedToday
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return &Usage{
+		DailyLimit:     dailyLimit,
+		PerMinuteLimit: l.perMinute,
+		UsedToday:      usedToday,
+		RemainingToday: remaining,
+		UsedThisMonth:  usedThisMonth,
+		ResetsAt:       dayStart.Add(day),
+	}, nil
+}
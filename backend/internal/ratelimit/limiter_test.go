@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingStore fails every operation, as when Redis is down
+type failingStore struct{}
+
+var errStoreDown = errors.New("store unavailable")
+
+func (failingStore) Increment(context.Context, string, time.Duration) (int64, error) {
+	return 0, errStoreDown
+}
+
+func (failingStore) Get(context.Context, string) (int64, error) { return 0, errStoreDown }
+
+func TestLimiterAllow(t *testing.T) {
+	tests := []struct {
+		name          string
+		perMinute     int
+		dailyLimit    int
+		wantAllowed   []bool
+		wantLimit     int
+		wantRemaining []int
+	}{
+		{
+			name:          "per-minute limit is the tighter",
+			perMinute:     2,
+			dailyLimit:    100,
+			wantAllowed:   []bool{true, true, false},
+			wantLimit:     2,
+			wantRemaining: []int{1, 0, 0},
+		},
+		{
+			name:          "daily limit is the tighter",
+			perMinute:     100,
+			dailyLimit:    2,
+			wantAllowed:   []bool{true, true, false},
+			wantLimit:     2,
+			wantRemaining: []int{1, 0, 0},
+		},
+		{
+			name:          "no per-minute limit",
+			dailyLimit:    1,
+			wantAllowed:   []bool{true, false},
+			wantLimit:     1,
+			wantRemaining: []int{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(NewMemoryStore(), tt.perMinute)
+			for i, wantAllowed := range tt.wantAllowed {
+				result, err := l.Allow(context.Background(), "user:7", tt.dailyLimit)
+				if err != nil {
+					t.Fatalf("Allow() error = %v", err)
+				}
+				if result.Allowed != wantAllowed || result.Limit != tt.wantLimit || result.Remaining != tt.wantRemaining[i] {
+					t.Errorf("request %d: Allow() = allowed %v, limit %d, remaining %d; want %v, %d, %d",
+						i+1, result.Allowed, result.Limit, result.Remaining, wantAllowed, tt.wantLimit, tt.wantRemaining[i])
+				}
+				if !result.Reset.After(time.Now()) {
+					t.Errorf("request %d: reset %v is not in the future", i+1, result.Reset)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterSubjectsAreCountedSeparately(t *testing.T) {
+	l := NewLimiter(NewMemoryStore(), 0)
+	ctx := context.Background()
+
+	if result, _ := l.Allow(ctx, "user:7", 1); !result.Allowed {
+		t.Fatal("first request for user 7 was rejected")
+	}
+	if result, _ := l.Allow(ctx, "user:8", 1); !result.Allowed {
+		t.Error("first request for user 8 was rejected")
+	}
+}
+
+func TestLimiterUsage(t *testing.T) {
+	l := NewLimiter(NewMemoryStore(), 0)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := l.Allow(ctx, "user:7", 2); err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+	}
+
+	usage, err := l.Usage(ctx, "user:7", 2)
+	if err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	// Rejected requests use up the day but aren't reported as API calls
+	if usage.UsedToday != 3 || usage.RemainingToday != 0 || usage.UsedThisMonth != 2 {
+		t.Errorf("Usage() = used today %d, remaining %d, used this month %d; want 3, 0, 2",
+			usage.UsedToday, usage.RemainingToday, usage.UsedThisMonth)
+	}
+	if want := startOfDay(time.Now().UTC()).Add(day); !usage.ResetsAt.Equal(want) {
+		t.Errorf("ResetsAt = %v, want %v", usage.ResetsAt, want)
+	}
+}
+
+func TestLimiterReportsStoreErrors(t *testing.T) {
+	l := NewLimiter(failingStore{}, 10)
+	if _, err := l.Allow(context.Background(), "user:7", 100); !errors.Is(err, errStoreDown) {
+		t.Errorf("Allow() error = %v, want %v", err, errStoreDown)
+	}
+	if _, err := l.Usage(context.Background(), "user:7", 100); !errors.Is(err, errStoreDown) {
+		t.Errorf("Usage() error = %v, want %v", err, errStoreDown)
+	}
+}
+
+func TestMemoryStoreExpiresCounters(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+
+	s.Increment(ctx, "short", time.Millisecond)
+	s.Increment(ctx, "long", time.Minute)
+	time.Sleep(5 * time.Millisecond)
+
+	if count, _ := s.Get(ctx, "short"); count != 0 {
+		t.Errorf("Get(short) = %d, want 0", count)
+	}
+	if count, _ := s.Increment(ctx, "short", time.Minute); count != 1 {
+		t.Errorf("Increment(short) after expiry = %d, want 1", count)
+	}
+	if count, _ := s.Get(ctx, "long"); count != 1 {
+		t.Errorf("Get(long) = %d, want 1", count)
+	}
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/apierror"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/middleware"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// Subject returns the counter subject for a user. The daily quota belongs
+// to the account's plan, so counters are shared by all of a user's keys
+// and rotating a key does not reset them.
+func Subject(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+// Middleware enforces the authenticated user's API rate limits. It must run
+// after auth.APIKeyMiddleware. If the counter store fails, requests are let
+// through rather than taking the API down with it.
+func Middleware(limiter *Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("user")
+		user, ok := value.(*models.User)
+		if !exists || !ok {
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		dailyLimit := user.GetAPIRateLimit()
+		if dailyLimit <= 0 {
+			apierror.Abort(c, http.StatusForbidden, apierror.CodePlanRequired, "API access is not included in your plan")
+			return
+		}
+
+		ctx := middleware.GetContext(c)
+		result, err := limiter.Allow(ctx, Subject(user.ID), dailyLimit)
+		if err != nil {
+			logger.Errorf(ctx, "Rate limit check failed for user %d, allowing request: %+v", user.ID, err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(result.Reset.Unix(), 10))
+
+		if !result.Allowed {
+			retryAfter := int(result.RetryAfter().Seconds() + 0.5)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			apierror.Abort(c, http.StatusTooManyRequests, apierror.CodeRateLimited, "Rate limit exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
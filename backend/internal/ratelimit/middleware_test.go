@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pro := &models.User{ID: 7, SubscriptionTier: models.TierPro}
+
+	tests := []struct {
+		name        string
+		user        *models.User // nil when the request isn't authenticated
+		store       Store
+		requests    int
+		wantStatus  int
+		wantHeaders bool
+	}{
+		{name: "unauthenticated", store: NewMemoryStore(), requests: 1, wantStatus: http.StatusUnauthorized},
+		{name: "plan without API access", user: &models.User{ID: 7, SubscriptionTier: models.TierFree}, store: NewMemoryStore(), requests: 1, wantStatus: http.StatusForbidden},
+		{name: "within the limit", user: pro, store: NewMemoryStore(), requests: 2, wantStatus: http.StatusOK, wantHeaders: true},
+		{name: "over the limit", user: pro, store: NewMemoryStore(), requests: 3, wantStatus: http.StatusTooManyRequests, wantHeaders: true},
+		{name: "store unavailable", user: pro, store: failingStore{}, requests: 1, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.user != nil {
+					c.Set("user", tt.user)
+				}
+			})
+			router.Use(Middleware(NewLimiter(tt.store, 2)))
+			router.GET("/api/v1/links", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			var w *httptest.ResponseRecorder
+			for i := 0; i < tt.requests; i++ {
+				w = httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/links", nil))
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("X-RateLimit-Limit") != ""; got != tt.wantHeaders {
+				t.Errorf("X-RateLimit-Limit set = %v, want %v", got, tt.wantHeaders)
+			}
+			if got := w.Header().Get("Retry-After") != ""; got != (tt.wantStatus == http.StatusTooManyRequests) {
+				t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+			}
+			if tt.wantHeaders && tt.wantStatus == http.StatusOK && w.Header().Get("X-RateLimit-Remaining") != "0" {
+				t.Errorf("X-RateLimit-Remaining = %q, want 0", w.Header().Get("X-RateLimit-Remaining"))
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store holds fixed-window request counters
+type Store interface {
+	// Increment adds one to the counter at key, which expires after ttl,
+	// and returns the new count
+	Increment(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Get returns the current count at key, or zero if it does not exist
+	Get(ctx context.Context, key string) (int64, error)
+}
+
+type memoryCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// sweepInterval is how often expired counters are removed from a MemoryStore
+const sweepInterval = time.Minute
+
+// MemoryStore keeps counters in process. Limits are enforced per instance,
+// so use the Redis store when running more than one replica.
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]*memoryCounter
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters:  make(map[string]*memoryCounter),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	counter, ok := s.counters[key]
+	if !ok || now.After(counter.expiresAt) {
+		counter = &memoryCounter{expiresAt: now.Add(ttl)}
+		s.counters[key] = counter
+	}
+	counter.count++
+	return counter.count, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counter, ok := s.counters[key]
+	if !ok || time.Now().After(counter.expiresAt) {
+		return 0, nil
+	}
+	return counter.count, nil
+}
+
+// sweep drops expired counters; callers must hold the lock
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	for key, counter := range s.counters {
+		if now.After(counter.expiresAt) {
+			delete(s.counters, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// RedisStore keeps counters in Redis so limits hold across replicas. The
+// client is owned by the caller.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Increment(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := s.client.TxPipeline()
+	incr := pipe.Incr(ctx, s.prefix+key)
+	pipe.Expire(ctx, s.prefix+key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("error incrementing rate limit counter: %w", err)
+	}
+	return incr.Val(), nil
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (int64, error) {
+	count, err := s.client.Get(ctx, s.prefix+key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading rate limit counter: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,362 @@
+// Package routing picks the destination a short link sends a visitor to,
+// evaluating the link's redirect rules against the request and splitting
+// the remaining visitors across its A/B variants.
+package routing
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// VariantCookieMaxAge is how long a visitor keeps seeing the same A/B variant
+const VariantCookieMaxAge = 30 * 24 * time.Hour
+
+// VariantCookie names the cookie remembering a visitor's A/B variant for a link
+func VariantCookie(linkID int64) string {
+	return fmt.Sprintf("link_variant_%d", linkID)
+}
+
+// Visitor holds the request attributes redirect rules match on. Platform
+// picks the app a deep link opens. Crawler marks link preview crawlers.
+// Variant is the A/B variant the visitor was assigned on an earlier visit,
+// if any.
+type Visitor struct {
+	DeviceType  string
+	Platform    string
+	Crawler     bool
+	CountryCode string
+	Language    string
+	Query       url.Values
+	Variant     string
+}
+
+// NewVisitor reads a visitor from a redirect request. The country comes
+// from countryHeader, set by a CDN or proxy in front of the app; without
+// one the country is unknown.
+func NewVisitor(r *http.Request, countryHeader string) *Visitor {
+	v := &Visitor{
+		DeviceType: DetectDeviceType(r.Header.Get("User-Agent")),
+		Platform:   DetectPlatform(r.Header.Get("User-Agent")),
+		Crawler:    IsCrawler(r.Header.Get("User-Agent")),
+		Language:   preferredLanguage(r.Header.Get("Accept-Language")),
+		Query:      r.URL.Query(),
+	}
+	if countryHeader != "" {
+		country := strings.ToUpper(strings.TrimSpace(r.Header.Get(countryHeader)))
+		// XX is the conventional code for an unknown country
+		if len(country) == 2 && country != "XX" && isLetters(country) {
+			v.CountryCode = country
+		}
+	}
+	return v
+}
+
+// Route is where a visitor is sent. Rule names the redirect rule that
+// matched and Variant the A/B variant picked; both are empty for the
+// link's default destination. Campaign is the destination's utm_campaign.
+// DeepLinkPath records how a visitor served the deep link page left it.
+type Route struct {
+	Visitor        *Visitor
+	DestinationURL string
+	Rule           string
+	Variant        string
+	Campaign       string
+	DeepLinkPath   string
+}
+
+// Resolve evaluates the link's redirect rules in order and routes the
+// visitor to the first match. Visitors no rule matches are split across the
+// link's A/B variants, if it has any, or sent to its destination. The
+// link's query parameters are then added to the destination.
+func Resolve(link *models.Link, visitor *Visitor, now time.Time) *Route {
+	route := &Route{Visitor: visitor, DestinationURL: link.DestinationURL}
+	matched := false
+	for i := range link.RedirectRules {
+		rule := &link.RedirectRules[i]
+		if Matches(rule, visitor, now) {
+			route.DestinationURL, route.Rule = rule.DestinationURL, rule.Name
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		if variant := PickVariant(link.Variants, visitor.Variant); variant != nil {
+			route.DestinationURL, route.Variant = variant.DestinationURL, variant.Name
+		}
+	}
+
+	route.DestinationURL = Destination(link, route.DestinationURL, visitor.Query)
+	if parsed, err := url.Parse(route.DestinationURL); err == nil {
+		route.Campaign = parsed.Query().Get("utm_campaign")
+	}
+	return route
+}
+
+// Destination sets the link's UTM parameters on destination and, when the
+// link passes its query string through, adds the short URL's query. Short
+// URL parameters the destination also sets replace its values under the
+// short_link conflict policy and are dropped otherwise.
+func Destination(link *models.Link, destination string, query url.Values) string {
+	destination = mergeQuery(destination, link.UTM.Values(), true)
+	if link.QueryPassthrough {
+		destination = mergeQuery(destination, query, link.QueryConflict == models.QueryConflictShortLink)
+	}
+	return destination
+}
+
+// mergeQuery adds params to rawURL's query string. With replace, params
+// replace parameters of the same name; otherwise those are kept and only
+// new ones are added. The rest of the query string is left as it was.
+func mergeQuery(rawURL string, params url.Values, replace bool) string {
+	if len(params) == 0 {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	// Parse errors only drop the malformed parameters
+	existing, _ := url.ParseQuery(u.RawQuery)
+	added := url.Values{}
+	for key, values := range params {
+		if replace || !existing.Has(key) {
+			added[key] = values
+		}
+	}
+	if len(added) == 0 {
+		return rawURL
+	}
+
+	var parts []string
+	for _, part := range strings.Split(u.RawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(part, "=")
+		if name, err := url.QueryUnescape(key); err == nil && added.Has(name) {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	u.RawQuery = strings.Join(append(parts, added.Encode()), "&")
+	return u.String()
+}
+
+// PickVariant returns the variant named sticky if the link still has it, so
+// repeat visitors see the same page, and otherwise picks one at random in
+// proportion to the weights. It returns nil when there are no variants.
+func PickVariant(variants models.LinkVariants, sticky string) *models.LinkVariant {
+	total := 0
+	for i := range variants {
+		if sticky != "" && variants[i].Name == sticky {
+			return &variants[i]
+		}
+		total += variants[i].Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	n := rand.IntN(total)
+	for i := range variants {
+		n -= variants[i].Weight
+		if n < 0 {
+			return &variants[i]
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the visitor meets every condition of the rule
+func Matches(rule *models.RedirectRule, v *Visitor, now time.Time) bool {
+	if len(rule.DeviceTypes) > 0 && !slices.Contains(rule.DeviceTypes, v.DeviceType) {
+		return false
+	}
+	if len(rule.Countries) > 0 && !slices.Contains(rule.Countries, v.CountryCode) {
+		return false
+	}
+	if len(rule.Languages) > 0 && !matchesLanguage(rule.Languages, v.Language) {
+		return false
+	}
+	if len(rule.Days) > 0 || rule.StartTime != "" {
+		local := now.In(location(rule.Timezone))
+		if len(rule.Days) > 0 && !slices.Contains(rule.Days, int(local.Weekday())) {
+			return false
+		}
+		if rule.StartTime != "" && !inWindow(rule.StartTime, rule.EndTime, local) {
+			return false
+		}
+	}
+	for key, want := range rule.Query {
+		values, ok := v.Query[key]
+		if !ok {
+			return false
+		}
+		if want != "" && !slices.Contains(values, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// DetectDeviceType determines device type from user agent
+func DetectDeviceType(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	if strings.Contains(ua, "mobile") || strings.Contains(ua, "android") || strings.Contains(ua, "iphone") {
+		return models.DeviceMobile
+	}
+
+	if strings.Contains(ua, "tablet") || strings.Contains(ua, "ipad") {
+		return models.DeviceTablet
+	}
+
+	return models.DeviceDesktop
+}
+
+// DetectPlatform determines the mobile operating system from user agent.
+// iPadOS reports itself as macOS, so iPads are only recognized by older
+// user agents.
+func DetectPlatform(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	if strings.Contains(ua, "android") {
+		return models.PlatformAndroid
+	}
+
+	if strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad") || strings.Contains(ua, "ipod") {
+		return models.PlatformIOS
+	}
+
+	return models.PlatformOther
+}
+
+// crawlerAgents are user agent fragments of the crawlers social networks
+// and chat apps send to build link previews
+var crawlerAgents = []string{
+	"facebookexternalhit",
+	"facebot",
+	"twitterbot",
+	"slackbot",
+	"slack-imgproxy",
+	"linkedinbot",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"pinterestbot",
+	"redditbot",
+	"skypeuripreview",
+	"mastodon",
+	"embedly",
+	"iframely",
+	"vkshare",
+	"quora link preview",
+}
+
+// IsCrawler reports whether the user agent is a known link preview crawler
+func IsCrawler(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+
+	for _, agent := range crawlerAgents {
+		if strings.Contains(ua, agent) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// preferredLanguage returns the lowercased Accept-Language tag with the
+// highest quality, the first one on ties, or "" if there is none
+func preferredLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
+
+// matchesLanguage reports whether lang is one of the languages or a more
+// specific variant of one, so "en" matches "en-us"
+func matchesLanguage(languages []string, lang string) bool {
+	if lang == "" {
+		return false
+	}
+	for _, l := range languages {
+		if lang == l || strings.HasPrefix(lang, l+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// inWindow reports whether t's time of day falls in [start, end). A window
+// ending before it starts runs past midnight.
+func inWindow(start, end string, t time.Time) bool {
+	now := t.Hour()*60 + t.Minute()
+	from, to := minutes(start), minutes(end)
+	if from <= to {
+		return now >= from && now < to
+	}
+	return now >= from || now < to
+}
+
+// minutes converts a validated "HH:MM" to minutes after midnight
+func minutes(clock string) int {
+	h, _ := strconv.Atoi(clock[:2])
+	m, _ := strconv.Atoi(clock[3:])
+	return h*60 + m
+}
+
+// locations caches loaded timezones, which are read from disk otherwise
+var locations sync.Map
+
+func location(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	locations.Store(name, loc)
+	return loc
+}
+
+func isLetters(s string) bool {
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,285 @@
+package routing
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+const iPhoneAgent = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148"
+
+func TestNewVisitor(t *testing.T) {
+	tests := []struct {
+		name          string
+		countryHeader string
+		country       string
+		language      string
+		wantCountry   string
+		wantLanguage  string
+	}{
+		{name: "country from the header", countryHeader: "CF-IPCountry", country: "de", wantCountry: "DE"},
+		{name: "unknown country", countryHeader: "CF-IPCountry", country: "XX"},
+		{name: "malformed country", countryHeader: "CF-IPCountry", country: "D3"},
+		{name: "no country header configured", country: "DE"},
+		{name: "highest quality language", language: "fr;q=0.5, en-GB, en;q=0.9", wantLanguage: "en-gb"},
+		{name: "first language on ties", language: "de, fr", wantLanguage: "de"},
+		{name: "wildcard language", language: "*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/sale?ref=mail", nil)
+			r.Header.Set("User-Agent", iPhoneAgent)
+			r.Header.Set("CF-IPCountry", tt.country)
+			r.Header.Set("Accept-Language", tt.language)
+
+			v := NewVisitor(r, tt.countryHeader)
+			if v.CountryCode != tt.wantCountry || v.Language != tt.wantLanguage {
+				t.Errorf("visitor country %q, language %q; want %q, %q", v.CountryCode, v.Language, tt.wantCountry, tt.wantLanguage)
+			}
+			if v.DeviceType != models.DeviceMobile || v.Query.Get("ref") != "mail" {
+				t.Errorf("visitor device %q, query %v; want mobile with ref=mail", v.DeviceType, v.Query)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	// A Saturday at 23:30 UTC, 08:30 on Sunday in Tokyo
+	now := time.Date(2026, 3, 14, 23, 30, 0, 0, time.UTC)
+	visitor := &Visitor{
+		DeviceType:  models.DeviceMobile,
+		CountryCode: "DE",
+		Language:    "en-gb",
+		Query:       url.Values{"ref": {"mail"}},
+	}
+
+	tests := []struct {
+		name string
+		rule models.RedirectRule
+		want bool
+	}{
+		{name: "device", rule: models.RedirectRule{DeviceTypes: []string{models.DeviceTablet, models.DeviceMobile}}, want: true},
+		{name: "other device", rule: models.RedirectRule{DeviceTypes: []string{models.DeviceDesktop}}},
+		{name: "country", rule: models.RedirectRule{Countries: []string{"DE"}}, want: true},
+		{name: "other country", rule: models.RedirectRule{Countries: []string{"FR"}}},
+		{name: "language family", rule: models.RedirectRule{Languages: []string{"en"}}, want: true},
+		{name: "exact language", rule: models.RedirectRule{Languages: []string{"en-gb"}}, want: true},
+		{name: "other regional language", rule: models.RedirectRule{Languages: []string{"en-us"}}},
+		{name: "weekday", rule: models.RedirectRule{Days: []int{6}}, want: true},
+		{name: "weekday in the rule's timezone", rule: models.RedirectRule{Days: []int{0}, Timezone: "Asia/Tokyo"}, want: true},
+		{name: "time window", rule: models.RedirectRule{StartTime: "23:00", EndTime: "23:59"}, want: true},
+		{name: "window ends exclusive", rule: models.RedirectRule{StartTime: "22:00", EndTime: "23:30"}},
+		{name: "window past midnight", rule: models.RedirectRule{StartTime: "22:00", EndTime: "02:00"}, want: true},
+		{name: "window in the rule's timezone", rule: models.RedirectRule{StartTime: "08:00", EndTime: "09:00", Timezone: "Asia/Tokyo"}, want: true},
+		{name: "query value", rule: models.RedirectRule{Query: map[string]string{"ref": "mail"}}, want: true},
+		{name: "query present", rule: models.RedirectRule{Query: map[string]string{"ref": ""}}, want: true},
+		{name: "other query value", rule: models.RedirectRule{Query: map[string]string{"ref": "ads"}}},
+		{name: "missing query", rule: models.RedirectRule{Query: map[string]string{"promo": ""}}},
+		{name: "every condition", rule: models.RedirectRule{DeviceTypes: []string{models.DeviceMobile}, Countries: []string{"DE"}}, want: true},
+		{name: "one condition fails", rule: models.RedirectRule{DeviceTypes: []string{models.DeviceMobile}, Countries: []string{"FR"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Matches(&tt.rule, visitor, now); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesUnknownLanguageAndCountry(t *testing.T) {
+	now := time.Now()
+	visitor := &Visitor{DeviceType: models.DeviceDesktop}
+
+	if Matches(&models.RedirectRule{Languages: []string{"en"}}, visitor, now) {
+		t.Error("language rule matched a visitor without a language")
+	}
+	if Matches(&models.RedirectRule{Countries: []string{"DE"}}, visitor, now) {
+		t.Error("country rule matched a visitor without a country")
+	}
+}
+
+func TestResolveUsesTheFirstMatchingRule(t *testing.T) {
+	link := &models.Link{
+		DestinationURL: "https://example.com",
+		RedirectRules: models.RedirectRules{
+			{Name: "german", DestinationURL: "https://example.de", Countries: []string{"DE"}},
+			{Name: "mobile", DestinationURL: "https://m.example.com", DeviceTypes: []string{models.DeviceMobile}},
+			{Name: "also-mobile", DestinationURL: "https://app.example.com", DeviceTypes: []string{models.DeviceMobile}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		visitor  *Visitor
+		wantURL  string
+		wantRule string
+	}{
+		{name: "first rule", visitor: &Visitor{DeviceType: models.DeviceMobile, CountryCode: "DE"}, wantURL: "https://example.de", wantRule: "german"},
+		{name: "later rule", visitor: &Visitor{DeviceType: models.DeviceMobile, CountryCode: "FR"}, wantURL: "https://m.example.com", wantRule: "mobile"},
+		{name: "no rule falls back to the destination", visitor: &Visitor{DeviceType: models.DeviceDesktop}, wantURL: "https://example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := Resolve(link, tt.visitor, time.Now())
+			if route.DestinationURL != tt.wantURL || route.Rule != tt.wantRule {
+				t.Errorf("Resolve() = %s by rule %q, want %s by rule %q", route.DestinationURL, route.Rule, tt.wantURL, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	variants := models.LinkVariants{
+		{Name: "control", DestinationURL: "https://example.com/a", Weight: 3},
+		{Name: "challenger", DestinationURL: "https://example.com/b", Weight: 1},
+	}
+
+	if got := PickVariant(variants, "challenger"); got == nil || got.Name != "challenger" {
+		t.Errorf("PickVariant() with a sticky variant = %v, want challenger", got)
+	}
+	if got := PickVariant(nil, ""); got != nil {
+		t.Errorf("PickVariant() without variants = %v, want nil", got)
+	}
+	if got := PickVariant(models.LinkVariants{{Name: "a"}, {Name: "b"}}, ""); got != nil {
+		t.Errorf("PickVariant() with no weight = %v, want nil", got)
+	}
+
+	// A sticky variant the link no longer has is picked again by weight
+	picks := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		picks[PickVariant(variants, "removed").Name]++
+	}
+	if share := float64(picks["control"]) / 4000; share < 0.7 || share > 0.8 {
+		t.Errorf("control picked %.2f of the time, want about 0.75", share)
+	}
+}
+
+func TestResolveSplitsVisitorsNoRuleMatches(t *testing.T) {
+	link := &models.Link{
+		DestinationURL: "https://example.com",
+		RedirectRules: models.RedirectRules{
+			{Name: "mobile", DestinationURL: "https://m.example.com", DeviceTypes: []string{models.DeviceMobile}},
+		},
+		Variants: models.LinkVariants{
+			{Name: "control", DestinationURL: "https://example.com/a", Weight: 1},
+			{Name: "challenger", DestinationURL: "https://example.com/b", Weight: 1},
+		},
+	}
+
+	route := Resolve(link, &Visitor{DeviceType: models.DeviceMobile, Variant: "control"}, time.Now())
+	if route.Rule != "mobile" || route.Variant != "" {
+		t.Errorf("Resolve() for a rule match = rule %q, variant %q; want the mobile rule only", route.Rule, route.Variant)
+	}
+
+	route = Resolve(link, &Visitor{DeviceType: models.DeviceDesktop, Variant: "challenger"}, time.Now())
+	if route.Variant != "challenger" || route.DestinationURL != "https://example.com/b" {
+		t.Errorf("Resolve() = %s as variant %q, want the sticky challenger", route.DestinationURL, route.Variant)
+	}
+}
+
+func TestDetectDeviceTypeAndPlatform(t *testing.T) {
+	tests := []struct {
+		name         string
+		userAgent    string
+		wantDevice   string
+		wantPlatform string
+	}{
+		{name: "iPhone", userAgent: iPhoneAgent, wantDevice: models.DeviceMobile, wantPlatform: models.PlatformIOS},
+		{name: "Android phone", userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile Safari/537.36", wantDevice: models.DeviceMobile, wantPlatform: models.PlatformAndroid},
+		{name: "older iPad", userAgent: "Mozilla/5.0 (iPad; CPU OS 12_0 like Mac OS X)", wantDevice: models.DeviceTablet, wantPlatform: models.PlatformIOS},
+		{name: "desktop", userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) Safari/605.1.15", wantDevice: models.DeviceDesktop, wantPlatform: models.PlatformOther},
+		{name: "no user agent", wantDevice: models.DeviceDesktop, wantPlatform: models.PlatformOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectDeviceType(tt.userAgent); got != tt.wantDevice {
+				t.Errorf("DetectDeviceType() = %s, want %s", got, tt.wantDevice)
+			}
+			if got := DetectPlatform(tt.userAgent); got != tt.wantPlatform {
+				t.Errorf("DetectPlatform() = %s, want %s", got, tt.wantPlatform)
+			}
+		})
+	}
+}
+
+func TestDestination(t *testing.T) {
+	utm := &models.UTMParams{Source: "newsletter", Campaign: "spring sale"}
+
+	tests := []struct {
+		name        string
+		destination string
+		link        models.Link
+		query       url.Values
+		want        string
+	}{
+		{name: "nothing to add", destination: "https://example.com/p?b=2&a=1", query: url.Values{"ref": {"mail"}}, want: "https://example.com/p?b=2&a=1"},
+		{name: "UTM parameters", destination: "https://example.com/p", link: models.Link{UTM: utm},
+			want: "https://example.com/p?utm_campaign=spring+sale&utm_source=newsletter"},
+		{name: "UTM parameters replace the destination's", destination: "https://example.com/p?utm_source=ads&id=7", link: models.Link{UTM: utm},
+			want: "https://example.com/p?id=7&utm_campaign=spring+sale&utm_source=newsletter"},
+		{name: "passthrough adds new parameters", destination: "https://example.com/p?id=7",
+			link:  models.Link{QueryPassthrough: true, QueryConflict: models.QueryConflictDestination},
+			query: url.Values{"ref": {"mail"}}, want: "https://example.com/p?id=7&ref=mail"},
+		{name: "destination wins conflicts", destination: "https://example.com/p?id=7",
+			link:  models.Link{QueryPassthrough: true, QueryConflict: models.QueryConflictDestination},
+			query: url.Values{"id": {"8"}}, want: "https://example.com/p?id=7"},
+		{name: "short link wins conflicts", destination: "https://example.com/p?id=7&x=%2F",
+			link:  models.Link{QueryPassthrough: true, QueryConflict: models.QueryConflictShortLink},
+			query: url.Values{"id": {"8"}}, want: "https://example.com/p?x=%2F&id=8"},
+		{name: "short URL UTM overrides stored UTM when it wins", destination: "https://example.com/p",
+			link:  models.Link{UTM: utm, QueryPassthrough: true, QueryConflict: models.QueryConflictShortLink},
+			query: url.Values{"utm_source": {"twitter"}}, want: "https://example.com/p?utm_campaign=spring+sale&utm_source=twitter"},
+		{name: "fragment kept", destination: "https://example.com/p#top", link: models.Link{UTM: &models.UTMParams{Medium: "email"}},
+			want: "https://example.com/p?utm_medium=email#top"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Destination(&tt.link, tt.destination, tt.query); got != tt.want {
+				t.Errorf("Destination() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveRecordsTheCampaign(t *testing.T) {
+	link := &models.Link{DestinationURL: "https://example.com/p?utm_campaign=launch"}
+	if route := Resolve(link, &Visitor{}, time.Now()); route.Campaign != "launch" {
+		t.Errorf("campaign = %q, want launch", route.Campaign)
+	}
+
+	link.UTM = &models.UTMParams{Campaign: "spring"}
+	if route := Resolve(link, &Visitor{}, time.Now()); route.Campaign != "spring" {
+		t.Errorf("campaign = %q, want the link's spring", route.Campaign)
+	}
+}
+
+func TestIsCrawler(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      bool
+	}{
+		{userAgent: "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", want: true},
+		{userAgent: "Twitterbot/1.0", want: true},
+		{userAgent: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", want: true},
+		{userAgent: "Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", want: true},
+		{userAgent: "WhatsApp/2.23.20.0", want: true},
+		{userAgent: iPhoneAgent},
+		{userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"},
+		{userAgent: ""},
+	}
+
+	for _, tt := range tests {
+		if got := IsCrawler(tt.userAgent); got != tt.want {
+			t.Errorf("IsCrawler(%q) = %v, want %v", tt.userAgent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/mail"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/ratelimit"
+)
+
+// Emails a single user or address can be sent per hour, so the endpoints
+// can't be used to flood an inbox
+const (
+	maxVerificationEmailsPerHour  = 5
+	maxPasswordResetEmailsPerHour = 3
+)
+
+var (
+	// ErrEmailAlreadyVerified is returned when resending verification to a verified user
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	// ErrEmailNotVerified is returned by features that need a verified email
+	ErrEmailNotVerified = errors.New("email address is not verified")
+	// ErrTooManyEmails is returned when the hourly email allowance is used up
+	ErrTooManyEmails = errors.New("too many emails requested")
+)
+
+// emailLinkData is passed to the email templates
+type emailLinkData struct {
+	Name      string
+	URL       string
+	ExpiresIn string
+}
+
+// AccountEmailService runs the account flows driven by emailed links:
+// email verification and password reset
+type AccountEmailService struct {
+	tokenRepo      *database.EmailTokenRepository
+	userRepo       *database.UserRepository
+	sessionService *SessionService
+	mailer         mail.Mailer
+	throttle       ratelimit.Store
+	config         *config.Config
+}
+
+func NewAccountEmailService(tokenRepo *database.EmailTokenRepository, userRepo *database.UserRepository, sessionService *SessionService, mailer mail.Mailer, throttle ratelimit.Store, cfg *config.Config) *AccountEmailService {
+	return &AccountEmailService{
+		tokenRepo:      tokenRepo,
+		userRepo:       userRepo,
+		sessionService: sessionService,
+		mailer:         mailer,
+		throttle:       throttle,
+		config:         cfg,
+	}
+}
+
+// SendVerification emails the user a link to confirm their address
+func (s *AccountEmailService) SendVerification(ctx context.Context, user *models.User) error {
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+	if !s.allow(ctx, "email_verification:"+fmt.Sprint(user.ID), maxVerificationEmailsPerHour) {
+		return ErrTooManyEmails
+	}
+
+	ttl := time.Duration(s.config.Email.VerificationTTLHours) * time.Hour
+	return s.sendLink(ctx, user, models.EmailTokenVerification, ttl, mail.TemplateVerifyEmail, "/verify-email")
+}
+
+// VerifyEmail confirms the address behind a verification token
+func (s *AccountEmailService) VerifyEmail(token string) error {
+	emailToken, err := s.tokenRepo.Consume(auth.HashEmailToken(token), models.EmailTokenVerification)
+	if err != nil {
+		return err
+	}
+	logger.Infof(context.Background(), "Email verified for user ID: %d", emailToken.UserID)
+	return s.userRepo.MarkEmailVerified(emailToken.UserID)
+}
+
+// RequestPasswordReset emails a reset link if an account uses the address.
+// Nothing is reported back, failures included, so callers can't probe
+// which emails are registered.
+func (s *AccountEmailService) RequestPasswordReset(ctx context.Context, email string) {
+	user, err := s.userRepo.GetByEmail(strings.TrimSpace(email))
+	if err != nil {
+		logger.Infof(ctx, "Password reset requested for unknown email")
+		return
+	}
+	if !s.allow(ctx, "password_reset:"+fmt.Sprint(user.ID), maxPasswordResetEmailsPerHour) {
+		logger.Warnf(ctx, "Password reset emails throttled for user ID: %d", user.ID)
+		return
+	}
+
+	ttl := time.Duration(s.config.Email.PasswordResetTTLMinutes) * time.Minute
+	if err := s.sendLink(ctx, user, models.EmailTokenPasswordReset, ttl, mail.TemplatePasswordReset, "/reset-password"); err != nil {
+		logger.Errorf(ctx, "Failed to send password reset for user ID %d: %+v", user.ID, err)
+	}
+}
+
+// ResetPassword sets a new password using a reset token and signs out
+// every session. Following the link also proves the user owns the address.
+func (s *AccountEmailService) ResetPassword(token, newPassword string) error {
+	emailToken, err := s.tokenRepo.Consume(auth.HashEmailToken(token), models.EmailTokenPasswordReset)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+	if err := s.userRepo.UpdatePassword(emailToken.UserID, passwordHash); err != nil {
+		return fmt.Errorf("error updating password: %w", err)
+	}
+	if err := s.sessionService.RevokeAll(emailToken.UserID); err != nil {
+		return err
+	}
+	if err := s.userRepo.MarkEmailVerified(emailToken.UserID); err != nil {
+		logger.Warnf(context.Background(), "Failed to mark email verified for user ID %d: %v", emailToken.UserID, err)
+	}
+
+	logger.Infof(context.Background(), "Password reset for user ID: %d", emailToken.UserID)
+	return nil
+}
+
+func (s *AccountEmailService) sendLink(ctx context.Context, user *models.User, purpose models.EmailTokenPurpose, ttl time.Duration, template, path string) error {
+	token, hash, err := auth.GenerateEmailToken()
+	if err != nil {
+		return fmt.Errorf("error generating email token: %w", err)
+	}
+	if err := s.tokenRepo.Replace(&models.EmailToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return err
+	}
+
+	msg, err := mail.Render(template, user.Email, emailLinkData{
+		Name:      user.FullName,
+		URL:       strings.TrimRight(s.config.Server.AppURL, "/") + path + "?token=" + url.QueryEscape(token),
+		ExpiresIn: formatTTL(ttl),
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+
+	logger.Infof(ctx, "Sent %s email to user ID: %d", purpose, user.ID)
+	return nil
+}
+
+// allow counts an email against an hourly allowance. If the counter store
+// is down, mail is sent anyway rather than blocking sign-in flows.
+func (s *AccountEmailService) allow(ctx context.Context, key string, limit int64) bool {
+	count, err := s.throttle.Increment(ctx, key, time.Hour)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to check email throttle %s: %+v", key, err)
+		return true
+	}
+	return count <= limit
+}
+
+// formatTTL renders a token lifetime for email copy, e.g. "48 hours"
+func formatTTL(ttl time.Duration) string {
+	value, unit := int(ttl.Minutes()), "minute"
+	if ttl >= time.Hour && ttl%time.Hour == 0 {
+		value, unit = int(ttl.Hours()), "hour"
+	}
+	if value != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", value, unit)
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/mail"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/ratelimit"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// consumeTokenQuery is the statement that spends an email token; only an
+// unused, unexpired token of the right purpose matches it
+var consumeTokenQuery = regexp.QuoteMeta(`UPDATE email_tokens SET used_at = $1
+		WHERE token_hash = $2 AND purpose = $3 AND used_at IS NULL AND expires_at > $4`)
+
+// aroundNow matches a time argument within a few seconds of now plus offset
+type aroundNow struct {
+	offset time.Duration
+}
+
+func (a aroundNow) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	diff := time.Until(t) - a.offset
+	return diff > -5*time.Second && diff < 5*time.Second
+}
+
+// capture records a string argument so the test can inspect it
+type capture struct {
+	value *string
+}
+
+func (c capture) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	*c.value = s
+	return ok
+}
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("creating mock database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	return db, mock
+}
+
+func newTestAccountEmailService(t *testing.T) (*AccountEmailService, sqlmock.Sqlmock, *mail.MemoryMailer) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	cfg := &config.Config{
+		Server: config.ServerConfig{AppURL: "https://app.sho.rt/"},
+		Email:  config.EmailConfig{VerificationTTLHours: 48, PasswordResetTTLMinutes: 30},
+	}
+	userRepo := database.NewUserRepository(db)
+	sessions := NewSessionService(database.NewSessionRepository(db), userRepo, nil, cfg)
+	mailer := mail.NewMemoryMailer()
+	s := NewAccountEmailService(database.NewEmailTokenRepository(db), userRepo, sessions, mailer, ratelimit.NewMemoryStore(), cfg)
+	return s, mock, mailer
+}
+
+// tokenRows is the row the consume statement returns for a spent token
+func tokenRows(userID int64, purpose models.EmailTokenPurpose, hash string) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows([]string{"id", "user_id", "purpose", "token_hash", "expires_at", "used_at", "created_at"}).
+		AddRow(1, userID, string(purpose), hash, now.Add(time.Hour), now, now.Add(-time.Hour))
+}
+
+func TestSendVerificationEmailsAFreshToken(t *testing.T) {
+	s, mock, mailer := newTestAccountEmailService(t)
+	user := &models.User{ID: 7, Email: "ada@example.com", FullName: "Ada"}
+
+	var storedHash string
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "email_tokens" SET "used_at"=\$1 WHERE user_id = \$2 AND purpose = \$3 AND used_at IS NULL`).
+		WithArgs(aroundNow{}, user.ID, models.EmailTokenVerification).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "email_tokens"`).
+		WithArgs(user.ID, models.EmailTokenVerification, capture{&storedHash}, aroundNow{offset: 48 * time.Hour}, nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	if err := s.SendVerification(context.Background(), user); err != nil {
+		t.Fatalf("SendVerification() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(messages))
+	}
+	link := regexp.MustCompile(`https://app\.sho\.rt/verify-email\?token=[^\s"<]+`).FindString(messages[0].Text)
+	if link == "" {
+		t.Fatalf("email has no verification link:\n%s", messages[0].Text)
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parsing link %q: %v", link, err)
+	}
+	token := parsed.Query().Get("token")
+	if auth.HashEmailToken(token) != storedHash {
+		t.Error("the emailed token doesn't match the stored hash")
+	}
+	if !strings.Contains(messages[0].Text, "48 hours") {
+		t.Error("email doesn't say when the link expires")
+	}
+}
+
+func TestSendVerificationRejectsVerifiedUsers(t *testing.T) {
+	s, mock, mailer := newTestAccountEmailService(t)
+
+	err := s.SendVerification(context.Background(), &models.User{ID: 7, EmailVerified: true})
+	if !errors.Is(err, ErrEmailAlreadyVerified) {
+		t.Fatalf("SendVerification() error = %v, want %v", err, ErrEmailAlreadyVerified)
+	}
+	if len(mailer.Messages()) != 0 {
+		t.Error("an email was sent")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	const token = "emailed-token"
+	hash := auth.HashEmailToken(token)
+
+	tests := []struct {
+		name string
+		// spent is true when the token is unknown, used, expired or for
+		// another purpose, so the consume statement matches nothing
+		spent   bool
+		wantErr error
+	}{
+		{name: "unused token", spent: false},
+		{name: "used or expired token", spent: true, wantErr: database.ErrEmailTokenInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, _ := newTestAccountEmailService(t)
+
+			rows := tokenRows(7, models.EmailTokenVerification, hash)
+			if tt.spent {
+				rows = sqlmock.NewRows([]string{"id"})
+			}
+			mock.ExpectQuery(consumeTokenQuery).
+				WithArgs(aroundNow{}, hash, models.EmailTokenVerification, aroundNow{}).
+				WillReturnRows(rows)
+			if !tt.spent {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "users" SET "email_verified"=\$1,"email_verified_at"=\$2,"updated_at"=\$3 WHERE id = \$4`).
+					WithArgs(true, aroundNow{}, aroundNow{}, int64(7)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			if err := s.VerifyEmail(token); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyEmail() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestVerificationTokenIsSingleUse(t *testing.T) {
+	const token = "emailed-token"
+	hash := auth.HashEmailToken(token)
+	s, mock, _ := newTestAccountEmailService(t)
+
+	// The first use spends the token; the second finds it already used
+	mock.ExpectQuery(consumeTokenQuery).
+		WithArgs(aroundNow{}, hash, models.EmailTokenVerification, aroundNow{}).
+		WillReturnRows(tokenRows(7, models.EmailTokenVerification, hash))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(consumeTokenQuery).
+		WithArgs(aroundNow{}, hash, models.EmailTokenVerification, aroundNow{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if err := s.VerifyEmail(token); err != nil {
+		t.Fatalf("first VerifyEmail() error = %v", err)
+	}
+	if err := s.VerifyEmail(token); !errors.Is(err, database.ErrEmailTokenInvalid) {
+		t.Fatalf("second VerifyEmail() error = %v, want %v", err, database.ErrEmailTokenInvalid)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	const token = "reset-token"
+	hash := auth.HashEmailToken(token)
+
+	tests := []struct {
+		name    string
+		spent   bool
+		wantErr error
+	}{
+		{name: "unused token", spent: false},
+		{name: "used or expired token", spent: true, wantErr: database.ErrEmailTokenInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, _ := newTestAccountEmailService(t)
+
+			rows := tokenRows(7, models.EmailTokenPasswordReset, hash)
+			if tt.spent {
+				rows = sqlmock.NewRows([]string{"id"})
+			}
+			mock.ExpectQuery(consumeTokenQuery).
+				WithArgs(aroundNow{}, hash, models.EmailTokenPasswordReset, aroundNow{}).
+				WillReturnRows(rows)
+			if !tt.spent {
+				// New password, then every session signed out, then the
+				// address marked verified
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "users" SET "password_hash"=\$1`).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "sessions" SET "revoked_at"=\$1,"updated_at"=\$2 WHERE user_id = \$3 AND id <> \$4 AND revoked_at IS NULL`).
+					WithArgs(aroundNow{}, aroundNow{}, int64(7), int64(0)).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "users" SET "email_verified"=\$1`).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			if err := s.ResetPassword(token, "new-password-123"); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResetPassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestFormatTTL(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want string
+	}{
+		{ttl: 48 * time.Hour, want: "48 hours"},
+		{ttl: time.Hour, want: "1 hour"},
+		{ttl: 30 * time.Minute, want: "30 minutes"},
+		{ttl: 90 * time.Minute, want: "90 minutes"},
+		{ttl: time.Minute, want: "1 minute"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ttl.String(), func(t *testing.T) {
+			if got := formatTTL(tt.ttl); got != tt.want {
+				t.Errorf("formatTTL(%s) = %q, want %q", tt.ttl, got, tt.want)
+			}
+		})
+	}
+}
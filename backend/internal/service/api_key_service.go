@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+const maxAPIKeysPerUser = 10
+
+var (
+	// ErrAPIAccessRequired is returned when the user's plan has no API access
+	ErrAPIAccessRequired = errors.New("API access requires Pro or Business subscription")
+	// ErrInvalidAPIKeyRequest is returned for an invalid key name, scope or expiry
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key request")
+	// ErrAPIKeyLimitReached is returned when the user has as many active keys as they may
+	ErrAPIKeyLimitReached = errors.New("API key limit reached")
+)
+
+type APIKeyService struct {
+	apiKeyRepo *database.APIKeyRepository
+	userRepo   *database.UserRepository
+}
+
+func NewAPIKeyService(apiKeyRepo *database.APIKeyRepository, userRepo *database.UserRepository) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+	}
+}
+
+// CreateKey issues a new named API key. The returned plaintext key is not
+// stored and cannot be retrieved again. Without scopes the key is granted
+// every scope. Keys require a verified email address.
+func (s *APIKeyService) CreateKey(userID int64, name string, scopes []string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	ctx := context.Background()
+	logger.Infof(ctx, "Creating API key for user ID: %d", userID)
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if user.GetAPIRateLimit() <= 0 {
+		return nil, "", ErrAPIAccessRequired
+	}
+	if !user.EmailVerified {
+		return nil, "", ErrEmailNotVerified
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidAPIKeyRequest)
+	}
+	if len(name) > 100 {
+		return nil, "", fmt.Errorf("%w: name must be at most 100 characters", ErrInvalidAPIKeyRequest)
+	}
+
+	if len(scopes) == 0 {
+		scopes = models.APIKeyScopes
+	}
+	for _, scope := range scopes {
+		if !models.IsValidAPIKeyScope(scope) {
+			return nil, "", fmt.Errorf("%w: unsupported scope: %s", ErrInvalidAPIKeyRequest, scope)
+		}
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expiry must be in the future", ErrInvalidAPIKeyRequest)
+	}
+
+	count, err := s.apiKeyRepo.CountActiveByUserID(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, "", ErrAPIKeyLimitReached
+	}
+
+	plaintext, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		logger.Errorf(ctx, "Failed to generate API key: %+v", err)
+		return nil, "", fmt.Errorf("error generating API key: %w", err)
+	}
+
+	key := &models.APIKey{
+		UserID:     userID,
+		Name:       name,
+		Prefix:     prefix,
+		SecretHash: hash,
+		Scopes:     scopes,
+		ExpiresAt:  expiresAt,
+	}
+	if err := s.apiKeyRepo.Create(key); err != nil {
+		return nil, "", err
+	}
+
+	return key, plaintext, nil
+}
+
+// ListKeys lists all of a user's API keys, including revoked ones
+func (s *APIKeyService) ListKeys(userID int64) ([]*models.APIKey, error) {
+	return s.apiKeyRepo.GetByUserID(userID)
+}
+
+// RevokeKey permanently disables an API key
+func (s *APIKeyService) RevokeKey(keyID int64, userID int64) error {
+	logger.Infof(context.Background(), "Revoking API key ID: %d for user ID: %d", keyID, userID)
+	return s.apiKeyRepo.Revoke(keyID, userID)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+func newTestAPIKeyService(t *testing.T) (*APIKeyService, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	return NewAPIKeyService(database.NewAPIKeyRepository(db), database.NewUserRepository(db)), mock
+}
+
+// expectUser expects user 7 to be loaded with the given plan
+func expectUser(mock sqlmock.Sqlmock, tier models.SubscriptionTier, emailVerified bool) {
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "subscription_tier", "email_verified"}).
+			AddRow(7, "ada@example.com", tier, emailVerified))
+}
+
+func expectActiveKeyCount(mock sqlmock.Sqlmock, count int) {
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "api_keys" WHERE user_id = \$1 AND revoked_at IS NULL`).
+		WithArgs(7, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+}
+
+func TestCreateKeyRejections(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name          string
+		tier          models.SubscriptionTier
+		emailVerified bool
+		keyName       string
+		scopes        []string
+		expiresAt     *time.Time
+		activeKeys    int // -1 when the keys aren't counted
+		wantErr       error
+	}{
+		{name: "plan without API access", tier: models.TierFree, emailVerified: true, keyName: "CI", activeKeys: -1, wantErr: ErrAPIAccessRequired},
+		{name: "unverified email", tier: models.TierPro, keyName: "CI", activeKeys: -1, wantErr: ErrEmailNotVerified},
+		{name: "blank name", tier: models.TierPro, emailVerified: true, keyName: "  ", activeKeys: -1, wantErr: ErrInvalidAPIKeyRequest},
+		{name: "long name", tier: models.TierPro, emailVerified: true, keyName: strings.Repeat("k", 101), activeKeys: -1, wantErr: ErrInvalidAPIKeyRequest},
+		{name: "unknown scope", tier: models.TierPro, emailVerified: true, keyName: "CI", scopes: []string{"links:delete"}, activeKeys: -1, wantErr: ErrInvalidAPIKeyRequest},
+		{name: "expiry in the past", tier: models.TierPro, emailVerified: true, keyName: "CI", expiresAt: &past, activeKeys: -1, wantErr: ErrInvalidAPIKeyRequest},
+		{name: "too many keys", tier: models.TierBusiness, emailVerified: true, keyName: "CI", activeKeys: maxAPIKeysPerUser, wantErr: ErrAPIKeyLimitReached},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestAPIKeyService(t)
+			expectUser(mock, tt.tier, tt.emailVerified)
+			if tt.activeKeys >= 0 {
+				expectActiveKeyCount(mock, tt.activeKeys)
+			}
+
+			if _, _, err := s.CreateKey(7, tt.keyName, tt.scopes, tt.expiresAt); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateKey() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCreateKeyStoresOnlyTheHash(t *testing.T) {
+	s, mock := newTestAPIKeyService(t)
+	expectUser(mock, models.TierPro, true)
+	expectActiveKeyCount(mock, 2)
+
+	var prefix, hash string
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "api_keys"`).
+		WithArgs(7, "CI", capture{&prefix}, capture{&hash}, "{links:read,links:write,analytics:read}",
+			nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectCommit()
+
+	key, plaintext, err := s.CreateKey(7, " CI ", nil, nil)
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without scopes the key is granted all of them
+	if len(key.Scopes) != len(models.APIKeyScopes) {
+		t.Errorf("scopes = %v, want %v", key.Scopes, models.APIKeyScopes)
+	}
+	if !strings.HasPrefix(plaintext, prefix) || strings.Contains(hash, plaintext) || !auth.CheckAPIKey(plaintext, hash) {
+		t.Errorf("stored prefix %q and hash %q do not match key %q", prefix, hash, plaintext)
+	}
+}
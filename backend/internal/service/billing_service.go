@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/billing"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// maxInvoicesListed bounds the billing history returned at once
+const maxInvoicesListed = 100
+
+var (
+	// ErrBillingDisabled is returned when Stripe is not configured
+	ErrBillingDisabled = errors.New("billing is not configured")
+	// ErrInvalidPlan is returned when checking out a tier that isn't for sale
+	ErrInvalidPlan = errors.New("plan is not available")
+	// ErrAlreadySubscribed is returned when checking out with an active subscription
+	ErrAlreadySubscribed = errors.New("already subscribed")
+	// ErrNoBillingAccount is returned when a user has never checked out
+	ErrNoBillingAccount = errors.New("no billing account")
+	// ErrPersonalOrganizationCovered is returned when changing whether a
+	// personal organization is covered; the subscription always covers it
+	ErrPersonalOrganizationCovered = errors.New("personal organizations are always covered")
+)
+
+// BillingStatus describes a user's plan and Stripe subscription
+type BillingStatus struct {
+	Enabled      bool                    `json:"enabled"`
+	Tier         models.SubscriptionTier `json:"tier"`
+	Subscription *models.Subscription    `json:"subscription"`
+}
+
+// BillingService sells the paid tiers through Stripe Checkout and applies
+// Stripe's webhooks to users' tiers
+type BillingService struct {
+	billingRepo *database.BillingRepository
+	userRepo    *database.UserRepository
+	orgRepo     *database.OrganizationRepository
+	authorizer  *authz.Authorizer
+	client      billing.Client
+	eventBus    *events.Bus
+	config      *config.Config
+}
+
+// NewBillingService creates the billing service. client is nil when billing
+// is disabled.
+func NewBillingService(billingRepo *database.BillingRepository, userRepo *database.UserRepository, orgRepo *database.OrganizationRepository, authorizer *authz.Authorizer, client billing.Client, eventBus *events.Bus, cfg *config.Config) *BillingService {
+	return &BillingService{
+		billingRepo: billingRepo,
+		userRepo:    userRepo,
+		orgRepo:     orgRepo,
+		authorizer:  authorizer,
+		client:      client,
+		eventBus:    eventBus,
+		config:      cfg,
+	}
+}
+
+// GetStatus returns the user's tier and subscription, if any
+func (s *BillingService) GetStatus(userID int64) (*BillingStatus, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &BillingStatus{Enabled: s.client != nil, Tier: user.SubscriptionTier}
+	sub, err := s.billingRepo.GetSubscriptionByUserID(userID)
+	if err == nil {
+		status.Subscription = sub
+	} else if !errors.Is(err, database.ErrSubscriptionNotFound) {
+		return nil, err
+	}
+	return status, nil
+}
+
+// ListInvoices returns the user's billing history, newest first
+func (s *BillingService) ListInvoices(userID int64) ([]*models.Invoice, error) {
+	return s.billingRepo.ListInvoices(userID, maxInvoicesListed)
+}
+
+// StartCheckout opens a Stripe Checkout session for a paid tier. Users who
+// already pay change plan through the billing portal instead.
+func (s *BillingService) StartCheckout(ctx context.Context, userID int64, tier models.SubscriptionTier) (*billing.Session, error) {
+	if s.client == nil {
+		return nil, ErrBillingDisabled
+	}
+	priceID := s.priceFor(tier)
+	if priceID == "" {
+		return nil, ErrInvalidPlan
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := s.billingRepo.GetSubscriptionByUserID(userID)
+	switch {
+	case err == nil:
+		if sub.IsActive() {
+			return nil, ErrAlreadySubscribed
+		}
+	case errors.Is(err, database.ErrSubscriptionNotFound):
+		customerID, err := s.client.CreateCustomer(ctx, billing.CustomerParams{
+			UserID: user.ID,
+			Email:  user.Email,
+			Name:   user.FullName,
+		})
+		if err != nil {
+			return nil, err
+		}
+		sub = &models.Subscription{UserID: user.ID, StripeCustomerID: customerID, Tier: models.TierFree}
+		if err := s.billingRepo.CreateCustomer(sub); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	logger.Infof(ctx, "Starting %s checkout for user ID: %d", tier, userID)
+	return s.client.CreateCheckoutSession(ctx, billing.CheckoutParams{
+		UserID:     user.ID,
+		CustomerID: sub.StripeCustomerID,
+		PriceID:    priceID,
+		SuccessURL: s.billingPageURL() + "?checkout=success",
+		CancelURL:  s.billingPageURL() + "?checkout=canceled",
+	})
+}
+
+// OpenPortal opens the Stripe billing portal, where customers change plan,
+// update their card or cancel
+func (s *BillingService) OpenPortal(ctx context.Context, userID int64) (*billing.Session, error) {
+	if s.client == nil {
+		return nil, ErrBillingDisabled
+	}
+
+	sub, err := s.billingRepo.GetSubscriptionByUserID(userID)
+	if err != nil {
+		if errors.Is(err, database.ErrSubscriptionNotFound) {
+			return nil, ErrNoBillingAccount
+		}
+		return nil, err
+	}
+	return s.client.CreatePortalSession(ctx, sub.StripeCustomerID, s.billingPageURL())
+}
+
+// SetOrganizationCoverage has the user's subscription start or stop
+// covering one of their team organizations. Only the organization's owner
+// can, since it is their subscription that pays. The link limit service
+// applies the new tier's limits when the tier changes.
+func (s *BillingService) SetOrganizationCoverage(ctx context.Context, orgID, userID int64, covered bool) (*models.Organization, error) {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageBilling); err != nil {
+		return nil, err
+	}
+
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if org.OwnerID != userID {
+		return nil, authz.ErrForbidden
+	}
+	if org.IsPersonal {
+		return nil, ErrPersonalOrganizationCovered
+	}
+
+	change, err := s.billingRepo.SetOrganizationCoverage(org, covered)
+	if err != nil {
+		return nil, err
+	}
+	if change == nil {
+		return org, nil
+	}
+
+	logger.Infof(ctx, "Organization %d moved from %s to %s", change.OrganizationID, change.From, change.To)
+	s.eventBus.Publish(ctx, events.Event{
+		Type:           events.OrganizationTierChanged,
+		UserID:         change.OwnerID,
+		OrganizationID: change.OrganizationID,
+		Data: map[string]interface{}{
+			"from": change.From,
+			"to":   change.To,
+		},
+	})
+	return org, nil
+}
+
+// HandleWebhook verifies and applies a Stripe webhook. Subscription events
+// set the customer's tier; invoice events update billing history. Events
+// are applied at most once, and a late event never overrides a newer one.
+func (s *BillingService) HandleWebhook(ctx context.Context, payload []byte, signature string) error {
+	if s.config.Stripe.WebhookSecret == "" {
+		return ErrBillingDisabled
+	}
+
+	event, err := billing.ConstructEvent(payload, signature, s.config.Stripe.WebhookSecret, time.Now())
+	if err != nil {
+		return err
+	}
+	logger.Infof(ctx, "Received Stripe event %s (%s)", event.ID, event.Type)
+
+	switch {
+	case strings.HasPrefix(event.Type, "customer.subscription."):
+		err = s.applySubscriptionEvent(ctx, event)
+	case strings.HasPrefix(event.Type, "invoice."):
+		err = s.applyInvoiceEvent(event)
+	default:
+		return nil
+	}
+
+	// Customers created outside this app have no user to update. Report
+	// success so Stripe doesn't keep retrying.
+	if errors.Is(err, database.ErrSubscriptionNotFound) {
+		logger.Warnf(ctx, "Ignoring Stripe event %s for an unknown customer", event.ID)
+		return nil
+	}
+	return err
+}
+
+func (s *BillingService) applySubscriptionEvent(ctx context.Context, event *billing.Event) error {
+	stripeSub, err := event.Subscription()
+	if err != nil {
+		return err
+	}
+
+	change, err := s.billingRepo.ApplySubscriptionUpdate(&database.SubscriptionUpdate{
+		EventID:           event.ID,
+		EventType:         event.Type,
+		OccurredAt:        event.CreatedAt(),
+		CustomerID:        stripeSub.Customer,
+		SubscriptionID:    stripeSub.ID,
+		PriceID:           stripeSub.PriceID(),
+		Status:            stripeSub.Status,
+		Tier:              s.tierFor(ctx, stripeSub),
+		CurrentPeriodEnd:  stripeSub.PeriodEnd(),
+		CancelAtPeriodEnd: stripeSub.CancelAtPeriodEnd,
+	})
+	if err != nil || change == nil {
+		return err
+	}
+
+	logger.Infof(ctx, "User ID %d moved from %s to %s", change.UserID, change.From, change.To)
+	s.eventBus.Publish(ctx, events.Event{
+		Type:   events.SubscriptionChanged,
+		UserID: change.UserID,
+		Data: map[string]interface{}{
+			"from": change.From,
+			"to":   change.To,
+		},
+	})
+	return nil
+}
+
+func (s *BillingService) applyInvoiceEvent(event *billing.Event) error {
+	stripeInvoice, err := event.Invoice()
+	if err != nil {
+		return err
+	}
+
+	return s.billingRepo.RecordInvoice(event.ID, event.Type, stripeInvoice.Customer, &models.Invoice{
+		StripeInvoiceID:  stripeInvoice.ID,
+		Number:           stripeInvoice.Number,
+		Description:      stripeInvoice.Description(),
+		Status:           stripeInvoice.Status,
+		AmountDue:        stripeInvoice.AmountDue,
+		AmountPaid:       stripeInvoice.AmountPaid,
+		Currency:         stripeInvoice.Currency,
+		HostedInvoiceURL: stripeInvoice.HostedInvoiceURL,
+		PeriodStart:      unixTime(stripeInvoice.PeriodStart),
+		PeriodEnd:        unixTime(stripeInvoice.PeriodEnd),
+		IssuedAt:         time.Unix(stripeInvoice.Created, 0),
+		LastEventAt:      event.CreatedAt(),
+	})
+}
+
+// tierFor returns the tier a subscription grants: the tier of its price
+// while the subscription is active, and free otherwise
+func (s *BillingService) tierFor(ctx context.Context, sub *billing.Subscription) models.SubscriptionTier {
+	priceID := sub.PriceID()
+	if !models.IsActiveSubscriptionStatus(sub.Status) || priceID == "" {
+		return models.TierFree
+	}
+	switch priceID {
+	case s.config.Stripe.ProPriceID:
+		return models.TierPro
+	case s.config.Stripe.BusinessPriceID:
+		return models.TierBusiness
+	default:
+		logger.Warnf(ctx, "Subscription %s has unknown price %s", sub.ID, priceID)
+		return models.TierFree
+	}
+}
+
+// priceFor returns the Stripe price sold for a tier, or "" if none is
+func (s *BillingService) priceFor(tier models.SubscriptionTier) string {
+	switch tier {
+	case models.TierPro:
+		return s.config.Stripe.ProPriceID
+	case models.TierBusiness:
+		return s.config.Stripe.BusinessPriceID
+	default:
+		return ""
+	}
+}
+
+func (s *BillingService) billingPageURL() string {
+	return strings.TrimRight(s.config.Server.AppURL, "/") + "/account/billing"
+}
+
+func unixTime(seconds int64) *time.Time {
+	if seconds == 0 {
+		return nil
+	}
+	t := time.Unix(seconds, 0)
+	return &t
+}
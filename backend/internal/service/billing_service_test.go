@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/billing"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+const testStripeSecret = "whsec_test"
+
+func newTestBillingService(t *testing.T) (*BillingService, sqlmock.Sqlmock, *[]events.Event) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	cfg := &config.Config{Stripe: config.StripeConfig{
+		SecretKey:       "sk_test",
+		WebhookSecret:   testStripeSecret,
+		ProPriceID:      "price_pro",
+		BusinessPriceID: "price_business",
+	}}
+
+	orgRepo := database.NewOrganizationRepository(db)
+
+	var published []events.Event
+	bus := events.NewBus()
+	bus.Subscribe(func(_ context.Context, event events.Event) {
+		published = append(published, event)
+	})
+	s := NewBillingService(database.NewBillingRepository(db), database.NewUserRepository(db), orgRepo, authz.NewAuthorizer(orgRepo), billing.NewFakeClient(), bus, cfg)
+	return s, mock, &published
+}
+
+// subscriptionEvent builds a signed customer.subscription.updated webhook
+func subscriptionEvent(eventID string, created time.Time, status, priceID string) ([]byte, string) {
+	payload := []byte(fmt.Sprintf(`{"id":%q,"type":"customer.subscription.updated","created":%d,`+
+		`"data":{"object":{"id":"sub_1","customer":"cus_1","status":%q,"current_period_end":%d,`+
+		`"items":{"data":[{"price":{"id":%q}}]}}}}`,
+		eventID, created.Unix(), status, created.Add(30*24*time.Hour).Unix(), priceID))
+	return payload, billing.SignPayload(payload, testStripeSecret, time.Now().Unix())
+}
+
+// expectEventRecorded expects the insert that marks an event processed;
+// a redelivered event inserts nothing
+func expectEventRecorded(mock sqlmock.Sqlmock, eventID string, firstDelivery bool) {
+	var affected int64
+	if firstDelivery {
+		affected = 1
+	}
+	mock.ExpectExec(`INSERT INTO "billing_events" .* ON CONFLICT DO NOTHING`).
+		WithArgs(eventID, "customer.subscription.updated", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, affected))
+}
+
+// expectSubscriptionLocked expects the subscription to be read for update
+func expectSubscriptionLocked(mock sqlmock.Sqlmock, lastEventAt *time.Time) {
+	mock.ExpectQuery(`SELECT \* FROM "subscriptions" WHERE stripe_customer_id = \$1 .* FOR UPDATE`).
+		WithArgs("cus_1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "stripe_customer_id", "tier", "status", "last_event_at"}).
+			AddRow(3, 7, "cus_1", "free", "", lastEventAt))
+}
+
+func TestHandleWebhookAppliesSubscriptionEventsOnce(t *testing.T) {
+	created := time.Now().Add(-time.Minute)
+	payload, signature := subscriptionEvent("evt_1", created, "active", "price_pro")
+
+	tests := []struct {
+		name          string
+		firstDelivery bool
+		wantPublished int
+	}{
+		{name: "first delivery", firstDelivery: true, wantPublished: 1},
+		{name: "redelivered event", firstDelivery: false, wantPublished: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, published := newTestBillingService(t)
+
+			mock.ExpectBegin()
+			expectEventRecorded(mock, "evt_1", tt.firstDelivery)
+			if tt.firstDelivery {
+				expectSubscriptionLocked(mock, nil)
+				mock.ExpectExec(`UPDATE "subscriptions" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`SELECT "id","subscription_tier" FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "subscription_tier"}).AddRow(7, "free"))
+				mock.ExpectExec(`UPDATE "users" SET "subscription_tier"=\$1`).
+					WithArgs(models.TierPro, sqlmock.AnyArg(), int64(7)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`SELECT "id" FROM "organizations" WHERE \(owner_id = \$1 AND is_personal = \$2 AND covered_by_owner = \$3\)`).
+					WithArgs(int64(7), false, true).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectExec(`UPDATE "organizations" SET "subscription_tier"=\$1`).
+					WithArgs(models.TierPro, sqlmock.AnyArg(), int64(7), true, true).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			mock.ExpectCommit()
+
+			if err := s.HandleWebhook(context.Background(), payload, signature); err != nil {
+				t.Fatalf("HandleWebhook() error = %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+			if len(*published) != tt.wantPublished {
+				t.Errorf("published %d events, want %d", len(*published), tt.wantPublished)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookIgnoresOutdatedEvents(t *testing.T) {
+	s, mock, published := newTestBillingService(t)
+	created := time.Now().Add(-time.Hour)
+	lastApplied := time.Now().Add(-time.Minute)
+	payload, signature := subscriptionEvent("evt_old", created, "canceled", "price_pro")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "billing_events"`).
+		WithArgs("evt_old", "customer.subscription.updated", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSubscriptionLocked(mock, &lastApplied)
+	mock.ExpectCommit()
+
+	if err := s.HandleWebhook(context.Background(), payload, signature); err != nil {
+		t.Fatalf("HandleWebhook() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*published) != 0 {
+		t.Errorf("published %d events for an outdated event", len(*published))
+	}
+}
+
+func TestHandleWebhookRejectsBadSignatures(t *testing.T) {
+	payload, _ := subscriptionEvent("evt_1", time.Now(), "active", "price_pro")
+	stale := time.Now().Add(-10 * time.Minute).Unix()
+
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{name: "wrong secret", signature: billing.SignPayload(payload, "whsec_other", time.Now().Unix())},
+		{name: "replayed", signature: billing.SignPayload(payload, testStripeSecret, stale)},
+		{name: "missing", signature: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, _ := newTestBillingService(t)
+
+			err := s.HandleWebhook(context.Background(), payload, tt.signature)
+			if !errors.Is(err, billing.ErrInvalidSignature) {
+				t.Fatalf("HandleWebhook() error = %v, want %v", err, billing.ErrInvalidSignature)
+			}
+			// Nothing may be recorded for an unverified event
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSetOrganizationCoverage(t *testing.T) {
+	tests := []struct {
+		name          string
+		ownerTier     models.SubscriptionTier
+		alreadyCover  int
+		wantErr       error
+		wantPublished int
+	}{
+		{name: "pro covers its first team", ownerTier: models.TierPro, wantPublished: 1},
+		{name: "pro covers a second team", ownerTier: models.TierPro, alreadyCover: 1, wantErr: database.ErrCoverageLimitReached},
+		{name: "free covers no teams", ownerTier: models.TierFree, wantErr: database.ErrCoverageLimitReached},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, published := newTestBillingService(t)
+			expectMember(mock, 4, 7, models.RoleOwner)
+			mock.ExpectQuery(`SELECT \* FROM "organizations" WHERE "organizations"."id" = \$1`).
+				WithArgs(4, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "subscription_tier"}).AddRow(4, 7, models.TierFree))
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT "id","subscription_tier" FROM "users" .* FOR UPDATE`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "subscription_tier"}).AddRow(7, tt.ownerTier))
+			mock.ExpectQuery(`SELECT \* FROM "organizations" .* FOR UPDATE`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "subscription_tier", "covered_by_owner"}).AddRow(4, 7, models.TierFree, false))
+			mock.ExpectQuery(`SELECT count\(\*\) FROM "organizations" WHERE \(owner_id = \$1 AND is_personal = \$2 AND covered_by_owner = \$3\)`).
+				WithArgs(7, false, true).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.alreadyCover))
+			if tt.wantErr == nil {
+				mock.ExpectExec(`UPDATE "organizations" SET "covered_by_owner"=\$1,"subscription_tier"=\$2`).
+					WithArgs(true, tt.ownerTier, sqlmock.AnyArg(), 4).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			org, err := s.SetOrganizationCoverage(context.Background(), 4, 7, true)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetOrganizationCoverage() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr == nil && (org.SubscriptionTier != tt.ownerTier || !org.CoveredByOwner) {
+				t.Errorf("organization tier = %s, covered = %v, want %s, covered", org.SubscriptionTier, org.CoveredByOwner, tt.ownerTier)
+			}
+			if len(*published) != tt.wantPublished {
+				t.Errorf("published %d events, want %d", len(*published), tt.wantPublished)
+			}
+		})
+	}
+}
+
+func TestSetOrganizationCoverageIsForTheOwner(t *testing.T) {
+	s, mock, _ := newTestBillingService(t)
+	// A co-owner may manage billing but the plan that would pay is the owner's
+	expectMember(mock, 4, 8, models.RoleOwner)
+	mock.ExpectQuery(`SELECT \* FROM "organizations" WHERE "organizations"."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(4, 7))
+
+	if _, err := s.SetOrganizationCoverage(context.Background(), 4, 8, true); !errors.Is(err, authz.ErrForbidden) {
+		t.Fatalf("SetOrganizationCoverage() error = %v, want %v", err, authz.ErrForbidden)
+	}
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/cache"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+const dnsLookupTimeout = 5 * time.Second
+
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+var (
+	// ErrInvalidHostname is returned for a hostname that can't be registered
+	ErrInvalidHostname = errors.New("invalid hostname")
+	// ErrDomainTaken is returned when the hostname is already registered
+	ErrDomainTaken = errors.New("domain already registered")
+	// ErrDomainInUse is returned when deleting a domain that still has links
+	ErrDomainInUse = errors.New("domain still has links")
+)
+
+// TXTResolver looks up DNS TXT records. *net.Resolver satisfies this
+// interface; tests can substitute a stub.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DomainService manages an organization's custom domains. Members may see
+// them and use them for links; admins add, verify and delete them.
+type DomainService struct {
+	domainRepo *database.DomainRepository
+	authorizer *authz.Authorizer
+	resolver   TXTResolver
+	linkCache  *cache.LinkCache
+	baseHost   string
+}
+
+func NewDomainService(domainRepo *database.DomainRepository, authorizer *authz.Authorizer, resolver TXTResolver, linkCache *cache.LinkCache, cfg *config.Config) *DomainService {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &DomainService{
+		domainRepo: domainRepo,
+		authorizer: authorizer,
+		resolver:   resolver,
+		linkCache:  linkCache,
+		baseHost:   hostFromURL(cfg.Server.BaseURL),
+	}
+}
+
+// hostFromURL extracts the lowercase hostname (without port) from a URL
+func hostFromURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// NormalizeHost lowercases a Host header value and strips any port
+func NormalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// AddDomain registers a custom domain for the organization, pending DNS
+// verification
+func (s *DomainService) AddDomain(orgID, userID int64, hostname string) (*models.Domain, error) {
+	ctx := context.Background()
+	hostname = NormalizeHost(hostname)
+	logger.Infof(ctx, "Adding domain %s to organization ID %d by user ID: %d", hostname, orgID, userID)
+
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageDomains); err != nil {
+		return nil, err
+	}
+
+	if !hostnamePattern.MatchString(hostname) {
+		return nil, ErrInvalidHostname
+	}
+	if hostname == s.baseHost {
+		return nil, fmt.Errorf("%w: %s is reserved", ErrInvalidHostname, hostname)
+	}
+
+	exists, err := s.domainRepo.HostnameExists(hostname)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrDomainTaken
+	}
+
+	token, err := generateVerificationToken()
+	if err != nil {
+		logger.Errorf(ctx, "Failed to generate verification token: %+v", err)
+		return nil, fmt.Errorf("error generating verification token: %w", err)
+	}
+
+	domain := &models.Domain{
+		UserID:            userID,
+		OrganizationID:    orgID,
+		Hostname:          hostname,
+		VerificationToken: token,
+		Status:            models.DomainPending,
+	}
+	if err := s.domainRepo.Create(domain); err != nil {
+		return nil, err
+	}
+
+	return domain, nil
+}
+
+// GetDomain retrieves one of the organization's domains
+func (s *DomainService) GetDomain(orgID, domainID, userID int64) (*models.Domain, error) {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionViewLinks); err != nil {
+		return nil, err
+	}
+	return s.getDomain(orgID, domainID)
+}
+
+// ListDomains lists the organization's domains
+func (s *DomainService) ListDomains(orgID, userID int64) ([]*models.Domain, error) {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionViewLinks); err != nil {
+		return nil, err
+	}
+	return s.domainRepo.GetByOrganizationID(orgID)
+}
+
+// VerifyDomain checks the domain's TXT record and updates its status
+func (s *DomainService) VerifyDomain(orgID, domainID, userID int64) (*models.Domain, error) {
+	ctx := context.Background()
+
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageDomains); err != nil {
+		return nil, err
+	}
+	domain, err := s.getDomain(orgID, domainID)
+	if err != nil {
+		return nil, err
+	}
+
+	verified := s.hasVerificationRecord(ctx, domain)
+	now := time.Now().UTC()
+	domain.LastCheckedAt = &now
+
+	if verified {
+		domain.Status = models.DomainVerified
+		if domain.VerifiedAt == nil {
+			domain.VerifiedAt = &now
+		}
+		logger.Infof(ctx, "Domain %s verified", domain.Hostname)
+	} else if !domain.IsVerified() {
+		domain.Status = models.DomainFailed
+		logger.Infof(ctx, "Domain %s verification record not found", domain.Hostname)
+	}
+
+	if err := s.domainRepo.UpdateVerification(domain); err != nil {
+		return nil, fmt.Errorf("error updating domain: %w", err)
+	}
+	s.linkCache.InvalidateDomain(ctx, domain.Hostname)
+
+	return domain, nil
+}
+
+// hasVerificationRecord reports whether the domain's TXT record is
+// published. A failed lookup counts as not published.
+func (s *DomainService) hasVerificationRecord(ctx context.Context, domain *models.Domain) bool {
+	lookupCtx, cancel := context.WithTimeout(ctx, dnsLookupTimeout)
+	defer cancel()
+
+	records, err := s.resolver.LookupTXT(lookupCtx, domain.VerificationRecordName())
+	if err != nil {
+		logger.Warnf(ctx, "TXT lookup failed for %s: %+v", domain.VerificationRecordName(), err)
+		return false
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == domain.VerificationRecordValue() {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteDomain deletes a domain that has no links
+func (s *DomainService) DeleteDomain(orgID, domainID, userID int64) error {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageDomains); err != nil {
+		return err
+	}
+	domain, err := s.getDomain(orgID, domainID)
+	if err != nil {
+		return err
+	}
+
+	count, err := s.domainRepo.CountLinks(domainID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d links use it", ErrDomainInUse, count)
+	}
+
+	if err := s.domainRepo.Delete(domainID, orgID); err != nil {
+		return err
+	}
+	s.linkCache.InvalidateDomain(context.Background(), domain.Hostname)
+	return nil
+}
+
+// ResolveHost maps a Host header to the namespace it serves. It returns a nil
+// domain for the default short domain and for unknown hosts, and false when the
+// host is a registered custom domain that has not been verified yet. An error
+// means the domain couldn't be looked up.
+func (s *DomainService) ResolveHost(host string) (*models.Domain, bool, error) {
+	hostname := NormalizeHost(host)
+	if hostname == "" || hostname == s.baseHost {
+		return nil, true, nil
+	}
+
+	domain, err := s.linkCache.GetDomain(context.Background(), hostname, func() (*models.Domain, error) {
+		domain, err := s.domainRepo.GetByHostname(hostname)
+		if errors.Is(err, database.ErrDomainNotFound) {
+			return nil, cache.ErrNotFound
+		}
+		return domain, err
+	})
+	if errors.Is(err, cache.ErrNotFound) {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error resolving host %s: %w", hostname, err)
+	}
+	if !domain.IsVerified() {
+		return nil, false, nil
+	}
+	return domain, true, nil
+}
+
+// getDomain loads a domain, reporting domains of other organizations as
+// missing
+func (s *DomainService) getDomain(orgID, domainID int64) (*models.Domain, error) {
+	domain, err := s.domainRepo.GetByID(domainID)
+	if err != nil {
+		return nil, err
+	}
+	if domain.OrganizationID != orgID {
+		logger.Warnf(context.Background(), "Domain ID %d requested from organization ID %d", domainID, orgID)
+		return nil, database.ErrDomainNotFound
+	}
+	return domain, nil
+}
+
+// generateVerificationToken creates a random DNS verification token
+func generateVerificationToken() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
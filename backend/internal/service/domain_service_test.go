@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/cache"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// stubResolver answers TXT lookups from a fixed set of records
+type stubResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (r *stubResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.records[name], nil
+}
+
+// unreachableDB returns a database whose every query fails, as when
+// Postgres is down
+func unreachableDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		DSN: "host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1",
+	}), &gorm.Config{DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	return db
+}
+
+func newTestDomainService(t *testing.T, resolver TXTResolver) (*DomainService, *cache.LinkCache) {
+	t.Helper()
+	linkCache := cache.NewLinkCache(cache.NewLRUStore(100), time.Minute, time.Minute)
+	cfg := &config.Config{Server: config.ServerConfig{BaseURL: "https://sho.rt"}}
+	db := unreachableDB(t)
+	authorizer := authz.NewAuthorizer(database.NewOrganizationRepository(db))
+	return NewDomainService(database.NewDomainRepository(db), authorizer, resolver, linkCache, cfg), linkCache
+}
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "go.example.com", want: "go.example.com"},
+		{host: "Go.Example.COM", want: "go.example.com"},
+		{host: "go.example.com:8080", want: "go.example.com"},
+		{host: "go.example.com.", want: "go.example.com"},
+		{host: " go.example.com ", want: "go.example.com"},
+		{host: "[::1]:8080", want: "::1"},
+		{host: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := NormalizeHost(tt.host); got != tt.want {
+				t.Errorf("NormalizeHost(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveHost(t *testing.T) {
+	verified := &models.Domain{ID: 1, Hostname: "go.example.com", Status: models.DomainVerified}
+	pending := &models.Domain{ID: 2, Hostname: "links.example.org", Status: models.DomainPending}
+
+	tests := []struct {
+		name    string
+		host    string
+		wantID  int64
+		wantOK  bool
+		wantErr bool
+	}{
+		{name: "base host", host: "sho.rt", wantOK: true},
+		{name: "base host with port", host: "SHO.RT:443", wantOK: true},
+		{name: "empty host", host: "", wantOK: true},
+		{name: "verified domain", host: "go.example.com", wantID: 1, wantOK: true},
+		{name: "verified domain with port", host: "Go.Example.com:8080", wantID: 1, wantOK: true},
+		{name: "unverified domain", host: "links.example.org", wantOK: false},
+		{name: "unknown host", host: "unknown.example.net", wantOK: true},
+		{name: "lookup failure", host: "down.example.net", wantErr: true},
+	}
+
+	s, linkCache := newTestDomainService(t, nil)
+	ctx := context.Background()
+	for _, domain := range []*models.Domain{verified, pending} {
+		linkCache.GetDomain(ctx, domain.Hostname, func() (*models.Domain, error) { return domain, nil })
+	}
+	linkCache.GetDomain(ctx, "unknown.example.net", func() (*models.Domain, error) { return nil, cache.ErrNotFound })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domain, ok, err := s.ResolveHost(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveHost(%q) error = %v, wantErr %v", tt.host, err, tt.wantErr)
+			}
+			if ok != tt.wantOK {
+				t.Errorf("ResolveHost(%q) ok = %v, want %v", tt.host, ok, tt.wantOK)
+			}
+			var gotID int64
+			if domain != nil {
+				gotID = domain.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("ResolveHost(%q) domain ID = %d, want %d", tt.host, gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestHasVerificationRecord(t *testing.T) {
+	domain := &models.Domain{Hostname: "go.example.com", VerificationToken: "abc123"}
+	name := domain.VerificationRecordName()
+
+	tests := []struct {
+		name     string
+		resolver *stubResolver
+		want     bool
+	}{
+		{name: "record published", resolver: &stubResolver{records: map[string][]string{name: {"urlshortener-verify=abc123"}}}, want: true},
+		{name: "among other records", resolver: &stubResolver{records: map[string][]string{name: {"v=spf1 -all", " urlshortener-verify=abc123 "}}}, want: true},
+		{name: "wrong token", resolver: &stubResolver{records: map[string][]string{name: {"urlshortener-verify=other"}}}, want: false},
+		{name: "record on the domain itself", resolver: &stubResolver{records: map[string][]string{"go.example.com": {"urlshortener-verify=abc123"}}}, want: false},
+		{name: "no records", resolver: &stubResolver{}, want: false},
+		{name: "lookup failure", resolver: &stubResolver{err: errors.New("no such host")}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestDomainService(t, tt.resolver)
+			if got := s.hasVerificationRecord(context.Background(), domain); got != tt.want {
+				t.Errorf("hasVerificationRecord() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
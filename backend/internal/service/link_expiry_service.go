@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+)
+
+// linkExpiryBatchSize bounds the links marked expired per query
+const linkExpiryBatchSize = 500
+
+// LinkExpiryService publishes a link.expired event once for every link
+// whose expiry passes. Redirects already refuse expired links; this only
+// tells webhooks and other subscribers about it. Instances take turns
+// through a database lock, so each link is announced by one of them.
+type LinkExpiryService struct {
+	linkRepo *database.LinkRepository
+	eventBus *events.Bus
+	interval time.Duration
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewLinkExpiryService(linkRepo *database.LinkRepository, eventBus *events.Bus, cfg *config.Config) *LinkExpiryService {
+	interval := time.Duration(cfg.Expiry.ScanIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	return &LinkExpiryService{
+		linkRepo: linkRepo,
+		eventBus: eventBus,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start launches the worker that scans for expired links
+func (s *LinkExpiryService) Start() {
+	logger.Infof(context.Background(), "Starting link expiry scans every %s", s.interval)
+	go s.run()
+}
+
+// Stop stops the worker once the current scan has finished
+func (s *LinkExpiryService) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+
+	select {
+	case <-s.done:
+		logger.Infof(ctx, "Link expiry scans stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("link expiry scans did not stop in time: %w", ctx.Err())
+	}
+}
+
+func (s *LinkExpiryService) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.scan()
+	for {
+		select {
+		case <-ticker.C:
+			s.scan()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// scan marks links that have expired since the last scan and publishes an
+// event for each, a batch at a time
+func (s *LinkExpiryService) scan() {
+	ctx := context.Background()
+	now := time.Now()
+
+	for {
+		links, locked, err := s.linkRepo.MarkExpired(now, linkExpiryBatchSize)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to mark expired links: %+v", err)
+			return
+		}
+		if !locked {
+			logger.Debugf(ctx, "Link expiry scan running on another instance, skipping")
+			return
+		}
+
+		for _, link := range links {
+			logger.Infof(ctx, "Link expired: short code %s, link ID: %d", link.ShortCode, link.ID)
+			s.eventBus.Publish(ctx, events.Event{
+				Type:           events.LinkExpired,
+				UserID:         link.UserID,
+				OrganizationID: link.OrganizationID,
+				Data:           link,
+			})
+		}
+
+		if len(links) < linkExpiryBatchSize {
+			return
+		}
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+func newTestLinkExpiryService(t *testing.T) (*LinkExpiryService, sqlmock.Sqlmock, *[]events.Event) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	bus := events.NewBus()
+	published := []events.Event{}
+	bus.Subscribe(func(_ context.Context, event events.Event) { published = append(published, event) })
+	return NewLinkExpiryService(database.NewLinkRepository(db), bus, &config.Config{}), mock, &published
+}
+
+// expectExpiryBatch expects one locked pass of the expiry scan returning
+// the given number of links, or none when another instance holds the lock
+func expectExpiryBatch(mock sqlmock.Sqlmock, locked bool, expired int) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(hashtext\(\$1\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(locked))
+	if locked {
+		rows := sqlmock.NewRows([]string{"id", "user_id", "organization_id", "short_code"})
+		for i := 1; i <= expired; i++ {
+			rows.AddRow(i, 7, 3, "code")
+		}
+		mock.ExpectQuery(`UPDATE links SET expired_at = \$1\s+WHERE id IN \(\s+SELECT id FROM links\s+WHERE expires_at < \$2 AND expired_at IS NULL AND deleted_at IS NULL`).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), linkExpiryBatchSize).
+			WillReturnRows(rows)
+	}
+	mock.ExpectCommit()
+}
+
+func TestLinkExpiryScan(t *testing.T) {
+	tests := []struct {
+		name       string
+		locked     bool
+		batches    []int // links expired by each pass
+		wantEvents int
+	}{
+		{name: "another instance scanning", batches: []int{0}},
+		{name: "nothing expired", locked: true, batches: []int{0}},
+		{name: "some links expired", locked: true, batches: []int{2}, wantEvents: 2},
+		{name: "more than a batch expired", locked: true, batches: []int{linkExpiryBatchSize, 1}, wantEvents: linkExpiryBatchSize + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, published := newTestLinkExpiryService(t)
+			for _, expired := range tt.batches {
+				expectExpiryBatch(mock, tt.locked, expired)
+			}
+
+			s.scan()
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(*published) != tt.wantEvents {
+				t.Fatalf("published %d events, want %d", len(*published), tt.wantEvents)
+			}
+			for _, event := range *published {
+				link, ok := event.Data.(*models.Link)
+				if event.Type != events.LinkExpired || event.UserID != 7 || event.OrganizationID != 3 || !ok {
+					t.Fatalf("published %+v, want %s for a link of user 7 in organization 3", event, events.LinkExpired)
+				}
+				if link.ShortCode != "code" {
+					t.Errorf("event link short code = %q, want code", link.ShortCode)
+				}
+			}
+		})
+	}
+}
+
+func TestLinkExpiryStop(t *testing.T) {
+	s, mock, _ := newTestLinkExpiryService(t)
+	expectExpiryBatch(mock, true, 0)
+
+	s.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/cache"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// linkLimitQueueSize bounds the tier changes waiting to be checked
+const linkLimitQueueSize = 1000
+
+// LinkLimitService enforces link limits after a tier change. An
+// organization left with more active links than its tier allows gets a
+// grace period to archive or delete links itself; once it passes, the
+// newest links over the limit are archived. Upgrading restores them, oldest
+// first.
+type LinkLimitService struct {
+	linkRepo  *database.LinkRepository
+	orgRepo   *database.OrganizationRepository
+	linkCache *cache.LinkCache
+	eventBus  *events.Bus
+	grace     time.Duration
+	interval  time.Duration
+
+	owners   chan int64
+	orgs     chan int64
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewLinkLimitService(linkRepo *database.LinkRepository, orgRepo *database.OrganizationRepository, linkCache *cache.LinkCache, eventBus *events.Bus, cfg *config.Config) *LinkLimitService {
+	interval := time.Duration(cfg.Links.CheckIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	grace := time.Duration(cfg.Links.GraceDays) * 24 * time.Hour
+	if grace < 0 {
+		grace = 0
+	}
+
+	return &LinkLimitService{
+		linkRepo:  linkRepo,
+		orgRepo:   orgRepo,
+		linkCache: linkCache,
+		eventBus:  eventBus,
+		grace:     grace,
+		interval:  interval,
+		owners:    make(chan int64, linkLimitQueueSize),
+		orgs:      make(chan int64, linkLimitQueueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start launches the worker that applies tier changes and checks grace
+// periods
+func (s *LinkLimitService) Start() {
+	logger.Infof(context.Background(), "Starting link limit checks every %s (grace period: %s)", s.interval, s.grace)
+	go s.run()
+}
+
+// Stop stops the worker once queued tier changes have been applied
+func (s *LinkLimitService) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+
+	select {
+	case <-s.done:
+		logger.Infof(ctx, "Link limit checks stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("link limit checks did not stop in time: %w", ctx.Err())
+	}
+}
+
+// HandleEvent is an events.Handler that queues a user's organizations for a
+// link limit check when their subscription tier changes, and a single
+// organization when the subscription starts or stops covering it
+func (s *LinkLimitService) HandleEvent(ctx context.Context, event events.Event) {
+	switch event.Type {
+	case events.SubscriptionChanged:
+		select {
+		case s.owners <- event.UserID:
+		default:
+			logger.Warnf(ctx, "Link limit queue full, dropping tier change for user ID: %d", event.UserID)
+		}
+	case events.OrganizationTierChanged:
+		select {
+		case s.orgs <- event.OrganizationID:
+		default:
+			logger.Warnf(ctx, "Link limit queue full, dropping tier change for organization %d", event.OrganizationID)
+		}
+	}
+}
+
+func (s *LinkLimitService) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.checkGracePeriods()
+	for {
+		select {
+		case ownerID := <-s.owners:
+			s.checkOwner(ownerID)
+		case orgID := <-s.orgs:
+			s.checkOrganization(orgID)
+		case <-ticker.C:
+			s.checkGracePeriods()
+		case <-s.stop:
+			for {
+				select {
+				case ownerID := <-s.owners:
+					s.checkOwner(ownerID)
+				case orgID := <-s.orgs:
+					s.checkOrganization(orgID)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// checkOwner applies a tier change to every organization the user owns
+func (s *LinkLimitService) checkOwner(ownerID int64) {
+	ctx := context.Background()
+
+	orgs, err := s.orgRepo.GetByOwnerID(ownerID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to load organizations for user ID %d: %+v", ownerID, err)
+		return
+	}
+	for _, org := range orgs {
+		if err := s.reconcile(ctx, org, true); err != nil {
+			logger.Errorf(ctx, "Failed to apply link limit for organization %d: %+v", org.ID, err)
+		}
+	}
+}
+
+// checkOrganization applies a tier change to a single organization
+func (s *LinkLimitService) checkOrganization(orgID int64) {
+	ctx := context.Background()
+
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to load organization %d: %+v", orgID, err)
+		return
+	}
+	if err := s.reconcile(ctx, org, true); err != nil {
+		logger.Errorf(ctx, "Failed to apply link limit for organization %d: %+v", org.ID, err)
+	}
+}
+
+// checkGracePeriods re-checks organizations in a grace period, archiving
+// links for those whose grace period has passed
+func (s *LinkLimitService) checkGracePeriods() {
+	ctx := context.Background()
+
+	orgs, err := s.orgRepo.GetInLinkGrace()
+	if err != nil {
+		logger.Errorf(ctx, "Failed to load organizations in a link grace period: %+v", err)
+		return
+	}
+	for _, org := range orgs {
+		if err := s.reconcile(ctx, org, false); err != nil {
+			logger.Errorf(ctx, "Failed to apply link limit for organization %d: %+v", org.ID, err)
+		}
+	}
+}
+
+// reconcile brings an organization in line with its link limit. Links
+// archived for the limit are only restored on a tier change, so a slot
+// freed by deleting a link stays free for a new one.
+func (s *LinkLimitService) reconcile(ctx context.Context, org *models.Organization, tierChanged bool) error {
+	limit := org.GetLinkLimit()
+	count, err := s.linkRepo.CountByOrganizationID(org.ID)
+	if err != nil {
+		return err
+	}
+
+	if count <= limit {
+		if org.LinkGraceEndsAt != nil {
+			logger.Infof(ctx, "Organization %d is back within its link limit (%d/%d)", org.ID, count, limit)
+			if err := s.orgRepo.ClearLinkGrace(org.ID); err != nil {
+				return err
+			}
+		}
+		if !tierChanged {
+			return nil
+		}
+
+		restored, err := s.linkRepo.RestoreWithinLimit(org.ID, limit)
+		if err != nil {
+			return err
+		}
+		s.invalidate(ctx, restored)
+		if len(restored) > 0 {
+			logger.Infof(ctx, "Restored %d archived links in organization %d", len(restored), org.ID)
+		}
+		return nil
+	}
+
+	if org.LinkGraceEndsAt == nil {
+		endsAt := time.Now().Add(s.grace)
+		started, err := s.orgRepo.StartLinkGrace(org.ID, endsAt)
+		if err != nil || !started {
+			return err
+		}
+
+		logger.Warnf(ctx, "Organization %d is over its link limit (%d/%d), archiving at %s", org.ID, count, limit, endsAt.Format(time.RFC3339))
+		s.eventBus.Publish(ctx, events.Event{
+			Type:           events.LinkQuotaExceeded,
+			UserID:         org.OwnerID,
+			OrganizationID: org.ID,
+			Data: map[string]interface{}{
+				"organization_id": org.ID,
+				"links":           count,
+				"limit":           limit,
+				"grace_ends_at":   endsAt,
+			},
+		})
+		return nil
+	}
+	if time.Now().Before(*org.LinkGraceEndsAt) {
+		return nil
+	}
+
+	archived, err := s.linkRepo.ArchiveOverLimit(org.ID, limit)
+	if err != nil {
+		return err
+	}
+	s.invalidate(ctx, archived)
+	if err := s.orgRepo.ClearLinkGrace(org.ID); err != nil {
+		return err
+	}
+	if len(archived) == 0 {
+		return nil
+	}
+
+	logger.Infof(ctx, "Archived %d links over the limit in organization %d", len(archived), org.ID)
+	s.eventBus.Publish(ctx, events.Event{
+		Type:           events.LinkQuotaArchived,
+		UserID:         org.OwnerID,
+		OrganizationID: org.ID,
+		Data: map[string]interface{}{
+			"organization_id": org.ID,
+			"archived":        len(archived),
+			"limit":           limit,
+		},
+	})
+	return nil
+}
+
+// invalidate drops cached redirects for links whose archived state changed
+func (s *LinkLimitService) invalidate(ctx context.Context, links []*models.Link) {
+	for _, link := range links {
+		s.linkCache.InvalidateLink(ctx, link.DomainID, link.ShortCode)
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/cache"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+func newTestLinkLimitService(t *testing.T) (*LinkLimitService, sqlmock.Sqlmock, *cache.LinkCache, *[]events.Event) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	linkCache := cache.NewLinkCache(cache.NewLRUStore(100), time.Minute, time.Minute)
+	bus := events.NewBus()
+	published := []events.Event{}
+	bus.Subscribe(func(_ context.Context, event events.Event) { published = append(published, event) })
+	cfg := &config.Config{Links: config.LinkLimitConfig{GraceDays: 7}}
+	s := NewLinkLimitService(database.NewLinkRepository(db), database.NewOrganizationRepository(db), linkCache, bus, cfg)
+	return s, mock, linkCache, &published
+}
+
+// expectActiveLinkCount expects organization 3's active links to be counted
+func expectActiveLinkCount(mock sqlmock.Sqlmock, count int) {
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "links" WHERE \(organization_id = \$1 AND archived_at IS NULL\) AND "links"."deleted_at" IS NULL`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+}
+
+func expectOrganizationLocked(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "id" FROM "organizations" WHERE .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+}
+
+func expectGraceCleared(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "organizations" SET "link_grace_ends_at"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND link_grace_ends_at IS NOT NULL\)`).
+		WithArgs(nil, sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestReconcileStartsAGracePeriod(t *testing.T) {
+	s, mock, _, published := newTestLinkLimitService(t)
+	org := &models.Organization{ID: 3, OwnerID: 7, SubscriptionTier: models.TierFree}
+
+	expectActiveLinkCount(mock, 60)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "organizations" SET "link_grace_ends_at"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND link_grace_ends_at IS NULL\)`).
+		WithArgs(aroundNow{offset: 7 * 24 * time.Hour}, sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := s.reconcile(context.Background(), org, true); err != nil {
+		t.Fatalf("reconcile() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*published) != 1 || (*published)[0].Type != events.LinkQuotaExceeded || (*published)[0].UserID != 7 {
+		t.Fatalf("published %+v, want one %s event for owner 7", *published, events.LinkQuotaExceeded)
+	}
+	data := (*published)[0].Data.(map[string]interface{})
+	if data["links"] != 60 || data["limit"] != 50 {
+		t.Errorf("event data = %v, want 60 links over a limit of 50", data)
+	}
+}
+
+func TestReconcileWaitsForTheGracePeriod(t *testing.T) {
+	s, mock, _, published := newTestLinkLimitService(t)
+	endsAt := time.Now().Add(time.Hour)
+	org := &models.Organization{ID: 3, OwnerID: 7, SubscriptionTier: models.TierFree, LinkGraceEndsAt: &endsAt}
+
+	expectActiveLinkCount(mock, 60)
+
+	if err := s.reconcile(context.Background(), org, false); err != nil {
+		t.Fatalf("reconcile() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*published) != 0 {
+		t.Errorf("published %+v during the grace period", *published)
+	}
+}
+
+func TestReconcileArchivesNewestLinksAfterTheGracePeriod(t *testing.T) {
+	s, mock, linkCache, published := newTestLinkLimitService(t)
+	endsAt := time.Now().Add(-time.Hour)
+	org := &models.Organization{ID: 3, OwnerID: 7, SubscriptionTier: models.TierFree, LinkGraceEndsAt: &endsAt}
+	ctx := context.Background()
+
+	// A cached redirect for a link about to be archived
+	linkCache.GetLink(ctx, nil, "sale", func() (*models.Link, error) { return &models.Link{ID: 60, ShortCode: "sale"}, nil })
+
+	expectActiveLinkCount(mock, 52)
+	expectOrganizationLocked(mock)
+	mock.ExpectQuery(`UPDATE links SET archived_at = \$1, archive_reason = \$2, updated_at = \$3\s+WHERE id IN \(\s+SELECT id FROM links\s+WHERE organization_id = \$4 AND archived_at IS NULL AND deleted_at IS NULL\s+ORDER BY created_at ASC, id ASC\s+OFFSET \$5`).
+		WithArgs(sqlmock.AnyArg(), models.ArchiveReasonPlanLimit, sqlmock.AnyArg(), 3, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "short_code"}).AddRow(59, "promo").AddRow(60, "sale"))
+	mock.ExpectCommit()
+	expectGraceCleared(mock)
+
+	if err := s.reconcile(ctx, org, false); err != nil {
+		t.Fatalf("reconcile() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*published) != 1 || (*published)[0].Type != events.LinkQuotaArchived {
+		t.Fatalf("published %+v, want one %s event", *published, events.LinkQuotaArchived)
+	}
+	if archived := (*published)[0].Data.(map[string]interface{})["archived"]; archived != 2 {
+		t.Errorf("archived = %v, want 2", archived)
+	}
+
+	reloaded := false
+	linkCache.GetLink(ctx, nil, "sale", func() (*models.Link, error) {
+		reloaded = true
+		return &models.Link{ID: 60, ShortCode: "sale"}, nil
+	})
+	if !reloaded {
+		t.Error("archived link is still served from the cache")
+	}
+}
+
+func TestReconcileRestoresLinksOnUpgrade(t *testing.T) {
+	s, mock, _, _ := newTestLinkLimitService(t)
+	endsAt := time.Now().Add(time.Hour)
+	org := &models.Organization{ID: 3, OwnerID: 7, SubscriptionTier: models.TierPro, LinkGraceEndsAt: &endsAt}
+
+	expectActiveLinkCount(mock, 60)
+	expectGraceCleared(mock)
+	expectOrganizationLocked(mock)
+	expectActiveLinkCount(mock, 60)
+	mock.ExpectQuery(`UPDATE links SET archived_at = NULL, archive_reason = '', updated_at = \$1`).
+		WithArgs(sqlmock.AnyArg(), 3, models.ArchiveReasonPlanLimit, 440).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "short_code"}).AddRow(61, "spring"))
+	mock.ExpectCommit()
+
+	if err := s.reconcile(context.Background(), org, true); err != nil {
+		t.Fatalf("reconcile() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconcileLeavesFreedSlotsOpen(t *testing.T) {
+	s, mock, _, _ := newTestLinkLimitService(t)
+	org := &models.Organization{ID: 3, OwnerID: 7, SubscriptionTier: models.TierFree}
+
+	// Without a tier change, archived links stay archived
+	expectActiveLinkCount(mock, 49)
+
+	if err := s.reconcile(context.Background(), org, false); err != nil {
+		t.Fatalf("reconcile() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
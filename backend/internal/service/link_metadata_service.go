@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/metadata"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+const (
+	// linkMetadataQueueSize bounds the links waiting for a fetch; links
+	// that don't fit are picked up by the next scan
+	linkMetadataQueueSize = 1000
+	// linkMetadataBatchSize bounds the pending links loaded per scan
+	linkMetadataBatchSize = 100
+)
+
+// LinkMetadataService fills in links' titles, descriptions, favicons and
+// canonical URLs from their destination pages. New links and changed
+// destinations are fetched as they come in; a periodic scan catches links
+// that were missed, such as those queued when an instance stopped.
+type LinkMetadataService struct {
+	linkRepo *database.LinkRepository
+	fetcher  *metadata.Fetcher
+	workers  int
+	interval time.Duration
+
+	queue    chan *models.Link
+	queued   sync.Map
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+func NewLinkMetadataService(linkRepo *database.LinkRepository, fetcher *metadata.Fetcher, cfg *config.Config) *LinkMetadataService {
+	workers := cfg.Metadata.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	interval := time.Duration(cfg.Metadata.ScanIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	return &LinkMetadataService{
+		linkRepo: linkRepo,
+		fetcher:  fetcher,
+		workers:  workers,
+		interval: interval,
+		queue:    make(chan *models.Link, linkMetadataQueueSize),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start launches the fetch workers and the scan for pending links
+func (s *LinkMetadataService) Start() {
+	logger.Infof(context.Background(), "Starting link metadata fetching with %d workers (scan every %s)", s.workers, s.interval)
+
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	s.wg.Add(1)
+	go s.scanner()
+}
+
+// Stop stops the workers once their current fetches have finished. Links
+// still queued stay pending and are fetched after a restart.
+func (s *LinkMetadataService) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Infof(ctx, "Link metadata fetching stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("link metadata fetching did not stop in time: %w", ctx.Err())
+	}
+}
+
+// HandleEvent is an events.Handler that queues new links and changed
+// destinations for a fetch
+func (s *LinkMetadataService) HandleEvent(ctx context.Context, event events.Event) {
+	if event.Type != events.LinkCreated && event.Type != events.LinkDestinationChanged {
+		return
+	}
+	link, ok := event.Data.(*models.Link)
+	if !ok {
+		return
+	}
+	s.enqueue(ctx, link)
+}
+
+// enqueue queues a copy of the link unless it's already waiting
+func (s *LinkMetadataService) enqueue(ctx context.Context, link *models.Link) {
+	if _, loaded := s.queued.LoadOrStore(link.ID, struct{}{}); loaded {
+		return
+	}
+
+	queued := *link
+	select {
+	case s.queue <- &queued:
+	default:
+		s.queued.Delete(link.ID)
+		logger.Warnf(ctx, "Link metadata queue full, deferring link ID: %d to the next scan", link.ID)
+	}
+}
+
+func (s *LinkMetadataService) worker() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case link := <-s.queue:
+			s.fetch(link)
+			s.queued.Delete(link.ID)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *LinkMetadataService) scanner() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.scan()
+	for {
+		select {
+		case <-ticker.C:
+			s.scan()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// scan queues pending links that haven't changed for a scan interval, so
+// links just queued through events aren't fetched twice
+func (s *LinkMetadataService) scan() {
+	ctx := context.Background()
+
+	links, err := s.linkRepo.GetPendingMetadata(time.Now().Add(-s.interval), linkMetadataBatchSize)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to load links pending metadata: %+v", err)
+		return
+	}
+	for _, link := range links {
+		s.enqueue(ctx, link)
+	}
+}
+
+// fetch retrieves the link's destination and saves what it found, or marks
+// the link failed
+func (s *LinkMetadataService) fetch(link *models.Link) {
+	ctx := context.Background()
+
+	fetched, err := s.fetcher.Fetch(ctx, link.DestinationURL)
+	now := time.Now()
+	result := &models.Link{ID: link.ID, MetadataStatus: models.MetadataFetched, MetadataFetchedAt: &now}
+	if err != nil {
+		logger.Warnf(ctx, "Failed to fetch metadata for link ID %d: %v", link.ID, err)
+		result.MetadataStatus = models.MetadataFailed
+	} else {
+		result.Title = optionalString(fetched.Title)
+		result.Description = optionalString(fetched.Description)
+		result.FaviconURL = optionalString(fetched.FaviconURL)
+		result.CanonicalURL = optionalString(fetched.CanonicalURL)
+	}
+
+	saved, err := s.linkRepo.SaveMetadata(result, link.DestinationURL)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to save metadata for link ID %d: %+v", link.ID, err)
+		return
+	}
+	if !saved {
+		logger.Debugf(ctx, "Link ID %d changed while its metadata was fetched, discarding", link.ID)
+		return
+	}
+	logger.Infof(ctx, "Link metadata %s for link ID: %d", result.MetadataStatus, link.ID)
+}
+
+// optionalString returns nil for an empty string
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -3,35 +3,114 @@ package service
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"math/big"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/cache"
 	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
 	"github.com/shafikshaon/url_shortener/internal/logger"
 	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/ratelimit"
+	"github.com/shafikshaon/url_shortener/internal/urlcheck"
 )
 
 const (
 	shortCodeLength = 7
 	charset         = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	maxRetries      = 5
+
+	// Link passwords are bcrypt hashed, which only reads the first 72 bytes
+	minLinkPasswordLength = 4
+	maxLinkPasswordLength = 72
+
+	// Bounds checking a link's destinations against the blocklists
+	blocklistCheckTimeout = 5 * time.Second
+	maxFlagReasonLength   = 500
+)
+
+var (
+	// ErrInvalidShortCode is returned for malformed custom short codes
+	ErrInvalidShortCode = errors.New("invalid short code")
+	// ErrShortCodeReserved is returned for custom short codes that clash with app routes
+	ErrShortCodeReserved = errors.New("short code is reserved")
+	// ErrShortCodeTaken is returned when a custom short code is already in use
+	ErrShortCodeTaken = errors.New("short code already exists")
+	// ErrLinkLimitReached is returned when the organization has no links left on its tier
+	ErrLinkLimitReached = errors.New("link limit reached for your subscription tier")
+	// ErrDomainNotVerified is returned when creating a link on an unverified domain
+	ErrDomainNotVerified = errors.New("domain is not verified")
+	// ErrInvalidDestination is returned for destination URLs a link can't
+	// redirect to, including other short links
+	ErrInvalidDestination = errors.New("invalid destination URL")
+	// ErrInvalidActivation is returned for inconsistent click limits, start times or fallback URLs
+	ErrInvalidActivation = errors.New("invalid link activation")
+	// ErrInvalidRedirectRule is returned for malformed or duplicate redirect rules
+	ErrInvalidRedirectRule = errors.New("invalid redirect rule")
+	// ErrInvalidVariants is returned for malformed or duplicate A/B variants
+	ErrInvalidVariants = errors.New("invalid link variants")
+	// ErrInvalidDeepLink is returned for malformed app URIs or store URLs
+	ErrInvalidDeepLink = errors.New("invalid deep link")
+	// ErrInvalidPreview is returned for overlong preview text or a malformed
+	// preview image URL
+	ErrInvalidPreview = errors.New("invalid link preview")
+	// ErrInvalidQueryOptions is returned for overlong UTM parameters or an
+	// unknown query conflict policy
+	ErrInvalidQueryOptions = errors.New("invalid query options")
+	// ErrInvalidLinkPassword is returned when setting a password that is too short or too long
+	ErrInvalidLinkPassword = errors.New("invalid link password")
+	// ErrIncorrectLinkPassword is returned when a visitor enters the wrong link password
+	ErrIncorrectLinkPassword = errors.New("incorrect link password")
+	// ErrTooManyUnlockAttempts is returned when a visitor has entered too many wrong link passwords
+	ErrTooManyUnlockAttempts = errors.New("too many unlock attempts")
 )
 
 type LinkService struct {
-	linkRepo *database.LinkRepository
-	userRepo *database.UserRepository
+	linkRepo   *database.LinkRepository
+	orgRepo    *database.OrganizationRepository
+	domainRepo *database.DomainRepository
+	authorizer *authz.Authorizer
+	linkCache  *cache.LinkCache
+	eventBus   *events.Bus
+	attempts   ratelimit.Store
+	blocklist  urlcheck.Blocklist
+	baseHost   string
+	shorteners []string
+	config     *config.Config
 }
 
-func NewLinkService(linkRepo *database.LinkRepository, userRepo *database.UserRepository) *LinkService {
+func NewLinkService(linkRepo *database.LinkRepository, orgRepo *database.OrganizationRepository, domainRepo *database.DomainRepository, authorizer *authz.Authorizer, linkCache *cache.LinkCache, eventBus *events.Bus, attempts ratelimit.Store, blocklist urlcheck.Blocklist, cfg *config.Config) *LinkService {
+	shorteners := make([]string, 0, len(cfg.URLSafety.ShortenerDomains))
+	for _, domain := range cfg.URLSafety.ShortenerDomains {
+		shorteners = append(shorteners, NormalizeHost(domain))
+	}
+
 	return &LinkService{
-		linkRepo: linkRepo,
-		userRepo: userRepo,
+		linkRepo:   linkRepo,
+		orgRepo:    orgRepo,
+		domainRepo: domainRepo,
+		authorizer: authorizer,
+		linkCache:  linkCache,
+		eventBus:   eventBus,
+		attempts:   attempts,
+		blocklist:  blocklist,
+		baseHost:   hostFromURL(cfg.Server.BaseURL),
+		shorteners: shorteners,
+		config:     cfg,
 	}
 }
 
-// GenerateShortCode generates a random short code
-func (s *LinkService) GenerateShortCode() (string, error) {
+// GenerateShortCode generates a random short code unique within the domain's namespace
+func (s *LinkService) GenerateShortCode(domainID *int64) (string, error) {
 	ctx := context.Background()
 	logger.Debugf(ctx, "Generating short code")
 
@@ -43,7 +122,7 @@ func (s *LinkService) GenerateShortCode() (string, error) {
 		}
 
 		// Check if code already exists
-		exists, err := s.linkRepo.ShortCodeExists(code)
+		exists, err := s.linkRepo.ShortCodeExists(domainID, code)
 		if err != nil {
 			logger.Errorf(ctx, "Failed to check short code existence: %+v", err)
 			return "", err
@@ -76,16 +155,16 @@ func generateRandomString(length int) (string, error) {
 	return string(result), nil
 }
 
-// ValidateCustomShortCode validates a custom short code
-func (s *LinkService) ValidateCustomShortCode(shortCode string) error {
+// ValidateCustomShortCode validates a custom short code within the domain's namespace
+func (s *LinkService) ValidateCustomShortCode(domainID *int64, shortCode string) error {
 	if len(shortCode) < 3 || len(shortCode) > 20 {
-		return fmt.Errorf("short code must be between 3 and 20 characters")
+		return fmt.Errorf("%w: must be between 3 and 20 characters", ErrInvalidShortCode)
 	}
 
 	// Only allow alphanumeric and hyphens
 	for _, char := range shortCode {
 		if !isAlphanumeric(char) && char != '-' {
-			return fmt.Errorf("short code can only contain letters, numbers, and hyphens")
+			return fmt.Errorf("%w: only letters, numbers, and hyphens are allowed", ErrInvalidShortCode)
 		}
 	}
 
@@ -93,18 +172,18 @@ func (s *LinkService) ValidateCustomShortCode(shortCode string) error {
 	reserved := []string{"api", "admin", "login", "signup", "dashboard", "settings", "analytics"}
 	for _, r := range reserved {
 		if strings.EqualFold(shortCode, r) {
-			return fmt.Errorf("short code is reserved")
+			return ErrShortCodeReserved
 		}
 	}
 
 	// Check if code already exists
-	exists, err := s.linkRepo.ShortCodeExists(shortCode)
+	exists, err := s.linkRepo.ShortCodeExists(domainID, shortCode)
 	if err != nil {
 		return err
 	}
 
 	if exists {
-		return fmt.Errorf("short code already exists")
+		return ErrShortCodeTaken
 	}
 
 	return nil
@@ -114,41 +193,327 @@ func isAlphanumeric(char rune) bool {
 	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
 }
 
-// CreateLink creates a new short link
-func (s *LinkService) CreateLink(link *models.Link, customCode string) error {
+// hashLinkPassword validates and hashes a link password
+func hashLinkPassword(password string) (string, error) {
+	if len(password) < minLinkPasswordLength || len(password) > maxLinkPasswordLength {
+		return "", fmt.Errorf("%w: must be between %d and %d characters", ErrInvalidLinkPassword, minLinkPasswordLength, maxLinkPasswordLength)
+	}
+	return auth.HashPassword(password)
+}
+
+// validateActivation checks the settings that decide when a link redirects
+// and normalizes its start time and fallback URL
+func validateActivation(link *models.Link) error {
+	if link.MaxClicks != nil && *link.MaxClicks < 1 {
+		return fmt.Errorf("%w: max_clicks must be at least 1", ErrInvalidActivation)
+	}
+	if link.StartsAt != nil && link.StartsAt.IsZero() {
+		link.StartsAt = nil
+	}
+	if link.StartsAt != nil && link.ExpiresAt != nil && !link.StartsAt.Before(*link.ExpiresAt) {
+		return fmt.Errorf("%w: starts_at must be before expires_at", ErrInvalidActivation)
+	}
+	if link.FallbackURL != nil {
+		if *link.FallbackURL == "" {
+			link.FallbackURL = nil
+		} else {
+			fallback, err := urlcheck.Normalize(*link.FallbackURL)
+			if err != nil {
+				return fmt.Errorf("%w: fallback_url: %v", ErrInvalidActivation, err)
+			}
+			link.FallbackURL = &fallback
+		}
+	}
+	return nil
+}
+
+// validateRedirectRules checks the link's redirect rules and normalizes
+// their destination URLs
+func validateRedirectRules(link *models.Link) error {
+	if len(link.RedirectRules) > models.MaxRedirectRules {
+		return fmt.Errorf("%w: at most %d rules are allowed", ErrInvalidRedirectRule, models.MaxRedirectRules)
+	}
+
+	names := make(map[string]bool, len(link.RedirectRules))
+	for i := range link.RedirectRules {
+		rule := &link.RedirectRules[i]
+		rule.Normalize()
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("%w: rule %d: %v", ErrInvalidRedirectRule, i+1, err)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("%w: duplicate rule name %q", ErrInvalidRedirectRule, rule.Name)
+		}
+		names[rule.Name] = true
+
+		if strings.TrimSpace(rule.DestinationURL) == "" {
+			return fmt.Errorf("%w: rule %q needs a destination_url", ErrInvalidRedirectRule, rule.Name)
+		}
+		destination, err := urlcheck.Normalize(rule.DestinationURL)
+		if err != nil {
+			return fmt.Errorf("%w: rule %q: %v", ErrInvalidRedirectRule, rule.Name, err)
+		}
+		rule.DestinationURL = destination
+	}
+	return nil
+}
+
+// validateVariants checks the link's A/B variants and normalizes their
+// destination URLs
+func validateVariants(link *models.Link) error {
+	if len(link.Variants) == 0 {
+		return nil
+	}
+	if len(link.Variants) < 2 || len(link.Variants) > models.MaxLinkVariants {
+		return fmt.Errorf("%w: between 2 and %d variants are needed", ErrInvalidVariants, models.MaxLinkVariants)
+	}
+
+	names := make(map[string]bool, len(link.Variants))
+	for i := range link.Variants {
+		variant := &link.Variants[i]
+		variant.Name = strings.TrimSpace(variant.Name)
+		if err := variant.Validate(); err != nil {
+			return fmt.Errorf("%w: variant %d: %v", ErrInvalidVariants, i+1, err)
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("%w: duplicate variant name %q", ErrInvalidVariants, variant.Name)
+		}
+		names[variant.Name] = true
+
+		if strings.TrimSpace(variant.DestinationURL) == "" {
+			return fmt.Errorf("%w: variant %q needs a destination_url", ErrInvalidVariants, variant.Name)
+		}
+		destination, err := urlcheck.Normalize(variant.DestinationURL)
+		if err != nil {
+			return fmt.Errorf("%w: variant %q: %v", ErrInvalidVariants, variant.Name, err)
+		}
+		variant.DestinationURL = destination
+	}
+	return nil
+}
+
+// unsafeAppSchemes can run code in the browser instead of opening an app
+var unsafeAppSchemes = map[string]bool{"javascript": true, "data": true, "vbscript": true, "file": true}
+
+// validateDeepLink checks the link's app URIs and store URLs. A deep link
+// with nothing set is dropped.
+func validateDeepLink(link *models.Link) error {
+	if link.DeepLink.IsZero() {
+		link.DeepLink = nil
+		return nil
+	}
+
+	for _, appURI := range []string{link.DeepLink.IOSAppURI, link.DeepLink.AndroidAppURI} {
+		if appURI == "" {
+			continue
+		}
+		parsed, err := url.Parse(appURI)
+		if err != nil || parsed.Scheme == "" || unsafeAppSchemes[strings.ToLower(parsed.Scheme)] {
+			return fmt.Errorf("%w: %q is not a valid app URI", ErrInvalidDeepLink, appURI)
+		}
+	}
+	for _, storeURL := range []*string{&link.DeepLink.IOSStoreURL, &link.DeepLink.AndroidStoreURL} {
+		if *storeURL == "" {
+			continue
+		}
+		normalized, err := urlcheck.Normalize(*storeURL)
+		if err != nil {
+			return fmt.Errorf("%w: store URL %q: %v", ErrInvalidDeepLink, *storeURL, err)
+		}
+		*storeURL = normalized
+	}
+	return nil
+}
+
+// validatePreview checks the link's preview overrides. A preview with
+// nothing set is dropped.
+func validatePreview(link *models.Link) error {
+	if link.Preview != nil {
+		link.Preview.Title = strings.TrimSpace(link.Preview.Title)
+		link.Preview.Description = strings.TrimSpace(link.Preview.Description)
+		link.Preview.ImageURL = strings.TrimSpace(link.Preview.ImageURL)
+	}
+	if link.Preview.IsZero() {
+		link.Preview = nil
+		return nil
+	}
+
+	if utf8.RuneCountInString(link.Preview.Title) > models.MaxPreviewTitleLength {
+		return fmt.Errorf("%w: title can be at most %d characters", ErrInvalidPreview, models.MaxPreviewTitleLength)
+	}
+	if utf8.RuneCountInString(link.Preview.Description) > models.MaxPreviewDescriptionLength {
+		return fmt.Errorf("%w: description can be at most %d characters", ErrInvalidPreview, models.MaxPreviewDescriptionLength)
+	}
+	if link.Preview.ImageURL != "" {
+		imageURL, err := urlcheck.Normalize(link.Preview.ImageURL)
+		if err != nil {
+			return fmt.Errorf("%w: image URL %q: %v", ErrInvalidPreview, link.Preview.ImageURL, err)
+		}
+		link.Preview.ImageURL = imageURL
+	}
+	return nil
+}
+
+// validateQueryOptions checks the link's UTM parameters and query conflict
+// policy, which defaults to keeping the destination's values. UTM
+// parameters with nothing set are dropped.
+func validateQueryOptions(link *models.Link) error {
+	switch link.QueryConflict {
+	case "":
+		link.QueryConflict = models.QueryConflictDestination
+	case models.QueryConflictShortLink, models.QueryConflictDestination:
+	default:
+		return fmt.Errorf("%w: query_conflict must be %s or %s", ErrInvalidQueryOptions,
+			models.QueryConflictShortLink, models.QueryConflictDestination)
+	}
+
+	if link.UTM != nil {
+		for _, param := range []*string{&link.UTM.Source, &link.UTM.Medium, &link.UTM.Campaign, &link.UTM.Term, &link.UTM.Content} {
+			*param = strings.TrimSpace(*param)
+			if len(*param) > models.MaxUTMParamLength {
+				return fmt.Errorf("%w: UTM parameters can be at most %d characters", ErrInvalidQueryOptions, models.MaxUTMParamLength)
+			}
+		}
+	}
+	if link.UTM.IsZero() {
+		link.UTM = nil
+	}
+	return nil
+}
+
+// linkDestinations returns every URL the link can send visitors to, along
+// with the image its previews show
+func linkDestinations(link *models.Link) []string {
+	destinations := []string{link.DestinationURL}
+	if link.FallbackURL != nil {
+		destinations = append(destinations, *link.FallbackURL)
+	}
+	for _, rule := range link.RedirectRules {
+		destinations = append(destinations, rule.DestinationURL)
+	}
+	for _, variant := range link.Variants {
+		destinations = append(destinations, variant.DestinationURL)
+	}
+	if link.DeepLink != nil {
+		for _, storeURL := range []string{link.DeepLink.IOSStoreURL, link.DeepLink.AndroidStoreURL} {
+			if storeURL != "" {
+				destinations = append(destinations, storeURL)
+			}
+		}
+	}
+	if link.Preview != nil && link.Preview.ImageURL != "" {
+		destinations = append(destinations, link.Preview.ImageURL)
+	}
+	return destinations
+}
+
+// checkDestinations rejects links that point at short links, here or on
+// other shorteners, which could loop or hide where they lead. Links with a
+// blocklisted destination are flagged rather than rejected, so their owners
+// can see why they stopped redirecting. A blocklist that can't be checked
+// doesn't stop the link from being saved.
+func (s *LinkService) checkDestinations(ctx context.Context, link *models.Link) error {
+	destinations := linkDestinations(link)
+	for _, destination := range destinations {
+		host := urlcheck.Host(destination)
+		if host == s.baseHost {
+			return fmt.Errorf("%w: %s is a short link", ErrInvalidDestination, destination)
+		}
+		if urlcheck.IsShortener(host, s.shorteners) {
+			return fmt.Errorf("%w: %s is on another URL shortener, link to where it leads instead", ErrInvalidDestination, destination)
+		}
+		domain, err := s.domainRepo.GetByHostname(host)
+		if err != nil && !errors.Is(err, database.ErrDomainNotFound) {
+			return err
+		}
+		if err == nil && domain.IsVerified() {
+			return fmt.Errorf("%w: %s is a short link", ErrInvalidDestination, destination)
+		}
+	}
+
+	link.FlaggedAt = nil
+	link.FlagReason = ""
+
+	checkCtx, cancel := context.WithTimeout(ctx, blocklistCheckTimeout)
+	defer cancel()
+	for _, destination := range destinations {
+		parsed, err := url.Parse(destination)
+		if err != nil {
+			continue
+		}
+		match, err := s.blocklist.Check(checkCtx, parsed)
+		if err != nil {
+			logger.Warnf(ctx, "Blocklist check failed for %s: %v", destination, err)
+			continue
+		}
+		if match != nil {
+			logger.Warnf(ctx, "Flagging link with short code %s: %s", link.ShortCode, match.Reason)
+			now := time.Now()
+			link.FlaggedAt = &now
+			link.FlagReason = match.Reason
+			if runes := []rune(link.FlagReason); len(runes) > maxFlagReasonLength {
+				link.FlagReason = string(runes[:maxFlagReasonLength])
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// CreateLink creates a new short link in the link's organization. A
+// non-empty password protects the link.
+func (s *LinkService) CreateLink(link *models.Link, customCode string, password string) error {
 	ctx := context.Background()
-	logger.Infof(ctx, "Creating link for user ID: %d, custom code: %s", link.UserID, customCode)
+	logger.Infof(ctx, "Creating link for user ID: %d in organization ID: %d, custom code: %s", link.UserID, link.OrganizationID, customCode)
+
+	if err := s.authorizer.AuthorizeLink(link.UserID, link, authz.ActionEditLinks); err != nil {
+		return err
+	}
 
-	// Check user's link limit
-	user, err := s.userRepo.GetByID(link.UserID)
+	// Check the organization's link limit
+	org, err := s.orgRepo.GetByID(link.OrganizationID)
 	if err != nil {
-		logger.Errorf(ctx, "User not found: %d, error: %+v", link.UserID, err)
-		return fmt.Errorf("user not found")
+		logger.Errorf(ctx, "Organization not found: %d, error: %+v", link.OrganizationID, err)
+		return fmt.Errorf("error loading organization: %w", err)
 	}
 
-	linkCount, err := s.linkRepo.CountByUserID(link.UserID)
+	linkCount, err := s.linkRepo.CountByOrganizationID(org.ID)
 	if err != nil {
-		logger.Errorf(ctx, "Failed to count links for user: %+v", err)
+		logger.Errorf(ctx, "Failed to count links for organization: %+v", err)
 		return err
 	}
 
-	logger.Infof(ctx, "User %d has %d/%d links", link.UserID, linkCount, user.GetLinkLimit())
+	logger.Infof(ctx, "Organization %d has %d/%d links", org.ID, linkCount, org.GetLinkLimit())
 
-	if linkCount >= user.GetLinkLimit() {
-		logger.Warnf(ctx, "User %d reached link limit: %d", link.UserID, user.GetLinkLimit())
-		return fmt.Errorf("link limit reached for your subscription tier")
+	if linkCount >= org.GetLinkLimit() {
+		logger.Warnf(ctx, "Organization %d reached link limit: %d", org.ID, org.GetLinkLimit())
+		return ErrLinkLimitReached
+	}
+
+	// Resolve the custom domain, if one was requested
+	var domain *models.Domain
+	if link.DomainID != nil {
+		domain, err = s.domainRepo.GetByID(*link.DomainID)
+		// A link may only use its own organization's domains
+		if err != nil || domain.OrganizationID != link.OrganizationID {
+			logger.Warnf(ctx, "Organization %d requested unknown domain ID: %d", link.OrganizationID, *link.DomainID)
+			return database.ErrDomainNotFound
+		}
+		if !domain.IsVerified() {
+			return ErrDomainNotVerified
+		}
 	}
 
 	// Generate or validate short code
 	if customCode != "" {
 		logger.Infof(ctx, "Validating custom short code: %s", customCode)
-		if err := s.ValidateCustomShortCode(customCode); err != nil {
+		if err := s.ValidateCustomShortCode(link.DomainID, customCode); err != nil {
 			logger.Errorf(ctx, "Custom short code validation failed: %+v", err)
 			return err
 		}
 		link.ShortCode = customCode
 	} else {
-		code, err := s.GenerateShortCode()
+		code, err := s.GenerateShortCode(link.DomainID)
 		if err != nil {
 			return err
 		}
@@ -156,20 +521,70 @@ func (s *LinkService) CreateLink(link *models.Link, customCode string) error {
 	}
 
 	// Validate destination URL
-	if !strings.HasPrefix(link.DestinationURL, "http://") && !strings.HasPrefix(link.DestinationURL, "https://") {
-		link.DestinationURL = "https://" + link.DestinationURL
-		logger.Infof(ctx, "Added https:// prefix to destination URL")
+	destination, err := urlcheck.Normalize(link.DestinationURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDestination, err)
+	}
+	link.DestinationURL = destination
+
+	if err := validateActivation(link); err != nil {
+		return err
+	}
+	if err := validateRedirectRules(link); err != nil {
+		return err
+	}
+	if err := validateVariants(link); err != nil {
+		return err
+	}
+	if err := validateDeepLink(link); err != nil {
+		return err
+	}
+	if err := validateQueryOptions(link); err != nil {
+		return err
+	}
+	if err := validatePreview(link); err != nil {
+		return err
+	}
+	if err := s.checkDestinations(ctx, link); err != nil {
+		return err
+	}
+
+	if password != "" {
+		hash, err := hashLinkPassword(password)
+		if err != nil {
+			return err
+		}
+		link.PasswordHash = hash
 	}
 
 	logger.Infof(ctx, "Creating link with short code: %s, destination: %s", link.ShortCode, link.DestinationURL)
 
-	// Create link
+	// The destination's title, description and favicon are fetched in the
+	// background
+	link.MetadataStatus = models.MetadataPending
+
+	// Create link. The short code was checked above, but a concurrent
+	// request can still take it first.
 	if err := s.linkRepo.Create(link); err != nil {
+		if errors.Is(err, database.ErrShortCodeConflict) {
+			return ErrShortCodeTaken
+		}
 		logger.Errorf(ctx, "Failed to create link: %+v", err)
 		return err
 	}
 
+	link.Domain = domain
 	logger.Infof(ctx, "Successfully created link with ID: %d, short code: %s", link.ID, link.ShortCode)
+
+	// Drop any negative cache entry left by earlier lookups of this code
+	s.linkCache.InvalidateLink(ctx, link.DomainID, link.ShortCode)
+
+	s.eventBus.Publish(ctx, events.Event{
+		Type:           events.LinkCreated,
+		UserID:         link.UserID,
+		OrganizationID: link.OrganizationID,
+		Data:           link,
+	})
 	return nil
 }
 
@@ -184,22 +599,27 @@ func (s *LinkService) GetLink(linkID int64, userID int64) (*models.Link, error)
 		return nil, err
 	}
 
-	// Check ownership
-	if link.UserID != userID {
-		logger.Warnf(ctx, "Unauthorized access attempt: link ID %d by user ID %d (owner: %d)", linkID, userID, link.UserID)
-		return nil, fmt.Errorf("unauthorized")
+	if err := s.authorizer.AuthorizeLink(userID, link, authz.ActionViewLinks); err != nil {
+		return nil, err
 	}
 
 	logger.Infof(ctx, "Successfully fetched link ID: %d", linkID)
 	return link, nil
 }
 
-// GetLinkByShortCode retrieves a link by short code
-func (s *LinkService) GetLinkByShortCode(shortCode string) (*models.Link, error) {
+// GetLinkByShortCode retrieves a link by short code within a domain's
+// namespace, reading through the redirect cache
+func (s *LinkService) GetLinkByShortCode(domainID *int64, shortCode string) (*models.Link, error) {
 	ctx := context.Background()
 	logger.Infof(ctx, "Fetching link by short code: %s", shortCode)
 
-	link, err := s.linkRepo.GetByShortCode(shortCode)
+	link, err := s.linkCache.GetLink(ctx, domainID, shortCode, func() (*models.Link, error) {
+		link, err := s.linkRepo.GetByShortCode(domainID, shortCode)
+		if errors.Is(err, database.ErrLinkNotFound) {
+			return nil, cache.ErrNotFound
+		}
+		return link, err
+	})
 	if err != nil {
 		logger.Errorf(ctx, "Link not found with short code: %s, error: %+v", shortCode, err)
 		return nil, err
@@ -209,14 +629,19 @@ func (s *LinkService) GetLinkByShortCode(shortCode string) (*models.Link, error)
 	return link, nil
 }
 
-// ListLinks lists all links for a user with pagination
-func (s *LinkService) ListLinks(userID int64, limit, offset int, search string, sortBy string) ([]*models.Link, int, error) {
-	links, err := s.linkRepo.GetByUserID(userID, limit, offset, search, sortBy)
+// ListLinks lists all links in an organization with pagination, optionally
+// only those in the given state
+func (s *LinkService) ListLinks(userID, orgID int64, limit, offset int, search, status string, sortBy string) ([]*models.Link, int, error) {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionViewLinks); err != nil {
+		return nil, 0, err
+	}
+
+	links, err := s.linkRepo.GetByOrganizationID(orgID, limit, offset, search, status, sortBy)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	total, err := s.linkRepo.CountByUserIDWithSearch(userID, search)
+	total, err := s.linkRepo.CountByOrganizationIDWithSearch(orgID, search, status)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -224,27 +649,103 @@ func (s *LinkService) ListLinks(userID int64, limit, offset int, search string,
 	return links, total, nil
 }
 
-// UpdateLink updates a link
-func (s *LinkService) UpdateLink(link *models.Link, userID int64) error {
+// UpdateLink updates a link. A nil password leaves the link's password
+// unchanged and an empty one removes it; a nil queryPassthrough and an
+// empty query conflict leave those settings unchanged.
+func (s *LinkService) UpdateLink(link *models.Link, userID int64, password *string, queryPassthrough *bool) error {
 	ctx := context.Background()
 	logger.Infof(ctx, "Updating link ID: %d for user ID: %d", link.ID, userID)
 
-	// Check ownership
 	existing, err := s.linkRepo.GetByID(link.ID)
 	if err != nil {
 		logger.Errorf(ctx, "Failed to get existing link: %+v", err)
 		return err
 	}
 
-	if existing.UserID != userID {
-		logger.Warnf(ctx, "Unauthorized update attempt: link ID %d by user ID %d (owner: %d)", link.ID, userID, existing.UserID)
-		return fmt.Errorf("unauthorized")
+	if err := s.authorizer.AuthorizeLink(userID, existing, authz.ActionEditLinks); err != nil {
+		return err
 	}
 
 	// Validate destination URL
-	if !strings.HasPrefix(link.DestinationURL, "http://") && !strings.HasPrefix(link.DestinationURL, "https://") {
-		link.DestinationURL = "https://" + link.DestinationURL
-		logger.Infof(ctx, "Added https:// prefix to destination URL")
+	destination, err := urlcheck.Normalize(link.DestinationURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDestination, err)
+	}
+	link.DestinationURL = destination
+
+	// Start times, click limits and fallback URLs are kept unless
+	// replaced; the zero time, 0 and "" remove them
+	if link.StartsAt == nil {
+		link.StartsAt = existing.StartsAt
+	}
+	if link.MaxClicks == nil {
+		link.MaxClicks = existing.MaxClicks
+	} else if *link.MaxClicks == 0 {
+		link.MaxClicks = nil
+	}
+	if link.FallbackURL == nil {
+		link.FallbackURL = existing.FallbackURL
+	}
+	if err := validateActivation(link); err != nil {
+		return err
+	}
+
+	// Rules, variants, deep links, UTM parameters and previews are kept
+	// unless replaced; an empty list or object removes them
+	if link.RedirectRules == nil {
+		link.RedirectRules = existing.RedirectRules
+	}
+	if link.Variants == nil {
+		link.Variants = existing.Variants
+	}
+	if link.DeepLink == nil {
+		link.DeepLink = existing.DeepLink
+	}
+	if link.UTM == nil {
+		link.UTM = existing.UTM
+	}
+	if queryPassthrough == nil {
+		link.QueryPassthrough = existing.QueryPassthrough
+	}
+	if link.QueryConflict == "" {
+		link.QueryConflict = existing.QueryConflict
+	}
+	if link.Preview == nil {
+		link.Preview = existing.Preview
+	}
+	if err := validateRedirectRules(link); err != nil {
+		return err
+	}
+	if err := validateVariants(link); err != nil {
+		return err
+	}
+	if err := validateDeepLink(link); err != nil {
+		return err
+	}
+	if err := validateQueryOptions(link); err != nil {
+		return err
+	}
+	if err := validatePreview(link); err != nil {
+		return err
+	}
+	if err := s.checkDestinations(ctx, link); err != nil {
+		return err
+	}
+	// A link still flagged for the same reason keeps when it was flagged
+	if link.IsFlagged() && existing.IsFlagged() && link.FlagReason == existing.FlagReason {
+		link.FlaggedAt = existing.FlaggedAt
+	}
+
+	link.PasswordHash = existing.PasswordHash
+	if password != nil {
+		link.PasswordHash = ""
+		if *password != "" {
+			hash, err := hashLinkPassword(*password)
+			if err != nil {
+				return err
+			}
+			link.PasswordHash = hash
+		}
 	}
 
 	logger.Infof(ctx, "Updating link ID: %d with new destination: %s", link.ID, link.DestinationURL)
@@ -254,6 +755,17 @@ func (s *LinkService) UpdateLink(link *models.Link, userID int64) error {
 		return err
 	}
 
+	s.linkCache.InvalidateLink(ctx, existing.DomainID, existing.ShortCode)
+
+	if link.DestinationURL != existing.DestinationURL {
+		s.eventBus.Publish(ctx, events.Event{
+			Type:           events.LinkDestinationChanged,
+			UserID:         userID,
+			OrganizationID: existing.OrganizationID,
+			Data:           link,
+		})
+	}
+
 	logger.Infof(ctx, "Successfully updated link ID: %d", link.ID)
 	return nil
 }
@@ -263,21 +775,169 @@ func (s *LinkService) DeleteLink(linkID int64, userID int64) error {
 	ctx := context.Background()
 	logger.Infof(ctx, "Deleting link ID: %d for user ID: %d", linkID, userID)
 
-	if err := s.linkRepo.Delete(linkID, userID); err != nil {
+	link, err := s.linkRepo.GetByID(linkID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get link: %+v", err)
+		return err
+	}
+
+	if err := s.authorizer.AuthorizeLink(userID, link, authz.ActionEditLinks); err != nil {
+		return err
+	}
+
+	if err := s.linkRepo.Delete(linkID); err != nil {
 		logger.Errorf(ctx, "Failed to delete link: %+v", err)
 		return err
 	}
 
+	s.linkCache.InvalidateLink(ctx, link.DomainID, link.ShortCode)
+
 	logger.Infof(ctx, "Successfully deleted link ID: %d", linkID)
+
+	s.eventBus.Publish(ctx, events.Event{
+		Type:           events.LinkDeleted,
+		UserID:         link.UserID,
+		OrganizationID: link.OrganizationID,
+		Data:           link,
+	})
+	return nil
+}
+
+// ArchiveLink archives a link so it stops redirecting and no longer counts
+// towards the organization's link limit
+func (s *LinkService) ArchiveLink(linkID int64, userID int64) (*models.Link, error) {
+	ctx := context.Background()
+	logger.Infof(ctx, "Archiving link ID: %d for user ID: %d", linkID, userID)
+
+	link, err := s.linkRepo.GetByID(linkID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authorizer.AuthorizeLink(userID, link, authz.ActionEditLinks); err != nil {
+		return nil, err
+	}
+
+	if link.IsArchived() {
+		return link, nil
+	}
+
+	if err := s.linkRepo.SetArchived(link, models.ArchiveReasonUser); err != nil {
+		logger.Errorf(ctx, "Failed to archive link: %+v", err)
+		return nil, err
+	}
+
+	s.linkCache.InvalidateLink(ctx, link.DomainID, link.ShortCode)
+	return link, nil
+}
+
+// UnarchiveLink makes an archived link active again if the organization has
+// room for it under its link limit
+func (s *LinkService) UnarchiveLink(linkID int64, userID int64) (*models.Link, error) {
+	ctx := context.Background()
+	logger.Infof(ctx, "Unarchiving link ID: %d for user ID: %d", linkID, userID)
+
+	link, err := s.linkRepo.GetByID(linkID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authorizer.AuthorizeLink(userID, link, authz.ActionEditLinks); err != nil {
+		return nil, err
+	}
+
+	if !link.IsArchived() {
+		return link, nil
+	}
+
+	org, err := s.orgRepo.GetByID(link.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading organization: %w", err)
+	}
+	linkCount, err := s.linkRepo.CountByOrganizationID(org.ID)
+	if err != nil {
+		return nil, err
+	}
+	if linkCount >= org.GetLinkLimit() {
+		return nil, ErrLinkLimitReached
+	}
+
+	if err := s.linkRepo.SetArchived(link, ""); err != nil {
+		logger.Errorf(ctx, "Failed to unarchive link: %+v", err)
+		return nil, err
+	}
+
+	s.linkCache.InvalidateLink(ctx, link.DomainID, link.ShortCode)
+	return link, nil
+}
+
+// ConsumeRedirect counts a redirect against the link's click limit and
+// reports whether one was left. Links without a limit always redirect.
+func (s *LinkService) ConsumeRedirect(ctx context.Context, link *models.Link) (bool, error) {
+	if link.MaxClicks == nil {
+		return true, nil
+	}
+
+	ok, err := s.linkRepo.IncrementRedirectCount(link.ID)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		// The cached copy still counts redirects as left; reload it so later
+		// requests see the link as exhausted without trying to count
+		logger.Infof(ctx, "Link ID %d reached its limit of %d clicks", link.ID, *link.MaxClicks)
+		s.linkCache.InvalidateLink(ctx, link.DomainID, link.ShortCode)
+	}
+	return ok, nil
+}
+
+// HasRedirectsLeft reports whether the link has clicks left without
+// counting one. Links without a limit always do.
+func (s *LinkService) HasRedirectsLeft(link *models.Link) (bool, error) {
+	if link.MaxClicks == nil {
+		return true, nil
+	}
+	return s.linkRepo.HasRedirectsLeft(link.ID)
+}
+
+// UnlockLink checks a password entered for a protected link. Attempts are
+// limited per IP address and link. Each attempt is counted before the
+// password is checked, so concurrent guesses can't slip past the limit.
+func (s *LinkService) UnlockLink(ctx context.Context, link *models.Link, ipAddress, password string) error {
+	window := time.Duration(s.config.Unlock.WindowMinutes) * time.Minute
+	attempts, err := s.attempts.Increment(ctx, unlockAttemptsKey(link.ID, ipAddress), window)
+	if err != nil {
+		// Fail closed: without the counter passwords could be guessed freely
+		logger.Errorf(ctx, "Failed to record unlock attempt for link ID %d: %+v", link.ID, err)
+		return ErrTooManyUnlockAttempts
+	}
+	if attempts > int64(s.config.Unlock.MaxAttempts) {
+		return ErrTooManyUnlockAttempts
+	}
+
+	if !auth.CheckPassword(password, link.PasswordHash) {
+		return ErrIncorrectLinkPassword
+	}
 	return nil
 }
 
-// GetUserTags returns all tags for a user
-func (s *LinkService) GetUserTags(userID int64) ([]string, error) {
-	return s.linkRepo.GetUserTags(userID)
+// unlockAttemptsKey counts an IP address's unlock attempts for a link
+func unlockAttemptsKey(linkID int64, ipAddress string) string {
+	return "link_unlock:" + strconv.FormatInt(linkID, 10) + ":" + ipAddress
 }
 
-// GetLinksByTag returns links with a specific tag
-func (s *LinkService) GetLinksByTag(userID int64, tag string) ([]*models.Link, error) {
-	return s.linkRepo.GetByTag(userID, tag)
+// GetTags returns all tags used in an organization
+func (s *LinkService) GetTags(userID, orgID int64) ([]string, error) {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionViewLinks); err != nil {
+		return nil, err
+	}
+	return s.linkRepo.GetOrganizationTags(orgID)
+}
+
+// GetLinksByTag returns an organization's links with a specific tag
+func (s *LinkService) GetLinksByTag(userID, orgID int64, tag string) ([]*models.Link, error) {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionViewLinks); err != nil {
+		return nil, err
+	}
+	return s.linkRepo.GetByTag(orgID, tag)
 }
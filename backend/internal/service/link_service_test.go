@@ -0,0 +1,539 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/cache"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/ratelimit"
+	"github.com/shafikshaon/url_shortener/internal/urlcheck"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// timeArg matches a nullable time argument
+type timeArg struct {
+	want *time.Time
+}
+
+func (a timeArg) Match(v driver.Value) bool {
+	if a.want == nil {
+		return v == nil
+	}
+	t, ok := v.(time.Time)
+	return ok && t.Equal(*a.want)
+}
+
+// linkUpdateArgs are the arguments of a link update, in column order, with
+// starts_at checked
+func linkUpdateArgs(startsAt *time.Time) []driver.Value {
+	const columns, startsAtColumn = 26, 19
+	args := make([]driver.Value, columns)
+	for i := range args {
+		args[i] = sqlmock.AnyArg()
+	}
+	args[startsAtColumn] = timeArg{startsAt}
+	return args
+}
+
+func newTestLinkService(t *testing.T) (*LinkService, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	cfg := &config.Config{
+		Server: config.ServerConfig{BaseURL: "https://sho.rt"},
+		Unlock: config.LinkUnlockConfig{TTLMinutes: 60, MaxAttempts: 3, WindowMinutes: 15},
+	}
+	orgRepo := database.NewOrganizationRepository(db)
+	linkCache := cache.NewLinkCache(cache.NewLRUStore(100), time.Minute, time.Minute)
+	s := NewLinkService(database.NewLinkRepository(db), orgRepo, database.NewDomainRepository(db), authz.NewAuthorizer(orgRepo),
+		linkCache, events.NewBus(), ratelimit.NewMemoryStore(), urlcheck.Blocklists{}, cfg)
+	return s, mock
+}
+
+// expectLinkLoaded expects a link to be read by ID
+func expectLinkLoaded(mock sqlmock.Sqlmock, link *models.Link) {
+	mock.ExpectQuery(`SELECT \* FROM "links" WHERE "links"."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "organization_id", "short_code", "destination_url", "starts_at", "max_clicks", "fallback_url", "redirect_count"}).
+			AddRow(link.ID, link.UserID, link.OrganizationID, link.ShortCode, link.DestinationURL, link.StartsAt, link.MaxClicks, link.FallbackURL, link.RedirectCount))
+}
+
+// expectMember expects a membership lookup answering with the given role
+func expectMember(mock sqlmock.Sqlmock, orgID, userID int64, role models.OrganizationRole) {
+	mock.ExpectQuery(`SELECT \* FROM "organization_members" WHERE organization_id = \$1 AND user_id = \$2`).
+		WithArgs(orgID, userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"organization_id", "user_id", "role"}).AddRow(orgID, userID, role))
+}
+
+func TestUpdateLinkStartsAt(t *testing.T) {
+	scheduled := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	moved := time.Date(2031, 6, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		startsAt *time.Time
+		want     *time.Time
+	}{
+		{name: "omitted keeps the schedule", startsAt: nil, want: &scheduled},
+		{name: "new start replaces it", startsAt: &moved, want: &moved},
+		{name: "zero time removes it", startsAt: &time.Time{}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestLinkService(t)
+			existing := &models.Link{ID: 5, UserID: 7, OrganizationID: 3, ShortCode: "launch", DestinationURL: "https://example.com/", StartsAt: &scheduled}
+
+			expectLinkLoaded(mock, existing)
+			expectMember(mock, 3, 7, models.RoleEditor)
+			mock.ExpectQuery(`SELECT \* FROM "domains" WHERE hostname = \$1`).
+				WithArgs("example.com", 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE "links" SET .*"starts_at"=\$20,`).
+				WithArgs(linkUpdateArgs(tt.want)...).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			link := &models.Link{ID: 5, DestinationURL: "https://example.com/", StartsAt: tt.startsAt}
+			if err := s.UpdateLink(link, 7, nil, nil); err != nil {
+				t.Fatalf("UpdateLink() error = %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+
+			if (link.StartsAt == nil) != (tt.want == nil) || (tt.want != nil && !link.StartsAt.Equal(*tt.want)) {
+				t.Errorf("starts_at = %v, want %v", link.StartsAt, tt.want)
+			}
+		})
+	}
+}
+
+// cheapPasswordHash hashes a link password at bcrypt's lowest cost to keep
+// tests fast
+func cheapPasswordHash(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hashing password: %v", err)
+	}
+	return string(hash)
+}
+
+func TestUnlockLink(t *testing.T) {
+	s, _ := newTestLinkService(t)
+	link := &models.Link{ID: 10, PasswordHash: cheapPasswordHash(t, "open sesame")}
+	ctx := context.Background()
+
+	// Each IP gets MaxAttempts passwords per window, right or wrong
+	attempts := []struct {
+		ip       string
+		password string
+		wantErr  error
+	}{
+		{ip: "203.0.113.9", password: "guess", wantErr: ErrIncorrectLinkPassword},
+		{ip: "203.0.113.9", password: "open sesame"},
+		{ip: "203.0.113.9", password: "guess", wantErr: ErrIncorrectLinkPassword},
+		{ip: "203.0.113.9", password: "open sesame", wantErr: ErrTooManyUnlockAttempts},
+		{ip: "198.51.100.4", password: "open sesame"},
+	}
+	for i, tt := range attempts {
+		if err := s.UnlockLink(ctx, link, tt.ip, tt.password); !errors.Is(err, tt.wantErr) {
+			t.Errorf("attempt %d from %s: UnlockLink() error = %v, want %v", i+1, tt.ip, err, tt.wantErr)
+		}
+	}
+}
+
+func TestUnlockLinkCountsConcurrentAttempts(t *testing.T) {
+	s, _ := newTestLinkService(t)
+	link := &models.Link{ID: 10, PasswordHash: cheapPasswordHash(t, "open sesame")}
+
+	var wg sync.WaitGroup
+	results := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- s.UnlockLink(context.Background(), link, "203.0.113.9", "guess")
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	checked := 0
+	for err := range results {
+		switch {
+		case errors.Is(err, ErrIncorrectLinkPassword):
+			checked++
+		case !errors.Is(err, ErrTooManyUnlockAttempts):
+			t.Errorf("UnlockLink() error = %v", err)
+		}
+	}
+	if checked != 3 {
+		t.Errorf("%d passwords were checked, want 3", checked)
+	}
+}
+
+func TestHashLinkPassword(t *testing.T) {
+	tests := []struct {
+		password string
+		wantErr  error
+	}{
+		{password: "abc", wantErr: ErrInvalidLinkPassword},
+		{password: "abcd"},
+		{password: strings.Repeat("p", 72)},
+		{password: strings.Repeat("p", 73), wantErr: ErrInvalidLinkPassword},
+	}
+
+	for _, tt := range tests {
+		hash, err := hashLinkPassword(tt.password)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("hashLinkPassword(%d chars) error = %v, want %v", len(tt.password), err, tt.wantErr)
+			continue
+		}
+		if err == nil && hash == tt.password {
+			t.Errorf("hashLinkPassword(%d chars) stored the password", len(tt.password))
+		}
+	}
+}
+
+func TestConsumeRedirect(t *testing.T) {
+	limit := 3
+
+	tests := []struct {
+		name      string
+		maxClicks *int
+		counted   int64 // rows updated when counting, -1 if the link isn't counted
+		want      bool
+	}{
+		{name: "no click limit", counted: -1, want: true},
+		{name: "clicks left", maxClicks: &limit, counted: 1, want: true},
+		{name: "out of clicks", maxClicks: &limit, counted: 0, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestLinkService(t)
+			if tt.counted >= 0 {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "links" SET "redirect_count"=redirect_count \+ 1 WHERE \(id = \$1 AND \(max_clicks IS NULL OR redirect_count < max_clicks\)\)`).
+					WithArgs(10).
+					WillReturnResult(sqlmock.NewResult(0, tt.counted))
+				mock.ExpectCommit()
+			}
+
+			link := &models.Link{ID: 10, ShortCode: "sale", MaxClicks: tt.maxClicks}
+			got, err := s.ConsumeRedirect(context.Background(), link)
+			if err != nil {
+				t.Fatalf("ConsumeRedirect() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ConsumeRedirect() = %v, want %v", got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestValidateVariants(t *testing.T) {
+	variant := func(name, destination string, weight int) models.LinkVariant {
+		return models.LinkVariant{Name: name, DestinationURL: destination, Weight: weight}
+	}
+
+	tests := []struct {
+		name     string
+		variants models.LinkVariants
+		wantErr  bool
+	}{
+		{name: "no split"},
+		{name: "two variants", variants: models.LinkVariants{variant("a", "example.com/a", 1), variant("b", "example.com/b", 2)}},
+		{name: "a single variant", variants: models.LinkVariants{variant("a", "example.com/a", 1)}, wantErr: true},
+		{name: "duplicate names", variants: models.LinkVariants{variant("a", "example.com/a", 1), variant(" a ", "example.com/b", 1)}, wantErr: true},
+		{name: "zero weight", variants: models.LinkVariants{variant("a", "example.com/a", 0), variant("b", "example.com/b", 1)}, wantErr: true},
+		{name: "weight too high", variants: models.LinkVariants{variant("a", "example.com/a", models.MaxVariantWeight+1), variant("b", "example.com/b", 1)}, wantErr: true},
+		{name: "no destination", variants: models.LinkVariants{variant("a", "example.com/a", 1), variant("b", " ", 1)}, wantErr: true},
+		{name: "unsafe destination", variants: models.LinkVariants{variant("a", "example.com/a", 1), variant("b", "javascript:alert(1)", 1)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateVariants(&models.Link{Variants: tt.variants})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidVariants) {
+					t.Fatalf("validateVariants() error = %v, want %v", err, ErrInvalidVariants)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateVariants() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateVariantsNormalizesDestinations(t *testing.T) {
+	link := &models.Link{Variants: models.LinkVariants{
+		{Name: " a ", DestinationURL: "Example.com/a", Weight: 1},
+		{Name: "b", DestinationURL: "https://example.com/b", Weight: 1},
+	}}
+	if err := validateVariants(link); err != nil {
+		t.Fatalf("validateVariants() error = %v", err)
+	}
+	if got := link.Variants[0]; got.Name != "a" || got.DestinationURL != "https://example.com/a" {
+		t.Errorf("variant = %q to %s, want a to https://example.com/a", got.Name, got.DestinationURL)
+	}
+}
+
+func TestValidateDeepLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		deepLink *models.DeepLink
+		wantErr  bool
+	}{
+		{name: "app URIs with store fallbacks", deepLink: &models.DeepLink{
+			IOSAppURI: "myapp://product/42", IOSStoreURL: "apps.apple.com/app/id1",
+			AndroidAppURI: "intent://product/42#Intent;scheme=myapp;end", AndroidStoreURL: "https://play.google.com/store/apps/details?id=com.example",
+		}},
+		{name: "no scheme", deepLink: &models.DeepLink{IOSAppURI: "product/42"}, wantErr: true},
+		{name: "javascript scheme", deepLink: &models.DeepLink{AndroidAppURI: "JavaScript:alert(1)"}, wantErr: true},
+		{name: "data scheme", deepLink: &models.DeepLink{IOSAppURI: "data:text/html,hi"}, wantErr: true},
+		{name: "store URL with another scheme", deepLink: &models.DeepLink{IOSAppURI: "myapp://x", IOSStoreURL: "itms-apps://apps.apple.com/app/id1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDeepLink(&models.Link{DeepLink: tt.deepLink})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidDeepLink) {
+					t.Fatalf("validateDeepLink() error = %v, want %v", err, ErrInvalidDeepLink)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateDeepLink() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateDeepLinkNormalizes(t *testing.T) {
+	link := &models.Link{DeepLink: &models.DeepLink{}}
+	if err := validateDeepLink(link); err != nil || link.DeepLink != nil {
+		t.Errorf("validateDeepLink() with nothing set = %v, deep link %v; want it dropped", err, link.DeepLink)
+	}
+
+	link = &models.Link{DeepLink: &models.DeepLink{IOSAppURI: "myapp://x", IOSStoreURL: "Apps.Apple.com/app/id1"}}
+	if err := validateDeepLink(link); err != nil {
+		t.Fatalf("validateDeepLink() error = %v", err)
+	}
+	if link.DeepLink.IOSStoreURL != "https://apps.apple.com/app/id1" {
+		t.Errorf("store URL = %s, want https://apps.apple.com/app/id1", link.DeepLink.IOSStoreURL)
+	}
+}
+
+func TestValidateQueryOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		link         models.Link
+		wantErr      bool
+		wantConflict string
+		wantUTM      *models.UTMParams
+	}{
+		{name: "defaults to keeping the destination's values", wantConflict: models.QueryConflictDestination},
+		{name: "short link wins", link: models.Link{QueryConflict: models.QueryConflictShortLink}, wantConflict: models.QueryConflictShortLink},
+		{name: "unknown policy", link: models.Link{QueryConflict: "merge"}, wantErr: true},
+		{name: "UTM trimmed", link: models.Link{UTM: &models.UTMParams{Source: " mail ", Campaign: "spring"}},
+			wantConflict: models.QueryConflictDestination, wantUTM: &models.UTMParams{Source: "mail", Campaign: "spring"}},
+		{name: "blank UTM dropped", link: models.Link{UTM: &models.UTMParams{Source: "  "}}, wantConflict: models.QueryConflictDestination},
+		{name: "overlong UTM", link: models.Link{UTM: &models.UTMParams{Term: strings.Repeat("x", models.MaxUTMParamLength+1)}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateQueryOptions(&tt.link)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidQueryOptions) {
+					t.Fatalf("validateQueryOptions() error = %v, want %v", err, ErrInvalidQueryOptions)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateQueryOptions() error = %v", err)
+			}
+			if tt.link.QueryConflict != tt.wantConflict {
+				t.Errorf("query conflict = %s, want %s", tt.link.QueryConflict, tt.wantConflict)
+			}
+			if (tt.link.UTM == nil) != (tt.wantUTM == nil) || (tt.wantUTM != nil && *tt.link.UTM != *tt.wantUTM) {
+				t.Errorf("UTM = %+v, want %+v", tt.link.UTM, tt.wantUTM)
+			}
+		})
+	}
+}
+
+func TestValidatePreview(t *testing.T) {
+	tests := []struct {
+		name    string
+		preview *models.LinkPreview
+		want    *models.LinkPreview
+		wantErr bool
+	}{
+		{name: "no preview"},
+		{name: "blank preview dropped", preview: &models.LinkPreview{Title: " ", Description: "\n"}},
+		{name: "trimmed and normalized", preview: &models.LinkPreview{Title: " Sale ", ImageURL: " CDN.example.com/card.png "},
+			want: &models.LinkPreview{Title: "Sale", ImageURL: "https://cdn.example.com/card.png"}},
+		{name: "title at the limit", preview: &models.LinkPreview{Title: strings.Repeat("é", models.MaxPreviewTitleLength)},
+			want: &models.LinkPreview{Title: strings.Repeat("é", models.MaxPreviewTitleLength)}},
+		{name: "overlong title", preview: &models.LinkPreview{Title: strings.Repeat("x", models.MaxPreviewTitleLength+1)}, wantErr: true},
+		{name: "overlong description", preview: &models.LinkPreview{Description: strings.Repeat("x", models.MaxPreviewDescriptionLength+1)}, wantErr: true},
+		{name: "unsafe image URL", preview: &models.LinkPreview{ImageURL: "javascript:alert(1)"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := &models.Link{Preview: tt.preview}
+			err := validatePreview(link)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPreview) {
+					t.Fatalf("validatePreview() error = %v, want %v", err, ErrInvalidPreview)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validatePreview() error = %v", err)
+			}
+			if (link.Preview == nil) != (tt.want == nil) || (tt.want != nil && *link.Preview != *tt.want) {
+				t.Errorf("preview = %+v, want %+v", link.Preview, tt.want)
+			}
+		})
+	}
+}
+
+// listedHosts is a blocklist of hosts, each with its reason
+type listedHosts map[string]string
+
+func (l listedHosts) Check(_ context.Context, u *url.URL) (*urlcheck.Match, error) {
+	if reason, ok := l[u.Hostname()]; ok {
+		return &urlcheck.Match{List: "test", Reason: reason}, nil
+	}
+	return nil, nil
+}
+
+// expectDomainLookup expects a custom domain lookup by hostname, answering
+// with a domain in the given status or none when status is empty
+func expectDomainLookup(mock sqlmock.Sqlmock, hostname string, status models.DomainStatus) {
+	rows := sqlmock.NewRows([]string{"id", "hostname", "status"})
+	if status != "" {
+		rows.AddRow(1, hostname, status)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "domains" WHERE hostname = \$1`).
+		WithArgs(hostname, 1).
+		WillReturnRows(rows)
+}
+
+func TestCheckDestinations(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination string
+		lookup      bool                // whether the host is looked up as a custom domain
+		status      models.DomainStatus // of the custom domain found, if any
+		wantErr     error
+		wantFlag    string
+	}{
+		{name: "safe destination", destination: "https://example.com", lookup: true},
+		{name: "our own short link", destination: "https://sho.rt/abc", wantErr: ErrInvalidDestination},
+		{name: "another shortener", destination: "https://bit.ly/abc", wantErr: ErrInvalidDestination},
+		{name: "configured shortener", destination: "https://go.corp.example/abc", wantErr: ErrInvalidDestination},
+		{name: "verified custom domain", destination: "https://links.example.org/abc", lookup: true, status: models.DomainVerified, wantErr: ErrInvalidDestination},
+		{name: "unverified custom domain", destination: "https://links.example.net/abc", lookup: true, status: models.DomainPending},
+		{name: "blocklisted destination flagged", destination: "https://phish.example", lookup: true, wantFlag: "phish.example is phishing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestLinkService(t)
+			s.shorteners = []string{"go.corp.example"}
+			s.blocklist = listedHosts{"phish.example": "phish.example is phishing"}
+			if tt.lookup {
+				expectDomainLookup(mock, urlcheck.Host(tt.destination), tt.status)
+			}
+
+			now := time.Now()
+			link := &models.Link{DestinationURL: tt.destination, FlaggedAt: &now, FlagReason: "old reason"}
+			err := s.checkDestinations(context.Background(), link)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkDestinations() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			// Saving again clears a flag the link no longer deserves
+			if link.FlagReason != tt.wantFlag || (link.FlaggedAt != nil) != (tt.wantFlag != "") {
+				t.Errorf("flag = %q at %v, want %q", link.FlagReason, link.FlaggedAt, tt.wantFlag)
+			}
+		})
+	}
+}
+
+func TestCheckDestinationsCoversEveryDestination(t *testing.T) {
+	s, mock := newTestLinkService(t)
+	s.blocklist = listedHosts{"cdn.phish.example": "cdn.phish.example is phishing"}
+
+	fallback := "https://example.com/sold-out"
+	link := &models.Link{
+		DestinationURL: "https://example.com",
+		FallbackURL:    &fallback,
+		RedirectRules:  models.RedirectRules{{Name: "mobile", DestinationURL: "https://m.example.com"}},
+		Preview:        &models.LinkPreview{ImageURL: "https://cdn.phish.example/card.png"},
+	}
+	for _, hostname := range []string{"example.com", "example.com", "m.example.com", "cdn.phish.example"} {
+		expectDomainLookup(mock, hostname, "")
+	}
+
+	if err := s.checkDestinations(context.Background(), link); err != nil {
+		t.Fatalf("checkDestinations() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if link.FlaggedAt == nil || link.FlagReason != "cdn.phish.example is phishing" {
+		t.Errorf("flag = %q at %v, want the preview image's", link.FlagReason, link.FlaggedAt)
+	}
+}
+
+func TestCheckDestinationsIgnoresFailingBlocklists(t *testing.T) {
+	s, mock := newTestLinkService(t)
+	s.blocklist = urlcheck.Blocklists{failingBlocklist{}}
+	expectDomainLookup(mock, "example.com", "")
+
+	link := &models.Link{DestinationURL: "https://example.com"}
+	if err := s.checkDestinations(context.Background(), link); err != nil {
+		t.Fatalf("checkDestinations() error = %v", err)
+	}
+	if link.FlaggedAt != nil {
+		t.Errorf("link flagged %q when the blocklist failed", link.FlagReason)
+	}
+}
+
+// failingBlocklist fails every check, as when a reputation service is down
+type failingBlocklist struct{}
+
+func (failingBlocklist) Check(context.Context, *url.URL) (*urlcheck.Match, error) {
+	return nil, errors.New("reputation service down")
+}
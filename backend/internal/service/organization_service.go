@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/cache"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrInvalidOrganizationName is returned for a blank organization name
+	ErrInvalidOrganizationName = errors.New("organization name is required")
+	// ErrInvalidRole is returned for a role that doesn't exist
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrPersonalOrganization is returned when deleting a personal
+	// organization or inviting others to one
+	ErrPersonalOrganization = errors.New("personal organizations cannot be deleted or shared")
+	// ErrLastOwner is returned when a change would leave an organization
+	// without an owner
+	ErrLastOwner = errors.New("organization must have at least one owner")
+	// ErrInvalidInvitation is returned for an unknown, used or expired invitation
+	ErrInvalidInvitation = errors.New("invitation is invalid or has expired")
+	// ErrInvitationEmailMismatch is returned when accepting an invitation
+	// that was sent to another email address
+	ErrInvitationEmailMismatch = errors.New("invitation was sent to a different email address")
+	// ErrAlreadyMember is returned when accepting an invitation to an
+	// organization the user already belongs to
+	ErrAlreadyMember = errors.New("already a member of this organization")
+)
+
+type OrganizationService struct {
+	orgRepo    *database.OrganizationRepository
+	userRepo   *database.UserRepository
+	authorizer *authz.Authorizer
+	linkCache  *cache.LinkCache
+}
+
+func NewOrganizationService(orgRepo *database.OrganizationRepository, userRepo *database.UserRepository, authorizer *authz.Authorizer, linkCache *cache.LinkCache) *OrganizationService {
+	return &OrganizationService{
+		orgRepo:    orgRepo,
+		userRepo:   userRepo,
+		authorizer: authorizer,
+		linkCache:  linkCache,
+	}
+}
+
+// CreateOrganization creates a team organization owned by the user. Team
+// organizations start on the free tier; the owner's subscription can then
+// cover as many as its tier allows (see BillingService), so its limits
+// can't be multiplied by creating more organizations.
+func (s *OrganizationService) CreateOrganization(userID int64, name string) (*models.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidOrganizationName
+	}
+
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, err
+	}
+
+	org := &models.Organization{
+		Name:             name,
+		OwnerID:          userID,
+		SubscriptionTier: models.TierFree,
+	}
+	if err := s.orgRepo.Create(org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// ListOrganizations lists the organizations the user belongs to
+func (s *OrganizationService) ListOrganizations(userID int64) ([]*models.Organization, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.orgRepo.EnsurePersonal(user); err != nil {
+		return nil, err
+	}
+	return s.orgRepo.GetByUserID(userID)
+}
+
+// GetOrganization retrieves an organization the user belongs to
+func (s *OrganizationService) GetOrganization(orgID, userID int64) (*models.Organization, *models.OrganizationMember, error) {
+	member, err := s.authorizer.Authorize(userID, orgID, authz.ActionViewLinks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return org, member, nil
+}
+
+// UpdateOrganization renames an organization
+func (s *OrganizationService) UpdateOrganization(orgID, userID int64, name string) (*models.Organization, error) {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageOrganization); err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidOrganizationName
+	}
+
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	org.Name = name
+	if err := s.orgRepo.Update(org); err != nil {
+		return nil, fmt.Errorf("error updating organization: %w", err)
+	}
+	return org, nil
+}
+
+// DeleteOrganization deletes a team organization along with its links,
+// webhooks and domains
+func (s *OrganizationService) DeleteOrganization(orgID, userID int64) error {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionDeleteOrganization); err != nil {
+		return err
+	}
+
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		return err
+	}
+	if org.IsPersonal {
+		return ErrPersonalOrganization
+	}
+
+	ctx := context.Background()
+	logger.Infof(ctx, "Deleting organization ID: %d by user ID: %d", orgID, userID)
+	links, domains, err := s.orgRepo.Delete(orgID)
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		s.linkCache.InvalidateLink(ctx, link.DomainID, link.ShortCode)
+	}
+	for _, domain := range domains {
+		s.linkCache.InvalidateDomain(ctx, domain.Hostname)
+	}
+	return nil
+}
+
+// ListMembers lists an organization's members
+func (s *OrganizationService) ListMembers(orgID, userID int64) ([]*models.OrganizationMember, error) {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionViewMembers); err != nil {
+		return nil, err
+	}
+	return s.orgRepo.GetMembers(orgID)
+}
+
+// UpdateMemberRole changes a member's role. Only owners may grant or revoke ownership.
+func (s *OrganizationService) UpdateMemberRole(orgID, userID, memberUserID int64, role models.OrganizationRole) error {
+	actor, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageMembers)
+	if err != nil {
+		return err
+	}
+	if !role.IsValid() {
+		return ErrInvalidRole
+	}
+
+	target, err := s.orgRepo.GetMember(orgID, memberUserID)
+	if err != nil {
+		return err
+	}
+
+	if (role == models.RoleOwner || target.Role == models.RoleOwner) && actor.Role != models.RoleOwner {
+		return authz.ErrForbidden
+	}
+	if target.Role == models.RoleOwner && role != models.RoleOwner {
+		if err := s.ensureAnotherOwner(orgID); err != nil {
+			return err
+		}
+	}
+
+	return s.orgRepo.UpdateMemberRole(orgID, memberUserID, role)
+}
+
+// RemoveMember removes a member. Members may always remove themselves.
+func (s *OrganizationService) RemoveMember(orgID, userID, memberUserID int64) error {
+	target, err := s.orgRepo.GetMember(orgID, memberUserID)
+	if err != nil {
+		return err
+	}
+
+	if memberUserID != userID {
+		actor, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageMembers)
+		if err != nil {
+			return err
+		}
+		if target.Role == models.RoleOwner && actor.Role != models.RoleOwner {
+			return authz.ErrForbidden
+		}
+	}
+
+	if target.Role == models.RoleOwner {
+		if err := s.ensureAnotherOwner(orgID); err != nil {
+			return err
+		}
+	}
+
+	return s.orgRepo.RemoveMember(orgID, memberUserID)
+}
+
+// ensureAnotherOwner prevents an organization from losing its last owner
+func (s *OrganizationService) ensureAnotherOwner(orgID int64) error {
+	owners, err := s.orgRepo.CountMembersWithRole(orgID, models.RoleOwner)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// CreateInvitation invites an email address to join the organization. The
+// returned token is only available at creation time; only its hash is stored.
+func (s *OrganizationService) CreateInvitation(orgID, userID int64, email string, role models.OrganizationRole) (*models.OrganizationInvitation, string, error) {
+	actor, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageMembers)
+	if err != nil {
+		return nil, "", err
+	}
+	if !role.IsValid() {
+		return nil, "", ErrInvalidRole
+	}
+	if !actor.Role.AtLeast(role) {
+		return nil, "", authz.ErrForbidden
+	}
+
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		return nil, "", err
+	}
+	if org.IsPersonal {
+		return nil, "", ErrPersonalOrganization
+	}
+
+	token, err := generateInvitationToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating invitation token: %w", err)
+	}
+
+	invitation := &models.OrganizationInvitation{
+		OrganizationID: orgID,
+		Email:          strings.ToLower(strings.TrimSpace(email)),
+		Role:           role,
+		TokenHash:      hashToken(token),
+		InvitedBy:      userID,
+		ExpiresAt:      time.Now().UTC().Add(invitationTTL),
+	}
+	if err := s.orgRepo.CreateInvitation(invitation); err != nil {
+		return nil, "", err
+	}
+
+	logger.Infof(context.Background(), "User ID %d invited %s to organization ID %d as %s", userID, invitation.Email, orgID, role)
+	return invitation, token, nil
+}
+
+// ListInvitations lists an organization's pending invitations
+func (s *OrganizationService) ListInvitations(orgID, userID int64) ([]*models.OrganizationInvitation, error) {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageMembers); err != nil {
+		return nil, err
+	}
+	return s.orgRepo.GetPendingInvitations(orgID)
+}
+
+// RevokeInvitation deletes a pending invitation
+func (s *OrganizationService) RevokeInvitation(orgID, userID, invitationID int64) error {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageMembers); err != nil {
+		return err
+	}
+	return s.orgRepo.DeleteInvitation(invitationID, orgID)
+}
+
+// AcceptInvitation adds the user to the invitation's organization. The
+// invitation must have been sent to the user's email address.
+func (s *OrganizationService) AcceptInvitation(userID int64, token string) (*models.Organization, error) {
+	invitation, err := s.orgRepo.GetInvitationByTokenHash(hashToken(token))
+	if errors.Is(err, database.ErrInvitationNotFound) {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !invitation.IsPending() {
+		return nil, ErrInvalidInvitation
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return nil, ErrInvitationEmailMismatch
+	}
+
+	_, err = s.orgRepo.GetMember(invitation.OrganizationID, userID)
+	if err == nil {
+		return nil, ErrAlreadyMember
+	}
+	if !errors.Is(err, database.ErrMemberNotFound) {
+		return nil, err
+	}
+
+	if err := s.orgRepo.AcceptInvitation(invitation, userID); err != nil {
+		if errors.Is(err, database.ErrInvitationNotFound) {
+			// Accepted by a concurrent request
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+
+	return s.orgRepo.GetByID(invitation.OrganizationID)
+}
+
+// generateInvitationToken creates a random invitation token
+func generateInvitationToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// hashToken returns the hex SHA-256 of a token for storage and lookup
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/cache"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+func newTestOrganizationService(t *testing.T) (*OrganizationService, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	orgRepo := database.NewOrganizationRepository(db)
+	linkCache := cache.NewLinkCache(cache.NewLRUStore(100), time.Minute, time.Minute)
+	return NewOrganizationService(orgRepo, database.NewUserRepository(db), authz.NewAuthorizer(orgRepo), linkCache), mock
+}
+
+// expectOwnerCount expects the count of an organization's owners
+func expectOwnerCount(mock sqlmock.Sqlmock, orgID int64, owners int) {
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "organization_members" WHERE organization_id = \$1 AND role = \$2`).
+		WithArgs(orgID, models.RoleOwner).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(owners))
+}
+
+func TestUpdateMemberRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		actorRole  models.OrganizationRole
+		targetRole models.OrganizationRole
+		role       models.OrganizationRole
+		owners     int // 0 when the owners aren't counted
+		wantErr    error
+	}{
+		{name: "admin promotes a viewer", actorRole: models.RoleAdmin, targetRole: models.RoleViewer, role: models.RoleEditor},
+		{name: "admin grants ownership", actorRole: models.RoleAdmin, targetRole: models.RoleEditor, role: models.RoleOwner, wantErr: authz.ErrForbidden},
+		{name: "admin demotes an owner", actorRole: models.RoleAdmin, targetRole: models.RoleOwner, role: models.RoleAdmin, wantErr: authz.ErrForbidden},
+		{name: "owner demotes another owner", actorRole: models.RoleOwner, targetRole: models.RoleOwner, role: models.RoleAdmin, owners: 2},
+		{name: "owner demotes the last owner", actorRole: models.RoleOwner, targetRole: models.RoleOwner, role: models.RoleAdmin, owners: 1, wantErr: ErrLastOwner},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestOrganizationService(t)
+			expectMember(mock, 3, 7, tt.actorRole)
+			expectMember(mock, 3, 8, tt.targetRole)
+			if tt.owners > 0 {
+				expectOwnerCount(mock, 3, tt.owners)
+			}
+			if tt.wantErr == nil {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "organization_members" SET "role"=\$1`).
+					WithArgs(tt.role, sqlmock.AnyArg(), 3, 8).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			if err := s.UpdateMemberRole(3, 7, 8, tt.role); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateMemberRole() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdateMemberRoleRejectsUnknownRoles(t *testing.T) {
+	s, mock := newTestOrganizationService(t)
+	expectMember(mock, 3, 7, models.RoleOwner)
+
+	if err := s.UpdateMemberRole(3, 7, 8, models.OrganizationRole("guest")); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("UpdateMemberRole() error = %v, want %v", err, ErrInvalidRole)
+	}
+}
+
+func TestCreateInvitationLimitsRoleToTheInviters(t *testing.T) {
+	s, mock := newTestOrganizationService(t)
+	expectMember(mock, 3, 7, models.RoleAdmin)
+
+	if _, _, err := s.CreateInvitation(3, 7, "new@example.com", models.RoleOwner); !errors.Is(err, authz.ErrForbidden) {
+		t.Fatalf("CreateInvitation() error = %v, want %v", err, authz.ErrForbidden)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		email     string
+		expiresAt time.Time
+		wantErr   error
+	}{
+		{name: "expired", email: "ada@example.com", expiresAt: past, wantErr: ErrInvalidInvitation},
+		{name: "sent to someone else", email: "grace@example.com", expiresAt: future, wantErr: ErrInvitationEmailMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestOrganizationService(t)
+			mock.ExpectQuery(`SELECT \* FROM "organization_invitations" WHERE token_hash = \$1`).
+				WithArgs(hashToken("token"), 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "organization_id", "email", "role", "expires_at"}).
+					AddRow(1, 3, tt.email, models.RoleEditor, tt.expiresAt))
+			if tt.wantErr != ErrInvalidInvitation {
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).
+					WithArgs(7, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "Ada@Example.com"))
+			}
+
+			if _, err := s.AcceptInvitation(7, "token"); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AcceptInvitation() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestAcceptInvitationRejectsUnknownTokens(t *testing.T) {
+	s, mock := newTestOrganizationService(t)
+	mock.ExpectQuery(`SELECT \* FROM "organization_invitations" WHERE token_hash = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if _, err := s.AcceptInvitation(7, "token"); !errors.Is(err, ErrInvalidInvitation) {
+		t.Fatalf("AcceptInvitation() error = %v, want %v", err, ErrInvalidInvitation)
+	}
+}
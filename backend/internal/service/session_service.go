@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+)
+
+// ErrInvalidRefreshToken is returned for unknown, expired, revoked or
+// already-used refresh tokens
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// TokenPair is the credentials handed to a client for a session
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int // access token lifetime in seconds
+	Session      *models.Session
+}
+
+// ClientInfo identifies the device behind a request
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+type SessionService struct {
+	sessionRepo *database.SessionRepository
+	userRepo    *database.UserRepository
+	jwtService  *auth.JWTService
+	config      *config.Config
+}
+
+func NewSessionService(sessionRepo *database.SessionRepository, userRepo *database.UserRepository, jwtService *auth.JWTService, cfg *config.Config) *SessionService {
+	return &SessionService{
+		sessionRepo: sessionRepo,
+		userRepo:    userRepo,
+		jwtService:  jwtService,
+		config:      cfg,
+	}
+}
+
+func (s *SessionService) refreshTTL() time.Duration {
+	return time.Duration(s.config.JWT.RefreshTTLHours) * time.Hour
+}
+
+// Start opens a new session for a user who has just authenticated
+func (s *SessionService) Start(user *models.User, client ClientInfo) (*TokenPair, error) {
+	refreshToken, hash, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("error generating refresh token: %w", err)
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hash,
+		Device:           describeDevice(client.UserAgent),
+		IPAddress:        client.IPAddress,
+		UserAgent:        client.UserAgent,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(s.refreshTTL()),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return s.issue(user, session, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new token pair, rotating the
+// refresh token. Presenting a token that was already rotated out revokes
+// the session, since either the client or an attacker holds a stolen copy.
+func (s *SessionService) Refresh(refreshToken string, client ClientInfo) (*TokenPair, *models.User, error) {
+	ctx := context.Background()
+
+	hash := auth.HashRefreshToken(refreshToken)
+	session, err := s.sessionRepo.GetByTokenHash(hash)
+	if err != nil {
+		if errors.Is(err, database.ErrSessionNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+	if !session.IsActive() {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if session.RefreshTokenHash != hash {
+		logger.Warnf(ctx, "Refresh token reuse detected for session ID %d, revoking it", session.ID)
+		if err := s.sessionRepo.Revoke(session.ID, session.UserID); err != nil {
+			logger.Errorf(ctx, "Failed to revoke session ID %d: %+v", session.ID, err)
+		}
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetByID(session.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	newToken, newHash, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating refresh token: %w", err)
+	}
+
+	now := time.Now()
+	session.Device = describeDevice(client.UserAgent)
+	session.IPAddress = client.IPAddress
+	session.UserAgent = client.UserAgent
+	session.LastSeenAt = now
+	session.ExpiresAt = now.Add(s.refreshTTL())
+
+	rotated, err := s.sessionRepo.Rotate(session, hash, newHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !rotated {
+		// Another request rotated or revoked the session first
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	session.RefreshTokenHash = newHash
+
+	pair, err := s.issue(user, session, newToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, user, nil
+}
+
+func (s *SessionService) issue(user *models.User, session *models.Session, refreshToken string) (*TokenPair, error) {
+	accessToken, err := s.jwtService.GenerateToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.jwtService.AccessTokenTTL().Seconds()),
+		Session:      session,
+	}, nil
+}
+
+// ListSessions lists the user's active sessions
+func (s *SessionService) ListSessions(userID int64) ([]*models.Session, error) {
+	return s.sessionRepo.ListActiveByUserID(userID)
+}
+
+// Revoke signs out one of the user's sessions
+func (s *SessionService) Revoke(userID, sessionID int64) error {
+	logger.Infof(context.Background(), "Revoking session ID: %d for user ID: %d", sessionID, userID)
+	return s.sessionRepo.Revoke(sessionID, userID)
+}
+
+// RevokeOthers signs out every session of the user except keepID
+func (s *SessionService) RevokeOthers(userID, keepID int64) (int64, error) {
+	logger.Infof(context.Background(), "Revoking all sessions for user ID: %d except %d", userID, keepID)
+	return s.sessionRepo.RevokeAllByUserID(userID, keepID)
+}
+
+// RevokeAll signs out every session of the user
+func (s *SessionService) RevokeAll(userID int64) error {
+	logger.Infof(context.Background(), "Revoking all sessions for user ID: %d", userID)
+	_, err := s.sessionRepo.RevokeAllByUserID(userID, 0)
+	return err
+}
+
+// describeDevice summarizes a user agent as "<browser> on <os>" for the
+// session list
+func describeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case ua != "":
+		// Non-browser clients such as curl or SDKs
+		browser = strings.SplitN(userAgent, "/", 2)[0]
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		os = "iOS"
+	case strings.Contains(ua, "android"):
+		os = "Android"
+	case strings.Contains(ua, "windows"):
+		os = "Windows"
+	case strings.Contains(ua, "mac os") || strings.Contains(ua, "macintosh"):
+		os = "macOS"
+	case strings.Contains(ua, "linux"):
+		os = "Linux"
+	}
+
+	device := browser
+	if os != "" {
+		device += " on " + os
+	}
+	if len(device) > 100 {
+		device = device[:100]
+	}
+	return device
+}
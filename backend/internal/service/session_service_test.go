@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+)
+
+func newTestSessionService(t *testing.T) (*SessionService, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: "test-secret", AccessTTLMinutes: 15, RefreshTTLHours: 720}}
+	return NewSessionService(database.NewSessionRepository(db), database.NewUserRepository(db), auth.NewJWTService(cfg), cfg), mock
+}
+
+// expectSessionByToken expects session 4 of user 7 to be looked up by a
+// refresh token hash
+func expectSessionByToken(mock sqlmock.Sqlmock, hash, currentHash string, revokedAt *time.Time) {
+	mock.ExpectQuery(`SELECT \* FROM "sessions" WHERE refresh_token_hash = \$1 OR previous_token_hash = \$2`).
+		WithArgs(hash, hash, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "refresh_token_hash", "expires_at", "revoked_at"}).
+			AddRow(4, 7, currentHash, time.Now().Add(time.Hour), revokedAt))
+}
+
+func TestRefreshRotatesTheToken(t *testing.T) {
+	s, mock := newTestSessionService(t)
+	token, hash, err := auth.GenerateRefreshToken()
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken() error = %v", err)
+	}
+
+	expectSessionByToken(mock, hash, hash, nil)
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "ada@example.com"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "sessions" SET .* WHERE id = \$\d+ AND refresh_token_hash = \$\d+ AND revoked_at IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	pair, user, err := s.Refresh(token, ClientInfo{IPAddress: "203.0.113.9", UserAgent: "curl/8.5.0"})
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if user.ID != 7 || pair.Session.ID != 4 {
+		t.Errorf("refreshed session %d of user %d, want session 4 of user 7", pair.Session.ID, user.ID)
+	}
+	if pair.RefreshToken == token || pair.Session.RefreshTokenHash != auth.HashRefreshToken(pair.RefreshToken) {
+		t.Error("refresh token was not rotated")
+	}
+	if pair.Session.Device != "curl" || pair.ExpiresIn != 15*60 {
+		t.Errorf("device %q, expires in %d; want curl, %d", pair.Session.Device, pair.ExpiresIn, 15*60)
+	}
+}
+
+func TestRefreshRejections(t *testing.T) {
+	revokedAt := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name       string
+		found      bool
+		rotated    bool       // the token presented was already rotated out
+		revokedAt  *time.Time // when the session was revoked
+		wantRevoke bool
+		lostRace   bool // another refresh rotated the session first
+	}{
+		{name: "unknown token"},
+		{name: "revoked session", found: true, revokedAt: &revokedAt},
+		{name: "reused token", found: true, rotated: true, wantRevoke: true},
+		{name: "concurrent refresh", found: true, lostRace: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestSessionService(t)
+			token, hash, _ := auth.GenerateRefreshToken()
+
+			if !tt.found {
+				mock.ExpectQuery(`SELECT \* FROM "sessions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			} else {
+				currentHash := hash
+				if tt.rotated {
+					_, currentHash, _ = auth.GenerateRefreshToken()
+				}
+				expectSessionByToken(mock, hash, currentHash, tt.revokedAt)
+			}
+			if tt.wantRevoke {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "sessions" SET "revoked_at"=\$1,"updated_at"=\$2 WHERE id = \$3 AND user_id = \$4 AND revoked_at IS NULL`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 4, 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+			if tt.lostRace {
+				mock.ExpectQuery(`SELECT \* FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "sessions" SET`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			}
+
+			if _, _, err := s.Refresh(token, ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Fatalf("Refresh() error = %v, want %v", err, ErrInvalidRefreshToken)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestDescribeDevice(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36 Edg/126.0", want: "Edge on Windows"},
+		{userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15", want: "Safari on macOS"},
+		{userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/126.0 Mobile/15E148 Safari/604.1", want: "Chrome on iOS"},
+		{userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0", want: "Firefox on Linux"},
+		{userAgent: "Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Mobile Safari/537.36", want: "Chrome on Android"},
+		{userAgent: "python-requests/2.32", want: "python-requests"},
+		{userAgent: "", want: "Unknown browser"},
+	}
+
+	for _, tt := range tests {
+		if got := describeDevice(tt.userAgent); got != tt.want {
+			t.Errorf("describeDevice(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
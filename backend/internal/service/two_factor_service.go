@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/ratelimit"
+)
+
+var (
+	// ErrTwoFactorEnabled is returned when starting enrollment with two-factor already on
+	ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTwoFactorNotEnabled is returned for operations that need two-factor on
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrTwoFactorSetupRequired is returned when confirming without a pending secret
+	ErrTwoFactorSetupRequired = errors.New("two-factor setup has not been started")
+	// ErrInvalidTwoFactorCode is returned for a wrong, reused or expired code
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	// ErrTooManyTwoFactorAttempts is returned once a user has used up their attempts
+	ErrTooManyTwoFactorAttempts = errors.New("too many two-factor attempts")
+	// ErrInvalidPassword is returned when re-authentication fails
+	ErrInvalidPassword = errors.New("password is incorrect")
+)
+
+// TwoFactorSetup is the pending enrollment shown to the user
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorStatus describes a user's two-factor state
+type TwoFactorStatus struct {
+	Enabled                bool  `json:"enabled"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+type TwoFactorService struct {
+	twoFactorRepo *database.TwoFactorRepository
+	userRepo      *database.UserRepository
+	attempts      ratelimit.Store
+	config        *config.Config
+}
+
+func NewTwoFactorService(twoFactorRepo *database.TwoFactorRepository, userRepo *database.UserRepository, attempts ratelimit.Store, cfg *config.Config) *TwoFactorService {
+	return &TwoFactorService{
+		twoFactorRepo: twoFactorRepo,
+		userRepo:      userRepo,
+		attempts:      attempts,
+		config:        cfg,
+	}
+}
+
+// Status reports whether two-factor is on and how many recovery codes are left
+func (s *TwoFactorService) Status(userID int64) (*TwoFactorStatus, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TwoFactorStatus{Enabled: user.TwoFactorEnabled}
+	if user.TwoFactorEnabled {
+		status.RecoveryCodesRemaining, err = s.twoFactorRepo.CountUnusedRecoveryCodes(userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// BeginSetup generates a new TOTP secret for the user to add to their
+// authenticator app. Starting again replaces an unconfirmed secret.
+func (s *TwoFactorService) BeginSetup(userID int64) (*TwoFactorSetup, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("error generating TOTP secret: %w", err)
+	}
+	if err := s.twoFactorRepo.SetSecret(userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetup{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(s.config.TwoFactor.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmSetup enables two-factor once the user proves their app produces
+// valid codes, returning recovery codes to show once
+func (s *TwoFactorService) ConfirmSetup(userID int64, code string) ([]string, error) {
+	ctx := context.Background()
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorSetupRequired
+	}
+	if err := s.checkAttempts(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	step, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		s.recordFailure(ctx, userID)
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("error generating recovery codes: %w", err)
+	}
+	if err := s.twoFactorRepo.Enable(userID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	logger.Infof(ctx, "Two-factor authentication enabled for user ID: %d", userID)
+	return codes, nil
+}
+
+// Verify checks a second factor for a user with two-factor enabled. The code
+// may be a TOTP code or an unused recovery code, which is then spent.
+func (s *TwoFactorService) Verify(user *models.User, code string) error {
+	ctx := context.Background()
+
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if err := s.checkAttempts(ctx, user.ID); err != nil {
+		return err
+	}
+
+	if step, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now()); ok {
+		advanced, err := s.twoFactorRepo.AdvanceStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if advanced {
+			return nil
+		}
+		logger.Warnf(ctx, "Rejected reused TOTP code for user ID: %d", user.ID)
+	} else {
+		used, err := s.twoFactorRepo.UseRecoveryCode(user.ID, auth.HashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+		if used {
+			logger.Infof(ctx, "Recovery code used for user ID: %d", user.ID)
+			return nil
+		}
+	}
+
+	s.recordFailure(ctx, user.ID)
+	return ErrInvalidTwoFactorCode
+}
+
+// Disable turns off two-factor. The user must re-authenticate with their
+// password and a current code.
+func (s *TwoFactorService) Disable(userID int64, password, code string) error {
+	user, err := s.reauthenticate(userID, password, code)
+	if err != nil {
+		return err
+	}
+	return s.twoFactorRepo.Disable(user.ID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after
+// re-authentication
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID int64, password, code string) ([]string, error) {
+	user, err := s.reauthenticate(userID, password, code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("error generating recovery codes: %w", err)
+	}
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *TwoFactorService) reauthenticate(userID int64, password, code string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if !auth.CheckPassword(password, user.PasswordHash) {
+		return nil, ErrInvalidPassword
+	}
+	if err := s.Verify(user, code); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// attemptsKey counts a user's failed codes. Attempts are per user rather
+// than per challenge, so logging in again does not reset them.
+func attemptsKey(userID int64) string {
+	return "2fa_attempts:" + strconv.FormatInt(userID, 10)
+}
+
+func (s *TwoFactorService) attemptWindow() time.Duration {
+	return time.Duration(s.config.TwoFactor.ChallengeTTLSeconds) * time.Second
+}
+
+func (s *TwoFactorService) checkAttempts(ctx context.Context, userID int64) error {
+	failures, err := s.attempts.Get(ctx, attemptsKey(userID))
+	if err != nil {
+		// Fail closed: without the counter codes could be guessed freely
+		logger.Errorf(ctx, "Failed to read two-factor attempts for user ID %d: %+v", userID, err)
+		return ErrTooManyTwoFactorAttempts
+	}
+	if failures >= int64(s.config.TwoFactor.MaxAttempts) {
+		return ErrTooManyTwoFactorAttempts
+	}
+	return nil
+}
+
+func (s *TwoFactorService) recordFailure(ctx context.Context, userID int64) {
+	if _, err := s.attempts.Increment(ctx, attemptsKey(userID), s.attemptWindow()); err != nil {
+		logger.Errorf(ctx, "Failed to record two-factor attempt for user ID %d: %+v", userID, err)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/auth"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/ratelimit"
+)
+
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// currentTOTP computes the code an authenticator app shows for secret now
+func currentTOTP(t *testing.T, secret string) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decoding secret: %v", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func newTestTwoFactorService(t *testing.T) (*TwoFactorService, sqlmock.Sqlmock, *ratelimit.MemoryStore) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	attempts := ratelimit.NewMemoryStore()
+	cfg := &config.Config{TwoFactor: config.TwoFactorConfig{ChallengeTTLSeconds: 300, MaxAttempts: 3}}
+	return NewTwoFactorService(database.NewTwoFactorRepository(db), database.NewUserRepository(db), attempts, cfg), mock, attempts
+}
+
+func TestTwoFactorVerify(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         string // empty for the current TOTP code
+		failures     int    // earlier failed attempts
+		advanced     int64  // rows updated when the TOTP step is recorded, -1 if it isn't
+		recoveryUsed int64  // rows updated when spending a recovery code, -1 if none is tried
+		wantErr      error
+	}{
+		{name: "current code", advanced: 1, recoveryUsed: -1},
+		{name: "reused code", advanced: 0, recoveryUsed: -1, wantErr: ErrInvalidTwoFactorCode},
+		{name: "recovery code", code: "abcde-fghjk", advanced: -1, recoveryUsed: 1},
+		{name: "spent recovery code", code: "abcde-fghjk", advanced: -1, recoveryUsed: 0, wantErr: ErrInvalidTwoFactorCode},
+		{name: "out of attempts", failures: 3, advanced: -1, recoveryUsed: -1, wantErr: ErrTooManyTwoFactorAttempts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, attempts := newTestTwoFactorService(t)
+			ctx := context.Background()
+			for i := 0; i < tt.failures; i++ {
+				attempts.Increment(ctx, attemptsKey(7), time.Minute)
+			}
+			if tt.advanced >= 0 {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "users" SET "totp_last_step"=\$1`).
+					WillReturnResult(sqlmock.NewResult(0, tt.advanced))
+				mock.ExpectCommit()
+			}
+			if tt.recoveryUsed >= 0 {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "recovery_codes" SET "used_at"=\$1 WHERE user_id = \$2 AND code_hash = \$3 AND used_at IS NULL`).
+					WithArgs(sqlmock.AnyArg(), 7, auth.HashRecoveryCode(tt.code)).
+					WillReturnResult(sqlmock.NewResult(0, tt.recoveryUsed))
+				mock.ExpectCommit()
+			}
+
+			code := tt.code
+			if code == "" {
+				code = currentTOTP(t, testTOTPSecret)
+			}
+			user := &models.User{ID: 7, TwoFactorEnabled: true, TOTPSecret: testTOTPSecret}
+			if err := s.Verify(user, code); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Only wrong codes count against the user
+			wantFailures := int64(tt.failures)
+			if errors.Is(tt.wantErr, ErrInvalidTwoFactorCode) {
+				wantFailures++
+			}
+			if failures, _ := attempts.Get(ctx, attemptsKey(7)); failures != wantFailures {
+				t.Errorf("failed attempts = %d, want %d", failures, wantFailures)
+			}
+		})
+	}
+}
+
+func TestTwoFactorVerifyRequiresTwoFactor(t *testing.T) {
+	s, _, _ := newTestTwoFactorService(t)
+	if err := s.Verify(&models.User{ID: 7}, "123456"); !errors.Is(err, ErrTwoFactorNotEnabled) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrTwoFactorNotEnabled)
+	}
+}
+
+func TestTwoFactorDisableRequiresThePassword(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	s, mock, _ := newTestTwoFactorService(t)
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_hash", "two_factor_enabled", "totp_secret"}).
+			AddRow(7, hash, true, testTOTPSecret))
+
+	if err := s.Disable(7, "battery staple", currentTOTP(t, testTOTPSecret)); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("Disable() error = %v, want %v", err, ErrInvalidPassword)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfirmSetup(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		secret  string
+		code    string // empty for the current TOTP code
+		wantErr error
+	}{
+		{name: "already enabled", enabled: true, secret: testTOTPSecret, wantErr: ErrTwoFactorEnabled},
+		{name: "setup not started", wantErr: ErrTwoFactorSetupRequired},
+		{name: "wrong code", secret: testTOTPSecret, code: "000000", wantErr: ErrInvalidTwoFactorCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, _ := newTestTwoFactorService(t)
+			mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).
+				WithArgs(7, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "two_factor_enabled", "totp_secret"}).
+					AddRow(7, tt.enabled, tt.secret))
+
+			code := tt.code
+			if code == "" {
+				code = currentTOTP(t, testTOTPSecret)
+			}
+			if _, err := s.ConfirmSetup(7, code); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConfirmSetup() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
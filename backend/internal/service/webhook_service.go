@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/shafikshaon/url_shortener/internal/authz"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/urlcheck"
+)
+
+const maxWebhooksPerOrganization = 20
+
+var (
+	// ErrInvalidWebhook is returned for an invalid endpoint URL or event list
+	ErrInvalidWebhook = errors.New("invalid webhook")
+	// ErrWebhookLimitReached is returned when the organization has as many
+	// webhooks as it may
+	ErrWebhookLimitReached = errors.New("webhook limit reached")
+)
+
+// WebhookService manages an organization's webhooks, which admins set up
+// to receive the organization's events
+type WebhookService struct {
+	webhookRepo *database.WebhookRepository
+	authorizer  *authz.Authorizer
+}
+
+func NewWebhookService(webhookRepo *database.WebhookRepository, authorizer *authz.Authorizer) *WebhookService {
+	return &WebhookService{
+		webhookRepo: webhookRepo,
+		authorizer:  authorizer,
+	}
+}
+
+// validateWebhook checks the endpoint URL and subscribed events. Endpoints
+// on internal networks are refused here; hostnames that resolve to them are
+// refused when a delivery connects.
+func validateWebhook(webhook *models.Webhook) error {
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("%w: invalid URL", ErrInvalidWebhook)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%w: URL must use http or https", ErrInvalidWebhook)
+	}
+	normalized, err := urlcheck.Normalize(webhook.URL)
+	if err != nil {
+		return fmt.Errorf("%w: invalid URL: %v", ErrInvalidWebhook, err)
+	}
+	webhook.URL = normalized
+
+	if len(webhook.Events) == 0 {
+		return fmt.Errorf("%w: at least one event is required", ErrInvalidWebhook)
+	}
+	for _, event := range webhook.Events {
+		if !events.IsWebhookEvent(event) {
+			return fmt.Errorf("%w: unsupported event: %s", ErrInvalidWebhook, event)
+		}
+	}
+
+	return nil
+}
+
+// CreateWebhook registers a new webhook in webhook.OrganizationID for its
+// creator, webhook.UserID, with a freshly generated signing secret
+func (s *WebhookService) CreateWebhook(webhook *models.Webhook) error {
+	ctx := context.Background()
+	logger.Infof(ctx, "Creating webhook for organization ID: %d by user ID: %d, URL: %s", webhook.OrganizationID, webhook.UserID, webhook.URL)
+
+	if _, err := s.authorizer.Authorize(webhook.UserID, webhook.OrganizationID, authz.ActionManageWebhooks); err != nil {
+		return err
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return err
+	}
+
+	existing, err := s.webhookRepo.GetByOrganizationID(webhook.OrganizationID)
+	if err != nil {
+		return err
+	}
+	if len(existing) >= maxWebhooksPerOrganization {
+		return ErrWebhookLimitReached
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		logger.Errorf(ctx, "Failed to generate webhook secret: %+v", err)
+		return fmt.Errorf("error generating webhook secret: %w", err)
+	}
+	webhook.Secret = secret
+	webhook.IsActive = true
+
+	return s.webhookRepo.Create(webhook)
+}
+
+// GetWebhook retrieves one of the organization's webhooks. Webhooks of
+// other organizations are reported as missing.
+func (s *WebhookService) GetWebhook(orgID, webhookID, userID int64) (*models.Webhook, error) {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageWebhooks); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.webhookRepo.GetByID(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.OrganizationID != orgID {
+		logger.Warnf(context.Background(), "Webhook ID %d requested from organization ID %d", webhookID, orgID)
+		return nil, database.ErrWebhookNotFound
+	}
+
+	return webhook, nil
+}
+
+// ListWebhooks lists the organization's webhooks
+func (s *WebhookService) ListWebhooks(orgID, userID int64) ([]*models.Webhook, error) {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageWebhooks); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.GetByOrganizationID(orgID)
+}
+
+// UpdateWebhook updates a webhook's endpoint, events and status
+func (s *WebhookService) UpdateWebhook(orgID int64, webhook *models.Webhook, userID int64) error {
+	ctx := context.Background()
+	logger.Infof(ctx, "Updating webhook ID: %d for user ID: %d", webhook.ID, userID)
+
+	if _, err := s.GetWebhook(orgID, webhook.ID, userID); err != nil {
+		return err
+	}
+
+	if err := validateWebhook(webhook); err != nil {
+		return err
+	}
+
+	return s.webhookRepo.Update(webhook)
+}
+
+// RotateSecret replaces a webhook's signing secret. Deliveries are signed
+// with the new secret from the next attempt on.
+func (s *WebhookService) RotateSecret(orgID, webhookID, userID int64) (*models.Webhook, error) {
+	ctx := context.Background()
+	logger.Infof(ctx, "Rotating secret of webhook ID: %d for user ID: %d", webhookID, userID)
+
+	webhook, err := s.GetWebhook(orgID, webhookID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		logger.Errorf(ctx, "Failed to generate webhook secret: %+v", err)
+		return nil, fmt.Errorf("error generating webhook secret: %w", err)
+	}
+	webhook.Secret = secret
+	if err := s.webhookRepo.UpdateSecret(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook deletes a webhook
+func (s *WebhookService) DeleteWebhook(orgID, webhookID, userID int64) error {
+	logger.Infof(context.Background(), "Deleting webhook ID: %d for user ID: %d", webhookID, userID)
+
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageWebhooks); err != nil {
+		return err
+	}
+	return s.webhookRepo.Delete(webhookID, orgID)
+}
+
+// ListDeliveries returns the delivery log for a webhook
+func (s *WebhookService) ListDeliveries(orgID, webhookID, userID int64, limit, offset int) ([]*models.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(orgID, webhookID, userID); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.GetDeliveries(webhookID, limit, offset)
+}
+
+// GetStats returns delivery statistics across the organization's webhooks
+func (s *WebhookService) GetStats(orgID, userID int64) (*models.WebhookStats, error) {
+	if _, err := s.authorizer.Authorize(userID, orgID, authz.ActionManageWebhooks); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.GetStats(orgID)
+}
+
+// generateWebhookSecret creates a random signing secret
+func generateWebhookSecret() (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,199 @@
+package urlcheck
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/idna"
+)
+
+// fileCheckInterval is how often blocklist files are checked for changes
+const fileCheckInterval = time.Minute
+
+// Match describes why a URL is blocklisted
+type Match struct {
+	// List names the blocklist that matched
+	List string
+	// Reason is shown to the link's owner
+	Reason string
+}
+
+// Blocklist checks URLs against a source of known malicious or abusive
+// sites. Implement it to consult an external reputation service; Check
+// should give up when ctx is done.
+type Blocklist interface {
+	// Check returns the match for u, or nil when u isn't listed
+	Check(ctx context.Context, u *url.URL) (*Match, error)
+}
+
+// Blocklists checks several blocklists in order and returns the first
+// match. A list that fails doesn't stop the others from being checked; its
+// error is returned when nothing matched.
+type Blocklists []Blocklist
+
+// Check implements Blocklist
+func (b Blocklists) Check(ctx context.Context, u *url.URL) (*Match, error) {
+	var errs []error
+	for _, list := range b {
+		match, err := list.Check(ctx, u)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if match != nil {
+			return match, nil
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("checking blocklists: %v", errs)
+	}
+	return nil, nil
+}
+
+// FileBlocklist blocks domains listed in local files, with their
+// subdomains. Each line holds a domain; hosts-file lines such as
+// "0.0.0.0 example.com" are read too, and # starts a comment. Files are
+// re-read when they change.
+type FileBlocklist struct {
+	paths []string
+
+	mu        sync.RWMutex
+	domains   map[string]string
+	modTimes  map[string]time.Time
+	checkedAt time.Time
+}
+
+// NewFileBlocklist loads the blocklist files at paths
+func NewFileBlocklist(paths []string) (*FileBlocklist, error) {
+	b := &FileBlocklist{paths: paths}
+	if err := b.load(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Len returns the number of listed domains
+func (b *FileBlocklist) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.domains)
+}
+
+// Check implements Blocklist. A file that can no longer be read is
+// reported as an error and its previous contents stay in use.
+func (b *FileBlocklist) Check(_ context.Context, u *url.URL) (*Match, error) {
+	reloadErr := b.reloadIfChanged()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for domain := host; domain != ""; {
+		if list, ok := b.domains[domain]; ok {
+			return &Match{List: list, Reason: fmt.Sprintf("%s is listed in the %s blocklist", domain, list)}, nil
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+	return nil, reloadErr
+}
+
+// reloadIfChanged reloads the files when one has changed, checking at most
+// once per fileCheckInterval
+func (b *FileBlocklist) reloadIfChanged() error {
+	b.mu.RLock()
+	due := time.Since(b.checkedAt) >= fileCheckInterval
+	b.mu.RUnlock()
+	if !due {
+		return nil
+	}
+
+	b.mu.Lock()
+	b.checkedAt = time.Now()
+	modTimes := b.modTimes
+	b.mu.Unlock()
+
+	for _, path := range b.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("checking blocklist %s: %w", path, err)
+		}
+		if !info.ModTime().Equal(modTimes[path]) {
+			return b.load()
+		}
+	}
+	return nil
+}
+
+// load reads every file and swaps in their domains
+func (b *FileBlocklist) load() error {
+	domains := make(map[string]string)
+	modTimes := make(map[string]time.Time, len(b.paths))
+	for _, path := range b.paths {
+		modTime, err := readBlocklistFile(path, domains)
+		if err != nil {
+			return err
+		}
+		modTimes[path] = modTime
+	}
+
+	b.mu.Lock()
+	b.domains = domains
+	b.modTimes = modTimes
+	b.checkedAt = time.Now()
+	b.mu.Unlock()
+	return nil
+}
+
+// readBlocklistFile adds the domains listed in the file at path to domains,
+// named after the file, and returns the file's modification time
+func readBlocklistFile(path string, domains map[string]string) (time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("opening blocklist: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading blocklist %s: %w", path, err)
+	}
+	list := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		// Hosts-file lines put the domains after an address
+		names := fields[:1]
+		if _, err := netip.ParseAddr(fields[0]); err == nil {
+			names = fields[1:]
+		}
+		for _, name := range names {
+			domain := strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(name), "."), "*.")
+			if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+				domain = ascii
+			}
+			if domain != "" {
+				domains[domain] = list
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, fmt.Errorf("reading blocklist %s: %w", path, err)
+	}
+	return info.ModTime(), nil
+}
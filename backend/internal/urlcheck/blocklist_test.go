@@ -0,0 +1,147 @@
+package urlcheck
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeBlocklist writes a blocklist file named name and returns its path
+func writeBlocklist(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("writing blocklist: %v", err)
+	}
+	return path
+}
+
+// staticBlocklist answers every check with the same match and error
+type staticBlocklist struct {
+	match *Match
+	err   error
+}
+
+func (b staticBlocklist) Check(context.Context, *url.URL) (*Match, error) { return b.match, b.err }
+
+func TestFileBlocklist(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewFileBlocklist([]string{
+		writeBlocklist(t, dir, "phishing.txt", "# phishing domains\nevil.example\n*.Wildcard.example.  # trailing comment\n\nbücher.example\n"),
+		writeBlocklist(t, dir, "hosts", "0.0.0.0 ads.example tracker.example\n127.0.0.1 localhost.example\n"),
+	})
+	if err != nil {
+		t.Fatalf("NewFileBlocklist() error = %v", err)
+	}
+	if b.Len() != 6 {
+		t.Errorf("Len() = %d, want 6", b.Len())
+	}
+
+	tests := []struct {
+		url      string
+		wantList string
+	}{
+		{url: "https://evil.example/login", wantList: "phishing"},
+		{url: "https://login.EVIL.example./", wantList: "phishing"},
+		{url: "https://a.wildcard.example", wantList: "phishing"},
+		{url: "https://xn--bcher-kva.example", wantList: "phishing"},
+		{url: "https://tracker.example", wantList: "hosts"},
+		{url: "https://notevil.example"},
+		{url: "https://example"},
+	}
+
+	for _, tt := range tests {
+		match, err := b.Check(context.Background(), mustParse(t, tt.url))
+		if err != nil {
+			t.Fatalf("Check(%s) error = %v", tt.url, err)
+		}
+		got := ""
+		if match != nil {
+			got = match.List
+		}
+		if got != tt.wantList {
+			t.Errorf("Check(%s) matched list %q, want %q", tt.url, got, tt.wantList)
+		}
+	}
+}
+
+func TestFileBlocklistReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := writeBlocklist(t, dir, "abuse.txt", "old.example\n")
+	b, err := NewFileBlocklist([]string{path})
+	if err != nil {
+		t.Fatalf("NewFileBlocklist() error = %v", err)
+	}
+
+	writeBlocklist(t, dir, "abuse.txt", "new.example\n")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("touching blocklist: %v", err)
+	}
+	// Files are only checked for changes once per interval
+	if match, _ := b.Check(context.Background(), mustParse(t, "https://new.example")); match != nil {
+		t.Fatalf("Check() before the interval = %+v, want no match", match)
+	}
+
+	b.checkedAt = time.Now().Add(-fileCheckInterval)
+	if match, err := b.Check(context.Background(), mustParse(t, "https://new.example")); err != nil || match == nil {
+		t.Fatalf("Check() after the reload = %v, %v; want a match", match, err)
+	}
+
+	// A file that disappears keeps its last contents
+	os.Remove(path)
+	b.checkedAt = time.Now().Add(-fileCheckInterval)
+	match, err := b.Check(context.Background(), mustParse(t, "https://new.example"))
+	if match == nil || err != nil {
+		t.Errorf("Check() with the file removed = %v, %v; want the previous match", match, err)
+	}
+	b.checkedAt = time.Now().Add(-fileCheckInterval)
+	if _, err := b.Check(context.Background(), mustParse(t, "https://other.example")); err == nil {
+		t.Error("Check() of an unlisted host with the file removed returned no error")
+	}
+}
+
+func TestNewFileBlocklistMissingFile(t *testing.T) {
+	if _, err := NewFileBlocklist([]string{filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("NewFileBlocklist() accepted a missing file")
+	}
+}
+
+func TestBlocklists(t *testing.T) {
+	errDown := errors.New("reputation service down")
+	first, second := &Match{List: "first"}, &Match{List: "second"}
+
+	tests := []struct {
+		name      string
+		lists     Blocklists
+		wantMatch *Match
+		wantErr   bool
+	}{
+		{name: "no lists"},
+		{name: "first match wins", lists: Blocklists{staticBlocklist{}, staticBlocklist{match: first}, staticBlocklist{match: second}}, wantMatch: first},
+		{name: "failing list skipped", lists: Blocklists{staticBlocklist{err: errDown}, staticBlocklist{match: second}}, wantMatch: second},
+		{name: "failure reported without a match", lists: Blocklists{staticBlocklist{err: errDown}, staticBlocklist{}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := tt.lists.Check(context.Background(), mustParse(t, "https://example.com"))
+			if (err != nil) != tt.wantErr || match != tt.wantMatch {
+				t.Errorf("Check() = %v, %v; want %v, error %v", match, err, tt.wantMatch, tt.wantErr)
+			}
+		})
+	}
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", raw, err)
+	}
+	return u
+}
@@ -0,0 +1,168 @@
+// Package urlcheck normalizes the URLs links redirect to and checks them
+// against what a short link may not point at: other schemes, hosts on
+// internal networks, other short links and blocklisted domains.
+package urlcheck
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/shafikshaon/url_shortener/internal/netguard"
+	"golang.org/x/net/idna"
+)
+
+// MaxURLLength bounds a destination URL
+const MaxURLLength = 8192
+
+// schemePrefix matches a URL scheme, or a host followed by a port
+var schemePrefix = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*):`)
+
+// internalSuffixes are names reserved for private networks and testing,
+// which never resolve on the public internet
+var internalSuffixes = []string{"localhost", "local", "internal", "intranet", "lan", "home.arpa", "test", "invalid"}
+
+// knownShorteners are public URL shorteners. Linking to them would hide the
+// final destination behind a second short link.
+var knownShorteners = []string{
+	"bit.ly", "bitly.com", "bl.ink", "buff.ly", "cutt.ly", "goo.gl", "is.gd",
+	"ow.ly", "rb.gy", "rebrand.ly", "s.id", "shorturl.at", "t.co", "t.ly",
+	"tiny.cc", "tinyurl.com", "v.gd",
+}
+
+// Normalize cleans up a URL entered for a link: it trims it, adds https://
+// when there is no scheme and lowercases the scheme and host, converting
+// international hostnames to their ASCII form. It rejects schemes other
+// than http(s), embedded credentials, and hosts that are IP addresses off
+// the public internet, disguised IP addresses or internal names; the error
+// says why.
+func Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("URL is empty")
+	}
+	if len(raw) > MaxURLLength {
+		return "", fmt.Errorf("URL can be at most %d characters", MaxURLLength)
+	}
+	// Browsers drop whitespace and read backslashes as slashes, so the URL
+	// they follow could differ from the one checked here
+	for _, r := range raw {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || r == '\\' {
+			return "", errors.New("URL contains spaces, control characters or backslashes")
+		}
+	}
+
+	switch scheme, ok := parseScheme(raw); {
+	case !ok:
+		raw = "https://" + strings.TrimPrefix(raw, "//")
+	case scheme != "http" && scheme != "https":
+		return "", errors.New("only http and https URLs are allowed")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Opaque != "" {
+		return "", errors.New("URL is malformed")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.User != nil {
+		return "", errors.New("URLs with a username or password are not allowed")
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+	return u.String(), nil
+}
+
+// parseScheme returns the lowercase scheme raw starts with. "host:port"
+// isn't taken for a scheme.
+func parseScheme(raw string) (string, bool) {
+	match := schemePrefix.FindStringSubmatch(raw)
+	if match == nil {
+		return "", false
+	}
+	rest := raw[len(match[0]):]
+	if !strings.HasPrefix(rest, "//") && rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+		return "", false
+	}
+	return strings.ToLower(match[1]), true
+}
+
+// normalizeHost lowercases host, drops a trailing dot, converts it to ASCII
+// and checks that it can be on the public internet
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return "", errors.New("URL has no host")
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if addr.Zone() != "" || !netguard.IsPublic(addr) {
+			return "", fmt.Errorf("%s is not a public address", host)
+		}
+		return addr.String(), nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a valid hostname", host)
+	}
+	labels := strings.Split(ascii, ".")
+	// Top-level domains are never numeric; browsers read hosts like
+	// 2130706433 or 0x7f.1 as IPv4 addresses
+	if isNumericLabel(labels[len(labels)-1]) {
+		return "", fmt.Errorf("%s is not a valid IP address", host)
+	}
+	if len(labels) == 1 || matchesDomain(ascii, internalSuffixes) {
+		return "", fmt.Errorf("%s is an internal hostname", host)
+	}
+	return ascii, nil
+}
+
+// isNumericLabel reports whether a host label is a decimal, octal or hex
+// number
+func isNumericLabel(label string) bool {
+	if hex, ok := strings.CutPrefix(label, "0x"); ok {
+		return strings.Trim(hex, "0123456789abcdef") == ""
+	}
+	return label != "" && strings.Trim(label, "0123456789") == ""
+}
+
+// IsShortener reports whether host belongs to a well-known URL shortener
+// or one of extra, which lists further shortener domains
+func IsShortener(host string, extra []string) bool {
+	return matchesDomain(host, knownShorteners) || matchesDomain(host, extra)
+}
+
+// Host returns the lowercase hostname of a normalized URL
+func Host(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// matchesDomain reports whether host is one of domains or a subdomain of
+// one
+func matchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,398 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/events"
+	"github.com/shafikshaon/url_shortener/internal/logger"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/netguard"
+)
+
+const (
+	queueSize       = 1000
+	resumeBatchSize = 1000
+	maxRedirects    = 5
+
+	// claimMargin is added to the request timeout to lease a delivery for
+	// an attempt
+	claimMargin = time.Minute
+)
+
+// Payload is the JSON body POSTed to webhook endpoints
+type Payload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher fans events out to subscribed webhooks and delivers them
+// with retries and exponential backoff
+type Dispatcher struct {
+	webhookRepo *database.WebhookRepository
+	client      *http.Client
+	workers     int
+	maxAttempts int
+	retryBase   time.Duration
+	claimLease  time.Duration
+
+	events chan events.Event
+	jobs   chan *models.WebhookDelivery
+	stop   chan struct{}
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	stopped  bool
+	timers   map[int64]*time.Timer
+	stopOnce sync.Once
+}
+
+func NewDispatcher(webhookRepo *database.WebhookRepository, cfg *config.Config) *Dispatcher {
+	return newDispatcher(webhookRepo, cfg, netguard.Control)
+}
+
+// newDispatcher builds a dispatcher whose connections are vetted by
+// control. Endpoints are user-supplied, so deliveries must not reach the
+// server's own network.
+func newDispatcher(webhookRepo *database.WebhookRepository, cfg *config.Config, control func(network, address string, c syscall.RawConn) error) *Dispatcher {
+	workers := cfg.Webhook.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	maxAttempts := cfg.Webhook.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	timeout := time.Duration(cfg.Webhook.TimeoutSeconds) * time.Second
+	return &Dispatcher{
+		webhookRepo: webhookRepo,
+		client:      newClient(timeout, control),
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		retryBase:   time.Duration(cfg.Webhook.RetryBaseSeconds) * time.Second,
+		claimLease:  timeout + claimMargin,
+		events:      make(chan events.Event, queueSize),
+		jobs:        make(chan *models.WebhookDelivery, queueSize),
+		stop:        make(chan struct{}),
+		timers:      make(map[int64]*time.Timer),
+	}
+}
+
+// newClient builds the HTTP client for deliveries. Every connection,
+// including those made for redirects, goes through control.
+func newClient(timeout time.Duration, control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: control}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// No proxy: it would connect to the endpoint on our behalf, past
+			// the address check
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// Start launches the delivery workers and resumes deliveries left pending
+// by a previous run. Every instance resumes them; each attempt is claimed
+// before it is sent, so only one instance sends it.
+func (d *Dispatcher) Start() {
+	ctx := context.Background()
+	logger.Infof(ctx, "Starting webhook dispatcher with %d workers", d.workers)
+
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+
+	pending, err := d.webhookRepo.GetPendingDeliveries(resumeBatchSize)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to load pending webhook deliveries: %+v", err)
+		return
+	}
+	for _, delivery := range pending {
+		delay := time.Duration(0)
+		if delivery.NextRetryAt != nil {
+			delay = time.Until(*delivery.NextRetryAt)
+		}
+		d.schedule(delivery, delay)
+	}
+	if len(pending) > 0 {
+		logger.Infof(ctx, "Resumed %d pending webhook deliveries", len(pending))
+	}
+}
+
+// Stop stops the workers. Queued events are persisted as pending deliveries
+// so they are picked up again on the next Start.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.stopOnce.Do(func() {
+		d.mu.Lock()
+		d.stopped = true
+		for id, timer := range d.timers {
+			timer.Stop()
+			delete(d.timers, id)
+		}
+		d.mu.Unlock()
+		close(d.stop)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Infof(ctx, "Webhook dispatcher stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("webhook dispatcher did not stop in time: %w", ctx.Err())
+	}
+}
+
+// HandleEvent is an events.Handler that queues webhook events for fan-out.
+// Events outside an organization have no webhooks to go to.
+func (d *Dispatcher) HandleEvent(ctx context.Context, event events.Event) {
+	if !events.IsWebhookEvent(event.Type) || event.OrganizationID == 0 {
+		return
+	}
+
+	select {
+	case d.events <- event:
+	default:
+		logger.Warnf(ctx, "Webhook event queue full, dropping %s event for organization ID: %d", event.Type, event.OrganizationID)
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case event := <-d.events:
+			d.fanOut(event)
+		case delivery := <-d.jobs:
+			d.deliver(delivery)
+		case <-d.stop:
+			d.drainEvents()
+			return
+		}
+	}
+}
+
+// drainEvents records any queued events as pending deliveries without sending them
+func (d *Dispatcher) drainEvents() {
+	for {
+		select {
+		case event := <-d.events:
+			d.fanOut(event)
+		default:
+			return
+		}
+	}
+}
+
+// fanOut creates a delivery record for every webhook in the event's
+// organization subscribed to it
+func (d *Dispatcher) fanOut(event events.Event) {
+	ctx := context.Background()
+
+	webhooks, err := d.webhookRepo.GetActiveForEvent(event.OrganizationID, event.Type)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to look up webhooks for %s event: %+v", event.Type, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	payload := Payload{
+		ID:        uuid.New().String(),
+		Event:     event.Type,
+		CreatedAt: event.OccurredAt,
+		Data:      event.Data,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to marshal webhook payload: %+v", err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		delivery := &models.WebhookDelivery{
+			WebhookID: webhook.ID,
+			EventID:   payload.ID,
+			Event:     event.Type,
+			Payload:   string(body),
+			Status:    models.DeliveryPending,
+		}
+		if err := d.webhookRepo.CreateDelivery(delivery); err != nil {
+			logger.Errorf(ctx, "Failed to record webhook delivery for webhook ID %d: %+v", webhook.ID, err)
+			continue
+		}
+		d.schedule(delivery, 0)
+	}
+}
+
+// schedule queues a delivery attempt after the given delay
+func (d *Dispatcher) schedule(delivery *models.WebhookDelivery, delay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return
+	}
+	if delay < 0 {
+		delay = 0
+	}
+
+	d.timers[delivery.ID] = time.AfterFunc(delay, func() {
+		d.mu.Lock()
+		delete(d.timers, delivery.ID)
+		d.mu.Unlock()
+
+		select {
+		case d.jobs <- delivery:
+		case <-d.stop:
+		}
+	})
+}
+
+// deliver claims a delivery, performs a single attempt and schedules a
+// retry on failure. Deliveries another instance has claimed, or has already
+// finished or rescheduled, are left to it.
+func (d *Dispatcher) deliver(scheduled *models.WebhookDelivery) {
+	ctx := context.Background()
+
+	delivery, err := d.webhookRepo.ClaimDelivery(scheduled.ID, d.claimLease)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to claim webhook delivery %d: %+v", scheduled.ID, err)
+		d.schedule(scheduled, d.retryBase)
+		return
+	}
+	if delivery == nil {
+		logger.Debugf(ctx, "Webhook delivery %d is no longer due here, skipping", scheduled.ID)
+		return
+	}
+
+	webhook, err := d.webhookRepo.GetByID(delivery.WebhookID)
+	if err != nil || !webhook.IsActive {
+		reason := "webhook deleted or disabled"
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = &reason
+		delivery.NextRetryAt = nil
+		if err := d.webhookRepo.UpdateDelivery(delivery); err != nil {
+			logger.Errorf(ctx, "Failed to update webhook delivery %d: %+v", delivery.ID, err)
+		}
+		return
+	}
+
+	delivery.Attempts++
+	start := time.Now()
+	statusCode, sendErr := d.send(webhook, delivery)
+	duration := time.Since(start).Milliseconds()
+	delivery.DurationMs = &duration
+
+	if statusCode != 0 {
+		delivery.ResponseStatus = &statusCode
+	}
+
+	if sendErr == nil {
+		now := time.Now().UTC()
+		delivery.Status = models.DeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.LastError = nil
+		delivery.NextRetryAt = nil
+		logger.Infof(ctx, "Delivered %s event to webhook ID %d (attempt %d)", delivery.Event, webhook.ID, delivery.Attempts)
+	} else {
+		errMsg := sendErr.Error()
+		delivery.LastError = &errMsg
+
+		if delivery.Attempts >= d.maxAttempts {
+			delivery.Status = models.DeliveryFailed
+			delivery.NextRetryAt = nil
+			logger.Warnf(ctx, "Giving up on webhook delivery %d after %d attempts: %s", delivery.ID, delivery.Attempts, errMsg)
+		} else {
+			backoff := d.backoff(delivery.Attempts)
+			nextRetry := time.Now().UTC().Add(backoff)
+			delivery.NextRetryAt = &nextRetry
+			logger.Warnf(ctx, "Webhook delivery %d failed (attempt %d/%d), retrying in %s: %s",
+				delivery.ID, delivery.Attempts, d.maxAttempts, backoff, errMsg)
+		}
+	}
+
+	if err := d.webhookRepo.UpdateDelivery(delivery); err != nil {
+		logger.Errorf(ctx, "Failed to update webhook delivery %d: %+v", delivery.ID, err)
+	}
+
+	if delivery.Status == models.DeliveryPending {
+		d.schedule(delivery, time.Until(*delivery.NextRetryAt))
+	}
+}
+
+// send POSTs the signed payload and returns the response status code. The
+// response body is never recorded: the delivery log is visible to the
+// webhook's owner, who controls where it points.
+func (d *Dispatcher) send(webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("error building request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "url-shortener-webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.EventID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt: base * 2^(attempt-1)
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	return d.retryBase * time.Duration(1<<uint(attempt-1))
+}
@@ -0,0 +1,224 @@
+package webhook
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shafikshaon/url_shortener/config"
+	"github.com/shafikshaon/url_shortener/internal/database"
+	"github.com/shafikshaon/url_shortener/internal/models"
+	"github.com/shafikshaon/url_shortener/internal/netguard"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// allowAll lets the test client reach httptest servers on loopback
+func allowAll(string, string, syscall.RawConn) error { return nil }
+
+func testDispatcher(control func(network, address string, c syscall.RawConn) error) *Dispatcher {
+	return newDispatcher(nil, testConfig(), control)
+}
+
+func testConfig() *config.Config {
+	return &config.Config{Webhook: config.WebhookConfig{
+		Workers:          1,
+		MaxAttempts:      5,
+		TimeoutSeconds:   5,
+		RetryBaseSeconds: 30,
+	}}
+}
+
+func newMockRepo(t *testing.T) (*database.WebhookRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("creating mock database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	return database.NewWebhookRepository(db), mock
+}
+
+func TestBackoff(t *testing.T) {
+	d := testDispatcher(allowAll)
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 30 * time.Second},
+		{attempt: 2, want: time.Minute},
+		{attempt: 3, want: 2 * time.Minute},
+		{attempt: 4, want: 4 * time.Minute},
+		{attempt: 5, want: 8 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempt), func(t *testing.T) {
+			if got := d.backoff(tt.attempt); got != tt.want {
+				t.Errorf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantStatus int
+		wantErr    bool
+	}{
+		{name: "ok", status: http.StatusOK, wantStatus: http.StatusOK},
+		{name: "no content", status: http.StatusNoContent, wantStatus: http.StatusNoContent},
+		{name: "server error", status: http.StatusInternalServerError, wantStatus: http.StatusInternalServerError, wantErr: true},
+		{name: "not found", status: http.StatusNotFound, wantStatus: http.StatusNotFound, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verified bool
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+				verified = Verify("whsec_test", r.Header.Get(SignatureHeader), timestamp, body) &&
+					r.Header.Get(EventHeader) == "link.created" &&
+					r.Header.Get(DeliveryHeader) == "evt_1"
+				w.WriteHeader(tt.status)
+				io.WriteString(w, "internal details the owner must not see")
+			}))
+			defer server.Close()
+
+			webhook := &models.Webhook{URL: server.URL, Secret: "whsec_test"}
+			delivery := &models.WebhookDelivery{EventID: "evt_1", Event: "link.created", Payload: `{"id":"evt_1"}`}
+
+			status, err := testDispatcher(allowAll).send(webhook, delivery)
+			if status != tt.wantStatus {
+				t.Errorf("send() status = %d, want %d", status, tt.wantStatus)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && strings.Contains(err.Error(), "internal details") {
+				t.Errorf("send() error %q includes the response body", err)
+			}
+			if !verified {
+				t.Error("receiver could not verify the signed request")
+			}
+		})
+	}
+}
+
+func TestSendRefusesInternalAddresses(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer internal.Close()
+
+	// The redirecting server stands in for a public endpoint; only its own
+	// address is let through, like netguard.Control would for a public host
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusFound)
+	}))
+	defer public.Close()
+	publicAddr := strings.TrimPrefix(public.URL, "http://")
+	onlyPublic := func(network, address string, c syscall.RawConn) error {
+		if address != publicAddr {
+			return netguard.ErrBlockedAddress
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		control func(network, address string, c syscall.RawConn) error
+	}{
+		{name: "loopback endpoint", url: internal.URL, control: netguard.Control},
+		{name: "redirect to loopback", url: public.URL, control: onlyPublic},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook := &models.Webhook{URL: tt.url, Secret: "whsec_test"}
+			delivery := &models.WebhookDelivery{EventID: "evt_1", Event: "link.created", Payload: "{}"}
+
+			status, err := testDispatcher(tt.control).send(webhook, delivery)
+			if !errors.Is(err, netguard.ErrBlockedAddress) {
+				t.Fatalf("send() error = %v, want %v", err, netguard.ErrBlockedAddress)
+			}
+			if status != 0 {
+				t.Errorf("send() status = %d, want 0", status)
+			}
+		})
+	}
+}
+
+func TestDeliverSendsOnlyClaimedDeliveries(t *testing.T) {
+	tests := []struct {
+		name string
+		// claimed is false when another instance holds the delivery, or
+		// has already sent or rescheduled it
+		claimed      bool
+		wantRequests int
+	}{
+		{name: "claimed", claimed: true, wantRequests: 1},
+		{name: "claimed elsewhere", claimed: false, wantRequests: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			repo, mock := newMockRepo(t)
+			rows := sqlmock.NewRows([]string{"id"})
+			if tt.claimed {
+				rows = sqlmock.NewRows([]string{"id", "webhook_id", "event_id", "event", "payload", "status", "attempts"}).
+					AddRow(1, 2, "evt_1", "link.created", "{}", "pending", 1)
+			}
+			mock.ExpectQuery(`UPDATE webhook_deliveries SET locked_until = \$1`).
+				WithArgs(sqlmock.AnyArg(), int64(1), models.DeliveryPending, sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnRows(rows)
+			if tt.claimed {
+				mock.ExpectQuery(`SELECT \* FROM "webhooks"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "url", "secret", "is_active"}).
+						AddRow(2, server.URL, "whsec_test", true))
+				mock.ExpectBegin()
+				// The attempt is counted from the claimed row and the lock released
+				mock.ExpectExec(`UPDATE "webhook_deliveries" SET "attempts"=\$1,.*"locked_until"=\$5`).
+					WithArgs(2, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil, nil, sqlmock.AnyArg(),
+						models.DeliverySucceeded, sqlmock.AnyArg(), int64(1)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			d := newDispatcher(repo, testConfig(), allowAll)
+			d.deliver(&models.WebhookDelivery{ID: 1, WebhookID: 2})
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("endpoint received %d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
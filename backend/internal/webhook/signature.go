@@ -0,0 +1,35 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 signature of the request
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader carries the unix timestamp included in the signature
+	TimestampHeader = "X-Webhook-Timestamp"
+	// EventHeader carries the event type
+	EventHeader = "X-Webhook-Event"
+	// DeliveryHeader carries the delivery ID, stable across retries
+	DeliveryHeader = "X-Webhook-Delivery"
+)
+
+// Sign computes the signature for a payload. The signed message is
+// "<timestamp>.<body>" so that receivers can reject replayed requests.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign
+func Verify(secret, signature string, timestamp int64, body []byte) bool {
+	expected := Sign(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+	}{
+		{name: "json body", secret: "whsec_test", timestamp: 1700000000, body: `{"event":"link.created"}`},
+		{name: "empty body", secret: "whsec_test", timestamp: 1700000000, body: ""},
+		{name: "empty secret", secret: "", timestamp: 1, body: "{}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mac := hmac.New(sha256.New, []byte(tt.secret))
+			mac.Write([]byte(strconv.FormatInt(tt.timestamp, 10) + "." + tt.body))
+			want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+			if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != want {
+				t.Errorf("Sign() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	const (
+		secret    = "whsec_test"
+		timestamp = int64(1700000000)
+		body      = `{"event":"link.created"}`
+	)
+	signature := Sign(secret, timestamp, []byte(body))
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp int64
+		body      string
+		want      bool
+	}{
+		{name: "valid", secret: secret, signature: signature, timestamp: timestamp, body: body, want: true},
+		{name: "wrong secret", secret: "whsec_other", signature: signature, timestamp: timestamp, body: body, want: false},
+		{name: "tampered body", secret: secret, signature: signature, timestamp: timestamp, body: `{"event":"link.deleted"}`, want: false},
+		{name: "replayed with new timestamp", secret: secret, signature: signature, timestamp: timestamp + 1, body: body, want: false},
+		{name: "missing prefix", secret: secret, signature: signature[len("sha256="):], timestamp: timestamp, body: body, want: false},
+		{name: "empty signature", secret: secret, signature: "", timestamp: timestamp, body: body, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.signature, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
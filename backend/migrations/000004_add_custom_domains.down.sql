@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_links_default_short_code;
+DROP INDEX IF EXISTS idx_links_domain_short_code;
+CREATE INDEX IF NOT EXISTS idx_links_short_code ON links(short_code);
+ALTER TABLE links ADD CONSTRAINT links_short_code_key UNIQUE (short_code);
+ALTER TABLE links DROP COLUMN IF EXISTS domain_id;
+
+DROP TABLE IF EXISTS domains;
@@ -0,0 +1,23 @@
+-- Custom branded domains
+CREATE TABLE IF NOT EXISTS domains (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hostname VARCHAR(255) UNIQUE NOT NULL,
+    verification_token VARCHAR(64) NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'verified', 'failed')),
+    verified_at TIMESTAMP,
+    last_checked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_domains_user_id ON domains(user_id);
+
+-- Short codes are unique per domain instead of globally
+ALTER TABLE links ADD COLUMN IF NOT EXISTS domain_id BIGINT REFERENCES domains(id) ON DELETE SET NULL;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE links DROP CONSTRAINT IF EXISTS links_short_code_key;
+DROP INDEX IF EXISTS idx_links_short_code;
+
+CREATE UNIQUE INDEX idx_links_domain_short_code ON links(domain_id, short_code) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX idx_links_default_short_code ON links(short_code) WHERE domain_id IS NULL AND deleted_at IS NULL;
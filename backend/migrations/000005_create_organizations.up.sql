@@ -0,0 +1,59 @@
+-- Organizations
+CREATE TABLE IF NOT EXISTS organizations (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    owner_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    is_personal BOOLEAN DEFAULT FALSE,
+    subscription_tier VARCHAR(50) DEFAULT 'free',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX idx_organizations_owner_id ON organizations(owner_id);
+CREATE INDEX idx_organizations_deleted_at ON organizations(deleted_at);
+
+-- Organization membership
+CREATE TABLE IF NOT EXISTS organization_members (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'editor', 'viewer')),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_org_members_org_user ON organization_members(organization_id, user_id);
+CREATE INDEX idx_organization_members_user_id ON organization_members(user_id);
+
+-- Organization invitations
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    invited_by BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_organization_invitations_organization_id ON organization_invitations(organization_id);
+
+-- Links belong to an organization
+ALTER TABLE links ADD COLUMN IF NOT EXISTS organization_id BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX idx_links_organization_id ON links(organization_id);
+
+-- Backfill a personal organization for every existing user and move their links into it
+INSERT INTO organizations (name, owner_id, is_personal, subscription_tier)
+SELECT COALESCE(NULLIF(full_name, ''), email), id, TRUE, COALESCE(subscription_tier, 'free')
+FROM users;
+
+INSERT INTO organization_members (organization_id, user_id, role)
+SELECT id, owner_id, 'owner' FROM organizations WHERE is_personal;
+
+UPDATE links l
+SET organization_id = o.id
+FROM organizations o
+WHERE o.owner_id = l.user_id AND o.is_personal AND l.organization_id = 0;
@@ -0,0 +1,17 @@
+-- Monthly click usage per organization, used to enforce plan click quotas
+CREATE TABLE IF NOT EXISTS click_usage_monthly (
+    organization_id BIGINT NOT NULL,
+    month DATE NOT NULL,
+    click_count BIGINT NOT NULL DEFAULT 0,
+    notified_percent INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (organization_id, month)
+);
+
+-- Seed the current month from clicks already recorded
+INSERT INTO click_usage_monthly (organization_id, month, click_count)
+SELECT l.organization_id, DATE_TRUNC('month', NOW())::date, COUNT(*)
+FROM clicks c
+INNER JOIN links l ON c.link_id = l.id
+WHERE c.clicked_at >= DATE_TRUNC('month', NOW())
+GROUP BY l.organization_id
+ON CONFLICT (organization_id, month) DO NOTHING;
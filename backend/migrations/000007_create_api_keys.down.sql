@@ -0,0 +1,4 @@
+-- Plaintext keys cannot be restored from their hashes; users must issue new keys
+ALTER TABLE users ADD COLUMN IF NOT EXISTS api_key VARCHAR(255) UNIQUE;
+
+DROP TABLE IF EXISTS api_keys;
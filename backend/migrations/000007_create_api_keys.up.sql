@@ -0,0 +1,26 @@
+-- Named API keys with hashed secrets and scopes
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(32) UNIQUE NOT NULL,
+    secret_hash VARCHAR(64) NOT NULL,
+    scopes TEXT[],
+    last_used_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
+
+-- Move existing plaintext keys over as hashed keys with full scopes
+INSERT INTO api_keys (user_id, name, prefix, secret_hash, scopes)
+SELECT id, 'Default key', LEFT(api_key, 15), encode(sha256(convert_to(api_key, 'UTF8')), 'hex'),
+       ARRAY['links:read', 'links:write', 'analytics:read']
+FROM users
+WHERE api_key IS NOT NULL AND api_key <> '' AND deleted_at IS NULL
+ON CONFLICT (prefix) DO NOTHING;
+
+ALTER TABLE users DROP COLUMN IF EXISTS api_key;
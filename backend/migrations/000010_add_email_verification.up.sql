@@ -0,0 +1,19 @@
+-- Email verification state; existing users are treated as verified
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+UPDATE users SET email_verified = TRUE, email_verified_at = created_at
+WHERE email_verified = FALSE;
+
+-- Single-use tokens sent by email for verification and password reset
+CREATE TABLE IF NOT EXISTS email_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(50) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_email_tokens_user_id ON email_tokens(user_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS billing_events;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS subscriptions;
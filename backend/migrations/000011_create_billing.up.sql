@@ -0,0 +1,44 @@
+-- Stripe customers and the subscription each user currently holds
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT UNIQUE NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stripe_customer_id VARCHAR(255) UNIQUE NOT NULL,
+    stripe_subscription_id VARCHAR(255),
+    price_id VARCHAR(255),
+    tier VARCHAR(50) DEFAULT 'free',
+    status VARCHAR(50),
+    current_period_end TIMESTAMP,
+    cancel_at_period_end BOOLEAN NOT NULL DEFAULT FALSE,
+    last_event_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Billing history, mirrored from Stripe invoices
+CREATE TABLE IF NOT EXISTS invoices (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stripe_invoice_id VARCHAR(255) UNIQUE NOT NULL,
+    number VARCHAR(100),
+    description TEXT,
+    status VARCHAR(50),
+    amount_due BIGINT NOT NULL DEFAULT 0,
+    amount_paid BIGINT NOT NULL DEFAULT 0,
+    currency VARCHAR(3),
+    hosted_invoice_url TEXT,
+    period_start TIMESTAMP,
+    period_end TIMESTAMP,
+    issued_at TIMESTAMP NOT NULL,
+    last_event_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_invoices_user_id ON invoices(user_id);
+
+-- Processed Stripe webhook events, so redeliveries are applied once
+CREATE TABLE IF NOT EXISTS billing_events (
+    id VARCHAR(255) PRIMARY KEY,
+    type VARCHAR(100) NOT NULL,
+    processed_at TIMESTAMP DEFAULT NOW()
+);
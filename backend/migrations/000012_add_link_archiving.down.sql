@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_organizations_link_grace_ends_at;
+ALTER TABLE organizations DROP COLUMN IF EXISTS link_grace_ends_at;
+
+DROP INDEX IF EXISTS idx_links_archived_at;
+ALTER TABLE links DROP COLUMN IF EXISTS archive_reason;
+ALTER TABLE links DROP COLUMN IF EXISTS archived_at;
@@ -0,0 +1,11 @@
+-- Archived links keep their short code but don't redirect or count towards
+-- the organization's link limit
+ALTER TABLE links ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS archive_reason VARCHAR(20) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_links_archived_at ON links(archived_at);
+
+-- Set while an organization is over its link limit after a downgrade
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS link_grace_ends_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_organizations_link_grace_ends_at ON organizations(link_grace_ends_at);
@@ -0,0 +1,2 @@
+ALTER TABLE link_analytics_daily DROP COLUMN IF EXISTS failed_unlocks;
+ALTER TABLE links DROP COLUMN IF EXISTS password_hash;
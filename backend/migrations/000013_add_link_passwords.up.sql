@@ -0,0 +1,5 @@
+-- Optional bcrypt hash gating a link behind an unlock form
+ALTER TABLE links ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Wrong passwords entered for protected links, counted apart from clicks
+ALTER TABLE link_analytics_daily ADD COLUMN IF NOT EXISTS failed_unlocks INTEGER NOT NULL DEFAULT 0;
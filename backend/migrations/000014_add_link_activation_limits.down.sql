@@ -0,0 +1,4 @@
+ALTER TABLE links DROP COLUMN IF EXISTS fallback_url;
+ALTER TABLE links DROP COLUMN IF EXISTS redirect_count;
+ALTER TABLE links DROP COLUMN IF EXISTS max_clicks;
+ALTER TABLE links DROP COLUMN IF EXISTS starts_at;
@@ -0,0 +1,7 @@
+-- Optional window and click limit outside which a link stops redirecting
+ALTER TABLE links ADD COLUMN IF NOT EXISTS starts_at TIMESTAMP;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS max_clicks INTEGER;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS redirect_count BIGINT NOT NULL DEFAULT 0;
+
+-- Where visitors go once a link has expired or used up its clicks
+ALTER TABLE links ADD COLUMN IF NOT EXISTS fallback_url TEXT;
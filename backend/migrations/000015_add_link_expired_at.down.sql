@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_links_pending_expiry;
+ALTER TABLE links DROP COLUMN IF EXISTS expired_at;
@@ -0,0 +1,8 @@
+-- When the expiry scanner announced the link's expiry with link.expired
+ALTER TABLE links ADD COLUMN IF NOT EXISTS expired_at TIMESTAMP;
+
+-- Links the expiry scanner has yet to announce
+CREATE INDEX IF NOT EXISTS idx_links_pending_expiry ON links(expires_at) WHERE expired_at IS NULL AND deleted_at IS NULL;
+
+-- Links that expired before the scanner existed aren't announced
+UPDATE links SET expired_at = expires_at WHERE expires_at < NOW() AND expired_at IS NULL;
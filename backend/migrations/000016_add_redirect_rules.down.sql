@@ -0,0 +1,2 @@
+ALTER TABLE clicks DROP COLUMN IF EXISTS redirect_rule;
+ALTER TABLE links DROP COLUMN IF EXISTS redirect_rules;
@@ -0,0 +1,5 @@
+-- Ordered routing rules sending matching visitors to alternate destinations
+ALTER TABLE links ADD COLUMN IF NOT EXISTS redirect_rules JSONB NOT NULL DEFAULT '[]';
+
+-- The rule that routed each click; NULL for the link's own destination
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS redirect_rule VARCHAR(50);
@@ -0,0 +1,2 @@
+ALTER TABLE clicks DROP COLUMN IF EXISTS variant;
+ALTER TABLE links DROP COLUMN IF EXISTS variants;
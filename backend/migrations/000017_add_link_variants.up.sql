@@ -0,0 +1,5 @@
+-- Weighted A/B destinations for visitors no redirect rule matches
+ALTER TABLE links ADD COLUMN IF NOT EXISTS variants JSONB NOT NULL DEFAULT '[]';
+
+-- The variant each click was sent to
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS variant VARCHAR(50);
@@ -0,0 +1,2 @@
+ALTER TABLE clicks DROP COLUMN IF EXISTS deep_link_path;
+ALTER TABLE links DROP COLUMN IF EXISTS deep_link;
@@ -0,0 +1,5 @@
+-- App URIs and store URLs for opening links in the mobile app
+ALTER TABLE links ADD COLUMN IF NOT EXISTS deep_link JSONB;
+
+-- How a visitor served the deep link page left it: app, store or web
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS deep_link_path VARCHAR(10);
@@ -0,0 +1,4 @@
+ALTER TABLE clicks DROP COLUMN IF EXISTS utm_campaign;
+ALTER TABLE links DROP COLUMN IF EXISTS query_conflict;
+ALTER TABLE links DROP COLUMN IF EXISTS query_passthrough;
+ALTER TABLE links DROP COLUMN IF EXISTS utm;
@@ -0,0 +1,8 @@
+-- UTM parameters set on the destination and passthrough of the short URL's
+-- query string
+ALTER TABLE links ADD COLUMN IF NOT EXISTS utm JSONB;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS query_passthrough BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS query_conflict VARCHAR(20) NOT NULL DEFAULT 'destination';
+
+-- The utm_campaign of the URL each click was sent to
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS utm_campaign VARCHAR(255);
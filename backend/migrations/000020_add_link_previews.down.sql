@@ -0,0 +1,2 @@
+ALTER TABLE clicks DROP COLUMN IF EXISTS is_bot;
+ALTER TABLE links DROP COLUMN IF EXISTS preview;
@@ -0,0 +1,5 @@
+-- Open Graph overrides served to link preview crawlers
+ALTER TABLE links ADD COLUMN IF NOT EXISTS preview JSONB;
+
+-- Clicks from link preview crawlers, kept out of the click counts
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT FALSE;
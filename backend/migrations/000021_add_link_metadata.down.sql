@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_links_metadata_pending;
+ALTER TABLE links DROP COLUMN IF EXISTS metadata_fetched_at;
+ALTER TABLE links DROP COLUMN IF EXISTS metadata_status;
+ALTER TABLE links DROP COLUMN IF EXISTS canonical_url;
+ALTER TABLE links DROP COLUMN IF EXISTS favicon_url;
+ALTER TABLE links DROP COLUMN IF EXISTS description;
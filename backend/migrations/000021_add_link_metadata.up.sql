@@ -0,0 +1,13 @@
+-- Metadata fetched from each link's destination page
+ALTER TABLE links ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS favicon_url TEXT;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS canonical_url TEXT;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS metadata_status VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS metadata_fetched_at TIMESTAMP;
+
+-- Links waiting for a fetch, for the background scan
+CREATE INDEX IF NOT EXISTS idx_links_metadata_pending ON links(metadata_status) WHERE metadata_status = 'pending' AND deleted_at IS NULL;
+
+-- Fetch titles for existing untitled links
+UPDATE links SET metadata_status = 'pending'
+WHERE title IS NULL AND deleted_at IS NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_links_flagged_at;
+ALTER TABLE links DROP COLUMN IF EXISTS flag_reason;
+ALTER TABLE links DROP COLUMN IF EXISTS flagged_at;
@@ -0,0 +1,5 @@
+-- Links whose destination was found on a blocklist; they don't redirect
+ALTER TABLE links ADD COLUMN IF NOT EXISTS flagged_at TIMESTAMP;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS flag_reason VARCHAR(500) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_links_flagged_at ON links(flagged_at);
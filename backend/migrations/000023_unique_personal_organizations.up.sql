@@ -0,0 +1,29 @@
+-- Concurrent first requests could provision a user several personal
+-- organizations. Keep the oldest, move the others' links into it and drop
+-- them before enforcing one per owner.
+WITH ranked AS (
+    SELECT id, FIRST_VALUE(id) OVER (PARTITION BY owner_id ORDER BY id) AS keep_id
+    FROM organizations
+    WHERE is_personal AND deleted_at IS NULL
+)
+UPDATE links SET organization_id = ranked.keep_id
+FROM ranked
+WHERE links.organization_id = ranked.id AND ranked.id <> ranked.keep_id;
+
+WITH ranked AS (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY owner_id ORDER BY id) AS n
+    FROM organizations
+    WHERE is_personal AND deleted_at IS NULL
+)
+DELETE FROM organization_members
+WHERE organization_id IN (SELECT id FROM ranked WHERE n > 1);
+
+WITH ranked AS (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY owner_id ORDER BY id) AS n
+    FROM organizations
+    WHERE is_personal AND deleted_at IS NULL
+)
+UPDATE organizations SET deleted_at = NOW()
+WHERE id IN (SELECT id FROM ranked WHERE n > 1);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organizations_personal_owner ON organizations(owner_id) WHERE is_personal AND deleted_at IS NULL;
@@ -0,0 +1,3 @@
+-- Set while an instance is sending a delivery, so other instances resuming
+-- pending deliveries don't send it too
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
@@ -0,0 +1 @@
+ALTER TABLE organizations DROP COLUMN IF EXISTS covered_by_owner;
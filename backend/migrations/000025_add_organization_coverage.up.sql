@@ -0,0 +1,3 @@
+-- Team organizations the owner's subscription pays for share the owner's
+-- tier, like their personal organization
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS covered_by_owner BOOLEAN NOT NULL DEFAULT FALSE;
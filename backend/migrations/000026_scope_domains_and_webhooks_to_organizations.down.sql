@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_webhooks_organization_id;
+DROP INDEX IF EXISTS idx_domains_organization_id;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE domains DROP COLUMN IF EXISTS organization_id;
@@ -0,0 +1,18 @@
+-- Domains and webhooks belong to an organization, like links. Existing ones
+-- move to their creator's personal organization; users without one yet are
+-- adopted when it is provisioned.
+ALTER TABLE domains ADD COLUMN IF NOT EXISTS organization_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS organization_id BIGINT NOT NULL DEFAULT 0;
+
+UPDATE domains SET organization_id = organizations.id
+FROM organizations
+WHERE organizations.owner_id = domains.user_id AND organizations.is_personal
+    AND organizations.deleted_at IS NULL AND domains.organization_id = 0;
+
+UPDATE webhooks SET organization_id = organizations.id
+FROM organizations
+WHERE organizations.owner_id = webhooks.user_id AND organizations.is_personal
+    AND organizations.deleted_at IS NULL AND webhooks.organization_id = 0;
+
+CREATE INDEX IF NOT EXISTS idx_domains_organization_id ON domains(organization_id);
+CREATE INDEX IF NOT EXISTS idx_webhooks_organization_id ON webhooks(organization_id);
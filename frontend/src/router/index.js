@@ -21,6 +21,23 @@ const router = createRouter({
       component: () => import('@/views/SignupPage.vue'),
       meta: { guest: true }
     },
+    {
+      path: '/forgot-password',
+      name: 'forgot-password',
+      component: () => import('@/views/ForgotPasswordPage.vue'),
+      meta: { guest: true }
+    },
+    {
+      path: '/reset-password',
+      name: 'reset-password',
+      component: () => import('@/views/ResetPasswordPage.vue'),
+      meta: { guest: true }
+    },
+    {
+      path: '/verify-email',
+      name: 'verify-email',
+      component: () => import('@/views/VerifyEmailPage.vue')
+    },
     {
       path: '/dashboard',
       name: 'dashboard',
@@ -23,14 +23,56 @@ apiClient.interceptors.request.use(
   }
 )
 
+const clearSession = () => {
+  localStorage.removeItem('auth_token')
+  localStorage.removeItem('refresh_token')
+  localStorage.removeItem('user')
+}
+
+// refreshSession trades the refresh token for a new token pair. Concurrent
+// callers share one request, since each refresh token works only once.
+let refreshPromise = null
+const refreshSession = () => {
+  if (!refreshPromise) {
+    const refreshToken = localStorage.getItem('refresh_token')
+    refreshPromise = (refreshToken
+      ? axios.post(`${API_BASE_URL}/auth/refresh`, { refresh_token: refreshToken })
+      : Promise.reject(new Error('No refresh token'))
+    )
+      .then((response) => {
+        localStorage.setItem('auth_token', response.data.token)
+        localStorage.setItem('refresh_token', response.data.refresh_token)
+        localStorage.setItem('user', JSON.stringify(response.data.user))
+        return response.data.token
+      })
+      .finally(() => {
+        refreshPromise = null
+      })
+  }
+  return refreshPromise
+}
+
 // Response interceptor to handle errors
 apiClient.interceptors.response.use(
   (response) => response,
-  (error) => {
-    if (error.response?.status === 401) {
+  async (error) => {
+    // A 401 from the login endpoints is a failed sign-in, not an expired session
+    const isLoginRequest = error.config?.url?.startsWith('/auth/login')
+    if (error.response?.status === 401 && !isLoginRequest) {
+      // Retry once with a fresh access token before giving up on the session
+      if (!error.config._retried) {
+        try {
+          const token = await refreshSession()
+          error.config._retried = true
+          error.config.headers.Authorization = `Bearer ${token}`
+          return apiClient(error.config)
+        } catch (refreshError) {
+          // Fall through to sign out
+        }
+      }
+
       // Unauthorized - clear token and redirect to login
-      localStorage.removeItem('auth_token')
-      localStorage.removeItem('user')
+      clearSession()
       window.location.href = '/login'
     }
     return Promise.reject(error)
@@ -46,6 +88,12 @@ export default {
     login(email, password) {
       return apiClient.post('/auth/login', { email, password })
     },
+    completeTwoFactorLogin(challengeToken, code) {
+      return apiClient.post('/auth/login/2fa', { challenge_token: challengeToken, code })
+    },
+    logout() {
+      return apiClient.post('/auth/logout')
+    },
     getProfile() {
       return apiClient.get('/profile')
     },
@@ -58,8 +106,78 @@ export default {
         new_password: newPassword
       })
     },
-    generateAPIKey() {
-      return apiClient.post('/api-key')
+    verifyEmail(token) {
+      return apiClient.post('/auth/verify-email', { token })
+    },
+    resendVerification() {
+      return apiClient.post('/auth/verify-email/resend')
+    },
+    forgotPassword(email) {
+      return apiClient.post('/auth/forgot-password', { email })
+    },
+    resetPassword(token, newPassword) {
+      return apiClient.post('/auth/reset-password', { token, new_password: newPassword })
+    }
+  },
+
+  // Billing endpoints
+  billing: {
+    get() {
+      return apiClient.get('/billing')
+    },
+    listInvoices() {
+      return apiClient.get('/billing/invoices')
+    },
+    checkout(tier) {
+      return apiClient.post('/billing/checkout', { tier })
+    },
+    portal() {
+      return apiClient.post('/billing/portal')
+    }
+  },
+
+  // Session endpoints
+  sessions: {
+    list() {
+      return apiClient.get('/sessions')
+    },
+    revoke(id) {
+      return apiClient.delete(`/sessions/${id}`)
+    },
+    revokeOthers() {
+      return apiClient.delete('/sessions')
+    }
+  },
+
+  // Two-factor authentication endpoints
+  twoFactor: {
+    status() {
+      return apiClient.get('/auth/2fa')
+    },
+    setup() {
+      return apiClient.post('/auth/2fa/setup')
+    },
+    confirm(code) {
+      return apiClient.post('/auth/2fa/confirm', { code })
+    },
+    disable(password, code) {
+      return apiClient.post('/auth/2fa/disable', { password, code })
+    },
+    regenerateRecoveryCodes(password, code) {
+      return apiClient.post('/auth/2fa/recovery-codes', { password, code })
+    }
+  },
+
+  // API key endpoints
+  apiKeys: {
+    list() {
+      return apiClient.get('/api-keys')
+    },
+    create(keyData) {
+      return apiClient.post('/api-keys', keyData)
+    },
+    revoke(id) {
+      return apiClient.delete(`/api-keys/${id}`)
     }
   },
 
@@ -80,6 +198,12 @@ export default {
     delete(id) {
       return apiClient.delete(`/links/${id}`)
     },
+    archive(id) {
+      return apiClient.post(`/links/${id}/archive`)
+    },
+    unarchive(id) {
+      return apiClient.post(`/links/${id}/unarchive`)
+    },
     getStats(id) {
       return apiClient.get(`/links/${id}/stats`)
     }
@@ -97,5 +221,12 @@ export default {
     list() {
       return apiClient.get('/tags')
     }
+  },
+
+  // API usage endpoints
+  apiUsage: {
+    get() {
+      return apiClient.get('/api-usage')
+    }
   }
 }
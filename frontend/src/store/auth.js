@@ -18,15 +18,17 @@ export const useAuthStore = defineStore('auth', {
     async login(email, password) {
       try {
         const response = await api.auth.login(email, password)
-        const { token, user } = response.data
 
-        this.token = token
-        this.user = user
-        this.isAuthenticated = true
-
-        localStorage.setItem('auth_token', token)
-        localStorage.setItem('user', JSON.stringify(user))
+        // Accounts with 2FA get a challenge to complete with a code
+        if (response.data.two_factor_required) {
+          return {
+            success: false,
+            twoFactorRequired: true,
+            challengeToken: response.data.challenge_token
+          }
+        }
 
+        this.setSession(response.data)
         return { success: true }
       } catch (error) {
         return {
@@ -36,17 +38,34 @@ export const useAuthStore = defineStore('auth', {
       }
     },
 
-    async signup(email, password) {
+    async completeTwoFactorLogin(challengeToken, code) {
       try {
-        const response = await api.auth.signup(email, password)
-        const { token, user } = response.data
+        const response = await api.auth.completeTwoFactorLogin(challengeToken, code)
+        this.setSession(response.data)
+        return { success: true }
+      } catch (error) {
+        return {
+          success: false,
+          code: error.response?.data?.code,
+          error: error.response?.data?.error || 'Verification failed'
+        }
+      }
+    },
+
+    setSession({ token, refresh_token: refreshToken, user }) {
+      this.token = token
+      this.user = user
+      this.isAuthenticated = true
 
-        this.token = token
-        this.user = user
-        this.isAuthenticated = true
+      localStorage.setItem('auth_token', token)
+      localStorage.setItem('refresh_token', refreshToken)
+      localStorage.setItem('user', JSON.stringify(user))
+    },
 
-        localStorage.setItem('auth_token', token)
-        localStorage.setItem('user', JSON.stringify(user))
+    async signup(email, password) {
+      try {
+        const response = await api.auth.signup(email, password)
+        this.setSession(response.data)
 
         return { success: true }
       } catch (error) {
@@ -58,11 +77,18 @@ export const useAuthStore = defineStore('auth', {
     },
 
     async logout() {
+      try {
+        await api.auth.logout()
+      } catch (error) {
+        // The session may already be gone; sign out locally regardless
+      }
+
       this.token = null
       this.user = null
       this.isAuthenticated = false
 
       localStorage.removeItem('auth_token')
+      localStorage.removeItem('refresh_token')
       localStorage.removeItem('user')
     },
 